@@ -26,8 +26,10 @@ import (
 	"github.com/deepspace2/plugnpin/pkg/clients/docker"
 	"github.com/deepspace2/plugnpin/pkg/clients/npm"
 	"github.com/deepspace2/plugnpin/pkg/clients/pihole"
+	plugnpinConfig "github.com/deepspace2/plugnpin/pkg/config"
 	"github.com/deepspace2/plugnpin/pkg/logging"
 	"github.com/deepspace2/plugnpin/pkg/processor"
+	"github.com/deepspace2/plugnpin/pkg/providers"
 )
 
 type config struct {
@@ -232,7 +234,7 @@ func setClients(t *testing.T, containers []Container) (*docker.Client, *pihole.C
 		}
 	}
 
-	piholeClient := pihole.NewClient(piholeURL, "password")
+	piholeClient := pihole.NewClient(piholeURL, "password", "")
 	logger.Info("Waiting for Pi-Hole to be ready...")
 	piholeLoginTimeout := time.After(60 * time.Second)
 	piholeLoginTicker := time.NewTicker(3 * time.Second)
@@ -293,7 +295,7 @@ func cleanup(dockerCli *dockerApi.Client, containers []Container, npmClient *npm
 	if npmClient != nil {
 		npmProxyHosts, err := npmClient.GetProxyHosts()
 		if err == nil {
-			_, _ = npmClient.DeleteProxyHosts(slices.Collect(maps.Keys(npmProxyHosts)))
+			_, _, _ = npmClient.DeleteProxyHosts(slices.Collect(maps.Keys(npmProxyHosts)))
 		}
 	}
 	var wg sync.WaitGroup
@@ -367,10 +369,9 @@ func TestE2E(t *testing.T) {
 
 	proc := processor.New(
 		map[string]*docker.Client{dockerClient.Host: dockerClient},
-		adguardHomeClient,
-		piholeClient,
-		npmClient,
-		false,
+		[]providers.DNSProvider{providers.NewAdguardHome(adguardHomeClient, false), providers.NewPiHole(piholeClient)},
+		providers.NewNpm(map[string]*npm.Client{plugnpinConfig.DEFAULT_NPM_INSTANCE: npmClient}, providers.NpmOptions{}),
+		processor.Options{},
 	)
 
 	proc.RunOnce(ctx)
@@ -402,19 +403,19 @@ func TestE2E(t *testing.T) {
 				require.Equal(t, pihole.IP(npmClient.GetIP()), piholeDnsRecordIP, "The pihole DNS record should point to the NPM container's IP")
 				require.Contains(t, npmProxyHosts, url, "The NPM proxy hosts should contain the url %s", url)
 
-				adguardHomeDnsRewriteIP, exists := adguardDnsRewrites[adguardhome.DomainName(url)]
+				adguardHomeDnsRewriteIPs, exists := adguardDnsRewrites[adguardhome.DomainName(url)]
 				require.True(t, exists, "An AdGuard Home DNS rewrite should exist for the url %s", url)
-				require.Equal(t, adguardhome.IP(npmClient.GetIP()), adguardHomeDnsRewriteIP, "The AdGuard Home DNS rewrite should point to the NPM container's IP")
+				require.Contains(t, adguardHomeDnsRewriteIPs, adguardhome.IP(npmClient.GetIP()), "The AdGuard Home DNS rewrite should point to the NPM container's IP")
 			}
 
 			// Deleting to assert delete functionality
 			_, err = piholeClient.DeleteDnsRecords(urls)
 			require.NoError(t, err, "Failed to delete Pi-Hole DNS records")
 
-			_, err = npmClient.DeleteProxyHosts(urls)
+			_, _, err = npmClient.DeleteProxyHosts(urls)
 			require.NoError(t, err, "Failed to delete NPM proxy hosts")
 
-			_, err = adguardHomeClient.DeleteDnsRewrites(urls, npmClient.GetIP())
+			_, err = adguardHomeClient.DeleteDnsRewrites(urls)
 			require.NoError(t, err, "Failed to delete AdGuard Home DNS rewrites")
 
 			piholeDnsRecords, err := piholeClient.GetDnsRecords()
@@ -434,7 +435,7 @@ func TestE2E(t *testing.T) {
 			for _, url := range urls {
 				require.NotContains(t, piholeDnsRecords, pihole.DomainName(url), "The pihole DNS record should be deleted for %s", url)
 				require.NotContains(t, npmProxyHosts, url, "The NPM proxy host should be deleted for %s", url)
-				require.NotContains(t, adguardDnsRewrites, url, "The AdGuard Home DNS rewrite should be deleted for %s", url)
+				require.NotContains(t, adguardDnsRewrites, adguardhome.DomainName(url), "The AdGuard Home DNS rewrite should be deleted for %s", url)
 			}
 		}
 	}
@@ -471,10 +472,9 @@ func TestE2E_CreateOnHealthy(t *testing.T) {
 
 	proc := processor.New(
 		map[string]*docker.Client{dockerClient.Host: dockerClient},
-		adguardHomeClient,
-		piholeClient,
-		npmClient,
-		false,
+		[]providers.DNSProvider{providers.NewAdguardHome(adguardHomeClient, false), providers.NewPiHole(piholeClient)},
+		providers.NewNpm(map[string]*npm.Client{plugnpinConfig.DEFAULT_NPM_INSTANCE: npmClient}, providers.NpmOptions{}),
+		processor.Options{},
 	)
 
 	var wg sync.WaitGroup
@@ -587,10 +587,9 @@ func TestE2E_CreateOnHealthy_NoHealthcheck(t *testing.T) {
 
 	proc := processor.New(
 		map[string]*docker.Client{dockerClient.Host: dockerClient},
-		adguardHomeClient,
-		nil,
-		npmClient,
-		false,
+		[]providers.DNSProvider{providers.NewAdguardHome(adguardHomeClient, false)},
+		providers.NewNpm(map[string]*npm.Client{plugnpinConfig.DEFAULT_NPM_INSTANCE: npmClient}, providers.NpmOptions{}),
+		processor.Options{},
 	)
 
 	var wg sync.WaitGroup
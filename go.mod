@@ -8,11 +8,13 @@ require (
 	github.com/docker/go-connections v0.5.0
 	github.com/docker/go-sdk/client v0.1.0-alpha009
 	github.com/joho/godotenv v1.5.1
+	github.com/miekg/dns v1.1.68
 	github.com/moby/term v0.5.2
 	github.com/prometheus/client_golang v1.23.2
 	github.com/spf13/pflag v1.0.7
 	github.com/stretchr/testify v1.11.1
 	golang.org/x/sync v0.20.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -48,9 +50,11 @@ require (
 	go.opentelemetry.io/otel/metric v1.41.0 // indirect
 	go.opentelemetry.io/otel/trace v1.41.0 // indirect
 	go.yaml.in/yaml/v2 v2.4.2 // indirect
+	golang.org/x/mod v0.24.0 // indirect
+	golang.org/x/net v0.43.0 // indirect
 	golang.org/x/sys v0.35.0 // indirect
+	golang.org/x/tools v0.33.0 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20250818200422-3122310a409c // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250811230008-5f3141c8851a // indirect
 	google.golang.org/protobuf v1.36.8 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -14,6 +14,8 @@ import (
 	"github.com/deepspace2/plugnpin/pkg/logging"
 	"github.com/deepspace2/plugnpin/pkg/metrics"
 	"github.com/deepspace2/plugnpin/pkg/processor"
+	"github.com/deepspace2/plugnpin/pkg/providers"
+	"github.com/deepspace2/plugnpin/pkg/snippets"
 )
 
 var log = logging.GetLogger("main")
@@ -40,12 +42,27 @@ func main() {
 		log.Info(fmt.Sprintf("Will run every %v", config.RunInterval))
 	}
 
-	dockerClients, adguardHomeClient, piholeClient, npmClient, err := clients.GetClients(cliFlags, config)
+	var snippetLibrary *snippets.Library
+	if config.NpmSnippetsDir != "" {
+		snippetLibrary, err = snippets.Load(config.NpmSnippetsDir)
+		if err != nil {
+			log.Error("Failed to load snippets", "error", err)
+			os.Exit(1)
+		}
+		log.Info(fmt.Sprintf("Loaded %v snippets", len(snippetLibrary.Names())), "dir", config.NpmSnippetsDir)
+	}
+
+	dockerClients, dnsProviders, proxyProvider, err := clients.GetClients(cliFlags, config, providers.Options{
+		Snippets: snippetLibrary,
+	})
 	if err != nil {
 		os.Exit(1)
 	}
 
-	proc := processor.New(dockerClients, adguardHomeClient, piholeClient, npmClient, cliFlags.DryRun)
+	proc := processor.New(dockerClients, dnsProviders, proxyProvider, processor.Options{
+		DnsTargetIP: config.DnsTargetIP,
+		DryRun:      cliFlags.DryRun,
+	})
 	defer proc.Shutdown()
 
 	if config.RunInterval == 0 {
@@ -77,6 +94,14 @@ func main() {
 		proc.RunScheduled(ctx, config.RunInterval)
 	})
 
+	if snippetLibrary != nil {
+		wg.Go(func() {
+			snippetLibrary.Watch(ctx, snippets.DEFAULT_RELOAD_INTERVAL, func(changed []string) {
+				proc.HandleSnippetsChange(ctx, changed)
+			})
+		})
+	}
+
 	<-ctx.Done()
 	log.Info("Shutdown signal received, exiting gracefully.")
 	wg.Wait()
@@ -5,12 +5,18 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"net/http"
+	"slices"
+	"strings"
 
 	"github.com/deepspace2/plugnpin/pkg/clients/common"
+	"github.com/deepspace2/plugnpin/pkg/logging"
 	"github.com/deepspace2/plugnpin/pkg/metrics"
 )
 
+var log = logging.GetLogger("adguardhome")
+
 type Client struct {
 	http.Client
 	baseURL string
@@ -33,80 +39,191 @@ func NewClient(baseURL, username, password string) *Client {
 	}
 }
 
-func (ad *Client) GetDnsRewrites() (DnsRewrites, error) {
-	dnsRewritesResponseString, _, err := common.Get(&ad.Client, ad.baseURL+"/rewrite/list", headers)
+// getDnsRewrites lists the existing rewrites grouped by domain, as a domain can
+// have several rewrites with different answers.
+func (ad *Client) getDnsRewrites() (map[DomainName][]DnsRewrite, error) {
+	dnsRewritesResponseString, statusCode, err := common.Get(&ad.Client, ad.baseURL+"/rewrite/list", headers)
 	if err != nil {
 		return nil, err
 	}
+
+	if statusCode == 401 {
+		return nil, errors.New("Unauthorized")
+	}
+
 	var resp []DnsRewrite
 	err = json.Unmarshal([]byte(dnsRewritesResponseString), &resp)
 	if err != nil {
 		return nil, err
 	}
 
-	dnsRewrites := DnsRewrites{}
+	dnsRewrites := map[DomainName][]DnsRewrite{}
 	for _, rawDnsRewrite := range resp {
-		dnsRewrites[DomainName(rawDnsRewrite.Domain)] = IP(rawDnsRewrite.Answer)
+		domain := DomainName(rawDnsRewrite.Domain)
+		dnsRewrites[domain] = append(dnsRewrites[domain], rawDnsRewrite)
 	}
 	return dnsRewrites, nil
 }
 
-func (ad *Client) AddDnsRewrites(domains []string, ip string) (numOfAddedRewrites int, err error) {
-	existingRecords, err := ad.GetDnsRewrites()
+func (ad *Client) GetDnsRewrites() (DnsRewrites, error) {
+	existingRewrites, err := ad.getDnsRewrites()
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+
+	dnsRewrites := DnsRewrites{}
+	for domain, domainRewrites := range existingRewrites {
+		for _, dnsRewrite := range domainRewrites {
+			dnsRewrites[domain] = append(dnsRewrites[domain], IP(dnsRewrite.Answer))
+		}
+	}
+	return dnsRewrites, nil
+}
+
+func (ad *Client) post(path string, payload any) error {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	payloadString := string(payloadBytes)
+	resp, statusCode, err := common.Post(&ad.Client, ad.baseURL+path, headers, &payloadString)
+	if err != nil {
+		return err
+	}
+
+	if statusCode == 401 {
+		return errors.New("Unauthorized")
 	}
 
+	if statusCode >= 400 {
+		return fmt.Errorf("AdGuard Home returned status %d for %v: %v", statusCode, path, strings.TrimSpace(resp))
+	}
+	return nil
+}
+
+// AddDnsRewrites creates a rewrite to answer for every domain that doesn't have
+// one yet. A domain without an enabled rewrite to answer gets its disabled
+// rewrite to answer re-enabled, or else its first rewrite updated. Other
+// rewrites of the domain are left alone.
+func (ad *Client) AddDnsRewrites(domains []string, answer string) (numOfAddedRewrites, numOfUpdatedRewrites int, err error) {
+	answers := map[DomainName]string{}
 	for _, domain := range domains {
-		d := DomainName(domain)
-		_, exists := existingRecords[d]
+		answers[DomainName(domain)] = answer
+	}
+	return ad.AddDnsRewritesBatch(answers)
+}
+
+// AddDnsRewritesBatch adds or updates the rewrites of all of the given
+// domains, listing the existing rewrites only once.
+func (ad *Client) AddDnsRewritesBatch(answers map[DomainName]string) (numOfAddedRewrites, numOfUpdatedRewrites int, err error) {
+	existingRewrites, err := ad.getDnsRewrites()
+	if err != nil {
+		return 0, 0, err
+	}
 
-		if exists {
+	for _, domain := range slices.Sorted(maps.Keys(answers)) {
+		answer := answers[domain]
+		domainRewrites := existingRewrites[domain]
+
+		if len(domainRewrites) == 0 {
+			err := ad.post("/rewrite/add", DnsRewrite{Answer: answer, Domain: string(domain), Enabled: true})
+			if err != nil {
+				return numOfAddedRewrites, numOfUpdatedRewrites, err
+			}
+			numOfAddedRewrites += 1
 			continue
 		}
 
-		payload, err := json.Marshal(DnsRewrite{Answer: ip, Domain: domain, Enabled: true})
-		if err != nil {
-			return 0, err
+		if slices.Contains(domainRewrites, DnsRewrite{Answer: answer, Domain: string(domain), Enabled: true}) {
+			continue
 		}
-		payloadString := string(payload)
-		_, statusCode, err := common.Post(&ad.Client, ad.baseURL+"/rewrite/add", headers, &payloadString)
+
+		// Prefer re-enabling a disabled rewrite with the same answer
+		existingRewrite := domainRewrites[0]
+		if i := slices.IndexFunc(domainRewrites, func(rewrite DnsRewrite) bool { return rewrite.Answer == answer }); i >= 0 {
+			existingRewrite = domainRewrites[i]
+		}
+		updatedRewrite := existingRewrite
+		updatedRewrite.Answer = answer
+		updatedRewrite.Enabled = true
+		err := ad.post("/rewrite/update", updateDnsRewritePayload{Target: existingRewrite, Update: updatedRewrite})
 		if err != nil {
-			return 0, err
+			return numOfAddedRewrites, numOfUpdatedRewrites, err
 		}
+		numOfUpdatedRewrites += 1
+	}
 
-		if statusCode == 401 {
-			return 0, errors.New("Unauthorized")
-		}
+	return numOfAddedRewrites, numOfUpdatedRewrites, nil
+}
 
-		if statusCode < 400 {
-			numOfAddedRewrites += 1
+// DisableDnsRewrites sets enabled=false on every existing rewrite of domains,
+// keeping them (and any manual edits) around for when they are enabled again.
+func (ad *Client) DisableDnsRewrites(domains []string) (numOfDisabledRewrites int, err error) {
+	existingRewrites, err := ad.getDnsRewrites()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, domain := range domains {
+		for _, existingRewrite := range existingRewrites[DomainName(domain)] {
+			if !existingRewrite.Enabled {
+				continue
+			}
+
+			updatedRewrite := existingRewrite
+			updatedRewrite.Enabled = false
+			err := ad.post("/rewrite/update", updateDnsRewritePayload{Target: existingRewrite, Update: updatedRewrite})
+			if err != nil {
+				return numOfDisabledRewrites, err
+			}
+			numOfDisabledRewrites += 1
 		}
 	}
 
-	return numOfAddedRewrites, nil
+	return numOfDisabledRewrites, nil
 }
 
-func (ad *Client) DeleteDnsRewrites(domains []string, ip string) (numOfDeletedRewrites int, err error) {
+// DeleteDnsRewrites deletes every rewrite of domains using their actual
+// answer, and only counts rewrites that are really gone afterwards. A failed
+// delete doesn't stop the remaining ones, the errors are joined and returned
+// together with the number of deleted rewrites.
+func (ad *Client) DeleteDnsRewrites(domains []string) (numOfDeletedRewrites int, err error) {
+	existingRewrites, err := ad.getDnsRewrites()
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []error
+	requestedRewrites := []DnsRewrite{}
 	for _, domain := range domains {
-		payload, err := json.Marshal(DnsRewrite{Answer: ip, Domain: domain, Enabled: true})
-		if err != nil {
-			return 0, err
-		}
-		payloadString := string(payload)
-		_, statusCode, err := common.Post(&ad.Client, ad.baseURL+"/rewrite/delete", headers, &payloadString)
-		if err != nil {
-			return 0, err
+		for _, existingRewrite := range existingRewrites[DomainName(domain)] {
+			if err := ad.post("/rewrite/delete", existingRewrite); err != nil {
+				errs = append(errs, fmt.Errorf("failed to delete DNS rewrite %v -> %v: %w", existingRewrite.Domain, existingRewrite.Answer, err))
+				continue
+			}
+			requestedRewrites = append(requestedRewrites, existingRewrite)
 		}
+	}
 
-		if statusCode == 401 {
-			return 0, errors.New("Unauthorized")
-		}
+	if len(requestedRewrites) == 0 {
+		return 0, errors.Join(errs...)
+	}
 
-		if statusCode < 400 {
-			numOfDeletedRewrites += 1
+	remainingRewrites, err := ad.getDnsRewrites()
+	if err != nil {
+		return 0, errors.Join(append(errs, err)...)
+	}
+
+	for _, requestedRewrite := range requestedRewrites {
+		stillExists := slices.ContainsFunc(remainingRewrites[DomainName(requestedRewrite.Domain)], func(remainingRewrite DnsRewrite) bool {
+			return remainingRewrite.Answer == requestedRewrite.Answer
+		})
+		if stillExists {
+			log.Warn("DNS rewrite still exists after deleting it", "domain", requestedRewrite.Domain, "answer", requestedRewrite.Answer)
+			continue
 		}
+		numOfDeletedRewrites += 1
 	}
 
-	return numOfDeletedRewrites, nil
+	return numOfDeletedRewrites, errors.Join(errs...)
 }
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -56,14 +57,204 @@ func TestAddDnsRewrite(t *testing.T) {
 		client, server := setupTestServer("testuser", "testpass", handler)
 		defer server.Close()
 
-		count, err := client.AddDnsRewrites([]string{"test.com"}, "1.2.3.4")
+		count, updatedCount, err := client.AddDnsRewrites([]string{"test.com"}, "1.2.3.4")
 		assert.Equal(t, 1, count)
+		assert.Equal(t, 0, updatedCount)
 		assert.NoError(t, err)
 	})
+
+	t.Run("update when answer changed", func(t *testing.T) {
+		var updateCalled bool
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/control/rewrite/list" && r.Method == http.MethodGet {
+				w.WriteHeader(http.StatusOK)
+				_, _ = fmt.Fprint(w, `[{"domain": "test.com", "answer": "1.1.1.1", "enabled": true}]`)
+				return
+			}
+
+			if r.URL.Path == "/control/rewrite/update" && r.Method == http.MethodPost {
+				updateCalled = true
+				var payload updateDnsRewritePayload
+				err := json.NewDecoder(r.Body).Decode(&payload)
+				assert.NoError(t, err)
+
+				assert.Equal(t, DnsRewrite{Domain: "test.com", Answer: "1.1.1.1", Enabled: true}, payload.Target)
+				assert.Equal(t, DnsRewrite{Domain: "test.com", Answer: "1.2.3.4", Enabled: true}, payload.Update)
+
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+		})
+
+		client, server := setupTestServer("testuser", "testpass", handler)
+		defer server.Close()
+
+		count, updatedCount, err := client.AddDnsRewrites([]string{"test.com"}, "1.2.3.4")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+		assert.Equal(t, 1, updatedCount)
+		assert.True(t, updateCalled, "Update API endpoint was not called")
+	})
+
+	t.Run("re-enable disabled rewrite", func(t *testing.T) {
+		var updateCalled bool
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/control/rewrite/list" && r.Method == http.MethodGet {
+				w.WriteHeader(http.StatusOK)
+				_, _ = fmt.Fprint(w, `[{"domain": "test.com", "answer": "1.2.3.4", "enabled": false}]`)
+				return
+			}
+
+			if r.URL.Path == "/control/rewrite/update" && r.Method == http.MethodPost {
+				updateCalled = true
+				var payload updateDnsRewritePayload
+				err := json.NewDecoder(r.Body).Decode(&payload)
+				assert.NoError(t, err)
+
+				assert.Equal(t, DnsRewrite{Domain: "test.com", Answer: "1.2.3.4", Enabled: false}, payload.Target)
+				assert.Equal(t, DnsRewrite{Domain: "test.com", Answer: "1.2.3.4", Enabled: true}, payload.Update)
+
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+		})
+
+		client, server := setupTestServer("testuser", "testpass", handler)
+		defer server.Close()
+
+		count, updatedCount, err := client.AddDnsRewrites([]string{"test.com"}, "1.2.3.4")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+		assert.Equal(t, 1, updatedCount)
+		assert.True(t, updateCalled, "Update API endpoint was not called")
+	})
+
+	t.Run("leave other rewrites of the domain alone", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/control/rewrite/list" && r.Method == http.MethodGet {
+				w.WriteHeader(http.StatusOK)
+				_, _ = fmt.Fprint(w, `[{"domain": "test.com", "answer": "::1", "enabled": true}, {"domain": "test.com", "answer": "1.2.3.4", "enabled": true}]`)
+				return
+			}
+
+			t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+		})
+
+		client, server := setupTestServer("testuser", "testpass", handler)
+		defer server.Close()
+
+		count, updatedCount, err := client.AddDnsRewrites([]string{"test.com"}, "1.2.3.4")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+		assert.Equal(t, 0, updatedCount)
+	})
+}
+
+func TestAddDnsRewritesBatch(t *testing.T) {
+	t.Run("lists existing rewrites once", func(t *testing.T) {
+		listCalls, addCalls := 0, 0
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/control/rewrite/list" && r.Method == http.MethodGet {
+				listCalls++
+				w.WriteHeader(http.StatusOK)
+				_, _ = fmt.Fprint(w, `[{"domain": "one.com", "answer": "1.1.1.1", "enabled": true}]`)
+				return
+			}
+
+			if r.URL.Path == "/control/rewrite/add" && r.Method == http.MethodPost {
+				addCalls++
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+		})
+
+		client, server := setupTestServer("testuser", "testpass", handler)
+		defer server.Close()
+
+		count, updatedCount, err := client.AddDnsRewritesBatch(map[DomainName]string{"one.com": "1.1.1.1", "two.com": "1.2.3.4", "three.com": "5.6.7.8"})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+		assert.Equal(t, 0, updatedCount)
+		assert.Equal(t, 1, listCalls)
+		assert.Equal(t, 2, addCalls)
+	})
+}
+
+func TestDisableDnsRewrite(t *testing.T) {
+	t.Run("disable enabled rewrite", func(t *testing.T) {
+		var updateCalled bool
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/control/rewrite/list" && r.Method == http.MethodGet {
+				w.WriteHeader(http.StatusOK)
+				_, _ = fmt.Fprint(w, `[{"domain": "test.com", "answer": "1.2.3.4", "enabled": true}, {"domain": "off.com", "answer": "1.2.3.4", "enabled": false}]`)
+				return
+			}
+
+			if r.URL.Path == "/control/rewrite/update" && r.Method == http.MethodPost {
+				updateCalled = true
+				var payload updateDnsRewritePayload
+				err := json.NewDecoder(r.Body).Decode(&payload)
+				assert.NoError(t, err)
+
+				assert.Equal(t, DnsRewrite{Domain: "test.com", Answer: "1.2.3.4", Enabled: true}, payload.Target)
+				assert.Equal(t, DnsRewrite{Domain: "test.com", Answer: "1.2.3.4", Enabled: false}, payload.Update)
+
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+		})
+
+		client, server := setupTestServer("testuser", "testpass", handler)
+		defer server.Close()
+
+		count, err := client.DisableDnsRewrites([]string{"test.com", "off.com", "missing.com"})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+		assert.True(t, updateCalled, "Update API endpoint was not called")
+	})
+
+	t.Run("disable every rewrite of a domain", func(t *testing.T) {
+		disabledAnswers := []string{}
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/control/rewrite/list" && r.Method == http.MethodGet {
+				w.WriteHeader(http.StatusOK)
+				_, _ = fmt.Fprint(w, `[{"domain": "test.com", "answer": "1.2.3.4", "enabled": true}, {"domain": "test.com", "answer": "::1", "enabled": true}]`)
+				return
+			}
+
+			if r.URL.Path == "/control/rewrite/update" && r.Method == http.MethodPost {
+				var payload updateDnsRewritePayload
+				err := json.NewDecoder(r.Body).Decode(&payload)
+				assert.NoError(t, err)
+				assert.False(t, payload.Update.Enabled)
+				disabledAnswers = append(disabledAnswers, payload.Target.Answer)
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+		})
+
+		client, server := setupTestServer("testuser", "testpass", handler)
+		defer server.Close()
+
+		count, err := client.DisableDnsRewrites([]string{"test.com"})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+		assert.Equal(t, []string{"1.2.3.4", "::1"}, disabledAnswers)
+	})
 }
 
 func TestDeleteDnsRewrite(t *testing.T) {
-	t.Run("successful delete", func(t *testing.T) {
+	t.Run("successful delete uses the existing answer", func(t *testing.T) {
 		var deleteCalled bool
 		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			auth := r.Header.Get("Authorization")
@@ -77,7 +268,7 @@ func TestDeleteDnsRewrite(t *testing.T) {
 				assert.NoError(t, err)
 
 				assert.Equal(t, "test.com", payload.Domain)
-				assert.Equal(t, "1.2.3.4", payload.Answer)
+				assert.Equal(t, "5.6.7.8", payload.Answer)
 				assert.True(t, payload.Enabled)
 
 				w.WriteHeader(http.StatusOK)
@@ -87,7 +278,11 @@ func TestDeleteDnsRewrite(t *testing.T) {
 
 			if r.URL.Path == "/control/rewrite/list" && r.Method == http.MethodGet {
 				w.WriteHeader(http.StatusOK)
-				_, _ = fmt.Fprint(w, `[]`)
+				if deleteCalled {
+					_, _ = fmt.Fprint(w, `[]`)
+				} else {
+					_, _ = fmt.Fprint(w, `[{"domain": "test.com", "answer": "5.6.7.8", "enabled": true}]`)
+				}
 				return
 			}
 
@@ -97,7 +292,7 @@ func TestDeleteDnsRewrite(t *testing.T) {
 		client, server := setupTestServer("testuser", "testpass", handler)
 		defer server.Close()
 
-		count, err := client.DeleteDnsRewrites([]string{"test.com"}, "1.2.3.4")
+		count, err := client.DeleteDnsRewrites([]string{"test.com"})
 		assert.Equal(t, 1, count)
 		assert.NoError(t, err)
 		assert.True(t, deleteCalled, "Delete API endpoint was not called")
@@ -106,6 +301,120 @@ func TestDeleteDnsRewrite(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, 0, len(existingDnsRewrites))
 	})
+
+	t.Run("not counted when the rewrite did not disappear", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/control/rewrite/delete" && r.Method == http.MethodPost {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			if r.URL.Path == "/control/rewrite/list" && r.Method == http.MethodGet {
+				w.WriteHeader(http.StatusOK)
+				_, _ = fmt.Fprint(w, `[{"domain": "test.com", "answer": "5.6.7.8", "enabled": true}]`)
+				return
+			}
+
+			t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+		})
+
+		client, server := setupTestServer("testuser", "testpass", handler)
+		defer server.Close()
+
+		count, err := client.DeleteDnsRewrites([]string{"test.com"})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	t.Run("delete every rewrite of a domain", func(t *testing.T) {
+		remainingRewrites := []DnsRewrite{
+			{Domain: "test.com", Answer: "1.2.3.4", Enabled: true},
+			{Domain: "test.com", Answer: "::1", Enabled: true},
+			{Domain: "other.com", Answer: "1.2.3.4", Enabled: true},
+		}
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/control/rewrite/delete" && r.Method == http.MethodPost {
+				var payload DnsRewrite
+				err := json.NewDecoder(r.Body).Decode(&payload)
+				assert.NoError(t, err)
+				remainingRewrites = slices.DeleteFunc(remainingRewrites, func(rewrite DnsRewrite) bool { return rewrite == payload })
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			if r.URL.Path == "/control/rewrite/list" && r.Method == http.MethodGet {
+				w.WriteHeader(http.StatusOK)
+				_ = json.NewEncoder(w).Encode(remainingRewrites)
+				return
+			}
+
+			t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+		})
+
+		client, server := setupTestServer("testuser", "testpass", handler)
+		defer server.Close()
+
+		count, err := client.DeleteDnsRewrites([]string{"test.com"})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+		assert.Equal(t, []DnsRewrite{{Domain: "other.com", Answer: "1.2.3.4", Enabled: true}}, remainingRewrites)
+	})
+
+	t.Run("keep deleting after a failed delete", func(t *testing.T) {
+		remainingRewrites := []DnsRewrite{
+			{Domain: "fails.com", Answer: "1.2.3.4", Enabled: true},
+			{Domain: "test.com", Answer: "1.2.3.4", Enabled: true},
+		}
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/control/rewrite/delete" && r.Method == http.MethodPost {
+				var payload DnsRewrite
+				err := json.NewDecoder(r.Body).Decode(&payload)
+				assert.NoError(t, err)
+				if payload.Domain == "fails.com" {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				remainingRewrites = slices.DeleteFunc(remainingRewrites, func(rewrite DnsRewrite) bool { return rewrite == payload })
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			if r.URL.Path == "/control/rewrite/list" && r.Method == http.MethodGet {
+				w.WriteHeader(http.StatusOK)
+				_ = json.NewEncoder(w).Encode(remainingRewrites)
+				return
+			}
+
+			t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+		})
+
+		client, server := setupTestServer("testuser", "testpass", handler)
+		defer server.Close()
+
+		count, err := client.DeleteDnsRewrites([]string{"fails.com", "test.com"})
+		assert.ErrorContains(t, err, "fails.com")
+		assert.Equal(t, 1, count)
+		assert.Equal(t, []DnsRewrite{{Domain: "fails.com", Answer: "1.2.3.4", Enabled: true}}, remainingRewrites)
+	})
+
+	t.Run("no action when rewrite does not exist", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/control/rewrite/list" && r.Method == http.MethodGet {
+				w.WriteHeader(http.StatusOK)
+				_, _ = fmt.Fprint(w, `[]`)
+				return
+			}
+
+			t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+		})
+
+		client, server := setupTestServer("testuser", "testpass", handler)
+		defer server.Close()
+
+		count, err := client.DeleteDnsRewrites([]string{"test.com"})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
 }
 
 func TestWrongCredentials(t *testing.T) {
@@ -137,7 +446,7 @@ func TestWrongCredentials(t *testing.T) {
 		client, server := setupTestServer("testuser", "wrongpass", handler)
 		defer server.Close()
 
-		_, err := client.DeleteDnsRewrites([]string{"test.com"}, "1.2.3.4")
+		_, err := client.DeleteDnsRewrites([]string{"test.com"})
 		assert.Error(t, err)
 	})
 }
@@ -6,12 +6,19 @@ type DnsRewrite struct {
 	Enabled bool   `json:"enabled"`
 }
 
+type updateDnsRewritePayload struct {
+	Target DnsRewrite `json:"target"`
+	Update DnsRewrite `json:"update"`
+}
+
 type AdguardHomeOptions struct {
-	TargetDomain string
+	// DisableOnStop overrides the global ADGUARD_HOME_DISABLE_ON_STOP setting when set
+	DisableOnStop *bool
+	TargetDomain  string
 }
 
 type (
-	DnsRewrites map[DomainName]IP
+	DnsRewrites map[DomainName][]IP
 	DomainName  string
 	IP          string
 	Target      string
@@ -0,0 +1,225 @@
+package caddy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/deepspace2/plugnpin/pkg/clients/common"
+	"github.com/deepspace2/plugnpin/pkg/metrics"
+)
+
+// NAME identifies Caddy in logs and metrics.
+const NAME = "caddy"
+
+// ROUTE_ID_PREFIX marks the routes owned by PlugNPiN, their '@id' is this
+// prefix followed by the route's first host.
+const ROUTE_ID_PREFIX = "plugnpin_"
+
+type Client struct {
+	http.Client
+	baseURL string
+	server  string
+}
+
+var headers = map[string]string{
+	"accept":       "application/json",
+	"content-type": "application/json",
+}
+
+// NewClient returns a client of the admin API at baseURL, managing the routes
+// of the HTTP server named server, e.g. 'srv0'.
+func NewClient(baseURL, server string) *Client {
+	return &Client{
+		Client: http.Client{
+			Transport: common.NewInstrumentedRoundTripper(NAME, metrics.ObserveApiRequestDuration),
+		},
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		server:  server,
+	}
+}
+
+// RouteID returns the '@id' of the route whose first host is domain.
+func RouteID(domain string) string {
+	return ROUTE_ID_PREFIX + domain
+}
+
+func (c *Client) serverURL() string {
+	return fmt.Sprintf("%v/config/apps/http/servers/%v", c.baseURL, url.PathEscape(c.server))
+}
+
+func (c *Client) idURL(id string) string {
+	return fmt.Sprintf("%v/id/%v", c.baseURL, url.PathEscape(id))
+}
+
+func (c *Client) send(method, url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	var resp string
+	var statusCode int
+	switch method {
+	case http.MethodPatch:
+		resp, statusCode, err = common.Patch(&c.Client, url, headers, string(body))
+	case http.MethodPost:
+		data := string(body)
+		resp, statusCode, err = common.Post(&c.Client, url, headers, &data)
+	case http.MethodPut:
+		data := string(body)
+		resp, statusCode, err = common.Put(&c.Client, url, headers, &data)
+	default:
+		return fmt.Errorf("unsupported method %v", method)
+	}
+	if err != nil {
+		return err
+	}
+	if statusCode >= 400 {
+		return parseErrorResponse(statusCode, resp)
+	}
+	return nil
+}
+
+func parseErrorResponse(statusCode int, resp string) error {
+	var errorResponse struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(resp), &errorResponse); err == nil && errorResponse.Error != "" {
+		return fmt.Errorf("caddy returned %v: %v", statusCode, errorResponse.Error)
+	}
+	return fmt.Errorf("caddy returned %v: %v", statusCode, strings.TrimSpace(resp))
+}
+
+// CheckServer fails if the HTTP server doesn't exist.
+func (c *Client) CheckServer() error {
+	resp, statusCode, err := common.Get(&c.Client, c.serverURL(), headers)
+	if err != nil {
+		return err
+	}
+	if statusCode >= 400 {
+		return parseErrorResponse(statusCode, resp)
+	}
+	if strings.TrimSpace(resp) == "null" {
+		return fmt.Errorf("caddy has no HTTP server named '%v'", c.server)
+	}
+	return nil
+}
+
+// getAllRoutes returns all routes of the server, including foreign ones.
+func (c *Client) getAllRoutes() ([]Route, error) {
+	resp, statusCode, err := common.Get(&c.Client, c.serverURL()+"/routes", headers)
+	if err != nil {
+		return nil, err
+	}
+	if statusCode >= 400 {
+		return nil, parseErrorResponse(statusCode, resp)
+	}
+
+	var routes []Route
+	if err := json.Unmarshal([]byte(resp), &routes); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
+// GetRoutes returns the routes owned by PlugNPiN.
+func (c *Client) GetRoutes() ([]Route, error) {
+	routes, err := c.getAllRoutes()
+	if err != nil {
+		return nil, err
+	}
+
+	ownRoutes := []Route{}
+	for _, route := range routes {
+		if strings.HasPrefix(route.ID, ROUTE_ID_PREFIX) {
+			ownRoutes = append(ownRoutes, route)
+		}
+	}
+	return ownRoutes, nil
+}
+
+// GetRouteIDsOfHosts returns the '@id's of the routes owned by PlugNPiN that
+// match any of hosts.
+func (c *Client) GetRouteIDsOfHosts(hosts []string) ([]string, error) {
+	routes, err := c.GetRoutes()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, route := range routes {
+		for _, match := range route.Match {
+			if slices.ContainsFunc(match.Host, func(host string) bool { return slices.Contains(hosts, host) }) {
+				ids = append(ids, route.ID)
+				break
+			}
+		}
+	}
+	return ids, nil
+}
+
+// AddRoute adds the route in front of all others, so it takes precedence over
+// catch-all routes, or replaces the route with the same '@id' if it differs.
+func (c *Client) AddRoute(route Route) (added, updated bool, err error) {
+	routes, err := c.getAllRoutes()
+	if err != nil {
+		return false, false, err
+	}
+
+	for _, existingRoute := range routes {
+		if existingRoute.ID != route.ID {
+			continue
+		}
+		if reflect.DeepEqual(existingRoute, route) {
+			return false, false, nil
+		}
+		if err := c.send(http.MethodPatch, c.idURL(route.ID), route); err != nil {
+			return false, false, err
+		}
+		return false, true, nil
+	}
+
+	if routes == nil {
+		// The server has no routes at all yet
+		err = c.send(http.MethodPut, c.serverURL()+"/routes", []Route{route})
+	} else {
+		err = c.send(http.MethodPut, c.serverURL()+"/routes/0", route)
+	}
+	if err != nil {
+		return false, false, err
+	}
+	return true, false, nil
+}
+
+// DeleteRoute deletes the route with the given '@id', if it exists.
+func (c *Client) DeleteRoute(id string) (bool, error) {
+	routes, err := c.GetRoutes()
+	if err != nil {
+		return false, err
+	}
+
+	exists := false
+	for _, route := range routes {
+		if route.ID == id {
+			exists = true
+			break
+		}
+	}
+	if !exists {
+		return false, nil
+	}
+
+	resp, statusCode, err := common.Delete(&c.Client, c.idURL(id), headers)
+	if err != nil {
+		return false, err
+	}
+	if statusCode >= 400 {
+		return false, parseErrorResponse(statusCode, resp)
+	}
+	return true, nil
+}
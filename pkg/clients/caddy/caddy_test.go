@@ -0,0 +1,171 @@
+//go:build unit
+
+package caddy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAdminAPI is a stand-in for the routes of one server of Caddy's admin
+// API, routes is nil until the first route is added.
+type fakeAdminAPI struct {
+	t        *testing.T
+	routes   []Route
+	requests []string
+}
+
+func (f *fakeAdminAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	const routesPath = "/config/apps/http/servers/srv0/routes"
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/config/apps/http/servers/srv0":
+		_, _ = fmt.Fprint(w, `{"listen": [":443"]}`)
+	case r.Method == http.MethodGet && r.URL.Path == "/config/apps/http/servers/missing":
+		_, _ = fmt.Fprint(w, `null`)
+	case r.Method == http.MethodGet && r.URL.Path == routesPath:
+		assert.NoError(f.t, json.NewEncoder(w).Encode(f.routes))
+	case r.Method == http.MethodPut && r.URL.Path == routesPath:
+		assert.NoError(f.t, json.NewDecoder(r.Body).Decode(&f.routes))
+	case r.Method == http.MethodPut && r.URL.Path == routesPath+"/0":
+		var route Route
+		assert.NoError(f.t, json.NewDecoder(r.Body).Decode(&route))
+		f.routes = append([]Route{route}, f.routes...)
+	case strings.HasPrefix(r.URL.Path, "/id/"):
+		id := strings.TrimPrefix(r.URL.Path, "/id/")
+		for i, route := range f.routes {
+			if route.ID != id {
+				continue
+			}
+			switch r.Method {
+			case http.MethodPatch:
+				assert.NoError(f.t, json.NewDecoder(r.Body).Decode(&f.routes[i]))
+			case http.MethodDelete:
+				f.routes = append(f.routes[:i], f.routes[i+1:]...)
+			}
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = fmt.Fprintf(w, `{"error": "unknown object ID '%v'"}`, id)
+	default:
+		f.t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+	}
+}
+
+func setupTestServer(t *testing.T, server string, routes []Route) (*Client, *fakeAdminAPI, *httptest.Server) {
+	api := &fakeAdminAPI{t: t, routes: routes}
+	httpServer := httptest.NewServer(api)
+	client := NewClient(httpServer.URL+"/", server)
+	client.Client = *httpServer.Client()
+	return client, api, httpServer
+}
+
+func testRoute(domain, dial string) Route {
+	return Route{
+		ID:       RouteID(domain),
+		Match:    []Match{{Host: []string{domain}}},
+		Handle:   []Handler{{Handler: "reverse_proxy", Upstreams: []Upstream{{Dial: dial}}}},
+		Terminal: true,
+	}
+}
+
+func TestCheckServer(t *testing.T) {
+	client, _, server := setupTestServer(t, "srv0", nil)
+	defer server.Close()
+	assert.NoError(t, client.CheckServer())
+
+	client.server = "missing"
+	assert.ErrorContains(t, client.CheckServer(), "no HTTP server named 'missing'")
+}
+
+func TestGetRoutes(t *testing.T) {
+	foreignRoute := Route{Match: []Match{{Host: []string{"static.com"}}}, Handle: []Handler{{Handler: "file_server"}}}
+	client, _, server := setupTestServer(t, "srv0", []Route{foreignRoute, testRoute("app.com", "10.0.0.1:80")})
+	defer server.Close()
+
+	routes, err := client.GetRoutes()
+	assert.NoError(t, err)
+	assert.Equal(t, []Route{testRoute("app.com", "10.0.0.1:80")}, routes)
+}
+
+func TestGetRouteIDsOfHosts(t *testing.T) {
+	foreignRoute := Route{Match: []Match{{Host: []string{"app.com"}}}, Handle: []Handler{{Handler: "file_server"}}}
+	client, _, server := setupTestServer(t, "srv0", []Route{foreignRoute, testRoute("app.com", "10.0.0.1:80"), testRoute("other.com", "10.0.0.2:80")})
+	defer server.Close()
+
+	ids, err := client.GetRouteIDsOfHosts([]string{"new.com", "app.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"plugnpin_app.com"}, ids)
+}
+
+func TestAddRoute(t *testing.T) {
+	t.Run("first route of the server", func(t *testing.T) {
+		client, api, server := setupTestServer(t, "srv0", nil)
+		defer server.Close()
+
+		added, updated, err := client.AddRoute(testRoute("app.com", "10.0.0.1:80"))
+		assert.NoError(t, err)
+		assert.True(t, added)
+		assert.False(t, updated)
+		assert.Equal(t, []Route{testRoute("app.com", "10.0.0.1:80")}, api.routes)
+	})
+
+	t.Run("inserted in front of existing routes", func(t *testing.T) {
+		catchAll := Route{Handle: []Handler{{Handler: "file_server"}}}
+		client, api, server := setupTestServer(t, "srv0", []Route{catchAll})
+		defer server.Close()
+
+		added, _, err := client.AddRoute(testRoute("app.com", "10.0.0.1:80"))
+		assert.NoError(t, err)
+		assert.True(t, added)
+		assert.Equal(t, []Route{testRoute("app.com", "10.0.0.1:80"), catchAll}, api.routes)
+	})
+
+	t.Run("unchanged route", func(t *testing.T) {
+		client, api, server := setupTestServer(t, "srv0", []Route{testRoute("app.com", "10.0.0.1:80")})
+		defer server.Close()
+
+		added, updated, err := client.AddRoute(testRoute("app.com", "10.0.0.1:80"))
+		assert.NoError(t, err)
+		assert.False(t, added)
+		assert.False(t, updated)
+		assert.Equal(t, []string{"GET /config/apps/http/servers/srv0/routes"}, api.requests)
+	})
+
+	t.Run("changed route is replaced", func(t *testing.T) {
+		client, api, server := setupTestServer(t, "srv0", []Route{testRoute("app.com", "10.0.0.1:80")})
+		defer server.Close()
+
+		added, updated, err := client.AddRoute(testRoute("app.com", "10.0.0.2:8080"))
+		assert.NoError(t, err)
+		assert.False(t, added)
+		assert.True(t, updated)
+		assert.Equal(t, []Route{testRoute("app.com", "10.0.0.2:8080")}, api.routes)
+	})
+}
+
+func TestDeleteRoute(t *testing.T) {
+	client, api, server := setupTestServer(t, "srv0", []Route{testRoute("app.com", "10.0.0.1:80")})
+	defer server.Close()
+
+	deleted, err := client.DeleteRoute(RouteID("other.com"))
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+
+	deleted, err = client.DeleteRoute(RouteID("app.com"))
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	assert.Empty(t, api.routes)
+}
+
+func TestParseErrorResponse(t *testing.T) {
+	assert.EqualError(t, parseErrorResponse(400, `{"error": "bad config"}`), "caddy returned 400: bad config")
+	assert.EqualError(t, parseErrorResponse(502, "bad gateway\n"), "caddy returned 502: bad gateway")
+}
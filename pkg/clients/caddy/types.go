@@ -0,0 +1,58 @@
+package caddy
+
+// Route is a route of Caddy's HTTP app, only with the fields PlugNPiN manages.
+type Route struct {
+	ID       string    `json:"@id,omitempty"`
+	Match    []Match   `json:"match,omitempty"`
+	Handle   []Handler `json:"handle,omitempty"`
+	Terminal bool      `json:"terminal,omitempty"`
+}
+
+type Match struct {
+	Host     []string `json:"host,omitempty"`
+	Protocol string   `json:"protocol,omitempty"`
+}
+
+// Handler is a 'headers', 'reverse_proxy', 'static_response' or 'subroute'
+// handler.
+type Handler struct {
+	Handler string `json:"handler"`
+
+	// Response is set on 'headers' handlers
+	Response *HeaderOps `json:"response,omitempty"`
+
+	// Headers are set on 'reverse_proxy' and 'static_response' handlers
+	Headers *ProxyHeaders `json:"headers,omitempty"`
+
+	// Transport and Upstreams are set on 'reverse_proxy' handlers
+	Transport *Transport `json:"transport,omitempty"`
+	Upstreams []Upstream `json:"upstreams,omitempty"`
+
+	// StatusCode is set on 'static_response' handlers
+	StatusCode int `json:"status_code,omitempty"`
+
+	// Routes are set on 'subroute' handlers
+	Routes []Route `json:"routes,omitempty"`
+}
+
+type HeaderOps struct {
+	Delete []string            `json:"delete,omitempty"`
+	Set    map[string][]string `json:"set,omitempty"`
+}
+
+type ProxyHeaders struct {
+	// Request is set on 'reverse_proxy' handlers
+	Request *HeaderOps `json:"request,omitempty"`
+	// Location is set on 'static_response' handlers that redirect
+	Location []string `json:"Location,omitempty"`
+}
+
+type Transport struct {
+	Protocol string `json:"protocol"`
+	// TLS is set to an empty object to talk HTTPS to the upstream
+	TLS *struct{} `json:"tls,omitempty"`
+}
+
+type Upstream struct {
+	Dial string `json:"dial"`
+}
@@ -2,49 +2,25 @@ package clients
 
 import (
 	"github.com/deepspace2/plugnpin/pkg/cli"
-	"github.com/deepspace2/plugnpin/pkg/clients/adguardhome"
 	"github.com/deepspace2/plugnpin/pkg/clients/docker"
-	"github.com/deepspace2/plugnpin/pkg/clients/npm"
-	"github.com/deepspace2/plugnpin/pkg/clients/pihole"
 	"github.com/deepspace2/plugnpin/pkg/config"
 	"github.com/deepspace2/plugnpin/pkg/logging"
+	"github.com/deepspace2/plugnpin/pkg/providers"
 )
 
 var log = logging.GetLogger("clients")
 
-func GetClients(cliFlags cli.Flags, config *config.Config) (
+func GetClients(cliFlags cli.Flags, config *config.Config, providerOptions providers.Options) (
 	dockerClients map[string]*docker.Client,
-	adguardHomeClient *adguardhome.Client,
-	piholeClient *pihole.Client,
-	npmClient *npm.Client,
+	dnsProviders []providers.DNSProvider,
+	proxyProvider providers.ProxyProvider,
 	err error,
 ) {
 	if !cliFlags.DryRun {
-		if !config.PiholeDisabled {
-			piholeClient = pihole.NewClient(config.PiholeHost, config.PiholePassword)
-			if err = piholeClient.Login(); err != nil {
-				log.Error("Failed to login to Pi-Hole", "error", err)
-				return nil, nil, nil, nil, err
-			}
-			piholeClient := piholeClient
-			defer func() {
-				if err != nil {
-					if piholeLogoutErr := piholeClient.Logout(); piholeLogoutErr != nil {
-						log.Warn("Failed to logout from Pi-Hole", "error", piholeLogoutErr)
-					}
-				}
-			}()
-		}
-
-		if !config.AdguardHomeDisabled {
-			adguardHomeClient = adguardhome.NewClient(config.AdguardHomeHost, config.AdguardHomeUsername, config.AdguardHomePassword)
-		}
-
-		npmClient = npm.NewClient(config.NpmHost, config.NpmUsername, config.NpmPassword)
-		err = npmClient.Login()
+		dnsProviders, proxyProvider, err = providers.New(config, providerOptions)
 		if err != nil {
-			log.Error("Failed to login to Nginx Proxy Manager", "error", err)
-			return nil, nil, nil, nil, err
+			log.Error("Failed to create providers", "error", err)
+			return nil, nil, nil, err
 		}
 	}
 
@@ -61,5 +37,5 @@ func GetClients(cliFlags cli.Flags, config *config.Config) (
 		dockerClients[dockerClient.Host] = dockerClient
 	}
 
-	return dockerClients, adguardHomeClient, piholeClient, npmClient, nil
+	return dockerClients, dnsProviders, proxyProvider, nil
 }
@@ -81,6 +81,36 @@ func Post(client *http.Client, path string, headers map[string]string, data *str
 	return string(body), resp.StatusCode, nil
 }
 
+func Put(client *http.Client, path string, headers map[string]string, data *string) (bodyStr string, statusCode int, err error) {
+	req, err := http.NewRequest(
+		http.MethodPut,
+		path,
+		strings.NewReader(*data),
+	)
+	if err != nil {
+		return "", 0, err
+	}
+
+	setHeaders(req, headers)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return string(body), resp.StatusCode, nil
+}
+
 func Get(client *http.Client, path string, headers map[string]string) (bodyStr string, statusCode int, err error) {
 	req, err := http.NewRequest(
 		http.MethodGet,
@@ -3,6 +3,7 @@ package docker
 import (
 	"context"
 	"fmt"
+	"maps"
 	"slices"
 	"strconv"
 	"strings"
@@ -12,44 +13,48 @@ import (
 	"github.com/docker/docker/api/types/filters"
 	dockerSdk "github.com/docker/go-sdk/client"
 
-	"github.com/deepspace2/plugnpin/pkg/clients/adguardhome"
-	"github.com/deepspace2/plugnpin/pkg/clients/npm"
-	"github.com/deepspace2/plugnpin/pkg/clients/pihole"
 	"github.com/deepspace2/plugnpin/pkg/errors"
 	"github.com/deepspace2/plugnpin/pkg/logging"
 )
 
 type ClientOptions struct {
-	AdguardHome    *adguardhome.AdguardHomeOptions
 	GeneralOptions GeneralOptions
-	NPM            *npm.NpmProxyHostOptions
-	Pihole         *pihole.PiHoleOptions
+	// Providers are the options of providers that parse their own labels, by
+	// the namespace they registered with RegisterOptionsParser
+	Providers map[string]any
+}
+
+// OptionsParser parses the labels of a container into the options of a
+// provider. Invalid labels are reported as *errors.InvalidLabelValueError.
+type OptionsParser func(labels map[string]string) (any, error)
+
+var optionsParsers = map[string]OptionsParser{}
+
+// RegisterOptionsParser makes the options parsed by parser available as
+// ClientOptions.Providers[namespace], where namespace is the part of the
+// provider's labels after 'plugNPiN.', e.g. 'rfc2136Options'. It is meant to
+// be called from the init function of the provider's file.
+func RegisterOptionsParser(namespace string, parser OptionsParser) {
+	if _, exists := optionsParsers[namespace]; exists {
+		panic(fmt.Sprintf("options parser '%v' is already registered", namespace))
+	}
+	optionsParsers[namespace] = parser
 }
 
 type GeneralOptions struct {
 	CreateOnHealthy bool
+	// SkipProxy only creates DNS entries for the container, without any NPM
+	// proxy host, redirection hosts or streams
+	SkipProxy bool
 }
 
 var log = logging.GetLogger("docker")
 
 const (
 	GeneralOptionsCreateOnHealthyLabel = "plugNPiN.options.createOnHealthy"
+	GeneralOptionsSkipProxyLabel       = "plugNPiN.options.skipProxy"
 	IpLabel                            = "plugNPiN.ip"
 	UrlLabel                           = "plugNPiN.url"
-
-	adguardHomeOptionsTargetDomainLabel = "plugNPiN.adguardHomeOptions.targetDomain"
-	npmOptionsAccessListNameLabel       = "plugNPiN.npmOptions.accessListName"
-	npmOptionsAdvancedConfigLabel       = "plugNPiN.npmOptions.advancedConfig"
-	npmOptionsBlockExploitsLabel        = "plugNPiN.npmOptions.blockExploits"
-	npmOptionsCachingEnabledLabel       = "plugNPiN.npmOptions.cachingEnabled"
-	npmOptionsCertificateNameLabel      = "plugNPiN.npmOptions.certificateName"
-	npmOptionsHTTP2SupportLabel         = "plugNPiN.npmOptions.http2Support"
-	npmOptionsHstsEnabledLabel          = "plugNPiN.npmOptions.hstsEnabled"
-	npmOptionsHstsSubdomainsLabel       = "plugNPiN.npmOptions.hstsSubdomains"
-	npmOptionsSchemeLabel               = "plugNPiN.npmOptions.scheme"
-	npmOptionsSslForcedLabel            = "plugNPiN.npmOptions.forceSsl"
-	npmOptionsWebsocketsSupportLabel    = "plugNPiN.npmOptions.websocketsSupport"
-	piholeOptionsTargetDomainLabel      = "plugNPiN.piholeOptions.targetDomain"
 )
 
 var labels []string = []string{IpLabel, UrlLabel}
@@ -81,6 +86,26 @@ func (d *Client) GetRelevantContainers() ([]container.Summary, error) {
 	)
 }
 
+// GetStoppedRelevantContainers returns the containers with PlugNPiN labels
+// that exist but are not running.
+func (d *Client) GetStoppedRelevantContainers() ([]container.Summary, error) {
+	f := filters.NewArgs()
+	for _, label := range labels {
+		f.Add("label", label)
+	}
+	f.Add("status", "created")
+	f.Add("status", "exited")
+	f.Add("status", "dead")
+
+	return d.ContainerList(
+		context.Background(),
+		container.ListOptions{
+			All:     true,
+			Filters: f,
+		},
+	)
+}
+
 func GetParsedContainerName(container container.Summary) string {
 	return strings.Trim(container.Names[0], "/")
 }
@@ -112,63 +137,68 @@ func GetValuesFromLabels(labels map[string]string) (ip string, urls []string, po
 	opts = &ClientOptions{}
 
 	generalOptionsCreateOnHealthy, _ := strconv.ParseBool(labels[GeneralOptionsCreateOnHealthyLabel])
-	opts.GeneralOptions = GeneralOptions{CreateOnHealthy: generalOptionsCreateOnHealthy}
+	generalOptionsSkipProxy, err := ParseBoolLabel(labels, GeneralOptionsSkipProxyLabel, false)
+	if err != nil {
+		return "", nil, 0, nil, err
+	}
+	opts.GeneralOptions = GeneralOptions{CreateOnHealthy: generalOptionsCreateOnHealthy, SkipProxy: generalOptionsSkipProxy}
 
-	npmOptionsBlockExploitsLabelValue, exists := labels[npmOptionsBlockExploitsLabel]
-	if !exists {
-		npmOptionsBlockExploitsLabelValue = "true"
+	opts.Providers = map[string]any{}
+	for _, namespace := range slices.Sorted(maps.Keys(optionsParsers)) {
+		opts.Providers[namespace], err = optionsParsers[namespace](labels)
+		if err != nil {
+			return "", nil, 0, nil, err
+		}
 	}
 
-	npmOptionsBlockExploits, _ := strconv.ParseBool(npmOptionsBlockExploitsLabelValue)
-	npmOptionsWebsocketsSupport, _ := strconv.ParseBool(labels[npmOptionsWebsocketsSupportLabel])
-	npmOptionsCachingEnabled, _ := strconv.ParseBool(labels[npmOptionsCachingEnabledLabel])
+	return ip, urls, port, opts, nil
+}
 
-	npmOptionsScheme, exists := labels[npmOptionsSchemeLabel]
+// ParseBoolLabel returns defaultValue if the label is not set.
+func ParseBoolLabel(labels map[string]string, label string, defaultValue bool) (bool, error) {
+	value, exists := labels[label]
 	if !exists {
-		npmOptionsScheme = "http"
+		return defaultValue, nil
 	}
-	npmOptionsScheme = strings.ToLower(npmOptionsScheme)
-	if !slices.Contains([]string{"http", "https"}, npmOptionsScheme) {
-		return "", nil, 0, nil, &errors.InvalidSchemeError{
-			Msg: fmt.Sprintf("value of '%v' label must be one of 'http', 'https', got '%v'", npmOptionsSchemeLabel, npmOptionsScheme),
+
+	parsedValue, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, &errors.InvalidLabelValueError{
+			Msg: fmt.Sprintf("value of '%v' label must be a boolean, got '%v'", label, value),
 		}
 	}
+	return parsedValue, nil
+}
 
-	npmOptionsAdvancedConfig := labels[npmOptionsAdvancedConfigLabel]
-	npmOptionsCertificateName := labels[npmOptionsCertificateNameLabel]
-	npmOptionsAccessListName := labels[npmOptionsAccessListNameLabel]
-	npmOptionsHTTP2Support, _ := strconv.ParseBool(labels[npmOptionsHTTP2SupportLabel])
-	npmOptionsHstsEnabled, _ := strconv.ParseBool(labels[npmOptionsHstsEnabledLabel])
-	npmOptionsHstsSubdomains, _ := strconv.ParseBool(labels[npmOptionsHstsSubdomainsLabel])
-	npmOptionsSslForced, _ := strconv.ParseBool(labels[npmOptionsSslForcedLabel])
-
-	opts.NPM = &npm.NpmProxyHostOptions{
-		AccessListName:        npmOptionsAccessListName,
-		AdvancedConfig:        npmOptionsAdvancedConfig,
-		AllowWebsocketUpgrade: npmOptionsWebsocketsSupport,
-		BlockExploits:         npmOptionsBlockExploits,
-		CachingEnabled:        npmOptionsCachingEnabled,
-		CertificateName:       npmOptionsCertificateName,
-		ForwardScheme:         npmOptionsScheme,
-		HTTP2Support:          npmOptionsHTTP2Support,
-		HstsEnabled:           npmOptionsHstsEnabled,
-		HstsSubdomains:        npmOptionsHstsSubdomains,
-		SslForced:             npmOptionsSslForced,
+// ParseOptionalBoolLabel returns nil if the label is not set, so a global
+// setting can be used instead.
+func ParseOptionalBoolLabel(labels map[string]string, label string) (*bool, error) {
+	if _, exists := labels[label]; !exists {
+		return nil, nil
 	}
 
-	piholeOptionsTargetDomain := labels[piholeOptionsTargetDomainLabel]
-
-	opts.Pihole = &pihole.PiHoleOptions{
-		TargetDomain: piholeOptionsTargetDomain,
+	parsedValue, err := ParseBoolLabel(labels, label, false)
+	if err != nil {
+		return nil, err
 	}
+	return &parsedValue, nil
+}
 
-	adguardHomeOptionsTargetDomain := labels[adguardHomeOptionsTargetDomainLabel]
-
-	opts.AdguardHome = &adguardhome.AdguardHomeOptions{
-		TargetDomain: adguardHomeOptionsTargetDomain,
+// ParseTTLLabel returns 0 if the label is not set, leaving the TTL up to the
+// provider.
+func ParseTTLLabel(labels map[string]string, label string) (int, error) {
+	value, exists := labels[label]
+	if !exists {
+		return 0, nil
 	}
 
-	return ip, urls, port, opts, nil
+	ttl, err := strconv.Atoi(value)
+	if err != nil || ttl < 0 {
+		return 0, &errors.InvalidLabelValueError{
+			Msg: fmt.Sprintf("value of '%v' label must be a non-negative integer, got '%v'", label, value),
+		}
+	}
+	return ttl, nil
 }
 
 func (d *Client) InspectContainer(ctx context.Context, containerId string) (container.InspectResponse, error) {
@@ -194,6 +224,40 @@ func (d *Client) IsHealthy(containerInspectResponse container.InspectResponse) b
 		containerInspectResponse.State.Health.Status == CONTAINER_HEALTHY_STATUS
 }
 
+// GetMacAndIPAddress returns the MAC and IPv4 address the container has on
+// its network, as used by macvlan/ipvlan containers that get their own LAN
+// address. Docker's default networks are only used if nothing else is found.
+func (d *Client) GetMacAndIPAddress(containerInspectResponse container.InspectResponse) (mac, ip string, err error) {
+	if containerInspectResponse.NetworkSettings == nil {
+		return "", "", fmt.Errorf("container has no network settings")
+	}
+
+	networkNames := slices.Sorted(maps.Keys(containerInspectResponse.NetworkSettings.Networks))
+	slices.SortStableFunc(networkNames, func(a, b string) int {
+		switch {
+		case isDefaultNetwork(a) == isDefaultNetwork(b):
+			return 0
+		case isDefaultNetwork(a):
+			return 1
+		default:
+			return -1
+		}
+	})
+
+	for _, networkName := range networkNames {
+		endpoint := containerInspectResponse.NetworkSettings.Networks[networkName]
+		if endpoint != nil && endpoint.MacAddress != "" && endpoint.IPAddress != "" {
+			return endpoint.MacAddress, endpoint.IPAddress, nil
+		}
+	}
+
+	return "", "", fmt.Errorf("container has no network with both a MAC and an IP address")
+}
+
+func isDefaultNetwork(networkName string) bool {
+	return slices.Contains([]string{"bridge", "host", "none"}, networkName)
+}
+
 func (d *Client) GetShortContainerId(containerId string) string {
 	if len(containerId) < 12 {
 		return containerId
@@ -8,6 +8,7 @@ import (
 
 	"github.com/deepspace2/plugnpin/pkg/errors"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -62,19 +63,14 @@ func TestGetParsedContainerName(t *testing.T) {
 
 func TestGetValuesFromContainerLabels(t *testing.T) {
 	testCases := []struct {
-		name                                   string
-		container                              container.Summary
-		expectedIP                             string
-		expectedURLs                           []string
-		expectedPort                           int
-		expectedErr                            error
-		expectedAdguardHomeOptionsTargetDomain string
-		expectedNpmOptionsBlockExploits        bool
-		expectedNpmOptionsCachingEnabled       bool
-		expectedNpmOptionsScheme               string
-		expectedNpmOptionsWebsocketsSupport    bool
-		expectedPiholeOptionsTargetDomain      string
-		expectedCreateOnHealthy                bool
+		name                    string
+		container               container.Summary
+		expectedIP              string
+		expectedURLs            []string
+		expectedPort            int
+		expectedErr             error
+		expectedCreateOnHealthy bool
+		expectedSkipProxy       bool
 	}{
 		{
 			name: "Happy path",
@@ -84,14 +80,10 @@ func TestGetValuesFromContainerLabels(t *testing.T) {
 					UrlLabel: "my-service.example.com",
 				},
 			},
-			expectedIP:                          "192.168.1.10",
-			expectedURLs:                        []string{"my-service.example.com"},
-			expectedPort:                        8080,
-			expectedErr:                         nil,
-			expectedNpmOptionsBlockExploits:     true,
-			expectedNpmOptionsCachingEnabled:    false,
-			expectedNpmOptionsScheme:            "http",
-			expectedNpmOptionsWebsocketsSupport: false,
+			expectedIP:   "192.168.1.10",
+			expectedURLs: []string{"my-service.example.com"},
+			expectedPort: 8080,
+			expectedErr:  nil,
 		},
 		{
 			name: "Multiple URLs",
@@ -101,14 +93,10 @@ func TestGetValuesFromContainerLabels(t *testing.T) {
 					UrlLabel: "my-service.example.com,my-service.local",
 				},
 			},
-			expectedIP:                          "192.168.1.10",
-			expectedURLs:                        []string{"my-service.example.com", "my-service.local"},
-			expectedPort:                        8080,
-			expectedErr:                         nil,
-			expectedNpmOptionsBlockExploits:     true,
-			expectedNpmOptionsCachingEnabled:    false,
-			expectedNpmOptionsScheme:            "http",
-			expectedNpmOptionsWebsocketsSupport: false,
+			expectedIP:   "192.168.1.10",
+			expectedURLs: []string{"my-service.example.com", "my-service.local"},
+			expectedPort: 8080,
+			expectedErr:  nil,
 		},
 		{
 			name: "Malformed IP label - missing port",
@@ -137,184 +125,45 @@ func TestGetValuesFromContainerLabels(t *testing.T) {
 			expectedErr:  &errors.MalformedIPLabelError{Msg: fmt.Sprintf("value after ':' in value of '%v' label must be an integer, got 'http'", IpLabel)},
 		},
 		{
-			name: "NPM options",
-			container: container.Summary{
-				Labels: map[string]string{
-					IpLabel:                          "192.168.1.10:8080",
-					UrlLabel:                         "my-service.example.com",
-					npmOptionsBlockExploitsLabel:     "",
-					npmOptionsCachingEnabledLabel:    "true",
-					npmOptionsSchemeLabel:            "https",
-					npmOptionsWebsocketsSupportLabel: "",
-				},
-			},
-			expectedIP:                          "192.168.1.10",
-			expectedURLs:                        []string{"my-service.example.com"},
-			expectedPort:                        8080,
-			expectedErr:                         nil,
-			expectedNpmOptionsBlockExploits:     false,
-			expectedNpmOptionsCachingEnabled:    true,
-			expectedNpmOptionsScheme:            "https",
-			expectedNpmOptionsWebsocketsSupport: false,
-		},
-		{
-			name: "NPM options - true values",
-			container: container.Summary{
-				Labels: map[string]string{
-					IpLabel:                          "192.168.1.10:8080",
-					UrlLabel:                         "my-service.example.com",
-					npmOptionsBlockExploitsLabel:     "true",
-					npmOptionsCachingEnabledLabel:    "1",
-					npmOptionsWebsocketsSupportLabel: "T",
-				},
-			},
-			expectedIP:                          "192.168.1.10",
-			expectedURLs:                        []string{"my-service.example.com"},
-			expectedPort:                        8080,
-			expectedErr:                         nil,
-			expectedNpmOptionsBlockExploits:     true,
-			expectedNpmOptionsCachingEnabled:    true,
-			expectedNpmOptionsScheme:            "http",
-			expectedNpmOptionsWebsocketsSupport: true,
-		},
-		{
-			name: "NPM options - false values",
-			container: container.Summary{
-				Labels: map[string]string{
-					IpLabel:                          "192.168.1.10:8080",
-					UrlLabel:                         "my-service.example.com",
-					npmOptionsBlockExploitsLabel:     "false",
-					npmOptionsCachingEnabledLabel:    "0",
-					npmOptionsWebsocketsSupportLabel: "F",
-				},
-			},
-			expectedIP:                          "192.168.1.10",
-			expectedURLs:                        []string{"my-service.example.com"},
-			expectedPort:                        8080,
-			expectedErr:                         nil,
-			expectedNpmOptionsBlockExploits:     false,
-			expectedNpmOptionsCachingEnabled:    false,
-			expectedNpmOptionsScheme:            "http",
-			expectedNpmOptionsWebsocketsSupport: false,
-		},
-		{
-			name: "NPM options - invalid boolean values",
-			container: container.Summary{
-				Labels: map[string]string{
-					IpLabel:                          "192.168.1.10:8080",
-					UrlLabel:                         "my-service.example.com",
-					npmOptionsBlockExploitsLabel:     "yes",
-					npmOptionsCachingEnabledLabel:    "no",
-					npmOptionsWebsocketsSupportLabel: "2",
-				},
-			},
-			expectedIP:                          "192.168.1.10",
-			expectedURLs:                        []string{"my-service.example.com"},
-			expectedPort:                        8080,
-			expectedErr:                         nil,
-			expectedNpmOptionsBlockExploits:     false,
-			expectedNpmOptionsCachingEnabled:    false,
-			expectedNpmOptionsScheme:            "http",
-			expectedNpmOptionsWebsocketsSupport: false,
-		},
-		{
-			name: "NPM options - invalid scheme",
-			container: container.Summary{
-				Labels: map[string]string{
-					IpLabel:               "192.168.1.10:8080",
-					UrlLabel:              "my-service.example.com",
-					npmOptionsSchemeLabel: "invalid",
-				},
-			},
-			expectedIP:   "",
-			expectedURLs: nil,
-			expectedPort: 0,
-			expectedErr:  &errors.InvalidSchemeError{Msg: fmt.Sprintf("value of '%v' label must be one of 'http', 'https', got 'invalid'", npmOptionsSchemeLabel)},
-		},
-		{
-			name: "NPM options - case-insensitive scheme",
-			container: container.Summary{
-				Labels: map[string]string{
-					IpLabel:               "192.168.1.10:8080",
-					UrlLabel:              "my-service.example.com",
-					npmOptionsSchemeLabel: "HTTPS",
-				},
-			},
-			expectedIP:                          "192.168.1.10",
-			expectedURLs:                        []string{"my-service.example.com"},
-			expectedPort:                        8080,
-			expectedErr:                         nil,
-			expectedNpmOptionsBlockExploits:     true,
-			expectedNpmOptionsCachingEnabled:    false,
-			expectedNpmOptionsScheme:            "https",
-			expectedNpmOptionsWebsocketsSupport: false,
-		},
-		{
-			name: "Pi-Hole options - no target domain",
-			container: container.Summary{
-				Labels: map[string]string{
-					IpLabel:  "192.168.1.10:8080",
-					UrlLabel: "my-service.example.com",
-				},
-			},
-			expectedIP:                        "192.168.1.10",
-			expectedURLs:                      []string{"my-service.example.com"},
-			expectedPort:                      8080,
-			expectedErr:                       nil,
-			expectedNpmOptionsScheme:          "http",
-			expectedNpmOptionsBlockExploits:   true,
-			expectedPiholeOptionsTargetDomain: "",
-		},
-		{
-			name: "Pi-Hole options - target domain",
+			name: "General options - CreateOnHealthy true",
 			container: container.Summary{
 				Labels: map[string]string{
-					IpLabel:                        "192.168.1.10:8080",
-					UrlLabel:                       "my-service.example.com",
-					piholeOptionsTargetDomainLabel: "custom.domain",
+					IpLabel:                            "192.168.1.10:8080",
+					UrlLabel:                           "my-service.example.com",
+					GeneralOptionsCreateOnHealthyLabel: "true",
 				},
 			},
-			expectedIP:                        "192.168.1.10",
-			expectedURLs:                      []string{"my-service.example.com"},
-			expectedPort:                      8080,
-			expectedErr:                       nil,
-			expectedNpmOptionsScheme:          "http",
-			expectedNpmOptionsBlockExploits:   true,
-			expectedPiholeOptionsTargetDomain: "custom.domain",
+			expectedIP:              "192.168.1.10",
+			expectedURLs:            []string{"my-service.example.com"},
+			expectedPort:            8080,
+			expectedErr:             nil,
+			expectedCreateOnHealthy: true,
 		},
 		{
-			name: "AdguardHome options - target domain",
+			name: "General options - SkipProxy",
 			container: container.Summary{
 				Labels: map[string]string{
-					IpLabel:                             "192.168.1.10:8080",
-					UrlLabel:                            "my-service.example.com",
-					adguardHomeOptionsTargetDomainLabel: "custom.domain.adguard",
+					IpLabel:                      "192.168.1.10:8080",
+					UrlLabel:                     "my-service.example.com",
+					GeneralOptionsSkipProxyLabel: "true",
 				},
 			},
-			expectedIP:                             "192.168.1.10",
-			expectedURLs:                           []string{"my-service.example.com"},
-			expectedPort:                           8080,
-			expectedErr:                            nil,
-			expectedNpmOptionsScheme:               "http",
-			expectedNpmOptionsBlockExploits:        true,
-			expectedAdguardHomeOptionsTargetDomain: "custom.domain.adguard",
+			expectedIP:        "192.168.1.10",
+			expectedURLs:      []string{"my-service.example.com"},
+			expectedPort:      8080,
+			expectedErr:       nil,
+			expectedSkipProxy: true,
 		},
 		{
-			name: "General options - CreateOnHealthy true",
+			name: "General options - invalid SkipProxy",
 			container: container.Summary{
 				Labels: map[string]string{
-					IpLabel:                            "192.168.1.10:8080",
-					UrlLabel:                           "my-service.example.com",
-					GeneralOptionsCreateOnHealthyLabel: "true",
+					IpLabel:                      "192.168.1.10:8080",
+					UrlLabel:                     "my-service.example.com",
+					GeneralOptionsSkipProxyLabel: "maybe",
 				},
 			},
-			expectedIP:                      "192.168.1.10",
-			expectedURLs:                    []string{"my-service.example.com"},
-			expectedPort:                    8080,
-			expectedErr:                     nil,
-			expectedNpmOptionsScheme:        "http",
-			expectedNpmOptionsBlockExploits: true,
-			expectedCreateOnHealthy:         true,
+			expectedErr: &errors.InvalidLabelValueError{Msg: fmt.Sprintf("value of '%v' label must be a boolean, got 'maybe'", GeneralOptionsSkipProxyLabel)},
 		},
 		{
 			name: "General options - CreateOnHealthy false",
@@ -325,13 +174,11 @@ func TestGetValuesFromContainerLabels(t *testing.T) {
 					GeneralOptionsCreateOnHealthyLabel: "false",
 				},
 			},
-			expectedIP:                      "192.168.1.10",
-			expectedURLs:                    []string{"my-service.example.com"},
-			expectedPort:                    8080,
-			expectedErr:                     nil,
-			expectedNpmOptionsScheme:        "http",
-			expectedNpmOptionsBlockExploits: true,
-			expectedCreateOnHealthy:         false,
+			expectedIP:              "192.168.1.10",
+			expectedURLs:            []string{"my-service.example.com"},
+			expectedPort:            8080,
+			expectedErr:             nil,
+			expectedCreateOnHealthy: false,
 		},
 	}
 
@@ -345,20 +192,62 @@ func TestGetValuesFromContainerLabels(t *testing.T) {
 			assert.Equal(t, tc.expectedErr, err)
 			if err == nil {
 				assert.NotNil(t, opts)
-				assert.NotNil(t, opts.NPM)
-				assert.NotNil(t, opts.Pihole)
-				assert.NotNil(t, opts.AdguardHome)
 				assert.NotNil(t, opts.GeneralOptions)
-				assert.Equal(t, tc.expectedNpmOptionsBlockExploits, opts.NPM.BlockExploits)
-				assert.Equal(t, tc.expectedNpmOptionsCachingEnabled, opts.NPM.CachingEnabled)
-				assert.Equal(t, tc.expectedNpmOptionsScheme, opts.NPM.ForwardScheme)
-				assert.Equal(t, tc.expectedNpmOptionsWebsocketsSupport, opts.NPM.AllowWebsocketUpgrade)
-				assert.Equal(t, tc.expectedPiholeOptionsTargetDomain, opts.Pihole.TargetDomain)
-				assert.Equal(t, tc.expectedAdguardHomeOptionsTargetDomain, opts.AdguardHome.TargetDomain)
 				assert.Equal(t, tc.expectedCreateOnHealthy, opts.GeneralOptions.CreateOnHealthy)
+				assert.Equal(t, tc.expectedSkipProxy, opts.GeneralOptions.SkipProxy)
 			} else {
 				assert.Nil(t, opts)
 			}
 		})
 	}
 }
+
+func TestRegisterOptionsParser(t *testing.T) {
+	RegisterOptionsParser("testOptions", func(labels map[string]string) (any, error) {
+		if value, ok := labels["plugNPiN.testOptions.value"]; ok && value == "" {
+			return nil, &errors.InvalidLabelValueError{Msg: "value of 'plugNPiN.testOptions.value' label must not be empty"}
+		}
+		return labels["plugNPiN.testOptions.value"], nil
+	})
+	t.Cleanup(func() { delete(optionsParsers, "testOptions") })
+
+	assert.Panics(t, func() {
+		RegisterOptionsParser("testOptions", func(map[string]string) (any, error) { return nil, nil })
+	})
+
+	_, _, _, opts, err := GetValuesFromLabels(map[string]string{
+		IpLabel:                      "192.168.1.10:8080",
+		UrlLabel:                     "my-service.example.com",
+		"plugNPiN.testOptions.value": "custom",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "custom", opts.Providers["testOptions"])
+
+	_, _, _, _, err = GetValuesFromLabels(map[string]string{
+		IpLabel:                      "192.168.1.10:8080",
+		UrlLabel:                     "my-service.example.com",
+		"plugNPiN.testOptions.value": "",
+	})
+	assert.Equal(t, &errors.InvalidLabelValueError{Msg: "value of 'plugNPiN.testOptions.value' label must not be empty"}, err)
+}
+
+func TestGetMacAndIPAddress(t *testing.T) {
+	client := &Client{}
+
+	containerInspectResponse := container.InspectResponse{
+		NetworkSettings: &container.NetworkSettings{
+			Networks: map[string]*network.EndpointSettings{
+				"bridge": {MacAddress: "02:42:ac:11:00:02", IPAddress: "172.17.0.2"},
+				"lan":    {MacAddress: "aa:bb:cc:dd:ee:ff", IPAddress: "192.168.0.30"},
+			},
+		},
+	}
+
+	mac, ip, err := client.GetMacAndIPAddress(containerInspectResponse)
+	assert.NoError(t, err)
+	assert.Equal(t, "aa:bb:cc:dd:ee:ff", mac)
+	assert.Equal(t, "192.168.0.30", ip)
+
+	_, _, err = client.GetMacAndIPAddress(container.InspectResponse{NetworkSettings: &container.NetworkSettings{}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,260 @@
+package nginx
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// NAME identifies nginx in logs and metrics
+	NAME = "nginx"
+
+	// HEADER starts every file generated by PlugNPiN, files without it are
+	// never touched
+	HEADER = "# Generated by PlugNPiN, any change is overwritten\n"
+
+	// metadataPrefix starts the second line of generated files, which holds
+	// the server as JSON
+	metadataPrefix = "# plugnpin: "
+
+	commandTimeout = time.Minute
+)
+
+//go:embed default.conf.tmpl
+var DefaultTemplate string
+
+var fileNameReplacer = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+
+// Client manages server block files in an nginx conf.d directory.
+type Client struct {
+	dir             string
+	template        *template.Template
+	validateCommand string
+	reloadCommand   string
+}
+
+// NewClient returns a client writing to dir, which has to exist. The
+// commands are run with 'sh -c' after every change and may be empty.
+func NewClient(dir, templateText, validateCommand, reloadCommand string) (*Client, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("'%v' is not a directory", dir)
+	}
+
+	tmpl, err := template.New("server").Funcs(template.FuncMap{
+		"join": func(sep string, elems []string) string { return strings.Join(elems, sep) },
+	}).Parse(templateText)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	return &Client{
+		dir:             dir,
+		template:        tmpl,
+		validateCommand: validateCommand,
+		reloadCommand:   reloadCommand,
+	}, nil
+}
+
+// FileName returns the name of the file of the server whose first domain is
+// domain.
+func FileName(domain string) string {
+	return "plugnpin-" + fileNameReplacer.ReplaceAllString(domain, "_") + ".conf"
+}
+
+func isGenerated(content []byte) bool {
+	return bytes.HasPrefix(content, []byte(HEADER))
+}
+
+// GetServers returns the servers of all generated files in the directory.
+func (c *Client) GetServers() ([]Server, error) {
+	paths, err := filepath.Glob(filepath.Join(c.dir, "*.conf"))
+	if err != nil {
+		return nil, err
+	}
+
+	servers := []Server{}
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if !isGenerated(content) {
+			continue
+		}
+		server, err := parseMetadata(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse '%v': %w", path, err)
+		}
+		servers = append(servers, server)
+	}
+	return servers, nil
+}
+
+func parseMetadata(content []byte) (Server, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(content[len(HEADER):]))
+	if !scanner.Scan() || !strings.HasPrefix(scanner.Text(), metadataPrefix) {
+		return Server{}, errors.New("metadata line is missing")
+	}
+
+	var server Server
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(scanner.Text(), metadataPrefix)), &server); err != nil {
+		return Server{}, err
+	}
+	return server, nil
+}
+
+func (c *Client) render(server Server) ([]byte, error) {
+	metadata, err := json.Marshal(server)
+	if err != nil {
+		return nil, err
+	}
+
+	var content bytes.Buffer
+	content.WriteString(HEADER)
+	content.WriteString(metadataPrefix + string(metadata) + "\n")
+	if err := c.template.Execute(&content, server); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+	return content.Bytes(), nil
+}
+
+// readGenerated returns the content of the file at path, or nil if it doesn't
+// exist. It fails if the file wasn't generated by PlugNPiN.
+func readGenerated(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !isGenerated(content) {
+		return nil, fmt.Errorf("'%v' was not generated by PlugNPiN, not touching it", path)
+	}
+	return content, nil
+}
+
+// AddServer writes the server block of server, unless it is unchanged, then
+// validates and reloads nginx. The previous file is restored if validation
+// fails.
+func (c *Client) AddServer(server Server) (added, updated bool, err error) {
+	path := filepath.Join(c.dir, FileName(server.Domains[0]))
+	oldContent, err := readGenerated(path)
+	if err != nil {
+		return false, false, err
+	}
+
+	content, err := c.render(server)
+	if err != nil {
+		return false, false, err
+	}
+	if bytes.Equal(content, oldContent) {
+		return false, false, nil
+	}
+
+	if err := writeFile(path, content); err != nil {
+		return false, false, err
+	}
+	if err := c.apply(path, oldContent); err != nil {
+		return false, false, err
+	}
+	return oldContent == nil, oldContent != nil, nil
+}
+
+// DeleteServer deletes the server block of the server whose first domain is
+// domain, then validates and reloads nginx. The file is restored if
+// validation fails.
+func (c *Client) DeleteServer(domain string) (bool, error) {
+	path := filepath.Join(c.dir, FileName(domain))
+	oldContent, err := readGenerated(path)
+	if err != nil {
+		return false, err
+	}
+	if oldContent == nil {
+		return false, nil
+	}
+
+	if err := os.Remove(path); err != nil {
+		return false, err
+	}
+	if err := c.apply(path, oldContent); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// apply validates the configuration and reloads nginx. If validation fails,
+// the file at path is rolled back to oldContent, or deleted if it is nil.
+func (c *Client) apply(path string, oldContent []byte) error {
+	if err := runCommand(c.validateCommand); err != nil {
+		var rollbackErr error
+		if oldContent == nil {
+			rollbackErr = os.Remove(path)
+		} else {
+			rollbackErr = writeFile(path, oldContent)
+		}
+		if rollbackErr != nil {
+			return errors.Join(fmt.Errorf("validation failed: %w", err), fmt.Errorf("failed to roll back '%v': %w", path, rollbackErr))
+		}
+		return fmt.Errorf("validation failed, rolled back '%v': %w", path, err)
+	}
+
+	if err := runCommand(c.reloadCommand); err != nil {
+		return fmt.Errorf("reload failed: %w", err)
+	}
+	return nil
+}
+
+func runCommand(command string) error {
+	if command == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "sh", "-c", command).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("'%v': %w: %v", command, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// writeFile replaces the file at path atomically, so nginx never loads a
+// partial server block.
+func writeFile(path string, content []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(file.Name()) }()
+
+	if _, err := file.Write(content); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Chmod(0o644); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
@@ -0,0 +1,260 @@
+//go:build unit
+
+package nginx
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, validateCommand, reloadCommand string) (*Client, string) {
+	t.Helper()
+	dir := t.TempDir()
+	client, err := NewClient(dir, DefaultTemplate, validateCommand, reloadCommand)
+	require.NoError(t, err)
+	return client, dir
+}
+
+func testServer(port int) Server {
+	return Server{
+		Domains:       []string{"app.com", "www.app.com"},
+		ForwardHost:   "10.0.0.1",
+		ForwardPort:   port,
+		ForwardScheme: "http",
+		Websockets:    true,
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	_, err := NewClient(filepath.Join(t.TempDir(), "missing"), DefaultTemplate, "", "")
+	assert.Error(t, err)
+
+	_, err = NewClient(t.TempDir(), "{{ .Unclosed", "", "")
+	assert.ErrorContains(t, err, "failed to parse template")
+}
+
+func TestFileName(t *testing.T) {
+	assert.Equal(t, "plugnpin-app.com.conf", FileName("app.com"))
+	assert.Equal(t, "plugnpin-_.wildcard.com.conf", FileName("*.wildcard.com"))
+}
+
+func TestUpstream(t *testing.T) {
+	assert.Equal(t, "https://[fd00::1]:8443", Server{ForwardScheme: "https", ForwardHost: "fd00::1", ForwardPort: 8443}.Upstream())
+}
+
+func TestDefaultTemplate(t *testing.T) {
+	client, _ := newTestClient(t, "", "")
+
+	server := testServer(8080)
+	server.HstsEnabled = true
+	server.HstsSubdomains = true
+	server.AdvancedConfig = "    gzip on;"
+	content, err := client.render(server)
+	require.NoError(t, err)
+
+	assert.Equal(t, HEADER+`# plugnpin: {"domains":["app.com","www.app.com"],"forwardHost":"10.0.0.1","forwardPort":8080,"forwardScheme":"http","hstsEnabled":true,"hstsSubdomains":true,"websockets":true}
+server {
+    listen 80;
+    listen [::]:80;
+    server_name app.com www.app.com;
+
+    add_header Strict-Transport-Security "max-age=63072000; includeSubDomains" always;
+
+    gzip on;
+
+    location / {
+        proxy_pass http://10.0.0.1:8080;
+        proxy_set_header Host $host;
+        proxy_set_header X-Real-IP $remote_addr;
+        proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
+        proxy_set_header X-Forwarded-Proto $scheme;
+        proxy_http_version 1.1;
+        proxy_set_header Upgrade $http_upgrade;
+        proxy_set_header Connection "upgrade";
+    }
+}
+`, string(content))
+}
+
+func TestDefaultTemplateTLS(t *testing.T) {
+	client, _ := newTestClient(t, "", "")
+
+	server := testServer(8080)
+	server.CertificateName = "wildcard"
+	server.CertificateDir = "/etc/nginx/certs/wildcard"
+	server.SslForced = true
+	server.Websockets = false
+	content, err := client.render(server)
+	require.NoError(t, err)
+
+	assert.Equal(t, HEADER+`# plugnpin: {"domains":["app.com","www.app.com"],"certificateName":"wildcard","certificateDir":"/etc/nginx/certs/wildcard","forwardHost":"10.0.0.1","forwardPort":8080,"forwardScheme":"http","sslForced":true}
+server {
+    listen 80;
+    listen [::]:80;
+    server_name app.com www.app.com;
+
+    return 301 https://$host$request_uri;
+}
+
+server {
+    listen 443 ssl;
+    listen [::]:443 ssl;
+    server_name app.com www.app.com;
+
+    ssl_certificate /etc/nginx/certs/wildcard/fullchain.pem;
+    ssl_certificate_key /etc/nginx/certs/wildcard/privkey.pem;
+
+    location / {
+        proxy_pass http://10.0.0.1:8080;
+        proxy_set_header Host $host;
+        proxy_set_header X-Real-IP $remote_addr;
+        proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
+        proxy_set_header X-Forwarded-Proto $scheme;
+    }
+}
+`, string(content))
+
+	t.Run("without forced SSL both servers proxy", func(t *testing.T) {
+		server.SslForced = false
+		content, err := client.render(server)
+		require.NoError(t, err)
+		assert.Equal(t, 2, strings.Count(string(content), "proxy_pass http://10.0.0.1:8080;"))
+		assert.NotContains(t, string(content), "return 301")
+	})
+}
+
+func TestAddServer(t *testing.T) {
+	reloadedFile := filepath.Join(t.TempDir(), "reloaded")
+	client, dir := newTestClient(t, "true", "touch "+reloadedFile)
+
+	added, updated, err := client.AddServer(testServer(8080))
+	assert.NoError(t, err)
+	assert.True(t, added)
+	assert.False(t, updated)
+	assert.FileExists(t, filepath.Join(dir, "plugnpin-app.com.conf"))
+	assert.FileExists(t, reloadedFile)
+
+	t.Run("unchanged server is not written nor reloaded", func(t *testing.T) {
+		require.NoError(t, os.Remove(reloadedFile))
+
+		added, updated, err := client.AddServer(testServer(8080))
+		assert.NoError(t, err)
+		assert.False(t, added)
+		assert.False(t, updated)
+		assert.NoFileExists(t, reloadedFile)
+	})
+
+	t.Run("changed server", func(t *testing.T) {
+		added, updated, err := client.AddServer(testServer(9090))
+		assert.NoError(t, err)
+		assert.False(t, added)
+		assert.True(t, updated)
+		assert.FileExists(t, reloadedFile)
+
+		servers, err := client.GetServers()
+		assert.NoError(t, err)
+		assert.Equal(t, []Server{testServer(9090)}, servers)
+	})
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestAddServerRollback(t *testing.T) {
+	client, dir := newTestClient(t, "", "")
+	_, _, err := client.AddServer(testServer(8080))
+	require.NoError(t, err)
+	path := filepath.Join(dir, "plugnpin-app.com.conf")
+	validContent, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	client.validateCommand = "echo 'nginx: configuration file test failed' && false"
+	client.reloadCommand = "echo reload must not run && false"
+
+	t.Run("updated file is restored", func(t *testing.T) {
+		_, _, err := client.AddServer(testServer(9090))
+		assert.ErrorContains(t, err, "configuration file test failed")
+
+		content, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, validContent, content)
+	})
+
+	t.Run("new file is removed", func(t *testing.T) {
+		server := testServer(8080)
+		server.Domains = []string{"other.com"}
+		_, _, err := client.AddServer(server)
+		assert.ErrorContains(t, err, "rolled back")
+		assert.NoFileExists(t, filepath.Join(dir, "plugnpin-other.com.conf"))
+	})
+}
+
+func TestReloadFailure(t *testing.T) {
+	client, _ := newTestClient(t, "true", "false")
+
+	_, _, err := client.AddServer(testServer(8080))
+	assert.ErrorContains(t, err, "reload failed")
+}
+
+func TestHandWrittenFiles(t *testing.T) {
+	client, dir := newTestClient(t, "", "")
+	handWritten := "server {\n    listen 80;\n    server_name app.com;\n}\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "plugnpin-app.com.conf"), []byte(handWritten), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "default.conf"), []byte(handWritten), 0o644))
+
+	servers, err := client.GetServers()
+	assert.NoError(t, err)
+	assert.Empty(t, servers)
+
+	_, _, err = client.AddServer(testServer(8080))
+	assert.ErrorContains(t, err, "not generated by PlugNPiN")
+
+	_, err = client.DeleteServer("app.com")
+	assert.ErrorContains(t, err, "not generated by PlugNPiN")
+
+	for _, name := range []string{"plugnpin-app.com.conf", "default.conf"} {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		assert.NoError(t, err)
+		assert.Equal(t, handWritten, string(content))
+	}
+}
+
+func TestDeleteServer(t *testing.T) {
+	client, dir := newTestClient(t, "", "")
+	_, _, err := client.AddServer(testServer(8080))
+	require.NoError(t, err)
+	path := filepath.Join(dir, "plugnpin-app.com.conf")
+
+	deleted, err := client.DeleteServer("other.com")
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+
+	t.Run("restored if validation fails", func(t *testing.T) {
+		client.validateCommand = "false"
+		defer func() { client.validateCommand = "" }()
+
+		_, err := client.DeleteServer("app.com")
+		assert.Error(t, err)
+		assert.FileExists(t, path)
+	})
+
+	deleted, err = client.DeleteServer("app.com")
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	assert.NoFileExists(t, path)
+}
+
+func TestGetServersInvalidMetadata(t *testing.T) {
+	client, dir := newTestClient(t, "", "")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "plugnpin-app.com.conf"), []byte(HEADER+"server {}\n"), 0o644))
+
+	_, err := client.GetServers()
+	assert.ErrorContains(t, err, "metadata line is missing")
+}
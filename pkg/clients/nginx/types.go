@@ -0,0 +1,34 @@
+package nginx
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// Server is the data the template of a container's server block is rendered
+// with. All fields but AdvancedConfig are kept in the generated file, so
+// servers can be listed back.
+type Server struct {
+	Domains         []string `json:"domains"`
+	CertificateName string   `json:"certificateName,omitempty"`
+	// CertificateDir holds the fullchain.pem and privkey.pem of the
+	// certificate named CertificateName, as seen by nginx
+	CertificateDir string `json:"certificateDir,omitempty"`
+	ForwardHost    string `json:"forwardHost"`
+	ForwardPort    int    `json:"forwardPort"`
+	ForwardScheme  string `json:"forwardScheme"`
+	HstsEnabled    bool   `json:"hstsEnabled,omitempty"`
+	HstsSubdomains bool   `json:"hstsSubdomains,omitempty"`
+	SslForced      bool   `json:"sslForced,omitempty"`
+	Websockets     bool   `json:"websockets,omitempty"`
+
+	// AdvancedConfig is the rendered snippets and advanced config of the
+	// container
+	AdvancedConfig string `json:"-"`
+}
+
+// Upstream returns the URL to proxy_pass to.
+func (s Server) Upstream() string {
+	return fmt.Sprintf("%v://%v", s.ForwardScheme, net.JoinHostPort(s.ForwardHost, strconv.Itoa(s.ForwardPort)))
+}
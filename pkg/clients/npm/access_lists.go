@@ -0,0 +1,224 @@
+package npm
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const ACCESS_LIST_NAME_PREFIX = "plugNPiN-"
+
+func compareAccessListClients(a, b AccessListClient) int {
+	return cmp.Or(strings.Compare(a.Directive, b.Directive), strings.Compare(a.Address, b.Address))
+}
+
+func accessListUsernames(items []AccessListItem) []string {
+	usernames := []string{}
+	for _, item := range items {
+		usernames = append(usernames, item.Username)
+	}
+	slices.Sort(usernames)
+	return usernames
+}
+
+// accessListCredentials remembers a hash of the credentials last written to
+// each access list, since NPM never returns passwords. They are written once
+// after each start, and again whenever a password changes.
+type accessListCredentials struct {
+	hashes map[string]string
+	mu     sync.Mutex
+}
+
+func credentialsHash(items []AccessListItem) string {
+	items = slices.Clone(items)
+	slices.SortFunc(items, func(a, b AccessListItem) int {
+		return strings.Compare(a.Username, b.Username)
+	})
+
+	hash := sha256.New()
+	for _, item := range items {
+		fmt.Fprintf(hash, "%v\x00%v\n", item.Username, item.Password)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// changed reports whether the credentials of the named access list differ
+// from the ones last written to it.
+func (c *accessListCredentials) changed(name string, items []AccessListItem) bool {
+	if len(items) == 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hashes[name] != credentialsHash(items)
+}
+
+func (c *accessListCredentials) written(name string, items []AccessListItem) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.hashes == nil {
+		c.hashes = map[string]string{}
+	}
+	c.hashes[name] = credentialsHash(items)
+}
+
+// attachedAccessLists remembers the access list the labels of a container
+// attached to each proxy host, so it can be detached once the labels are
+// removed, even if PlugNPiN didn't create it.
+type attachedAccessLists struct {
+	ids map[int]int
+	mu  sync.Mutex
+}
+
+func (a *attachedAccessLists) get(proxyHostID int) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.ids[proxyHostID]
+}
+
+func (a *attachedAccessLists) set(proxyHostID, accessListID int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.ids == nil {
+		a.ids = map[int]int{}
+	}
+	if accessListID == 0 {
+		delete(a.ids, proxyHostID)
+		return
+	}
+	a.ids[proxyHostID] = accessListID
+}
+
+// accessListMatches compares everything but the passwords, which NPM never
+// returns.
+func accessListMatches(existingAccessList AccessList, accessList AccessList) bool {
+	existingClients := slices.Clone(existingAccessList.Clients)
+	slices.SortFunc(existingClients, compareAccessListClients)
+	clients := slices.Clone(accessList.Clients)
+	slices.SortFunc(clients, compareAccessListClients)
+
+	return existingAccessList.SatisfyAny == accessList.SatisfyAny &&
+		existingAccessList.PassAuth == accessList.PassAuth &&
+		slices.Equal(existingClients, clients) &&
+		slices.Equal(accessListUsernames(existingAccessList.Items), accessListUsernames(accessList.Items))
+}
+
+// EnsureAccessList creates the access list if there is none with its name yet,
+// or updates the existing one if its rules or passwords drifted. It returns
+// the ID of the access list.
+func (n *Client) EnsureAccessList(accessList AccessList) (id int, created, updated bool, err error) {
+	accessList.Meta.ManagedBy = MANAGED_BY
+
+	existingAccessLists, err := n.getAccessLists()
+	if err != nil {
+		return 0, false, false, err
+	}
+
+	for _, existingAccessList := range existingAccessLists {
+		if existingAccessList.Name != accessList.Name {
+			continue
+		}
+
+		existing := AccessList{
+			Clients:    existingAccessList.Clients,
+			Items:      existingAccessList.Items,
+			Name:       existingAccessList.Name,
+			PassAuth:   existingAccessList.PassAuth,
+			SatisfyAny: existingAccessList.SatisfyAny,
+		}
+		if accessListMatches(existing, accessList) && !n.accessListCredentials.changed(accessList.Name, accessList.Items) {
+			return existingAccessList.ID, false, false, nil
+		}
+
+		log.Info("Updating access list", "id", existingAccessList.ID, "name", accessList.Name)
+		url := fmt.Sprintf("%v/nginx/access-lists/%v", n.baseURL, existingAccessList.ID)
+		if err := n.sendJSON(http.MethodPut, url, accessList); err != nil {
+			return 0, false, false, err
+		}
+		n.accessListCredentials.written(accessList.Name, accessList.Items)
+		return existingAccessList.ID, false, true, nil
+	}
+
+	log.Info("Creating access list", "name", accessList.Name)
+
+	payloadBytes, err := json.Marshal(accessList)
+	if err != nil {
+		return 0, false, false, err
+	}
+
+	payloadString := string(payloadBytes)
+	resp, statusCode, err := n.makeRequest(http.MethodPost, n.baseURL+"/nginx/access-lists", &payloadString)
+	if err != nil {
+		return 0, false, false, err
+	}
+	if statusCode >= 400 {
+		return 0, false, false, parseErrorResponse(resp)
+	}
+
+	var createdAccessList struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(resp), &createdAccessList); err != nil {
+		return 0, false, false, err
+	}
+	n.accessListCredentials.written(accessList.Name, accessList.Items)
+	return createdAccessList.ID, true, false, nil
+}
+
+// detachableAccessList reports whether the access list of the proxy host may be
+// detached from it, which is only the case if PlugNPiN created it or the labels
+// of a container attached it. Access lists attached in the NPM UI are kept.
+func (n *Client) detachableAccessList(proxyHost ProxyHostReply) (bool, error) {
+	if n.attachedAccessLists.get(proxyHost.ID) == proxyHost.AccessListID {
+		return true, nil
+	}
+
+	existingAccessLists, err := n.getAccessLists()
+	if err != nil {
+		return false, err
+	}
+	for _, existingAccessList := range existingAccessLists {
+		if existingAccessList.ID == proxyHost.AccessListID {
+			return existingAccessList.Meta.Managed() || strings.HasPrefix(existingAccessList.Name, ACCESS_LIST_NAME_PREFIX), nil
+		}
+	}
+	return false, nil
+}
+
+// DeleteAccessListIfUnused deletes the access list with the given name once no
+// proxy host references it any more.
+func (n *Client) DeleteAccessListIfUnused(name string) (bool, error) {
+	existingAccessLists, err := n.getAccessLists()
+	if err != nil {
+		return false, err
+	}
+
+	for _, existingAccessList := range existingAccessLists {
+		if existingAccessList.Name != name {
+			continue
+		}
+
+		if existingAccessList.ProxyHostCount > 0 {
+			log.Debug("Access list is still in use, not deleting it", "name", name, "proxyHostCount", existingAccessList.ProxyHostCount)
+			return false, nil
+		}
+
+		url := fmt.Sprintf("%v/nginx/access-lists/%v", n.baseURL, existingAccessList.ID)
+		resp, statusCode, err := n.makeRequest(http.MethodDelete, url, nil)
+		if err != nil {
+			return false, err
+		}
+		if statusCode >= 400 {
+			return false, parseErrorResponse(resp)
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
@@ -0,0 +1,165 @@
+//go:build unit
+
+package npm
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnsureAccessList(t *testing.T) {
+	accessList := AccessList{
+		Clients: []AccessListClient{
+			{Address: "192.168.0.0/16", Directive: "allow"},
+			{Address: "192.168.1.66", Directive: "deny"},
+		},
+		Items: []AccessListItem{{Username: "alice", Password: "secret"}},
+		Name:  "plugNPiN-app.home.lan",
+	}
+
+	t.Run("create when missing", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/nginx/access-lists", r.URL.Path)
+
+			if r.Method == http.MethodGet {
+				_, _ = w.Write([]byte(`[{"id": 1, "name": "other"}]`))
+				return
+			}
+
+			assert.Equal(t, http.MethodPost, r.Method)
+			var received AccessList
+			err := json.NewDecoder(r.Body).Decode(&received)
+			assert.NoError(t, err)
+			expectedAccessList := accessList
+			expectedAccessList.Meta = managedMeta
+			assert.Equal(t, expectedAccessList, received)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id": 3}`))
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		id, created, updated, err := client.EnsureAccessList(accessList)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, id)
+		assert.True(t, created)
+		assert.False(t, updated)
+	})
+
+	t.Run("no update when only the order differs", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			_, _ = w.Write([]byte(`[{"id": 3, "name": "plugNPiN-app.home.lan", "items": [{"username": "alice", "password": ""}], "clients": [{"address": "192.168.1.66", "directive": "deny"}, {"address": "192.168.0.0/16", "directive": "allow"}]}]`))
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+		// The passwords were written before
+		client.accessListCredentials.written(accessList.Name, accessList.Items)
+
+		id, created, updated, err := client.EnsureAccessList(accessList)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, id)
+		assert.False(t, created)
+		assert.False(t, updated)
+	})
+
+	t.Run("update once after start and when a password changed", func(t *testing.T) {
+		putPasswords := []string{}
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				_, _ = w.Write([]byte(`[{"id": 3, "name": "plugNPiN-app.home.lan", "items": [{"username": "alice", "password": ""}], "clients": [{"address": "192.168.1.66", "directive": "deny"}, {"address": "192.168.0.0/16", "directive": "allow"}]}]`))
+				return
+			}
+
+			assert.Equal(t, http.MethodPut, r.Method)
+			var received AccessList
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+			putPasswords = append(putPasswords, received.Items[0].Password)
+			w.WriteHeader(http.StatusOK)
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		rotatedAccessList := accessList
+		rotatedAccessList.Items = []AccessListItem{{Username: "alice", Password: "rotated"}}
+		for _, expectedUpdated := range []bool{true, false} {
+			_, _, updated, err := client.EnsureAccessList(accessList)
+			assert.NoError(t, err)
+			assert.Equal(t, expectedUpdated, updated)
+		}
+		_, _, updated, err := client.EnsureAccessList(rotatedAccessList)
+		assert.NoError(t, err)
+		assert.True(t, updated)
+		assert.Equal(t, []string{"secret", "rotated"}, putPasswords)
+	})
+
+	t.Run("update when rules drifted", func(t *testing.T) {
+		putCalled := false
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				_, _ = w.Write([]byte(`[{"id": 3, "name": "plugNPiN-app.home.lan", "satisfy_any": true, "items": [], "clients": []}]`))
+				return
+			}
+
+			putCalled = true
+			assert.Equal(t, http.MethodPut, r.Method)
+			assert.Equal(t, "/api/nginx/access-lists/3", r.URL.Path)
+			w.WriteHeader(http.StatusOK)
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		id, created, updated, err := client.EnsureAccessList(accessList)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, id)
+		assert.False(t, created)
+		assert.True(t, updated)
+		assert.True(t, putCalled, "PUT was not called")
+	})
+}
+
+func TestDeleteAccessListIfUnused(t *testing.T) {
+	t.Run("delete unused access list", func(t *testing.T) {
+		deleteCalled := false
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				_, _ = w.Write([]byte(`[{"id": 3, "name": "shared", "proxy_host_count": 0}]`))
+				return
+			}
+
+			deleteCalled = true
+			assert.Equal(t, http.MethodDelete, r.Method)
+			assert.Equal(t, "/api/nginx/access-lists/3", r.URL.Path)
+			w.WriteHeader(http.StatusOK)
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		deleted, err := client.DeleteAccessListIfUnused("shared")
+		assert.NoError(t, err)
+		assert.True(t, deleted)
+		assert.True(t, deleteCalled, "DELETE was not called")
+	})
+
+	t.Run("keep access list that is still in use", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			_, _ = w.Write([]byte(`[{"id": 3, "name": "shared", "proxy_host_count": 1}]`))
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		deleted, err := client.DeleteAccessListIfUnused("shared")
+		assert.NoError(t, err)
+		assert.False(t, deleted)
+	})
+}
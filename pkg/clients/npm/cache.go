@@ -0,0 +1,114 @@
+package npm
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deepspace2/plugnpin/pkg/metrics"
+)
+
+const DEFAULT_CACHE_TTL = 30 * time.Second
+
+type cacheEntry struct {
+	body      string
+	expiresAt time.Time
+}
+
+// responseCache caches the bodies of successful GET requests. During a
+// snapshot, entries don't expire so a whole sync works off the same lists.
+// Any request that mutates NPM invalidates the whole cache, since e.g.
+// deleting a proxy host also changes the proxy host count of access lists.
+type responseCache struct {
+	entries map[string]cacheEntry
+	// generation is bumped on every invalidation, so responses of requests
+	// that raced with a mutation are not cached
+	generation int
+	mu         sync.Mutex
+	snapshot   bool
+	ttl        time.Duration
+}
+
+func newResponseCache(ttl time.Duration) *responseCache {
+	return &responseCache{
+		entries: map[string]cacheEntry{},
+		ttl:     ttl,
+	}
+}
+
+func (c *responseCache) get(url string) (body string, hit bool, generation int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, exists := c.entries[url]
+	if !exists || (!c.snapshot && time.Now().After(entry.expiresAt)) {
+		return "", false, c.generation
+	}
+	return entry.body, true, c.generation
+}
+
+func (c *responseCache) set(url, body string, generation int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation || (c.ttl <= 0 && !c.snapshot) {
+		return
+	}
+	c.entries[url] = cacheEntry{body: body, expiresAt: time.Now().Add(c.ttl)}
+}
+
+func (c *responseCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+	c.generation++
+}
+
+// SetCacheTTL sets how long GET responses are cached for. A TTL of 0 disables
+// caching outside of snapshots.
+func (n *Client) SetCacheTTL(ttl time.Duration) {
+	n.cache.mu.Lock()
+	defer n.cache.mu.Unlock()
+	n.cache.ttl = ttl
+}
+
+// BeginSnapshot makes cached responses valid until EndSnapshot, regardless of
+// their TTL. Mutations still invalidate the cache.
+func (n *Client) BeginSnapshot() {
+	n.cache.invalidate()
+
+	n.cache.mu.Lock()
+	defer n.cache.mu.Unlock()
+	n.cache.snapshot = true
+}
+
+func (n *Client) EndSnapshot() {
+	n.cache.invalidate()
+
+	n.cache.mu.Lock()
+	defer n.cache.mu.Unlock()
+	n.cache.snapshot = false
+}
+
+func cacheResource(url string) string {
+	path, _, _ := strings.Cut(url, "?")
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+func (n *Client) cachedGet(url string) (string, int, error) {
+	resource := cacheResource(url)
+
+	body, hit, generation := n.cache.get(url)
+	if hit {
+		metrics.IncrementNpmCacheHits(resource)
+		return body, http.StatusOK, nil
+	}
+	metrics.IncrementNpmCacheMisses(resource)
+
+	resp, statusCode, err := n.makeRequest(http.MethodGet, url, nil)
+	if err == nil && statusCode < 400 {
+		n.cache.set(url, resp, generation)
+	}
+	return resp, statusCode, err
+}
@@ -0,0 +1,91 @@
+//go:build unit
+
+package npm
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachedGet(t *testing.T) {
+	newCountingServer := func(getCalls *int) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				*getCalls++
+				_, _ = w.Write([]byte(`[{"id": 1, "domain_names": ["one.com"]}]`))
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		})
+	}
+
+	t.Run("hit within ttl", func(t *testing.T) {
+		getCalls := 0
+		client, closeServer := setupAuthorizedTestServer(newCountingServer(&getCalls))
+		defer closeServer()
+
+		for range 3 {
+			_, err := client.GetProxyHosts()
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, 1, getCalls)
+	})
+
+	t.Run("miss after ttl", func(t *testing.T) {
+		getCalls := 0
+		client, closeServer := setupAuthorizedTestServer(newCountingServer(&getCalls))
+		defer closeServer()
+		client.SetCacheTTL(time.Nanosecond)
+
+		_, _ = client.GetProxyHosts()
+		time.Sleep(time.Millisecond)
+		_, _ = client.GetProxyHosts()
+		assert.Equal(t, 2, getCalls)
+	})
+
+	t.Run("disabled with zero ttl", func(t *testing.T) {
+		getCalls := 0
+		client, closeServer := setupAuthorizedTestServer(newCountingServer(&getCalls))
+		defer closeServer()
+		client.SetCacheTTL(0)
+
+		_, _ = client.GetProxyHosts()
+		_, _ = client.GetProxyHosts()
+		assert.Equal(t, 2, getCalls)
+	})
+
+	t.Run("snapshot ignores ttl", func(t *testing.T) {
+		getCalls := 0
+		client, closeServer := setupAuthorizedTestServer(newCountingServer(&getCalls))
+		defer closeServer()
+		client.SetCacheTTL(0)
+
+		client.BeginSnapshot()
+		_, _ = client.GetProxyHosts()
+		_, _ = client.GetProxyHosts()
+		client.EndSnapshot()
+		_, _ = client.GetProxyHosts()
+		assert.Equal(t, 2, getCalls)
+	})
+
+	t.Run("invalidated by mutations", func(t *testing.T) {
+		getCalls := 0
+		client, closeServer := setupAuthorizedTestServer(newCountingServer(&getCalls))
+		defer closeServer()
+
+		_, _ = client.GetProxyHosts()
+		_, _, err := client.DeleteProxyHosts([]string{"one.com"})
+		assert.NoError(t, err)
+		_, _ = client.GetProxyHosts()
+		// DeleteProxyHosts itself is served from the cache
+		assert.Equal(t, 2, getCalls)
+	})
+}
+
+func TestCacheResource(t *testing.T) {
+	assert.Equal(t, "proxy-hosts", cacheResource("http://npm/api/nginx/proxy-hosts"))
+	assert.Equal(t, "access-lists", cacheResource("http://npm/api/nginx/access-lists?expand=items,clients"))
+}
@@ -0,0 +1,183 @@
+package npm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	CERTIFICATE_NAME_AUTO = "auto"
+
+	minCertificateRequestBackoff = time.Minute
+	maxCertificateRequestBackoff = 24 * time.Hour
+)
+
+var errMissingLetsencryptEmail = errors.New("a Let's Encrypt email is required to request certificates")
+
+type CertificateRequestOptions struct {
+	DNSChallengeCredentials string
+	DNSChallengeProvider    string
+	LetsencryptEmail        string
+	PropagationSeconds      int
+}
+
+type certificateRequestFailure struct {
+	attempts    int
+	nextAttempt time.Time
+}
+
+// certificateRequests keeps track of failed certificate requests, so issuance
+// is retried with an exponential backoff instead of on every sync.
+type certificateRequests struct {
+	failures map[string]*certificateRequestFailure
+	mu       sync.Mutex
+	options  CertificateRequestOptions
+}
+
+func (n *Client) SetCertificateRequestOptions(options CertificateRequestOptions) {
+	n.certificateRequests.mu.Lock()
+	defer n.certificateRequests.mu.Unlock()
+	n.certificateRequests.options = options
+}
+
+// domainCoveredBy reports whether certificateDomain, which may be a wildcard,
+// covers domain. Like in TLS, a wildcard only covers a single label.
+func domainCoveredBy(domain, certificateDomain string) bool {
+	domain = strings.ToLower(domain)
+	certificateDomain = strings.ToLower(certificateDomain)
+
+	if domain == certificateDomain {
+		return true
+	}
+
+	parentDomain, found := strings.CutPrefix(certificateDomain, "*.")
+	if !found {
+		return false
+	}
+	label, rest, found := strings.Cut(domain, ".")
+	return found && label != "" && rest == parentDomain
+}
+
+func certificateCovers(certificateDomains, domains []string) bool {
+	for _, domain := range domains {
+		covered := slices.ContainsFunc(certificateDomains, func(certificateDomain string) bool {
+			return domainCoveredBy(domain, certificateDomain)
+		})
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+func parseCertificateExpiry(expiresOn string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, time.DateTime} {
+		if t, err := time.Parse(layout, expiresOn); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown time format '%v'", expiresOn)
+}
+
+// GetOrRequestCertificate returns the ID of an existing certificate that covers
+// all domains, and otherwise requests a new Let's Encrypt certificate for them.
+// Failed requests are not retried before their backoff has passed.
+func (n *Client) GetOrRequestCertificate(domains []string) (int, error) {
+	certificates, err := n.getCertificates()
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	for _, certificate := range certificates {
+		if expiresOn, err := parseCertificateExpiry(certificate.ExpiresOn); err == nil && expiresOn.Before(now) {
+			continue
+		}
+		if certificateCovers(certificate.DomainNames, domains) {
+			return certificate.ID, nil
+		}
+	}
+
+	return n.requestCertificate(domains)
+}
+
+func (n *Client) requestCertificate(domains []string) (int, error) {
+	requests := &n.certificateRequests
+	requests.mu.Lock()
+	defer requests.mu.Unlock()
+
+	key := strings.Join(slices.Sorted(slices.Values(domains)), ",")
+	failure, failedBefore := requests.failures[key]
+	if failedBefore && time.Now().Before(failure.nextAttempt) {
+		return 0, fmt.Errorf("certificate request for %v failed %v time(s), retrying after %v", domains, failure.attempts, failure.nextAttempt.Format(time.RFC3339))
+	}
+
+	id, err := n.postCertificate(domains, requests.options)
+	if err == nil {
+		delete(requests.failures, key)
+		return id, nil
+	}
+
+	if !failedBefore {
+		failure = &certificateRequestFailure{}
+		if requests.failures == nil {
+			requests.failures = map[string]*certificateRequestFailure{}
+		}
+		requests.failures[key] = failure
+	}
+	failure.attempts++
+	backoff := min(minCertificateRequestBackoff<<(failure.attempts-1), maxCertificateRequestBackoff)
+	failure.nextAttempt = time.Now().Add(backoff)
+
+	return 0, fmt.Errorf("failed to request certificate for %v, retrying in %v: %w", domains, backoff, err)
+}
+
+func (n *Client) postCertificate(domains []string, options CertificateRequestOptions) (int, error) {
+	if options.LetsencryptEmail == "" {
+		return 0, errMissingLetsencryptEmail
+	}
+
+	request := CertificateRequest{
+		DomainNames: domains,
+		Meta: CertificateRequestMeta{
+			LetsencryptAgree: true,
+			LetsencryptEmail: options.LetsencryptEmail,
+		},
+		NiceName: domains[0],
+		Provider: "letsencrypt",
+	}
+	if options.DNSChallengeProvider != "" {
+		request.Meta.DNSChallenge = true
+		request.Meta.DNSProvider = options.DNSChallengeProvider
+		request.Meta.DNSProviderCredentials = options.DNSChallengeCredentials
+		request.Meta.PropagationSeconds = options.PropagationSeconds
+	}
+
+	log.Info("Requesting Let's Encrypt certificate", "domains", domains, "dnsChallenge", request.Meta.DNSChallenge)
+
+	payloadBytes, err := json.Marshal(request)
+	if err != nil {
+		return 0, err
+	}
+
+	payloadString := string(payloadBytes)
+	resp, statusCode, err := n.makeRequest(http.MethodPost, n.baseURL+"/nginx/certificates", &payloadString)
+	if err != nil {
+		return 0, err
+	}
+	if statusCode >= 400 {
+		return 0, parseErrorResponse(resp)
+	}
+
+	var certificate CertificateReply
+	if err := json.Unmarshal([]byte(resp), &certificate); err != nil {
+		return 0, err
+	}
+	return certificate.ID, nil
+}
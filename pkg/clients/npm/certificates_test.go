@@ -0,0 +1,138 @@
+//go:build unit
+
+package npm
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDomainCoveredBy(t *testing.T) {
+	testCases := []struct {
+		domain            string
+		certificateDomain string
+		expected          bool
+	}{
+		{domain: "app.home.lan", certificateDomain: "app.home.lan", expected: true},
+		{domain: "App.Home.lan", certificateDomain: "app.home.lan", expected: true},
+		{domain: "app.home.lan", certificateDomain: "*.home.lan", expected: true},
+		{domain: "home.lan", certificateDomain: "*.home.lan", expected: false},
+		{domain: "a.app.home.lan", certificateDomain: "*.home.lan", expected: false},
+		{domain: "app.home.lan", certificateDomain: "other.home.lan", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.domain+" "+tc.certificateDomain, func(t *testing.T) {
+			assert.Equal(t, tc.expected, domainCoveredBy(tc.domain, tc.certificateDomain))
+		})
+	}
+}
+
+func TestGetOrRequestCertificate(t *testing.T) {
+	t.Run("reuse existing wildcard certificate", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			_ = json.NewEncoder(w).Encode(Certificates{
+				{ID: 1, DomainNames: []string{"*.home.lan"}, ExpiresOn: "2000-01-01 00:00:00"},
+				{ID: 2, DomainNames: []string{"home.lan", "*.home.lan"}, ExpiresOn: time.Now().Add(time.Hour).Format(time.DateTime)},
+			})
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		id, err := client.GetOrRequestCertificate([]string{"app.home.lan", "home.lan"})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, id)
+	})
+
+	t.Run("request new certificate", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/nginx/certificates", r.URL.Path)
+
+			if r.Method == http.MethodGet {
+				_ = json.NewEncoder(w).Encode(Certificates{})
+				return
+			}
+
+			var request CertificateRequest
+			err := json.NewDecoder(r.Body).Decode(&request)
+			assert.NoError(t, err)
+			assert.Equal(t, CertificateRequest{
+				DomainNames: []string{"app.home.lan"},
+				Meta: CertificateRequestMeta{
+					DNSChallenge:           true,
+					DNSProvider:            "cloudflare",
+					DNSProviderCredentials: "dns_cloudflare_api_token = token",
+					LetsencryptAgree:       true,
+					LetsencryptEmail:       "admin@home.lan",
+				},
+				NiceName: "app.home.lan",
+				Provider: "letsencrypt",
+			}, request)
+
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(CertificateReply{ID: 9})
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+		client.SetCertificateRequestOptions(CertificateRequestOptions{
+			DNSChallengeCredentials: "dns_cloudflare_api_token = token",
+			DNSChallengeProvider:    "cloudflare",
+			LetsencryptEmail:        "admin@home.lan",
+		})
+
+		id, err := client.GetOrRequestCertificate([]string{"app.home.lan"})
+		assert.NoError(t, err)
+		assert.Equal(t, 9, id)
+	})
+
+	t.Run("back off after a failed request", func(t *testing.T) {
+		postCalls := 0
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				_ = json.NewEncoder(w).Encode(Certificates{})
+				return
+			}
+
+			postCalls++
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error": {"code": 400, "message": "Some challenges have failed"}}`))
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+		client.SetCertificateRequestOptions(CertificateRequestOptions{LetsencryptEmail: "admin@home.lan"})
+
+		_, err := client.GetOrRequestCertificate([]string{"app.home.lan"})
+		assert.ErrorContains(t, err, "Some challenges have failed")
+
+		_, err = client.GetOrRequestCertificate([]string{"app.home.lan"})
+		assert.ErrorContains(t, err, "retrying after")
+		assert.Equal(t, 1, postCalls)
+
+		client.certificateRequests.failures["app.home.lan"].nextAttempt = time.Now().Add(-time.Second)
+		_, err = client.GetOrRequestCertificate([]string{"app.home.lan"})
+		assert.Error(t, err)
+		assert.Equal(t, 2, postCalls)
+		assert.Equal(t, 2, client.certificateRequests.failures["app.home.lan"].attempts)
+	})
+
+	t.Run("missing email", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			_ = json.NewEncoder(w).Encode(Certificates{})
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		_, err := client.GetOrRequestCertificate([]string{"app.home.lan"})
+		assert.ErrorIs(t, err, errMissingLetsencryptEmail)
+	})
+}
@@ -0,0 +1,66 @@
+package npm
+
+import (
+	"fmt"
+	"time"
+)
+
+// ProxyHostHealth is the state of a proxy host as reported by NPM.
+type ProxyHostHealth struct {
+	// CertificateExpiresOn is zero if the proxy host has no certificate
+	CertificateExpiresOn time.Time
+	DomainNames          []string
+	ID                   int
+	NginxErr             string
+	NginxOnline          bool
+}
+
+func formatNginxErr(nginxErr any) string {
+	switch nginxErr := nginxErr.(type) {
+	case nil:
+		return ""
+	case string:
+		return nginxErr
+	default:
+		return fmt.Sprint(nginxErr)
+	}
+}
+
+// GetProxyHostsHealth returns the health of every proxy host that serves one
+// of domains.
+func (n *Client) GetProxyHostsHealth(domains []string) ([]ProxyHostHealth, error) {
+	existingProxyHosts, err := n.GetProxyHostReplies()
+	if err != nil {
+		return nil, err
+	}
+
+	certificates, err := n.getCertificates()
+	if err != nil {
+		return nil, err
+	}
+	certificateExpiries := map[int]time.Time{}
+	for _, certificate := range certificates {
+		expiresOn, err := parseCertificateExpiry(certificate.ExpiresOn)
+		if err != nil {
+			log.Debug("Failed to parse certificate expiry", "id", certificate.ID, "error", err)
+			continue
+		}
+		certificateExpiries[certificate.ID] = expiresOn
+	}
+
+	healths := []ProxyHostHealth{}
+	for _, existingProxyHost := range existingProxyHosts {
+		if !sharesDomain(existingProxyHost.DomainNames, domains) {
+			continue
+		}
+
+		healths = append(healths, ProxyHostHealth{
+			CertificateExpiresOn: certificateExpiries[existingProxyHost.CertificateID],
+			DomainNames:          existingProxyHost.DomainNames,
+			ID:                   existingProxyHost.ID,
+			NginxErr:             formatNginxErr(existingProxyHost.Meta.NginxErr),
+			NginxOnline:          existingProxyHost.Meta.NginxOnline,
+		})
+	}
+	return healths, nil
+}
@@ -0,0 +1,47 @@
+//go:build unit
+
+package npm
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetProxyHostsHealth(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/nginx/proxy-hosts":
+			_, _ = w.Write([]byte(`[
+				{"id": 1, "domain_names": ["a.com"], "certificate_id": 5, "meta": {"nginx_online": true, "nginx_err": null}},
+				{"id": 2, "domain_names": ["b.com", "c.com"], "meta": {"nginx_online": false, "nginx_err": "invalid directive"}},
+				{"id": 3, "domain_names": ["unrelated.com"], "meta": {"nginx_online": true}}
+			]`))
+		case "/api/nginx/certificates":
+			_, _ = w.Write([]byte(`[{"id": 5, "expires_on": "2030-01-02 03:04:05"}]`))
+		default:
+			t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	})
+
+	client, closeServer := setupAuthorizedTestServer(handler)
+	defer closeServer()
+
+	healths, err := client.GetProxyHostsHealth([]string{"a.com", "c.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, []ProxyHostHealth{
+		{
+			CertificateExpiresOn: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+			DomainNames:          []string{"a.com"},
+			ID:                   1,
+			NginxOnline:          true,
+		},
+		{
+			DomainNames: []string{"b.com", "c.com"},
+			ID:          2,
+			NginxErr:    "invalid directive",
+		},
+	}, healths)
+}
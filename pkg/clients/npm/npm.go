@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -19,13 +20,17 @@ var log = logging.GetLogger("npm")
 
 type Client struct {
 	http.Client
-	baseURL         string
-	headers         map[string]string
-	identity        string
-	secret          string
-	token           string
-	tokenExpireTime time.Time
-	mu              sync.Mutex
+	accessListCredentials accessListCredentials
+	attachedAccessLists   attachedAccessLists
+	baseURL               string
+	cache                 *responseCache
+	certificateRequests   certificateRequests
+	headers               map[string]string
+	identity              string
+	secret                string
+	token                 string
+	tokenExpireTime       time.Time
+	mu                    sync.Mutex
 }
 
 func NewClient(baseURL, identity, secret string) *Client {
@@ -34,6 +39,7 @@ func NewClient(baseURL, identity, secret string) *Client {
 			Transport: common.NewInstrumentedRoundTripper(metrics.NPM, metrics.ObserveApiRequestDuration),
 		},
 		baseURL: fmt.Sprintf("%v/api", baseURL),
+		cache:   newResponseCache(DEFAULT_CACHE_TTL),
 		headers: map[string]string{
 			"content-type": "application/json",
 		},
@@ -97,19 +103,27 @@ func (n *Client) GetIP() string {
 	return url.Hostname()
 }
 
-func (n *Client) GetProxyHosts() (map[string]int, error) {
-	proxyHostsString, statusCode, err := n.makeRequest(http.MethodGet, n.baseURL+"/nginx/proxy-hosts", nil)
+func (n *Client) GetProxyHostReplies() ([]ProxyHostReply, error) {
+	proxyHostsString, statusCode, err := n.cachedGet(n.baseURL + "/nginx/proxy-hosts")
 	if err != nil || statusCode >= 400 {
 		return nil, err
 	}
 
 	var proxyHosts []ProxyHostReply
-	existingProxyHostsMap := map[string]int{}
 	err = json.Unmarshal([]byte(proxyHostsString), &proxyHosts)
 	if err != nil {
 		return nil, err
 	}
+	return proxyHosts, nil
+}
 
+func (n *Client) GetProxyHosts() (map[string]int, error) {
+	proxyHosts, err := n.GetProxyHostReplies()
+	if err != nil {
+		return nil, err
+	}
+
+	existingProxyHostsMap := map[string]int{}
 	for _, host := range proxyHosts {
 		for _, domainName := range host.DomainNames {
 			existingProxyHostsMap[domainName] = host.ID
@@ -140,6 +154,8 @@ func (n *Client) makeRequest(method, url string, payload *string) (string, int,
 			return common.Get(&n.Client, url, n.headers)
 		case http.MethodPost:
 			return common.Post(&n.Client, url, n.headers, payload)
+		case http.MethodPut:
+			return common.Put(&n.Client, url, n.headers, payload)
 		case http.MethodDelete:
 			return common.Delete(&n.Client, url, n.headers)
 		default:
@@ -148,6 +164,9 @@ func (n *Client) makeRequest(method, url string, payload *string) (string, int,
 	}
 
 	resp, statusCode, err := doRequest()
+	if method != http.MethodGet {
+		defer n.cache.invalidate()
+	}
 
 	var errorResponse ErrorResponse
 	_ = json.Unmarshal([]byte(resp), &errorResponse)
@@ -165,7 +184,7 @@ func (n *Client) makeRequest(method, url string, payload *string) (string, int,
 }
 
 func (n *Client) getCertificates() (Certificates, error) {
-	resp, statusCode, err := n.makeRequest(http.MethodGet, n.baseURL+"/nginx/certificates", nil)
+	resp, statusCode, err := n.cachedGet(n.baseURL + "/nginx/certificates")
 	if err != nil || statusCode >= 400 {
 		return nil, err
 	}
@@ -193,7 +212,7 @@ func (n *Client) GetCertificateIDByName(name string) (int, error) {
 }
 
 func (n *Client) getAccessLists() (AccessLists, error) {
-	resp, statusCode, err := n.makeRequest(http.MethodGet, n.baseURL+"/nginx/access-lists", nil)
+	resp, statusCode, err := n.cachedGet(n.baseURL + "/nginx/access-lists?expand=items,clients")
 	if err != nil || statusCode >= 400 {
 		return nil, err
 	}
@@ -220,72 +239,221 @@ func (n *Client) GetAccessListIDByName(name string) (int, error) {
 	return 0, fmt.Errorf("access list with name %q does not exist", name)
 }
 
-func (n *Client) AddProxyHost(host ProxyHost) (bool, error) {
-	existingProxyHosts, err := n.GetProxyHosts()
+// AddProxyHost creates the proxy host if none of its domains have one yet.
+// Otherwise, the custom locations of the existing proxy host are reconciled
+// with the ones of host unless host has none, its access list is replaced by
+// the one of host, or detached if PlugNPiN attached it, and its certificate
+// and advanced config are set if host has them.
+func (n *Client) AddProxyHost(host ProxyHost) (added, updated bool, err error) {
+	existingProxyHosts, err := n.GetProxyHostReplies()
 	if err != nil {
-		return false, err
+		return false, false, err
 	}
-	for _, domainName := range host.DomainNames {
-		if _, exists := existingProxyHosts[domainName]; exists {
-			return false, nil
+	for _, existingProxyHost := range existingProxyHosts {
+		for _, domainName := range host.DomainNames {
+			if slices.Contains(existingProxyHost.DomainNames, domainName) {
+				updated, err := n.reconcileProxyHost(existingProxyHost, host)
+				return false, updated, err
+			}
 		}
 	}
 
-	payloadBytes, err := json.Marshal(host)
-	if err != nil {
+	if host.Locations == nil {
+		host.Locations = []Location{}
+	}
+	if err := n.sendJSON(http.MethodPost, n.baseURL+"/nginx/proxy-hosts", host); err != nil {
+		return false, false, err
+	}
+	return true, false, nil
+}
+
+func CompareLocations(a, b Location) int {
+	return strings.Compare(a.Path, b.Path)
+}
+
+func (n *Client) reconcileProxyHost(existingProxyHost ProxyHostReply, host ProxyHost) (bool, error) {
+	payload := updateProxyHostPayload{}
+
+	// Proxy hosts of running containers are always enabled, e.g. after having
+	// been disabled when the container stopped
+	enabled := false
+	if !existingProxyHost.Enabled {
+		if err := n.setProxyHostEnabled(existingProxyHost.ID, true); err != nil {
+			return false, err
+		}
+		enabled = true
+	}
+
+	needsUpdate := false
+	// Without location labels, locations added in the NPM UI are kept
+	if host.Locations != nil {
+		existingLocations := slices.Clone(existingProxyHost.Locations)
+		slices.SortFunc(existingLocations, CompareLocations)
+		if !slices.Equal(existingLocations, host.Locations) {
+			payload.Locations = &host.Locations
+			needsUpdate = true
+		}
+	}
+	if host.AccessListID != existingProxyHost.AccessListID {
+		detachable := true
+		if host.AccessListID == 0 {
+			var err error
+			detachable, err = n.detachableAccessList(existingProxyHost)
+			if err != nil {
+				return false, err
+			}
+		}
+		if detachable {
+			payload.AccessListID = &host.AccessListID
+			needsUpdate = true
+		} else {
+			log.Debug("Proxy host has an access list PlugNPiN didn't attach, keeping it", "id", existingProxyHost.ID, "accessListId", existingProxyHost.AccessListID)
+		}
+	}
+	if host.CertificateID != 0 && host.CertificateID != existingProxyHost.CertificateID {
+		payload.CertificateID = &host.CertificateID
+		needsUpdate = true
+	}
+	// An empty advanced config is left alone, so manual edits are not lost
+	if host.AdvancedConfig != "" && host.AdvancedConfig != existingProxyHost.AdvancedConfig {
+		payload.AdvancedConfig = &host.AdvancedConfig
+		needsUpdate = true
+	}
+
+	if !needsUpdate {
+		n.attachedAccessLists.set(existingProxyHost.ID, host.AccessListID)
+		return enabled, nil
+	}
+
+	log.Info("Updating proxy host", "id", existingProxyHost.ID, "domains", existingProxyHost.DomainNames, "locations", len(host.Locations), "certificateId", host.CertificateID, "accessListId", host.AccessListID)
+
+	url := fmt.Sprintf("%v/nginx/proxy-hosts/%v", n.baseURL, existingProxyHost.ID)
+	if err := n.sendJSON(http.MethodPut, url, payload); err != nil {
 		return false, err
 	}
+	n.attachedAccessLists.set(existingProxyHost.ID, host.AccessListID)
+	return true, nil
+}
+
+// sendJSON sends payload as JSON and turns an error status into an error.
+func (n *Client) sendJSON(method, url string, payload any) error {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
 
 	payloadString := string(payloadBytes)
-	resp, statusCode, err := n.makeRequest(http.MethodPost, n.baseURL+"/nginx/proxy-hosts", &payloadString)
+	resp, statusCode, err := n.makeRequest(method, url, &payloadString)
 	if err != nil {
-		return false, err
+		return err
 	}
 
 	if statusCode >= 400 {
-		var errorResponse ErrorResponse
-		err = json.Unmarshal([]byte(resp), &errorResponse)
-		if err != nil {
-			return false, err
-		}
-		return false, errors.New(errorResponse.Error.Message)
+		return parseErrorResponse(resp)
 	}
-	return true, nil
+	return nil
+}
+
+func parseErrorResponse(resp string) error {
+	var errorResponse ErrorResponse
+	if err := json.Unmarshal([]byte(resp), &errorResponse); err != nil {
+		return err
+	}
+	return errors.New(errorResponse.Error.Message)
 }
 
-func (n *Client) DeleteProxyHosts(domains []string) (bool, error) {
-	existingProxyHosts, err := n.GetProxyHosts()
+// DeleteProxyHosts deletes every proxy host whose domains all belong to
+// domains. Proxy hosts that also serve other domains are not deleted, since
+// they were likely set up by hand; only domains are removed from them, and
+// each of them is reported.
+func (n *Client) DeleteProxyHosts(domains []string) (numOfDeletedProxyHosts, numOfUpdatedProxyHosts int, err error) {
+	existingProxyHosts, err := n.GetProxyHostReplies()
 	if err != nil {
-		return false, err
+		return 0, 0, err
 	}
 
-	var hostID int
-	found := false
-	for _, domain := range domains {
-		if id, exists := existingProxyHosts[domain]; exists {
-			hostID = id
-			found = true
-			break
+	for _, existingProxyHost := range existingProxyHosts {
+		remainingDomains := slices.DeleteFunc(slices.Clone(existingProxyHost.DomainNames), func(domain string) bool {
+			return slices.Contains(domains, domain)
+		})
+		if len(remainingDomains) == len(existingProxyHost.DomainNames) {
+			continue
+		}
+
+		url := fmt.Sprintf("%v/nginx/proxy-hosts/%v", n.baseURL, existingProxyHost.ID)
+
+		if len(remainingDomains) > 0 {
+			log.Warn("Proxy host also serves domains of other origin, removing only the container's domains from it",
+				"id", existingProxyHost.ID, "domains", existingProxyHost.DomainNames, "remainingDomains", remainingDomains)
+			if err := n.sendJSON(http.MethodPut, url, updateDomainNamesPayload{DomainNames: remainingDomains}); err != nil {
+				return numOfDeletedProxyHosts, numOfUpdatedProxyHosts, err
+			}
+			numOfUpdatedProxyHosts++
+			continue
 		}
-	}
 
-	if !found {
-		return false, nil
+		resp, statusCode, err := n.makeRequest(http.MethodDelete, url, nil)
+		if err != nil {
+			return numOfDeletedProxyHosts, numOfUpdatedProxyHosts, err
+		}
+		if statusCode >= 400 {
+			return numOfDeletedProxyHosts, numOfUpdatedProxyHosts, parseErrorResponse(resp)
+		}
+		numOfDeletedProxyHosts++
 	}
 
-	url := fmt.Sprintf("%v/nginx/proxy-hosts/%v", n.baseURL, hostID)
-	resp, statusCode, err := n.makeRequest(http.MethodDelete, url, nil)
+	return numOfDeletedProxyHosts, numOfUpdatedProxyHosts, nil
+}
+
+// DisableProxyHosts disables the enabled proxy hosts whose domains all belong
+// to domains, keeping them (and any manual edits) around for when they are
+// enabled again.
+func (n *Client) DisableProxyHosts(domains []string) (numOfDisabledProxyHosts int, err error) {
+	existingProxyHosts, err := n.GetProxyHostReplies()
 	if err != nil {
-		return false, err
+		return 0, err
 	}
 
-	if statusCode >= 400 {
-		var errorResponse ErrorResponse
-		err = json.Unmarshal([]byte(resp), &errorResponse)
-		if err != nil {
-			return false, err
+	for _, existingProxyHost := range existingProxyHosts {
+		if !existingProxyHost.Enabled || !sharesDomain(existingProxyHost.DomainNames, domains) {
+			continue
 		}
-		return false, errors.New(errorResponse.Error.Message)
+		if !isSubset(existingProxyHost.DomainNames, domains) {
+			log.Warn("Proxy host also serves domains of other origin, not disabling it", "id", existingProxyHost.ID, "domains", existingProxyHost.DomainNames)
+			continue
+		}
+
+		if err := n.setProxyHostEnabled(existingProxyHost.ID, false); err != nil {
+			return numOfDisabledProxyHosts, err
+		}
+		numOfDisabledProxyHosts++
 	}
-	return true, nil
+
+	return numOfDisabledProxyHosts, nil
+}
+
+func isSubset(a, b []string) bool {
+	for _, element := range a {
+		if !slices.Contains(b, element) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *Client) setProxyHostEnabled(id int, enabled bool) error {
+	return n.setEnabled("proxy-hosts", id, enabled)
+}
+
+// setEnabled enables or disables the proxy host, redirection host or stream
+// with the given id, depending on resource.
+func (n *Client) setEnabled(resource string, id int, enabled bool) error {
+	action := "disable"
+	if enabled {
+		action = "enable"
+	}
+	log.Info("Setting state", "resource", resource, "id", id, "action", action)
+
+	url := fmt.Sprintf("%v/nginx/%v/%v/%v", n.baseURL, resource, id, action)
+	return n.sendJSON(http.MethodPost, url, struct{}{})
 }
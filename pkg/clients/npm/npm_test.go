@@ -68,6 +68,7 @@ func TestAddProxyHost(t *testing.T) {
 				err := json.NewDecoder(r.Body).Decode(&receivedHost)
 				assert.NoError(t, err)
 				assert.Equal(t, []string{"new-host.com"}, receivedHost.DomainNames)
+				assert.Equal(t, []Location{}, receivedHost.Locations)
 
 				w.WriteHeader(http.StatusCreated)
 				return
@@ -83,7 +84,7 @@ func TestAddProxyHost(t *testing.T) {
 		hostToAdd := ProxyHost{
 			DomainNames: []string{"new-host.com"},
 		}
-		_, err := client.AddProxyHost(hostToAdd)
+		_, _, err := client.AddProxyHost(hostToAdd)
 		assert.NoError(t, err)
 	})
 
@@ -94,7 +95,7 @@ func TestAddProxyHost(t *testing.T) {
 			assert.Equal(t, http.MethodGet, r.Method)
 
 			existingHosts := []ProxyHostReply{
-				{ID: 123, DomainNames: []string{"existing-host.com"}},
+				{ID: 123, DomainNames: []string{"existing-host.com"}, Enabled: true},
 			}
 			w.WriteHeader(http.StatusOK)
 			_ = json.NewEncoder(w).Encode(existingHosts)
@@ -110,16 +111,263 @@ func TestAddProxyHost(t *testing.T) {
 		hostToAdd := ProxyHost{
 			DomainNames: []string{"existing-host.com"},
 		}
-		_, err := client.AddProxyHost(hostToAdd)
+		_, _, err := client.AddProxyHost(hostToAdd)
 		// Expect no error, and no POST call would have been made.
 		assert.NoError(t, err)
 	})
+
+	t.Run("update locations of existing host", func(t *testing.T) {
+		putCalled := false
+		locations := []Location{
+			{Path: "/api", ForwardHost: "192.168.1.10", ForwardPort: 9000, ForwardScheme: "http"},
+		}
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				assert.Equal(t, "/api/nginx/proxy-hosts", r.URL.Path)
+				existingHosts := []ProxyHostReply{
+					{ID: 123, DomainNames: []string{"existing-host.com"}, Enabled: true},
+				}
+				w.WriteHeader(http.StatusOK)
+				_ = json.NewEncoder(w).Encode(existingHosts)
+				return
+			}
+
+			if r.Method == http.MethodPut {
+				putCalled = true
+				assert.Equal(t, "/api/nginx/proxy-hosts/123", r.URL.Path)
+				var payload updateProxyHostPayload
+				err := json.NewDecoder(r.Body).Decode(&payload)
+				assert.NoError(t, err)
+				if assert.NotNil(t, payload.Locations) {
+					assert.Equal(t, locations, *payload.Locations)
+				}
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+		})
+
+		client, server := setupTestServer(handler)
+		client.token = testToken // Pre-authorize client
+		client.tokenExpireTime = time.Now().Add(24 * time.Hour)
+		client.headers["authorization"] = "Bearer " + testToken
+		defer server.Close()
+
+		hostToAdd := ProxyHost{
+			DomainNames: []string{"existing-host.com"},
+			Locations:   locations,
+		}
+		added, updated, err := client.AddProxyHost(hostToAdd)
+		assert.NoError(t, err)
+		assert.False(t, added)
+		assert.True(t, updated)
+		assert.True(t, putCalled, "PUT was not called")
+	})
+
+	t.Run("keep locations of existing host without location labels", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			_ = json.NewEncoder(w).Encode([]ProxyHostReply{{ID: 123, DomainNames: []string{"existing-host.com"}, Enabled: true, Locations: []Location{
+				{Path: "/manual", ForwardHost: "manual", ForwardPort: 1, ForwardScheme: "http"},
+			}}})
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		added, updated, err := client.AddProxyHost(ProxyHost{DomainNames: []string{"existing-host.com"}})
+		assert.NoError(t, err)
+		assert.False(t, added)
+		assert.False(t, updated)
+	})
+
+	t.Run("attach certificate to existing host", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				_ = json.NewEncoder(w).Encode([]ProxyHostReply{{ID: 123, DomainNames: []string{"existing-host.com"}, Enabled: true}})
+				return
+			}
+
+			assert.Equal(t, http.MethodPut, r.Method)
+			var payload updateProxyHostPayload
+			err := json.NewDecoder(r.Body).Decode(&payload)
+			assert.NoError(t, err)
+			if assert.NotNil(t, payload.CertificateID) {
+				assert.Equal(t, 5, *payload.CertificateID)
+			}
+			w.WriteHeader(http.StatusOK)
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		added, updated, err := client.AddProxyHost(ProxyHost{DomainNames: []string{"existing-host.com"}, CertificateID: 5})
+		assert.NoError(t, err)
+		assert.False(t, added)
+		assert.True(t, updated)
+	})
+
+	t.Run("attach and detach access list of existing host", func(t *testing.T) {
+		for _, tc := range []struct {
+			existingAccessListID int
+			accessListID         int
+		}{
+			{existingAccessListID: 0, accessListID: 3},
+			{existingAccessListID: 4, accessListID: 3},
+			{existingAccessListID: 3, accessListID: 0},
+		} {
+			putCalled := false
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet && r.URL.Path == "/api/nginx/access-lists" {
+					_ = json.NewEncoder(w).Encode(AccessLists{{ID: 3, Name: "shared", Meta: managedMeta}})
+					return
+				}
+				if r.Method == http.MethodGet {
+					_ = json.NewEncoder(w).Encode([]ProxyHostReply{{ID: 123, DomainNames: []string{"existing-host.com"}, Enabled: true, AccessListID: tc.existingAccessListID}})
+					return
+				}
+
+				putCalled = true
+				assert.Equal(t, http.MethodPut, r.Method)
+				var payload updateProxyHostPayload
+				err := json.NewDecoder(r.Body).Decode(&payload)
+				assert.NoError(t, err)
+				if assert.NotNil(t, payload.AccessListID) {
+					assert.Equal(t, tc.accessListID, *payload.AccessListID)
+				}
+				w.WriteHeader(http.StatusOK)
+			})
+
+			client, closeServer := setupAuthorizedTestServer(handler)
+
+			added, updated, err := client.AddProxyHost(ProxyHost{DomainNames: []string{"existing-host.com"}, AccessListID: tc.accessListID})
+			assert.NoError(t, err)
+			assert.False(t, added)
+			assert.True(t, updated)
+			assert.True(t, putCalled, "PUT was not called")
+			closeServer()
+		}
+	})
+
+	t.Run("keep access list attached in the NPM UI", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			if r.URL.Path == "/api/nginx/access-lists" {
+				_ = json.NewEncoder(w).Encode(AccessLists{{ID: 4, Name: "admins"}})
+				return
+			}
+			_ = json.NewEncoder(w).Encode([]ProxyHostReply{{ID: 123, DomainNames: []string{"existing-host.com"}, Enabled: true, AccessListID: 4}})
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		added, updated, err := client.AddProxyHost(ProxyHost{DomainNames: []string{"existing-host.com"}})
+		assert.NoError(t, err)
+		assert.False(t, added)
+		assert.False(t, updated)
+	})
+
+	t.Run("detach access list attached by labels once they are removed", func(t *testing.T) {
+		existingAccessListID := 0
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet && r.URL.Path == "/api/nginx/access-lists" {
+				_ = json.NewEncoder(w).Encode(AccessLists{{ID: 4, Name: "admins"}})
+				return
+			}
+			if r.Method == http.MethodGet {
+				_ = json.NewEncoder(w).Encode([]ProxyHostReply{{ID: 123, DomainNames: []string{"existing-host.com"}, Enabled: true, AccessListID: existingAccessListID}})
+				return
+			}
+
+			assert.Equal(t, http.MethodPut, r.Method)
+			var payload updateProxyHostPayload
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			if assert.NotNil(t, payload.AccessListID) {
+				existingAccessListID = *payload.AccessListID
+			}
+			w.WriteHeader(http.StatusOK)
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		// 'plugNPiN.npmOptions.accessListName=admins'
+		_, updated, err := client.AddProxyHost(ProxyHost{DomainNames: []string{"existing-host.com"}, AccessListID: 4})
+		assert.NoError(t, err)
+		assert.True(t, updated)
+		assert.Equal(t, 4, existingAccessListID)
+
+		// The label was removed
+		_, updated, err = client.AddProxyHost(ProxyHost{DomainNames: []string{"existing-host.com"}})
+		assert.NoError(t, err)
+		assert.True(t, updated)
+		assert.Equal(t, 0, existingAccessListID)
+	})
+
+	t.Run("update advanced config of existing host", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				_ = json.NewEncoder(w).Encode([]ProxyHostReply{{ID: 123, DomainNames: []string{"existing-host.com"}, Enabled: true, AdvancedConfig: "client_max_body_size 1g;"}})
+				return
+			}
+
+			assert.Equal(t, http.MethodPut, r.Method)
+			var payload updateProxyHostPayload
+			err := json.NewDecoder(r.Body).Decode(&payload)
+			assert.NoError(t, err)
+			if assert.NotNil(t, payload.AdvancedConfig) {
+				assert.Equal(t, "client_max_body_size 2g;", *payload.AdvancedConfig)
+			}
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		added, updated, err := client.AddProxyHost(ProxyHost{DomainNames: []string{"existing-host.com"}, AdvancedConfig: "client_max_body_size 2g;"})
+		assert.NoError(t, err)
+		assert.False(t, added)
+		assert.True(t, updated)
+	})
+
+	t.Run("no update when locations match", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			existingHosts := []ProxyHostReply{
+				{ID: 123, DomainNames: []string{"existing-host.com"}, Enabled: true, Locations: []Location{
+					{Path: "/b", ForwardHost: "b", ForwardPort: 2, ForwardScheme: "http"},
+					{Path: "/a", ForwardHost: "a", ForwardPort: 1, ForwardScheme: "http"},
+				}},
+			}
+			w.WriteHeader(http.StatusOK)
+			_ = json.NewEncoder(w).Encode(existingHosts)
+		})
+
+		client, server := setupTestServer(handler)
+		client.token = testToken // Pre-authorize client
+		client.tokenExpireTime = time.Now().Add(24 * time.Hour)
+		client.headers["authorization"] = "Bearer " + testToken
+		defer server.Close()
+
+		hostToAdd := ProxyHost{
+			DomainNames: []string{"existing-host.com"},
+			Locations: []Location{
+				{Path: "/a", ForwardHost: "a", ForwardPort: 1, ForwardScheme: "http"},
+				{Path: "/b", ForwardHost: "b", ForwardPort: 2, ForwardScheme: "http"},
+			},
+		}
+		added, updated, err := client.AddProxyHost(hostToAdd)
+		assert.NoError(t, err)
+		assert.False(t, added)
+		assert.False(t, updated)
+	})
 }
 
 func TestDeleteProxyHosts(t *testing.T) {
 	const testToken = "test-jwt-token"
 
-	t.Run("successful delete when host exists (multiple domains)", func(t *testing.T) {
+	t.Run("successful delete when all domains of the host are given", func(t *testing.T) {
 		deleteCalled := false
 		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("authorization")
@@ -149,9 +397,46 @@ func TestDeleteProxyHosts(t *testing.T) {
 		client.headers["authorization"] = "Bearer " + testToken
 		defer server.Close()
 
-		_, err := client.DeleteProxyHosts([]string{"host2.com"})
+		deleted, updated, err := client.DeleteProxyHosts([]string{"host1.com", "host2.com"})
 		assert.NoError(t, err)
 		assert.True(t, deleteCalled, "The DELETE endpoint was not called")
+		assert.Equal(t, 1, deleted)
+		assert.Equal(t, 0, updated)
+	})
+
+	t.Run("deletes every matching host and only removes own domains from shared hosts", func(t *testing.T) {
+		var deletedPaths []string
+		updatePayloads := map[string]updateDomainNamesPayload{}
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet:
+				existingHosts := []ProxyHostReply{
+					{ID: 1, DomainNames: []string{"a.com"}},
+					{ID: 2, DomainNames: []string{"b.com", "manual.com"}},
+					{ID: 3, DomainNames: []string{"unrelated.com"}},
+					{ID: 4, DomainNames: []string{"c.com"}},
+				}
+				_ = json.NewEncoder(w).Encode(existingHosts)
+			case http.MethodDelete:
+				deletedPaths = append(deletedPaths, r.URL.Path)
+			case http.MethodPut:
+				var payload updateDomainNamesPayload
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+				updatePayloads[r.URL.Path] = payload
+			}
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		deleted, updated, err := client.DeleteProxyHosts([]string{"a.com", "b.com", "c.com"})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, deleted)
+		assert.Equal(t, 1, updated)
+		assert.Equal(t, []string{"/api/nginx/proxy-hosts/1", "/api/nginx/proxy-hosts/4"}, deletedPaths)
+		assert.Equal(t, map[string]updateDomainNamesPayload{
+			"/api/nginx/proxy-hosts/2": {DomainNames: []string{"manual.com"}},
+		}, updatePayloads)
 	})
 
 	t.Run("no action when host does not exist", func(t *testing.T) {
@@ -173,12 +458,59 @@ func TestDeleteProxyHosts(t *testing.T) {
 		client.tokenExpireTime = time.Now().Add(24 * time.Hour)
 		defer server.Close()
 
-		_, err := client.DeleteProxyHosts([]string{"non-existing-host.com"})
+		_, _, err := client.DeleteProxyHosts([]string{"non-existing-host.com"})
 		assert.NoError(t, err)
 		assert.False(t, deleteCalled, "The DELETE endpoint was called unexpectedly")
 	})
 }
 
+func TestDisableProxyHosts(t *testing.T) {
+	var disabledPaths []string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			existingHosts := []ProxyHostReply{
+				{ID: 1, DomainNames: []string{"a.com"}, Enabled: true},
+				{ID: 2, DomainNames: []string{"b.com"}, Enabled: false},
+				{ID: 3, DomainNames: []string{"c.com", "manual.com"}, Enabled: true},
+				{ID: 4, DomainNames: []string{"unrelated.com"}, Enabled: true},
+			}
+			_ = json.NewEncoder(w).Encode(existingHosts)
+			return
+		}
+
+		assert.Equal(t, http.MethodPost, r.Method)
+		disabledPaths = append(disabledPaths, r.URL.Path)
+	})
+
+	client, closeServer := setupAuthorizedTestServer(handler)
+	defer closeServer()
+
+	disabled, err := client.DisableProxyHosts([]string{"a.com", "b.com", "c.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, disabled)
+	assert.Equal(t, []string{"/api/nginx/proxy-hosts/1/disable"}, disabledPaths)
+}
+
+func TestAddProxyHostEnablesDisabledHost(t *testing.T) {
+	var requests []string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_ = json.NewEncoder(w).Encode([]ProxyHostReply{{ID: 123, DomainNames: []string{"existing-host.com"}, Enabled: false}})
+			return
+		}
+		requests = append(requests, r.Method+" "+r.URL.Path)
+	})
+
+	client, closeServer := setupAuthorizedTestServer(handler)
+	defer closeServer()
+
+	added, updated, err := client.AddProxyHost(ProxyHost{DomainNames: []string{"existing-host.com"}})
+	assert.NoError(t, err)
+	assert.False(t, added)
+	assert.True(t, updated)
+	assert.Equal(t, []string{"POST /api/nginx/proxy-hosts/123/enable"}, requests)
+}
+
 func TestGetCertificateIDByName(t *testing.T) {
 	const testToken = "test-jwt-token"
 
@@ -0,0 +1,168 @@
+package npm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+func (n *Client) getRedirectionHosts() ([]RedirectionHostReply, error) {
+	resp, statusCode, err := n.cachedGet(n.baseURL + "/nginx/redirection-hosts")
+	if err != nil {
+		return nil, err
+	}
+	if statusCode >= 400 {
+		return nil, parseErrorResponse(resp)
+	}
+
+	var redirectionHosts []RedirectionHostReply
+	err = json.Unmarshal([]byte(resp), &redirectionHosts)
+	if err != nil {
+		return nil, err
+	}
+	return redirectionHosts, nil
+}
+
+func sharesDomain(a, b []string) bool {
+	for _, domain := range a {
+		if slices.Contains(b, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddRedirectionHost creates the redirection host, marked as managed by
+// PlugNPiN, if none of its domains have one yet. Otherwise, the existing
+// redirection host is reconciled with host, adopted and enabled, unless it
+// also redirects domains of other origin, in which case it is left alone.
+func (n *Client) AddRedirectionHost(host RedirectionHost) (added, updated bool, err error) {
+	existingRedirectionHosts, err := n.getRedirectionHosts()
+	if err != nil {
+		return false, false, err
+	}
+
+	for _, existingRedirectionHost := range existingRedirectionHosts {
+		if !sharesDomain(existingRedirectionHost.DomainNames, host.DomainNames) {
+			continue
+		}
+		if !isSubset(existingRedirectionHost.DomainNames, host.DomainNames) {
+			log.Warn("Redirection host also redirects domains of other origin, not changing it", "id", existingRedirectionHost.ID, "domains", existingRedirectionHost.DomainNames)
+			return false, false, nil
+		}
+
+		// Redirection hosts of running containers are always enabled, e.g.
+		// after having been disabled when the container stopped
+		enabled := false
+		if !existingRedirectionHost.Enabled {
+			if err := n.setEnabled("redirection-hosts", existingRedirectionHost.ID, true); err != nil {
+				return false, false, err
+			}
+			enabled = true
+		}
+
+		domainNames := slices.Sorted(slices.Values(host.DomainNames))
+		existingDomainNames := slices.Sorted(slices.Values(existingRedirectionHost.DomainNames))
+		if slices.Equal(domainNames, existingDomainNames) &&
+			existingRedirectionHost.ForwardDomainName == host.ForwardDomainName &&
+			existingRedirectionHost.ForwardHTTPCode == host.ForwardHTTPCode &&
+			existingRedirectionHost.ForwardScheme == host.ForwardScheme &&
+			existingRedirectionHost.PreservePath == host.PreservePath &&
+			existingRedirectionHost.Meta.Managed() {
+			return false, enabled, nil
+		}
+
+		log.Info("Updating redirection host", "id", existingRedirectionHost.ID, "domains", host.DomainNames, "forwardDomain", host.ForwardDomainName)
+		url := fmt.Sprintf("%v/nginx/redirection-hosts/%v", n.baseURL, existingRedirectionHost.ID)
+		payload := updateRedirectionHostPayload{
+			DomainNames:       host.DomainNames,
+			ForwardDomainName: host.ForwardDomainName,
+			ForwardHTTPCode:   host.ForwardHTTPCode,
+			ForwardScheme:     host.ForwardScheme,
+			Meta:              existingRedirectionHost.Meta,
+			PreservePath:      host.PreservePath,
+		}
+		payload.Meta.ManagedBy = MANAGED_BY
+		if err := n.sendJSON(http.MethodPut, url, payload); err != nil {
+			return false, false, err
+		}
+		return false, true, nil
+	}
+
+	host.Meta.ManagedBy = MANAGED_BY
+	if err := n.sendJSON(http.MethodPost, n.baseURL+"/nginx/redirection-hosts", host); err != nil {
+		return false, false, err
+	}
+	return true, false, nil
+}
+
+// DeleteRedirectionHosts deletes every redirection host whose domains all
+// belong to domains. Redirection hosts that also redirect other domains are
+// not deleted; only domains are removed from them, and each of them is
+// reported.
+func (n *Client) DeleteRedirectionHosts(domains []string) (numOfDeletedRedirectionHosts, numOfUpdatedRedirectionHosts int, err error) {
+	existingRedirectionHosts, err := n.getRedirectionHosts()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, existingRedirectionHost := range existingRedirectionHosts {
+		remainingDomains := slices.DeleteFunc(slices.Clone(existingRedirectionHost.DomainNames), func(domain string) bool {
+			return slices.Contains(domains, domain)
+		})
+		if len(remainingDomains) == len(existingRedirectionHost.DomainNames) {
+			continue
+		}
+
+		url := fmt.Sprintf("%v/nginx/redirection-hosts/%v", n.baseURL, existingRedirectionHost.ID)
+
+		if len(remainingDomains) > 0 {
+			log.Warn("Redirection host also redirects domains of other origin, removing only the container's domains from it",
+				"id", existingRedirectionHost.ID, "domains", existingRedirectionHost.DomainNames, "remainingDomains", remainingDomains)
+			if err := n.sendJSON(http.MethodPut, url, updateDomainNamesPayload{DomainNames: remainingDomains}); err != nil {
+				return numOfDeletedRedirectionHosts, numOfUpdatedRedirectionHosts, err
+			}
+			numOfUpdatedRedirectionHosts++
+			continue
+		}
+
+		resp, statusCode, err := n.makeRequest(http.MethodDelete, url, nil)
+		if err != nil {
+			return numOfDeletedRedirectionHosts, numOfUpdatedRedirectionHosts, err
+		}
+		if statusCode >= 400 {
+			return numOfDeletedRedirectionHosts, numOfUpdatedRedirectionHosts, parseErrorResponse(resp)
+		}
+		numOfDeletedRedirectionHosts++
+	}
+
+	return numOfDeletedRedirectionHosts, numOfUpdatedRedirectionHosts, nil
+}
+
+// DisableRedirectionHosts disables the enabled redirection hosts created by
+// PlugNPiN whose domains all belong to domains, keeping them around for when
+// they are enabled again.
+func (n *Client) DisableRedirectionHosts(domains []string) (numOfDisabledRedirectionHosts int, err error) {
+	existingRedirectionHosts, err := n.getRedirectionHosts()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, existingRedirectionHost := range existingRedirectionHosts {
+		if !existingRedirectionHost.Enabled || !sharesDomain(existingRedirectionHost.DomainNames, domains) {
+			continue
+		}
+		if !existingRedirectionHost.Meta.Managed() || !isSubset(existingRedirectionHost.DomainNames, domains) {
+			log.Warn("Redirection host was not created by PlugNPiN, not disabling it", "id", existingRedirectionHost.ID, "domains", existingRedirectionHost.DomainNames)
+			continue
+		}
+
+		if err := n.setEnabled("redirection-hosts", existingRedirectionHost.ID, false); err != nil {
+			return numOfDisabledRedirectionHosts, err
+		}
+		numOfDisabledRedirectionHosts++
+	}
+
+	return numOfDisabledRedirectionHosts, nil
+}
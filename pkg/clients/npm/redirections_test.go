@@ -0,0 +1,264 @@
+//go:build unit
+
+package npm
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddRedirectionHost(t *testing.T) {
+	host := RedirectionHost{
+		DomainNames:       []string{"old.home.lan", "legacy.home.lan"},
+		ForwardDomainName: "new.home.lan",
+		ForwardHTTPCode:   301,
+		ForwardScheme:     "auto",
+		PreservePath:      true,
+	}
+
+	t.Run("create when no redirection host exists", func(t *testing.T) {
+		postCalled := false
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/nginx/redirection-hosts", r.URL.Path)
+
+			if r.Method == http.MethodGet {
+				_ = json.NewEncoder(w).Encode([]RedirectionHostReply{})
+				return
+			}
+
+			if r.Method == http.MethodPost {
+				postCalled = true
+				var receivedHost RedirectionHost
+				err := json.NewDecoder(r.Body).Decode(&receivedHost)
+				assert.NoError(t, err)
+				expectedHost := host
+				expectedHost.Meta.ManagedBy = MANAGED_BY
+				assert.Equal(t, expectedHost, receivedHost)
+				w.WriteHeader(http.StatusCreated)
+				return
+			}
+
+			t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		added, updated, err := client.AddRedirectionHost(host)
+		assert.NoError(t, err)
+		assert.True(t, added)
+		assert.False(t, updated)
+		assert.True(t, postCalled, "POST was not called")
+	})
+
+	t.Run("update when the forward domain changed", func(t *testing.T) {
+		putCalled := false
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				_ = json.NewEncoder(w).Encode([]RedirectionHostReply{
+					{ID: 4, DomainNames: []string{"legacy.home.lan", "old.home.lan"}, ForwardDomainName: "older.home.lan", ForwardHTTPCode: 301, ForwardScheme: "auto", PreservePath: true, Enabled: true, Meta: managedMeta},
+				})
+				return
+			}
+
+			if r.Method == http.MethodPut {
+				putCalled = true
+				assert.Equal(t, "/api/nginx/redirection-hosts/4", r.URL.Path)
+				var payload updateRedirectionHostPayload
+				err := json.NewDecoder(r.Body).Decode(&payload)
+				assert.NoError(t, err)
+				assert.Equal(t, "new.home.lan", payload.ForwardDomainName)
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		added, updated, err := client.AddRedirectionHost(host)
+		assert.NoError(t, err)
+		assert.False(t, added)
+		assert.True(t, updated)
+		assert.True(t, putCalled, "PUT was not called")
+	})
+
+	t.Run("no action when the redirection host is up to date", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			_ = json.NewEncoder(w).Encode([]RedirectionHostReply{
+				{ID: 4, DomainNames: []string{"legacy.home.lan", "old.home.lan"}, ForwardDomainName: "new.home.lan", ForwardHTTPCode: 301, ForwardScheme: "auto", PreservePath: true, Enabled: true, Meta: managedMeta},
+			})
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		added, updated, err := client.AddRedirectionHost(host)
+		assert.NoError(t, err)
+		assert.False(t, added)
+		assert.False(t, updated)
+	})
+
+	t.Run("adopt a redirection host of only the container's domains", func(t *testing.T) {
+		putCalled := false
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				_ = json.NewEncoder(w).Encode([]RedirectionHostReply{
+					{ID: 4, DomainNames: []string{"old.home.lan"}, ForwardDomainName: "new.home.lan", ForwardHTTPCode: 301, ForwardScheme: "auto", PreservePath: true, Enabled: true},
+				})
+				return
+			}
+
+			if r.Method == http.MethodPut {
+				putCalled = true
+				var payload updateRedirectionHostPayload
+				err := json.NewDecoder(r.Body).Decode(&payload)
+				assert.NoError(t, err)
+				assert.Equal(t, host.DomainNames, payload.DomainNames)
+				assert.True(t, payload.Meta.Managed())
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		added, updated, err := client.AddRedirectionHost(host)
+		assert.NoError(t, err)
+		assert.False(t, added)
+		assert.True(t, updated)
+		assert.True(t, putCalled, "PUT was not called")
+	})
+
+	t.Run("enable a disabled redirection host", func(t *testing.T) {
+		enableCalled := false
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				_ = json.NewEncoder(w).Encode([]RedirectionHostReply{
+					{ID: 4, DomainNames: []string{"legacy.home.lan", "old.home.lan"}, ForwardDomainName: "new.home.lan", ForwardHTTPCode: 301, ForwardScheme: "auto", PreservePath: true, Meta: managedMeta},
+				})
+				return
+			}
+
+			if r.Method == http.MethodPost {
+				enableCalled = true
+				assert.Equal(t, "/api/nginx/redirection-hosts/4/enable", r.URL.Path)
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		added, updated, err := client.AddRedirectionHost(host)
+		assert.NoError(t, err)
+		assert.False(t, added)
+		assert.True(t, updated)
+		assert.True(t, enableCalled, "enable was not called")
+	})
+
+	t.Run("no action when the redirection host also redirects other domains", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			_ = json.NewEncoder(w).Encode([]RedirectionHostReply{
+				{ID: 4, DomainNames: []string{"old.home.lan", "manual.home.lan"}, ForwardDomainName: "other.home.lan", ForwardHTTPCode: 302},
+			})
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		added, updated, err := client.AddRedirectionHost(host)
+		assert.NoError(t, err)
+		assert.False(t, added)
+		assert.False(t, updated)
+	})
+}
+
+func TestDeleteRedirectionHosts(t *testing.T) {
+	var deletedPaths []string
+	updatePayloads := map[string]updateDomainNamesPayload{}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_ = json.NewEncoder(w).Encode([]RedirectionHostReply{
+				{ID: 1, DomainNames: []string{"old.home.lan"}},
+				{ID: 2, DomainNames: []string{"unrelated.home.lan"}},
+				{ID: 3, DomainNames: []string{"legacy.home.lan"}},
+				{ID: 4, DomainNames: []string{"old.home.lan", "manual.home.lan"}},
+			})
+			return
+		}
+
+		if r.Method == http.MethodDelete {
+			deletedPaths = append(deletedPaths, r.URL.Path)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		if r.Method == http.MethodPut {
+			var payload updateDomainNamesPayload
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			updatePayloads[r.URL.Path] = payload
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+	})
+
+	client, closeServer := setupAuthorizedTestServer(handler)
+	defer closeServer()
+
+	deleted, updated, err := client.DeleteRedirectionHosts([]string{"old.home.lan", "legacy.home.lan"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+	assert.Equal(t, 1, updated)
+	assert.Equal(t, []string{"/api/nginx/redirection-hosts/1", "/api/nginx/redirection-hosts/3"}, deletedPaths)
+	assert.Equal(t, map[string]updateDomainNamesPayload{
+		"/api/nginx/redirection-hosts/4": {DomainNames: []string{"manual.home.lan"}},
+	}, updatePayloads)
+}
+
+func TestDisableRedirectionHosts(t *testing.T) {
+	var disabledPaths []string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_ = json.NewEncoder(w).Encode([]RedirectionHostReply{
+				{ID: 1, DomainNames: []string{"old.home.lan"}, Enabled: true, Meta: managedMeta},
+				{ID: 2, DomainNames: []string{"unrelated.home.lan"}, Enabled: true, Meta: managedMeta},
+				{ID: 3, DomainNames: []string{"legacy.home.lan"}, Meta: managedMeta},
+				{ID: 4, DomainNames: []string{"old.home.lan", "manual.home.lan"}, Enabled: true, Meta: managedMeta},
+				{ID: 5, DomainNames: []string{"legacy.home.lan"}, Enabled: true},
+			})
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			disabledPaths = append(disabledPaths, r.URL.Path)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+	})
+
+	client, closeServer := setupAuthorizedTestServer(handler)
+	defer closeServer()
+
+	disabled, err := client.DisableRedirectionHosts([]string{"old.home.lan", "legacy.home.lan"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, disabled)
+	assert.Equal(t, []string{"/api/nginx/redirection-hosts/1/disable"}, disabledPaths)
+}
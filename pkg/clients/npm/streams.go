@@ -0,0 +1,139 @@
+package npm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+func (n *Client) getStreams() ([]StreamReply, error) {
+	resp, statusCode, err := n.cachedGet(n.baseURL + "/nginx/streams")
+	if err != nil {
+		return nil, err
+	}
+	if statusCode >= 400 {
+		return nil, parseErrorResponse(resp)
+	}
+
+	var streams []StreamReply
+	err = json.Unmarshal([]byte(resp), &streams)
+	if err != nil {
+		return nil, err
+	}
+	return streams, nil
+}
+
+// AddStream creates the stream, marked as managed by PlugNPiN, if no stream
+// listens on its incoming port yet. Otherwise, the existing stream is enabled
+// and its forwarding target and protocols are reconciled with the ones of
+// stream, as long as PlugNPiN created it.
+func (n *Client) AddStream(stream Stream) (added, updated bool, err error) {
+	existingStreams, err := n.getStreams()
+	if err != nil {
+		return false, false, err
+	}
+
+	for _, existingStream := range existingStreams {
+		if existingStream.IncomingPort != stream.IncomingPort {
+			continue
+		}
+		if !existingStream.Meta.Managed() {
+			log.Warn("Stream on incoming port was not created by PlugNPiN, not changing it", "id", existingStream.ID, "incomingPort", existingStream.IncomingPort)
+			return false, false, nil
+		}
+
+		enabled := false
+		if !existingStream.Enabled {
+			if err := n.setEnabled("streams", existingStream.ID, true); err != nil {
+				return false, false, err
+			}
+			enabled = true
+		}
+
+		payload := updateStreamPayload{
+			ForwardingHost: stream.ForwardingHost,
+			ForwardingPort: stream.ForwardingPort,
+			TCPForwarding:  stream.TCPForwarding,
+			UDPForwarding:  stream.UDPForwarding,
+		}
+		existing := updateStreamPayload{
+			ForwardingHost: existingStream.ForwardingHost,
+			ForwardingPort: existingStream.ForwardingPort,
+			TCPForwarding:  existingStream.TCPForwarding,
+			UDPForwarding:  existingStream.UDPForwarding,
+		}
+		if payload == existing {
+			return false, enabled, nil
+		}
+
+		log.Info("Updating stream", "id", existingStream.ID, "incomingPort", existingStream.IncomingPort)
+		url := fmt.Sprintf("%v/nginx/streams/%v", n.baseURL, existingStream.ID)
+		if err := n.sendJSON(http.MethodPut, url, payload); err != nil {
+			return false, false, err
+		}
+		return false, true, nil
+	}
+
+	stream.Meta.ManagedBy = MANAGED_BY
+	if err := n.sendJSON(http.MethodPost, n.baseURL+"/nginx/streams", stream); err != nil {
+		return false, false, err
+	}
+	return true, false, nil
+}
+
+// DeleteStream deletes the stream listening on incomingPort, if there is one
+// and PlugNPiN created it.
+func (n *Client) DeleteStream(incomingPort int) (bool, error) {
+	existingStreams, err := n.getStreams()
+	if err != nil {
+		return false, err
+	}
+
+	for _, existingStream := range existingStreams {
+		if existingStream.IncomingPort != incomingPort {
+			continue
+		}
+		if !existingStream.Meta.Managed() {
+			log.Warn("Stream on incoming port was not created by PlugNPiN, not deleting it", "id", existingStream.ID, "incomingPort", existingStream.IncomingPort)
+			return false, nil
+		}
+
+		url := fmt.Sprintf("%v/nginx/streams/%v", n.baseURL, existingStream.ID)
+		resp, statusCode, err := n.makeRequest(http.MethodDelete, url, nil)
+		if err != nil {
+			return false, err
+		}
+		if statusCode >= 400 {
+			return false, parseErrorResponse(resp)
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// DisableStream disables the stream listening on incomingPort, if there is an
+// enabled one and PlugNPiN created it.
+func (n *Client) DisableStream(incomingPort int) (bool, error) {
+	existingStreams, err := n.getStreams()
+	if err != nil {
+		return false, err
+	}
+
+	for _, existingStream := range existingStreams {
+		if existingStream.IncomingPort != incomingPort || !existingStream.Enabled {
+			continue
+		}
+		if !existingStream.Meta.Managed() {
+			log.Warn("Stream on incoming port was not created by PlugNPiN, not disabling it", "id", existingStream.ID, "incomingPort", existingStream.IncomingPort)
+			return false, nil
+		}
+
+		if err := n.setEnabled("streams", existingStream.ID, false); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
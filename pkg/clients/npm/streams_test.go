@@ -0,0 +1,265 @@
+//go:build unit
+
+package npm
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupAuthorizedTestServer(handler http.Handler) (*Client, func()) {
+	client, server := setupTestServer(handler)
+	client.token = "test-jwt-token"
+	client.tokenExpireTime = time.Now().Add(24 * time.Hour)
+	client.headers["authorization"] = "Bearer test-jwt-token"
+	return client, server.Close
+}
+
+var managedMeta = Meta{ManagedBy: MANAGED_BY}
+
+func TestAddStream(t *testing.T) {
+	stream := Stream{ForwardingHost: "192.168.1.10", ForwardingPort: 1883, IncomingPort: 1883, TCPForwarding: true}
+
+	t.Run("create when no stream listens on the port", func(t *testing.T) {
+		postCalled := false
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/nginx/streams", r.URL.Path)
+
+			if r.Method == http.MethodGet {
+				_ = json.NewEncoder(w).Encode([]StreamReply{{ID: 1, IncomingPort: 25565}})
+				return
+			}
+
+			if r.Method == http.MethodPost {
+				postCalled = true
+				var receivedStream Stream
+				err := json.NewDecoder(r.Body).Decode(&receivedStream)
+				assert.NoError(t, err)
+				expectedStream := stream
+				expectedStream.Meta.ManagedBy = MANAGED_BY
+				assert.Equal(t, expectedStream, receivedStream)
+				w.WriteHeader(http.StatusCreated)
+				return
+			}
+
+			t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		added, updated, err := client.AddStream(stream)
+		assert.NoError(t, err)
+		assert.True(t, added)
+		assert.False(t, updated)
+		assert.True(t, postCalled, "POST was not called")
+	})
+
+	t.Run("update when the forwarding target drifted", func(t *testing.T) {
+		putCalled := false
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				_ = json.NewEncoder(w).Encode([]StreamReply{{ID: 7, IncomingPort: 1883, ForwardingHost: "192.168.1.99", ForwardingPort: 1883, TCPForwarding: true, Enabled: true, Meta: managedMeta}})
+				return
+			}
+
+			if r.Method == http.MethodPut {
+				putCalled = true
+				assert.Equal(t, "/api/nginx/streams/7", r.URL.Path)
+				var payload updateStreamPayload
+				err := json.NewDecoder(r.Body).Decode(&payload)
+				assert.NoError(t, err)
+				assert.Equal(t, "192.168.1.10", payload.ForwardingHost)
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		added, updated, err := client.AddStream(stream)
+		assert.NoError(t, err)
+		assert.False(t, added)
+		assert.True(t, updated)
+		assert.True(t, putCalled, "PUT was not called")
+	})
+
+	t.Run("no action when the stream is up to date", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			_ = json.NewEncoder(w).Encode([]StreamReply{{ID: 7, IncomingPort: 1883, ForwardingHost: "192.168.1.10", ForwardingPort: 1883, TCPForwarding: true, Enabled: true, Meta: managedMeta}})
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		added, updated, err := client.AddStream(stream)
+		assert.NoError(t, err)
+		assert.False(t, added)
+		assert.False(t, updated)
+	})
+
+	t.Run("enable a disabled stream", func(t *testing.T) {
+		enableCalled := false
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				_ = json.NewEncoder(w).Encode([]StreamReply{{ID: 7, IncomingPort: 1883, ForwardingHost: "192.168.1.10", ForwardingPort: 1883, TCPForwarding: true, Meta: managedMeta}})
+				return
+			}
+
+			if r.Method == http.MethodPost {
+				enableCalled = true
+				assert.Equal(t, "/api/nginx/streams/7/enable", r.URL.Path)
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		added, updated, err := client.AddStream(stream)
+		assert.NoError(t, err)
+		assert.False(t, added)
+		assert.True(t, updated)
+		assert.True(t, enableCalled, "enable was not called")
+	})
+
+	t.Run("no action when the stream on the port was not created by PlugNPiN", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			_ = json.NewEncoder(w).Encode([]StreamReply{{ID: 7, IncomingPort: 1883, ForwardingHost: "192.168.1.99", ForwardingPort: 1883, TCPForwarding: true}})
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		added, updated, err := client.AddStream(stream)
+		assert.NoError(t, err)
+		assert.False(t, added)
+		assert.False(t, updated)
+	})
+}
+
+func TestDeleteStream(t *testing.T) {
+	t.Run("delete stream listening on the port", func(t *testing.T) {
+		deleteCalled := false
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				_ = json.NewEncoder(w).Encode([]StreamReply{{ID: 7, IncomingPort: 1883, Meta: managedMeta}})
+				return
+			}
+
+			if r.Method == http.MethodDelete {
+				deleteCalled = true
+				assert.Equal(t, "/api/nginx/streams/7", r.URL.Path)
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		deleted, err := client.DeleteStream(1883)
+		assert.NoError(t, err)
+		assert.True(t, deleted)
+		assert.True(t, deleteCalled, "DELETE was not called")
+	})
+
+	t.Run("no action when the stream on the port was not created by PlugNPiN", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			_ = json.NewEncoder(w).Encode([]StreamReply{{ID: 7, IncomingPort: 1883}})
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		deleted, err := client.DeleteStream(1883)
+		assert.NoError(t, err)
+		assert.False(t, deleted)
+	})
+
+	t.Run("no action when no stream listens on the port", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			_ = json.NewEncoder(w).Encode([]StreamReply{})
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		deleted, err := client.DeleteStream(1883)
+		assert.NoError(t, err)
+		assert.False(t, deleted)
+	})
+}
+
+func TestDisableStream(t *testing.T) {
+	t.Run("disable stream listening on the port", func(t *testing.T) {
+		disableCalled := false
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				_ = json.NewEncoder(w).Encode([]StreamReply{{ID: 7, IncomingPort: 1883, Enabled: true, Meta: managedMeta}})
+				return
+			}
+
+			if r.Method == http.MethodPost {
+				disableCalled = true
+				assert.Equal(t, "/api/nginx/streams/7/disable", r.URL.Path)
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		disabled, err := client.DisableStream(1883)
+		assert.NoError(t, err)
+		assert.True(t, disabled)
+		assert.True(t, disableCalled, "disable was not called")
+	})
+
+	t.Run("no action when the stream is already disabled", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			_ = json.NewEncoder(w).Encode([]StreamReply{{ID: 7, IncomingPort: 1883, Meta: managedMeta}})
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		disabled, err := client.DisableStream(1883)
+		assert.NoError(t, err)
+		assert.False(t, disabled)
+	})
+
+	t.Run("no action when the stream on the port was not created by PlugNPiN", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			_ = json.NewEncoder(w).Encode([]StreamReply{{ID: 7, IncomingPort: 1883, Enabled: true}})
+		})
+
+		client, closeServer := setupAuthorizedTestServer(handler)
+		defer closeServer()
+
+		disabled, err := client.DisableStream(1883)
+		assert.NoError(t, err)
+		assert.False(t, disabled)
+	})
+}
@@ -1,5 +1,7 @@
 package npm
 
+import "github.com/deepspace2/plugnpin/pkg/snippets"
+
 type LoginResponse struct {
 	Expires string `json:"expires"`
 	Token   string `json:"token"`
@@ -41,14 +43,24 @@ type ProxyHostReply struct {
 	SslForced             bool       `json:"ssl_forced"`
 }
 
+// MANAGED_BY marks the meta of the streams and redirection hosts created by
+// PlugNPiN, which can't be told apart by their domains alone.
+const MANAGED_BY = "plugnpin"
+
 type Meta struct {
 	DNSChallenge     bool   `json:"dns_challenge"`
 	LetsencryptAgree bool   `json:"letsencrypt_agree"`
 	LetsencryptEmail string `json:"letsencrypt_email"`
+	ManagedBy        string `json:"managed_by,omitempty"`
 	NginxErr         any    `json:"nginx_err"`
 	NginxOnline      bool   `json:"nginx_online"`
 }
 
+// Managed reports whether the entry was created by PlugNPiN.
+func (m Meta) Managed() bool {
+	return m.ManagedBy == MANAGED_BY
+}
+
 type Location struct {
 	Path           string `json:"path"`
 	ForwardHost    string `json:"forward_host"`
@@ -57,6 +69,17 @@ type Location struct {
 	AdvancedConfig string `json:"advanced_config"`
 }
 
+type updateProxyHostPayload struct {
+	AccessListID   *int        `json:"access_list_id,omitempty"`
+	AdvancedConfig *string     `json:"advanced_config,omitempty"`
+	CertificateID  *int        `json:"certificate_id,omitempty"`
+	Locations      *[]Location `json:"locations,omitempty"`
+}
+
+type updateDomainNamesPayload struct {
+	DomainNames []string `json:"domain_names"`
+}
+
 type ProxyHost struct {
 	AccessListID          int        `json:"access_list_id"`
 	AdvancedConfig        string     `json:"advanced_config"`
@@ -88,6 +111,29 @@ type Certificates []struct {
 	Meta        Meta     `json:"meta"`
 }
 
+type CertificateRequest struct {
+	DomainNames []string               `json:"domain_names"`
+	Meta        CertificateRequestMeta `json:"meta"`
+	NiceName    string                 `json:"nice_name"`
+	Provider    string                 `json:"provider"`
+}
+
+type CertificateRequestMeta struct {
+	DNSChallenge           bool   `json:"dns_challenge"`
+	DNSProvider            string `json:"dns_provider,omitempty"`
+	DNSProviderCredentials string `json:"dns_provider_credentials,omitempty"`
+	LetsencryptAgree       bool   `json:"letsencrypt_agree"`
+	LetsencryptEmail       string `json:"letsencrypt_email"`
+	PropagationSeconds     int    `json:"propagation_seconds,omitempty"`
+}
+
+type CertificateReply struct {
+	DomainNames []string `json:"domain_names"`
+	ExpiresOn   string   `json:"expires_on"`
+	ID          int      `json:"id"`
+	NiceName    string   `json:"nice_name"`
+}
+
 type AccessLists []struct {
 	CreatedOn      string `json:"created_on"`
 	ID             int    `json:"id"`
@@ -98,18 +144,146 @@ type AccessLists []struct {
 	PassAuth       bool   `json:"pass_auth"`
 	ProxyHostCount int    `json:"proxy_host_count"`
 	SatisfyAny     bool   `json:"satisfy_any"`
+
+	Clients []AccessListClient `json:"clients"`
+	Items   []AccessListItem   `json:"items"`
+}
+
+type AccessList struct {
+	Clients    []AccessListClient `json:"clients"`
+	Items      []AccessListItem   `json:"items"`
+	Meta       Meta               `json:"meta"`
+	Name       string             `json:"name"`
+	PassAuth   bool               `json:"pass_auth"`
+	SatisfyAny bool               `json:"satisfy_any"`
+}
+
+type AccessListClient struct {
+	Address   string `json:"address"`
+	Directive string `json:"directive"`
+}
+
+type AccessListItem struct {
+	Password string `json:"password"`
+	Username string `json:"username"`
+}
+
+type AccessListOptions struct {
+	Allow      []string
+	Deny       []string
+	Name       string
+	SatisfyAny bool
+	Users      []AccessListUser
+}
+
+type AccessListUser struct {
+	// PasswordSecret is the name of the Docker secret holding the password
+	PasswordSecret string
+	Username       string
+}
+
+type Stream struct {
+	ForwardingHost string `json:"forwarding_host"`
+	ForwardingPort int    `json:"forwarding_port"`
+	IncomingPort   int    `json:"incoming_port"`
+	Meta           Meta   `json:"meta"`
+	TCPForwarding  bool   `json:"tcp_forwarding"`
+	UDPForwarding  bool   `json:"udp_forwarding"`
+}
+
+type StreamReply struct {
+	CreatedOn      string `json:"created_on"`
+	Enabled        bool   `json:"enabled"`
+	ForwardingHost string `json:"forwarding_host"`
+	ForwardingPort int    `json:"forwarding_port"`
+	ID             int    `json:"id"`
+	IncomingPort   int    `json:"incoming_port"`
+	Meta           Meta   `json:"meta"`
+	ModifiedOn     string `json:"modified_on"`
+	OwnerUserID    int    `json:"owner_user_id"`
+	TCPForwarding  bool   `json:"tcp_forwarding"`
+	UDPForwarding  bool   `json:"udp_forwarding"`
+}
+
+type updateStreamPayload struct {
+	ForwardingHost string `json:"forwarding_host"`
+	ForwardingPort int    `json:"forwarding_port"`
+	TCPForwarding  bool   `json:"tcp_forwarding"`
+	UDPForwarding  bool   `json:"udp_forwarding"`
+}
+
+type RedirectionHost struct {
+	AdvancedConfig    string   `json:"advanced_config"`
+	BlockExploits     bool     `json:"block_exploits"`
+	CertificateID     int      `json:"certificate_id"`
+	DomainNames       []string `json:"domain_names"`
+	ForwardDomainName string   `json:"forward_domain_name"`
+	ForwardHTTPCode   int      `json:"forward_http_code"`
+	ForwardScheme     string   `json:"forward_scheme"`
+	HTTP2Support      bool     `json:"http2_support"`
+	HstsEnabled       bool     `json:"hsts_enabled"`
+	HstsSubdomains    bool     `json:"hsts_subdomains"`
+	Meta              Meta     `json:"meta"`
+	PreservePath      bool     `json:"preserve_path"`
+	SslForced         bool     `json:"ssl_forced"`
+}
+
+type RedirectionHostReply struct {
+	CreatedOn         string   `json:"created_on"`
+	DomainNames       []string `json:"domain_names"`
+	Enabled           bool     `json:"enabled"`
+	ForwardDomainName string   `json:"forward_domain_name"`
+	ForwardHTTPCode   int      `json:"forward_http_code"`
+	ForwardScheme     string   `json:"forward_scheme"`
+	ID                int      `json:"id"`
+	Meta              Meta     `json:"meta"`
+	ModifiedOn        string   `json:"modified_on"`
+	OwnerUserID       int      `json:"owner_user_id"`
+	PreservePath      bool     `json:"preserve_path"`
+}
+
+type updateRedirectionHostPayload struct {
+	DomainNames       []string `json:"domain_names"`
+	ForwardDomainName string   `json:"forward_domain_name"`
+	ForwardHTTPCode   int      `json:"forward_http_code"`
+	ForwardScheme     string   `json:"forward_scheme"`
+	Meta              Meta     `json:"meta"`
+	PreservePath      bool     `json:"preserve_path"`
+}
+
+type NpmRedirectionOptions struct {
+	Domains         []string
+	ForwardHTTPCode int
+	ForwardScheme   string
+	PreservePath    bool
+}
+
+type NpmStreamOptions struct {
+	IncomingPort  int
+	TCPForwarding bool
+	UDPForwarding bool
 }
 
 type NpmProxyHostOptions struct {
+	AccessList            *AccessListOptions
 	AccessListName        string
 	AdvancedConfig        string
 	AllowWebsocketUpgrade bool
 	BlockExploits         bool
 	CachingEnabled        bool
 	CertificateName       string
-	ForwardScheme         string
-	HTTP2Support          bool
-	HstsEnabled           bool
-	HstsSubdomains        bool
-	SslForced             bool
+	// DisableOnStop overrides the global NGINX_PROXY_MANAGER_DISABLE_ON_STOP setting when set
+	DisableOnStop  *bool
+	ForwardScheme  string
+	HTTP2Support   bool
+	HstsEnabled    bool
+	HstsSubdomains bool
+	// Instance is the name of the NPM instance to create the proxy host on,
+	// empty for the default one
+	Instance string
+	// Locations are nil if the container has no location labels, leaving
+	// the locations of an existing proxy host alone
+	Locations []Location
+	Snippets  []snippets.Reference
+	SslForced bool
 }
@@ -0,0 +1,110 @@
+package pihole
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var invalidHostnameCharacters = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// DhcpHostname turns a container name into a hostname dnsmasq accepts.
+func DhcpHostname(containerName string) string {
+	hostname := invalidHostnameCharacters.ReplaceAllString(strings.ToLower(containerName), "-")
+	return strings.Trim(hostname, "-")
+}
+
+func dhcpHostToRaw(host DhcpHost) string {
+	if host.Hostname == "" {
+		return fmt.Sprintf("%v,%v", host.MAC, host.IP)
+	}
+	return fmt.Sprintf("%v,%v,%v", host.MAC, host.IP, host.Hostname)
+}
+
+// rawDhcpHostMatches reports whether a dhcp.hosts entry belongs to host, by
+// either its MAC address or its hostname. Entries use dnsmasq's dhcp-host
+// syntax, whose fields may come in any order.
+func rawDhcpHostMatches(rawDhcpHost string, host DhcpHost) bool {
+	for field := range strings.SplitSeq(rawDhcpHost, ",") {
+		field = strings.TrimSpace(field)
+		if host.MAC != "" && strings.EqualFold(field, host.MAC) {
+			return true
+		}
+		if host.Hostname != "" && strings.EqualFold(field, host.Hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Client) getDhcpHosts() ([]string, error) {
+	resp, err := p.getConfig()
+	if err != nil {
+		return nil, err
+	}
+	return resp.Config.DHCP.Hosts, nil
+}
+
+func (p *Client) setDhcpHosts(rawDhcpHosts []string) error {
+	payload := updateDhcpHostsPayload{}
+	payload.Config.DHCP.Hosts = rawDhcpHosts
+
+	return p.patchConfig(payload)
+}
+
+// AddDhcpHost registers a static DHCP lease for host, replacing any stale
+// lease with the same MAC address or hostname.
+func (p *Client) AddDhcpHost(host DhcpHost) (added bool, err error) {
+	if p.apiVersion == API_VERSION_5 {
+		return false, errDhcpNotSupported
+	}
+
+	existingHosts, err := p.getDhcpHosts()
+	if err != nil {
+		return false, err
+	}
+
+	rawDhcpHost := dhcpHostToRaw(host)
+	if slices.Contains(existingHosts, rawDhcpHost) {
+		return false, nil
+	}
+
+	updatedHosts := slices.DeleteFunc(existingHosts, func(existingHost string) bool {
+		return rawDhcpHostMatches(existingHost, host)
+	})
+	updatedHosts = append(updatedHosts, rawDhcpHost)
+
+	if err := p.setDhcpHosts(updatedHosts); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DeleteDhcpHosts removes every static DHCP lease matching host's MAC address
+// or hostname.
+func (p *Client) DeleteDhcpHosts(host DhcpHost) (numOfDeletedDhcpHosts int, err error) {
+	if p.apiVersion == API_VERSION_5 {
+		return 0, errDhcpNotSupported
+	}
+
+	existingHosts, err := p.getDhcpHosts()
+	if err != nil {
+		return 0, err
+	}
+
+	numOfExistingHosts := len(existingHosts)
+	updatedHosts := slices.DeleteFunc(existingHosts, func(existingHost string) bool {
+		return rawDhcpHostMatches(existingHost, host)
+	})
+	numOfDeletedDhcpHosts = numOfExistingHosts - len(updatedHosts)
+
+	if numOfDeletedDhcpHosts == 0 {
+		return 0, nil
+	}
+
+	if err := p.setDhcpHosts(updatedHosts); err != nil {
+		return 0, err
+	}
+	return numOfDeletedDhcpHosts, nil
+}
@@ -0,0 +1,97 @@
+//go:build unit
+
+package pihole
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddDhcpHost(t *testing.T) {
+	t.Run("replaces stale lease of the same host", func(t *testing.T) {
+		patchCalled := false
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/config", r.URL.Path)
+
+			if r.Method == http.MethodGet {
+				w.WriteHeader(http.StatusOK)
+				_, _ = fmt.Fprint(w, `{"config": {"dhcp": {"hosts": ["aa:bb:cc:dd:ee:01,192.168.0.10,other", "aa:bb:cc:dd:ee:ff,192.168.0.20,service"]}}}`)
+				return
+			}
+
+			patchCalled = true
+			var payload updateDhcpHostsPayload
+			err := json.NewDecoder(r.Body).Decode(&payload)
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, []string{"aa:bb:cc:dd:ee:01,192.168.0.10,other", "aa:bb:cc:dd:ee:ff,192.168.0.30,service"}, payload.Config.DHCP.Hosts)
+
+			w.WriteHeader(http.StatusOK)
+			_, _ = fmt.Fprint(w, `{}`)
+		})
+		client, server := setupTestServer(handler, "test-password")
+		defer server.Close()
+		client.sid = "test-sid"
+
+		added, err := client.AddDhcpHost(DhcpHost{MAC: "aa:bb:cc:dd:ee:ff", IP: "192.168.0.30", Hostname: "service"})
+		assert.NoError(t, err)
+		assert.True(t, added)
+		assert.True(t, patchCalled, "The PATCH endpoint was not called")
+	})
+
+	t.Run("no action if lease already exists", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			w.WriteHeader(http.StatusOK)
+			_, _ = fmt.Fprint(w, `{"config": {"dhcp": {"hosts": ["aa:bb:cc:dd:ee:ff,192.168.0.30,service"]}}}`)
+		})
+		client, server := setupTestServer(handler, "test-password")
+		defer server.Close()
+		client.sid = "test-sid"
+
+		added, err := client.AddDhcpHost(DhcpHost{MAC: "aa:bb:cc:dd:ee:ff", IP: "192.168.0.30", Hostname: "service"})
+		assert.NoError(t, err)
+		assert.False(t, added)
+	})
+
+	t.Run("not supported by v5", func(t *testing.T) {
+		client := NewLegacyClient("http://pihole.local", "token")
+
+		_, err := client.AddDhcpHost(DhcpHost{MAC: "aa:bb:cc:dd:ee:ff", IP: "192.168.0.30"})
+		assert.ErrorIs(t, err, errDhcpNotSupported)
+	})
+}
+
+func TestDeleteDhcpHosts(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusOK)
+			_, _ = fmt.Fprint(w, `{"config": {"dhcp": {"hosts": ["aa:bb:cc:dd:ee:01,192.168.0.10,other", "AA:BB:CC:DD:EE:FF,192.168.0.20,service"]}}}`)
+			return
+		}
+
+		var payload updateDhcpHostsPayload
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"aa:bb:cc:dd:ee:01,192.168.0.10,other"}, payload.Config.DHCP.Hosts)
+
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprint(w, `{}`)
+	})
+	client, server := setupTestServer(handler, "test-password")
+	defer server.Close()
+	client.sid = "test-sid"
+
+	// Only the hostname is known when a container dies
+	count, err := client.DeleteDhcpHosts(DhcpHost{Hostname: "service"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestDhcpHostname(t *testing.T) {
+	assert.Equal(t, "my-service-1", DhcpHostname("My_Service.1"))
+	assert.Equal(t, "service", DhcpHostname("-service-"))
+}
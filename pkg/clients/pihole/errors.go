@@ -5,6 +5,12 @@ import (
 )
 
 var (
-	errAuthRefreshFailed = errors.New("failed to refresh Pi-Hole authentication")
-	errMissingSessionId  = errors.New("missing Pi-Hole session ID")
+	errAPIVersionDetectionFailed = errors.New("failed to detect the Pi-Hole API version, set PIHOLE_API_VERSION explicitly")
+	errAuthRefreshFailed         = errors.New("failed to refresh Pi-Hole authentication")
+	errAuthRetriesExhausted      = errors.New("Pi-Hole kept rejecting the session after re-authenticating")
+	errDhcpNotSupported          = errors.New("static DHCP leases are not supported by the Pi-Hole v5 API")
+	errInvalidApiToken           = errors.New("Pi-Hole rejected the API token")
+	errInvalidTotpSecret         = errors.New("invalid Pi-Hole TOTP secret, expected a base32 encoded string")
+	errMissingSessionId          = errors.New("missing Pi-Hole session ID")
+	errTotpRequired              = errors.New("Pi-Hole requires a TOTP code (2FA is enabled), set PIHOLE_TOTP_SECRET or use an application password")
 )
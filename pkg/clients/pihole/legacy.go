@@ -0,0 +1,283 @@
+package pihole
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/deepspace2/plugnpin/pkg/clients/common"
+	"github.com/deepspace2/plugnpin/pkg/metrics"
+)
+
+const (
+	legacyCustomDnsEndpoint   = "customdns"
+	legacyCustomCNameEndpoint = "customcname"
+)
+
+// NewLegacyClient returns a client for Pi-Hole v5, whose custom DNS is managed
+// through admin/api.php and authenticated with an API token.
+func NewLegacyClient(baseURL, apiToken string) *Client {
+	return &Client{
+		Client: http.Client{
+			Transport: common.NewInstrumentedRoundTripper(metrics.PI_HOLE, metrics.ObserveApiRequestDuration),
+		},
+		apiVersion: API_VERSION_5,
+		apiToken:   apiToken,
+		legacyURL:  fmt.Sprintf("%v/admin/api.php", baseURL),
+	}
+}
+
+// LegacyAPITokenFromPassword derives the Pi-Hole v5 API token from the web
+// interface password, the same way Pi-Hole itself stores it (double SHA-256).
+func LegacyAPITokenFromPassword(password string) string {
+	first := sha256.Sum256([]byte(password))
+	second := sha256.Sum256([]byte(hex.EncodeToString(first[:])))
+	return hex.EncodeToString(second[:])
+}
+
+// DetectAPIVersion probes the Pi-Hole instance at baseURL and returns
+// API_VERSION_6 or API_VERSION_5.
+func DetectAPIVersion(baseURL string) (string, error) {
+	client := http.Client{
+		Transport: common.NewInstrumentedRoundTripper(metrics.PI_HOLE, metrics.ObserveApiRequestDuration),
+		Timeout:   10 * time.Second,
+	}
+
+	// v6 answers GET /api/auth with a session object, even when unauthenticated
+	resp, statusCode, err := common.Get(&client, baseURL+"/api/auth", headers)
+	if err == nil && statusCode != http.StatusNotFound {
+		var authResponse map[string]json.RawMessage
+		if json.Unmarshal([]byte(resp), &authResponse) == nil {
+			if _, exists := authResponse["session"]; exists {
+				return API_VERSION_6, nil
+			}
+		}
+	}
+
+	resp, statusCode, err = common.Get(&client, baseURL+"/admin/api.php?version", headers)
+	if err != nil {
+		return "", err
+	}
+	var versionResponse legacyVersionResponse
+	if statusCode < 400 && json.Unmarshal([]byte(resp), &versionResponse) == nil && versionResponse.Version > 0 {
+		return API_VERSION_5, nil
+	}
+
+	return "", errAPIVersionDetectionFailed
+}
+
+func (p *Client) legacyRequest(endpoint, action string, params url.Values) (string, error) {
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("action", action)
+	params.Set("auth", p.apiToken)
+
+	requestURL := fmt.Sprintf("%v?%v&%v", p.legacyURL, endpoint, params.Encode())
+	resp, statusCode, err := common.Get(&p.Client, requestURL, headers)
+	if err != nil {
+		// Transport errors include the URL, which has the API token in it
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = strings.ReplaceAll(urlErr.URL, params.Get("auth"), "REDACTED")
+		}
+		return "", err
+	}
+	if statusCode >= 400 {
+		return "", fmt.Errorf("Pi-Hole returned status %d for %v %v", statusCode, endpoint, action)
+	}
+
+	// api.php answers unauthenticated requests with an empty array
+	if strings.TrimSpace(resp) == "[]" {
+		return "", errInvalidApiToken
+	}
+	return resp, nil
+}
+
+func (p *Client) legacyGetPairs(endpoint string) ([][2]string, error) {
+	resp, err := p.legacyRequest(endpoint, "get", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var listResponse legacyListResponse
+	if err := json.Unmarshal([]byte(resp), &listResponse); err != nil {
+		return nil, err
+	}
+
+	pairs := [][2]string{}
+	for _, entry := range listResponse.Data {
+		if len(entry) != 2 {
+			return nil, fmt.Errorf("got bad %v entry from pihole: %v", endpoint, entry)
+		}
+		pairs = append(pairs, [2]string{entry[0], entry[1]})
+	}
+	return pairs, nil
+}
+
+func (p *Client) legacyModify(endpoint, action string, params url.Values) error {
+	resp, err := p.legacyRequest(endpoint, action, params)
+	if err != nil {
+		return err
+	}
+
+	var actionResponse legacyActionResponse
+	if err := json.Unmarshal([]byte(resp), &actionResponse); err != nil {
+		return err
+	}
+	if !actionResponse.Success {
+		return fmt.Errorf("failed to %v %v entry: %v", action, endpoint, actionResponse.Message)
+	}
+	return nil
+}
+
+func (p *Client) legacyLogin() error {
+	_, err := p.legacyRequest(legacyCustomDnsEndpoint, "get", nil)
+	return err
+}
+
+func (p *Client) legacyGetDnsRecords() (DnsRecords, error) {
+	pairs, err := p.legacyGetPairs(legacyCustomDnsEndpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	dnsRecords := DnsRecords{}
+	for _, pair := range pairs {
+		dnsRecords[DomainName(pair[0])] = IP(pair[1])
+	}
+	return dnsRecords, nil
+}
+
+// legacyAddDnsRecords adds the missing records and replaces the ones whose IP
+// differs. api.php can't change an entry, so it is deleted and added again.
+func (p *Client) legacyAddDnsRecords(dnsRecords DnsRecords) (numOfAddedDnsRecords, numOfUpdatedDnsRecords int, err error) {
+	existingRecords, err := p.legacyGetDnsRecords()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, domain := range slices.Sorted(maps.Keys(dnsRecords)) {
+		ip := dnsRecords[domain]
+		existingIP, exists := existingRecords[domain]
+		if exists && existingIP == ip {
+			continue
+		}
+		if exists {
+			err := p.legacyModify(legacyCustomDnsEndpoint, "delete", url.Values{"domain": {string(domain)}, "ip": {string(existingIP)}})
+			if err != nil {
+				return numOfAddedDnsRecords, numOfUpdatedDnsRecords, err
+			}
+		}
+		err := p.legacyModify(legacyCustomDnsEndpoint, "add", url.Values{"domain": {string(domain)}, "ip": {string(ip)}})
+		if err != nil {
+			return numOfAddedDnsRecords, numOfUpdatedDnsRecords, err
+		}
+		if exists {
+			numOfUpdatedDnsRecords++
+		} else {
+			numOfAddedDnsRecords++
+		}
+	}
+	return numOfAddedDnsRecords, numOfUpdatedDnsRecords, nil
+}
+
+func (p *Client) legacyDeleteDnsRecords(domains []string) (int, error) {
+	existingRecords, err := p.legacyGetDnsRecords()
+	if err != nil {
+		return 0, err
+	}
+
+	numOfDeletedDnsRecords := 0
+	for _, domain := range domains {
+		ip, exists := existingRecords[DomainName(domain)]
+		if !exists {
+			continue
+		}
+		err := p.legacyModify(legacyCustomDnsEndpoint, "delete", url.Values{"domain": {domain}, "ip": {string(ip)}})
+		if err != nil {
+			return numOfDeletedDnsRecords, err
+		}
+		numOfDeletedDnsRecords++
+	}
+	return numOfDeletedDnsRecords, nil
+}
+
+func (p *Client) legacyGetCNameRecords() (CNameRecords, error) {
+	pairs, err := p.legacyGetPairs(legacyCustomCNameEndpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	cNameRecords := CNameRecords{}
+	for _, pair := range pairs {
+		cNameRecords[DomainName(pair[0])] = CNameRecord{Target: Target(pair[1])}
+	}
+	return cNameRecords, nil
+}
+
+// legacyAddCNameRecords adds the missing records and replaces the ones whose
+// target differs, by deleting and adding them again.
+func (p *Client) legacyAddCNameRecords(cNameRecords CNameRecords) (numOfAddedCNameRecords, numOfUpdatedCNameRecords int, err error) {
+	existingRecords, err := p.legacyGetCNameRecords()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, domain := range slices.Sorted(maps.Keys(cNameRecords)) {
+		record := cNameRecords[domain]
+		existingRecord, exists := existingRecords[domain]
+		// TTLs are not compared, Pi-Hole v5 doesn't have them
+		if exists && existingRecord.Target == record.Target {
+			continue
+		}
+		if record.TTL > 0 {
+			log.Warn("Pi-Hole v5 does not support TTLs on CNAME records, ignoring it", "domain", domain, "ttl", record.TTL)
+		}
+		if exists {
+			err := p.legacyModify(legacyCustomCNameEndpoint, "delete", url.Values{"domain": {string(domain)}, "target": {string(existingRecord.Target)}})
+			if err != nil {
+				return numOfAddedCNameRecords, numOfUpdatedCNameRecords, err
+			}
+		}
+		err := p.legacyModify(legacyCustomCNameEndpoint, "add", url.Values{"domain": {string(domain)}, "target": {string(record.Target)}})
+		if err != nil {
+			return numOfAddedCNameRecords, numOfUpdatedCNameRecords, err
+		}
+		if exists {
+			numOfUpdatedCNameRecords++
+		} else {
+			numOfAddedCNameRecords++
+		}
+	}
+	return numOfAddedCNameRecords, numOfUpdatedCNameRecords, nil
+}
+
+func (p *Client) legacyDeleteCNameRecords(domains []string) (int, error) {
+	existingRecords, err := p.legacyGetCNameRecords()
+	if err != nil {
+		return 0, err
+	}
+
+	numOfDeletedCNameRecords := 0
+	for _, domain := range domains {
+		record, exists := existingRecords[DomainName(domain)]
+		if !exists {
+			continue
+		}
+		err := p.legacyModify(legacyCustomCNameEndpoint, "delete", url.Values{"domain": {domain}, "target": {string(record.Target)}})
+		if err != nil {
+			return numOfDeletedCNameRecords, err
+		}
+		numOfDeletedCNameRecords++
+	}
+	return numOfDeletedCNameRecords, nil
+}
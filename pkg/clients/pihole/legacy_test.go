@@ -0,0 +1,149 @@
+//go:build unit
+
+package pihole
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupLegacyTestServer creates a new test server and a v5 client pointing to it.
+func setupLegacyTestServer(handler http.Handler, apiToken string) (*Client, *httptest.Server) {
+	server := httptest.NewServer(handler)
+	client := NewLegacyClient(server.URL, apiToken)
+	client.Client = *server.Client()
+	return client, server
+}
+
+func TestDetectAPIVersion(t *testing.T) {
+	t.Run("v6", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/auth", r.URL.Path)
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = fmt.Fprint(w, `{"session": {"valid": false, "sid": null, "validity": -1}}`)
+		})
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		version, err := DetectAPIVersion(server.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, API_VERSION_6, version)
+	})
+
+	t.Run("v5", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/admin/api.php" {
+				_, _ = fmt.Fprint(w, `{"version": 3}`)
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		})
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		version, err := DetectAPIVersion(server.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, API_VERSION_5, version)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		_, err := DetectAPIVersion(server.URL)
+		assert.ErrorIs(t, err, errAPIVersionDetectionFailed)
+	})
+}
+
+func TestLegacyLogin(t *testing.T) {
+	t.Run("invalid token", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = fmt.Fprint(w, `[]`)
+		})
+		client, server := setupLegacyTestServer(handler, "wrong-token")
+		defer server.Close()
+
+		err := client.Login()
+		assert.ErrorIs(t, err, errInvalidApiToken)
+	})
+}
+
+func TestLegacyRequestErrorHidesAPIToken(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	client, server := setupLegacyTestServer(handler, "secret-token")
+	server.Close()
+
+	err := client.Login()
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "secret-token")
+	assert.Contains(t, err.Error(), "auth=REDACTED")
+}
+
+func TestLegacyAddDnsRecords(t *testing.T) {
+	var added, deleted []string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/admin/api.php", r.URL.Path)
+		assert.True(t, r.URL.Query().Has("customdns"))
+		assert.Equal(t, "test-token", r.URL.Query().Get("auth"))
+
+		switch r.URL.Query().Get("action") {
+		case "get":
+			_, _ = fmt.Fprint(w, `{"data": [["one.com", "1.1.1.1"], ["two.com", "1.2.3.4"]]}`)
+		case "add":
+			assert.Equal(t, "1.2.3.4", r.URL.Query().Get("ip"))
+			added = append(added, r.URL.Query().Get("domain"))
+			_, _ = fmt.Fprint(w, `{"success": true, "message": ""}`)
+		case "delete":
+			deleted = append(deleted, r.URL.Query().Get("domain")+","+r.URL.Query().Get("ip"))
+			_, _ = fmt.Fprint(w, `{"success": true, "message": ""}`)
+		default:
+			t.Fatalf("Received unexpected request: %s", r.URL)
+		}
+	})
+	client, server := setupLegacyTestServer(handler, "test-token")
+	defer server.Close()
+
+	count, updated, err := client.AddDnsRecords([]string{"one.com", "test1.com", "test2.com", "two.com"}, "1.2.3.4")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, 1, updated)
+	// The IP of one.com changed, so it is deleted and added again
+	assert.Equal(t, []string{"one.com,1.1.1.1"}, deleted)
+	assert.Equal(t, []string{"one.com", "test1.com", "test2.com"}, added)
+}
+
+func TestLegacyDeleteCNameRecords(t *testing.T) {
+	var deleted []string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, r.URL.Query().Has("customcname"))
+
+		switch r.URL.Query().Get("action") {
+		case "get":
+			_, _ = fmt.Fprint(w, `{"data": [["one.com", "one.target.com"], ["two.com", "two.target.com"]]}`)
+		case "delete":
+			deleted = append(deleted, r.URL.Query().Get("domain")+","+r.URL.Query().Get("target"))
+			_, _ = fmt.Fprint(w, `{"success": true, "message": ""}`)
+		default:
+			t.Fatalf("Received unexpected request: %s", r.URL)
+		}
+	})
+	client, server := setupLegacyTestServer(handler, "test-token")
+	defer server.Close()
+
+	count, err := client.DeleteCNameRecords([]string{"two.com", "non-existent.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, []string{"two.com,two.target.com"}, deleted)
+}
+
+func TestLegacyAPITokenFromPassword(t *testing.T) {
+	// Pi-Hole v5 stores WEBPASSWORD as sha256(sha256(password)), "password" here
+	assert.Equal(t, "113459eb7bb31bddee85ade5230d6ad5d8b2fb52879e00a84ff6ae1067a210d3", LegacyAPITokenFromPassword("password"))
+}
@@ -5,8 +5,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/deepspace2/plugnpin/pkg/clients/common"
@@ -16,11 +19,28 @@ import (
 
 var log = logging.GetLogger("pihole")
 
+const (
+	// maxAuthRetries bounds how many times a request is retried after
+	// re-authenticating because Pi-Hole answered with 401.
+	maxAuthRetries = 2
+	// sessionRenewalLeeway is how long before the session's validity runs out
+	// that it is proactively renewed.
+	sessionRenewalLeeway = 30 * time.Second
+)
+
 type Client struct {
 	http.Client
-	baseURL  string
-	password string
-	sid      string
+	apiToken        string
+	apiVersion      string
+	baseURL         string
+	legacyURL       string
+	password        string
+	totpSecret      string
+	sid             string
+	sessionValidity time.Duration
+	sidExpireTime   time.Time
+	lastTotpStep    int64
+	mu              sync.Mutex
 }
 
 var headers map[string]string = map[string]string{
@@ -28,20 +48,50 @@ var headers map[string]string = map[string]string{
 	"content-type": "application/json",
 }
 
-func NewClient(baseURL, password string) *Client {
+func NewClient(baseURL, password, totpSecret string) *Client {
 	return &Client{
 		Client: http.Client{
 			Transport: common.NewInstrumentedRoundTripper(metrics.PI_HOLE, metrics.ObserveApiRequestDuration),
 		},
-		baseURL:  fmt.Sprintf("%v/api", baseURL),
-		password: password,
-		sid:      "",
+		apiVersion: API_VERSION_6,
+		baseURL:    fmt.Sprintf("%v/api", baseURL),
+		password:   password,
+		totpSecret: totpSecret,
+		sid:        "",
 	}
 }
 
+// APIVersion returns the Pi-Hole API version the client talks to.
+func (p *Client) APIVersion() string {
+	return p.apiVersion
+}
+
 func (p *Client) Login() error {
-	loginPayload := fmt.Sprintf(`{"password": "%v"}`, p.password)
-	loginResponseString, statusCode, err := common.Post(&p.Client, p.baseURL+"/auth", headers, &loginPayload)
+	if p.apiVersion == API_VERSION_5 {
+		return p.legacyLogin()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.login()
+}
+
+func (p *Client) login() error {
+	loginPayload := loginRequest{Password: p.password}
+	if p.totpSecret != "" {
+		totp, err := p.nextTotpCode()
+		if err != nil {
+			return err
+		}
+		loginPayload.Totp = &totp
+	}
+
+	payloadBytes, err := json.Marshal(loginPayload)
+	if err != nil {
+		return err
+	}
+	payloadString := string(payloadBytes)
+	loginResponseString, statusCode, err := common.Post(&p.Client, p.baseURL+"/auth", headers, &payloadString)
 	if err != nil {
 		return err
 	}
@@ -52,14 +102,49 @@ func (p *Client) Login() error {
 	}
 
 	if statusCode >= 400 || resp.Session.Sid == "" {
-		return errors.New(resp.Session.Message)
+		message := resp.Session.Message
+		if message == "" {
+			var errorResponse ErrorResponse
+			if json.Unmarshal([]byte(loginResponseString), &errorResponse) == nil {
+				message = errorResponse.Error.Message
+			}
+		}
+		if p.totpSecret == "" && (resp.Session.Totp || strings.Contains(message, "2FA")) {
+			return errTotpRequired
+		}
+		return errors.New(message)
 	}
 
 	p.sid = resp.Session.Sid
+	p.sessionValidity = time.Duration(resp.Session.Validity) * time.Second
+	p.renewSessionExpireTime()
 	return nil
 }
 
+// renewSessionExpireTime slides the session's expiry forward, as Pi-Hole
+// extends a session's validity every time it is used.
+func (p *Client) renewSessionExpireTime() {
+	if p.sessionValidity <= 0 {
+		p.sidExpireTime = time.Time{}
+		return
+	}
+	p.sidExpireTime = time.Now().Add(p.sessionValidity)
+}
+
+func (p *Client) hasSessionExpired() bool {
+	if p.sidExpireTime.IsZero() {
+		return false
+	}
+	return time.Now().Add(sessionRenewalLeeway).After(p.sidExpireTime)
+}
+
 func (p *Client) Logout() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.logout()
+}
+
+func (p *Client) logout() error {
 	if p.sid == "" {
 		return nil
 	}
@@ -67,12 +152,14 @@ func (p *Client) Logout() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	headers["X-FTL-SID"] = p.sid
-	_, statusCode, err := common.DeleteWithContext(ctx, &p.Client, p.baseURL+"/auth", headers)
+	requestHeaders := maps.Clone(headers)
+	requestHeaders["X-FTL-SID"] = p.sid
+	_, statusCode, err := common.DeleteWithContext(ctx, &p.Client, p.baseURL+"/auth", requestHeaders)
 	if err != nil {
 		return err
 	}
 	p.sid = ""
+	p.sidExpireTime = time.Time{}
 	if statusCode >= 400 {
 		log.Warn("Pi-Hole logout returned non-success status", "status", statusCode)
 	} else {
@@ -81,6 +168,98 @@ func (p *Client) Logout() error {
 	return nil
 }
 
+func (p *Client) makeRequest(method, url string, payload *string) (string, int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.sid == "" {
+		return "", 0, errMissingSessionId
+	}
+
+	if p.hasSessionExpired() {
+		log.Debug("Pi-Hole session is about to expire, renewing it")
+		if err := p.refreshAuth(); err != nil {
+			return "", 0, errors.Join(errAuthRefreshFailed, err)
+		}
+	}
+
+	doRequest := func() (string, int, error) {
+		requestHeaders := maps.Clone(headers)
+		requestHeaders["X-FTL-SID"] = p.sid
+		switch method {
+		case http.MethodGet:
+			return common.Get(&p.Client, url, requestHeaders)
+		case http.MethodPatch:
+			return common.Patch(&p.Client, url, requestHeaders, *payload)
+		default:
+			return "", 0, fmt.Errorf("unsupported http method: %s", method)
+		}
+	}
+
+	resp, statusCode, err := doRequest()
+	for attempt := 1; err == nil && statusCode == http.StatusUnauthorized; attempt++ {
+		if attempt > maxAuthRetries {
+			return resp, statusCode, errAuthRetriesExhausted
+		}
+		log.Info("Received 401 from Pi-Hole, re-authenticating and retrying", "attempt", attempt)
+		if refreshErr := p.refreshAuth(); refreshErr != nil {
+			return resp, statusCode, errors.Join(errAuthRefreshFailed, refreshErr)
+		}
+		resp, statusCode, err = doRequest()
+	}
+
+	if err == nil && statusCode < 400 {
+		p.renewSessionExpireTime()
+	}
+
+	return resp, statusCode, err
+}
+
+func parseErrorResponse(resp string) error {
+	var errorResponse ErrorResponse
+	err := json.Unmarshal([]byte(resp), &errorResponse)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%v. %v", errorResponse.Error.Message, errorResponse.Error.Hint)
+}
+
+func (p *Client) getConfig() (*configResponse, error) {
+	configResponseString, statusCode, err := p.makeRequest(http.MethodGet, p.baseURL+"/config", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if statusCode >= 400 {
+		return nil, parseErrorResponse(configResponseString)
+	}
+
+	var resp configResponse
+	err = json.Unmarshal([]byte(configResponseString), &resp)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (p *Client) patchConfig(payload any) error {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	payloadString := string(payloadBytes)
+	resp, statusCode, err := p.makeRequest(http.MethodPatch, p.baseURL+"/config", &payloadString)
+	if err != nil {
+		return err
+	}
+
+	if statusCode >= 400 {
+		return parseErrorResponse(resp)
+	}
+	return nil
+}
+
 func rawDnsRecordToRecord(rawDnsRecord string) (DomainName, IP, error) {
 	splitRawDnsRecord := strings.Split(rawDnsRecord, " ")
 	if len(splitRawDnsRecord) == 2 {
@@ -97,18 +276,11 @@ func dnsRecordToRaw(domain DomainName, ip IP) string {
 }
 
 func (p *Client) GetDnsRecords() (DnsRecords, error) {
-	if p.sid == "" {
-		return nil, errMissingSessionId
-	}
-
-	headers["X-FTL-SID"] = p.sid
-	configResponseString, _, err := common.Get(&p.Client, p.baseURL+"/config", headers)
-	if err != nil {
-		return nil, err
+	if p.apiVersion == API_VERSION_5 {
+		return p.legacyGetDnsRecords()
 	}
 
-	var resp configResponse
-	err = json.Unmarshal([]byte(configResponseString), &resp)
+	resp, err := p.getConfig()
 	if err != nil {
 		return nil, err
 	}
@@ -124,68 +296,68 @@ func (p *Client) GetDnsRecords() (DnsRecords, error) {
 	return dnsRecords, nil
 }
 
-func (p *Client) AddDnsRecords(domains []string, ip string) (numOfAddedDnsRecords int, err error) {
-	existingRecords, err := p.GetDnsRecords()
-	if err != nil {
-		return 0, err
-	}
-
-	addedDomains := []string{}
-	for _, domain := range domains {
-		d := DomainName(domain)
-		if _, exists := existingRecords[d]; !exists {
-			existingRecords[d] = IP(ip)
-			addedDomains = append(addedDomains, domain)
-		}
-	}
-
-	if len(addedDomains) == 0 {
-		return 0, nil
-	}
-
+func (p *Client) setDnsRecords(dnsRecords DnsRecords) error {
 	rawRecordsSlice := []string{}
-	for domain, ip := range existingRecords {
+	for domain, ip := range dnsRecords {
 		rawRecordsSlice = append(rawRecordsSlice, dnsRecordToRaw(domain, ip))
 	}
 
 	payload := updateDnsRecordsPayload{}
 	payload.Config.DNS.Hosts = rawRecordsSlice
 
-	payloadString, err := json.Marshal(payload)
-	if err != nil {
-		return 0, err
+	return p.patchConfig(payload)
+}
+
+func (p *Client) AddDnsRecords(domains []string, ip string) (numOfAddedDnsRecords, numOfUpdatedDnsRecords int, err error) {
+	dnsRecords := DnsRecords{}
+	for _, domain := range domains {
+		dnsRecords[DomainName(domain)] = IP(ip)
 	}
+	return p.AddDnsRecordsBatch(dnsRecords)
+}
 
-	if p.sid == "" {
-		return 0, errMissingSessionId
+// AddDnsRecordsBatch adds all of the given records that don't exist yet and
+// replaces the ones whose IP differs, with a single read and at most a single
+// write of the config.
+func (p *Client) AddDnsRecordsBatch(dnsRecords DnsRecords) (numOfAddedDnsRecords, numOfUpdatedDnsRecords int, err error) {
+	if p.apiVersion == API_VERSION_5 {
+		return p.legacyAddDnsRecords(dnsRecords)
 	}
-	headers["X-FTL-SID"] = p.sid
-	resp, statusCode, err := common.Patch(&p.Client, p.baseURL+"/config", headers, string(payloadString))
+
+	existingRecords, err := p.GetDnsRecords()
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
-	if statusCode == 401 {
-		if err = p.refreshAuth(); err != nil {
-			return 0, errors.Join(errAuthRefreshFailed, err)
+	for domain, ip := range dnsRecords {
+		existingIP, exists := existingRecords[domain]
+		if exists && existingIP == ip {
+			continue
 		}
-		return p.AddDnsRecords(domains, ip)
+		if exists {
+			numOfUpdatedDnsRecords++
+		} else {
+			numOfAddedDnsRecords++
+		}
+		existingRecords[domain] = ip
 	}
 
-	if statusCode >= 400 {
-		var errorResponse ErrorResponse
-		err = json.Unmarshal([]byte(resp), &errorResponse)
-		if err != nil {
-			return 0, err
-		}
+	if numOfAddedDnsRecords == 0 && numOfUpdatedDnsRecords == 0 {
+		return 0, 0, nil
+	}
 
-		return 0, fmt.Errorf("%v. %v", errorResponse.Error.Message, errorResponse.Error.Hint)
+	if err := p.setDnsRecords(existingRecords); err != nil {
+		return 0, 0, err
 	}
 
-	return len(addedDomains), nil
+	return numOfAddedDnsRecords, numOfUpdatedDnsRecords, nil
 }
 
 func (p *Client) DeleteDnsRecords(domains []string) (numOfDeletedDnsRecords int, err error) {
+	if p.apiVersion == API_VERSION_5 {
+		return p.legacyDeleteDnsRecords(domains)
+	}
+
 	existingRecords, err := p.GetDnsRecords()
 	if err != nil {
 		return 0, err
@@ -204,155 +376,129 @@ func (p *Client) DeleteDnsRecords(domains []string) (numOfDeletedDnsRecords int,
 		return 0, nil
 	}
 
-	rawRecordsSlice := []string{}
-	for domain, ip := range existingRecords {
-		rawRecordsSlice = append(rawRecordsSlice, dnsRecordToRaw(domain, ip))
-	}
-
-	payload := updateDnsRecordsPayload{}
-	payload.Config.DNS.Hosts = rawRecordsSlice
-
-	payloadString, err := json.Marshal(payload)
-	if err != nil {
-		return 0, err
-	}
-
-	if p.sid == "" {
-		return 0, errMissingSessionId
-	}
-	headers["X-FTL-SID"] = p.sid
-	resp, statusCode, err := common.Patch(&p.Client, p.baseURL+"/config", headers, string(payloadString))
-	if err != nil {
+	if err := p.setDnsRecords(existingRecords); err != nil {
 		return 0, err
 	}
 
-	if statusCode == 401 {
-		if err = p.refreshAuth(); err != nil {
-			return 0, errors.Join(errAuthRefreshFailed, err)
-		}
-		return p.DeleteDnsRecords(domains)
-	}
-
-	if statusCode >= 400 {
-		var errorResponse ErrorResponse
-		err = json.Unmarshal([]byte(resp), &errorResponse)
-		if err != nil {
-			return 0, err
-		}
-		return 0, fmt.Errorf("%v. %v", errorResponse.Error.Message, errorResponse.Error.Hint)
-	}
-
 	return len(deletedDomains), nil
 }
 
-func rawCNameRecordToRecord(rawCNameRecord string) (DomainName, Target, error) {
+// rawCNameRecordToRecord parses a "domain,target[,ttl]" entry of dns.cnameRecords.
+func rawCNameRecordToRecord(rawCNameRecord string) (DomainName, CNameRecord, error) {
 	splitRawCNameRecord := strings.Split(rawCNameRecord, ",")
-	if len(splitRawCNameRecord) == 2 {
+	switch len(splitRawCNameRecord) {
+	case 2:
 		domain := DomainName(splitRawCNameRecord[0])
 		target := Target(splitRawCNameRecord[1])
-		return domain, target, nil
-	} else {
-		return "", "", fmt.Errorf("got bad raw CNAME record from pihole: %v", rawCNameRecord)
+		return domain, CNameRecord{Target: target}, nil
+	case 3:
+		ttl, err := strconv.Atoi(splitRawCNameRecord[2])
+		if err != nil || ttl < 0 {
+			return "", CNameRecord{}, fmt.Errorf("got bad TTL in raw CNAME record from pihole: %v", rawCNameRecord)
+		}
+		domain := DomainName(splitRawCNameRecord[0])
+		target := Target(splitRawCNameRecord[1])
+		return domain, CNameRecord{Target: target, TTL: ttl}, nil
+	default:
+		return "", CNameRecord{}, fmt.Errorf("got bad raw CNAME record from pihole: %v", rawCNameRecord)
 	}
 }
 
-func cNameRecordToRaw(domain DomainName, target Target) string {
-	return fmt.Sprintf("%v,%v", domain, target)
-}
-
-func (p *Client) getCNameRecords() (CNameRecords, error) {
-	if p.sid == "" {
-		return nil, errMissingSessionId
+func cNameRecordToRaw(domain DomainName, record CNameRecord) string {
+	if record.TTL > 0 {
+		return fmt.Sprintf("%v,%v,%v", domain, record.Target, record.TTL)
 	}
+	return fmt.Sprintf("%v,%v", domain, record.Target)
+}
 
-	headers["X-FTL-SID"] = p.sid
-	configResponseString, _, err := common.Get(&p.Client, p.baseURL+"/config", headers)
-	if err != nil {
-		return nil, err
+func (p *Client) GetCNameRecords() (CNameRecords, error) {
+	if p.apiVersion == API_VERSION_5 {
+		return p.legacyGetCNameRecords()
 	}
 
-	var resp configResponse
-	err = json.Unmarshal([]byte(configResponseString), &resp)
+	resp, err := p.getConfig()
 	if err != nil {
 		return nil, err
 	}
 
 	cNameRecords := CNameRecords{}
 	for _, rawCNameRecord := range resp.Config.DNS.CnameRecords {
-		domain, target, err := rawCNameRecordToRecord(rawCNameRecord.(string))
+		domain, record, err := rawCNameRecordToRecord(rawCNameRecord.(string))
 		if err != nil {
 			return nil, err
 		}
-		cNameRecords[domain] = target
+		cNameRecords[domain] = record
 	}
 
 	return cNameRecords, nil
 }
 
-func (p *Client) AddCNameRecords(domains []string, target string) (numOfAddedCNameRecords int, err error) {
-	existingRecords, err := p.getCNameRecords()
-	if err != nil {
-		return 0, err
-	}
-
-	addedDomains := []string{}
-	for _, domain := range domains {
-		d := DomainName(domain)
-		if _, exists := existingRecords[d]; !exists {
-			existingRecords[d] = Target(target)
-			addedDomains = append(addedDomains, domain)
-		}
-	}
-
-	if len(addedDomains) == 0 {
-		return 0, nil
-	}
-
+func (p *Client) setCNameRecords(cNameRecords CNameRecords) error {
 	rawRecordsSlice := []string{}
-	for domain, target := range existingRecords {
-		rawRecordsSlice = append(rawRecordsSlice, cNameRecordToRaw(domain, target))
+	for domain, record := range cNameRecords {
+		rawRecordsSlice = append(rawRecordsSlice, cNameRecordToRaw(domain, record))
 	}
 
 	payload := updateCNameRecordsPayload{}
 	payload.Config.DNS.CnameRecords = rawRecordsSlice
 
-	payloadString, err := json.Marshal(payload)
-	if err != nil {
-		return 0, err
+	return p.patchConfig(payload)
+}
+
+// AddCNameRecords adds a CNAME record pointing to target for every domain that
+// doesn't have one yet, and replaces the ones with another target or TTL. A
+// ttl of 0 leaves the TTL to Pi-Hole's default.
+func (p *Client) AddCNameRecords(domains []string, target string, ttl int) (numOfAddedCNameRecords, numOfUpdatedCNameRecords int, err error) {
+	cNameRecords := CNameRecords{}
+	for _, domain := range domains {
+		cNameRecords[DomainName(domain)] = CNameRecord{Target: Target(target), TTL: ttl}
 	}
+	return p.AddCNameRecordsBatch(cNameRecords)
+}
 
-	if p.sid == "" {
-		return 0, errMissingSessionId
+// AddCNameRecordsBatch adds all of the given records that don't exist yet and
+// replaces the ones whose target or TTL differs, with a single read and at
+// most a single write of the config.
+func (p *Client) AddCNameRecordsBatch(cNameRecords CNameRecords) (numOfAddedCNameRecords, numOfUpdatedCNameRecords int, err error) {
+	if p.apiVersion == API_VERSION_5 {
+		return p.legacyAddCNameRecords(cNameRecords)
 	}
 
-	headers["X-FTL-SID"] = p.sid
-	resp, statusCode, err := common.Patch(&p.Client, p.baseURL+"/config", headers, string(payloadString))
+	existingRecords, err := p.GetCNameRecords()
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
-	if statusCode == 401 {
-		if err = p.refreshAuth(); err != nil {
-			return 0, errors.Join(errAuthRefreshFailed, err)
+	for domain, record := range cNameRecords {
+		existingRecord, exists := existingRecords[domain]
+		if exists && existingRecord == record {
+			continue
 		}
-		return p.AddCNameRecords(domains, target)
+		if exists {
+			numOfUpdatedCNameRecords++
+		} else {
+			numOfAddedCNameRecords++
+		}
+		existingRecords[domain] = record
 	}
 
-	if statusCode >= 400 {
-		var errorResponse ErrorResponse
-		err = json.Unmarshal([]byte(resp), &errorResponse)
-		if err != nil {
-			return 0, err
-		}
+	if numOfAddedCNameRecords == 0 && numOfUpdatedCNameRecords == 0 {
+		return 0, 0, nil
+	}
 
-		return 0, fmt.Errorf("%v. %v", errorResponse.Error.Message, errorResponse.Error.Hint)
+	if err := p.setCNameRecords(existingRecords); err != nil {
+		return 0, 0, err
 	}
 
-	return len(addedDomains), nil
+	return numOfAddedCNameRecords, numOfUpdatedCNameRecords, nil
 }
 
 func (p *Client) DeleteCNameRecords(domains []string) (numOfDeletedCNameRecords int, err error) {
-	existingRecords, err := p.getCNameRecords()
+	if p.apiVersion == API_VERSION_5 {
+		return p.legacyDeleteCNameRecords(domains)
+	}
+
+	existingRecords, err := p.GetCNameRecords()
 	if err != nil {
 		return 0, err
 	}
@@ -370,53 +516,18 @@ func (p *Client) DeleteCNameRecords(domains []string) (numOfDeletedCNameRecords
 		return 0, nil
 	}
 
-	rawRecordsSlice := []string{}
-	for domain, target := range existingRecords {
-		rawRecordsSlice = append(rawRecordsSlice, cNameRecordToRaw(domain, target))
-	}
-
-	payload := updateCNameRecordsPayload{}
-	payload.Config.DNS.CnameRecords = rawRecordsSlice
-
-	payloadString, err := json.Marshal(payload)
-	if err != nil {
-		return 0, err
-	}
-
-	if p.sid == "" {
-		return 0, errMissingSessionId
-	}
-
-	headers["X-FTL-SID"] = p.sid
-	resp, statusCode, err := common.Patch(&p.Client, p.baseURL+"/config", headers, string(payloadString))
-	if err != nil {
+	if err := p.setCNameRecords(existingRecords); err != nil {
 		return 0, err
 	}
 
-	if statusCode == 401 {
-		if err = p.refreshAuth(); err != nil {
-			return 0, errors.Join(errAuthRefreshFailed, err)
-		}
-		return p.DeleteCNameRecords(domains)
-	}
-
-	if statusCode >= 400 {
-		var errorResponse ErrorResponse
-		err = json.Unmarshal([]byte(resp), &errorResponse)
-		if err != nil {
-			return 0, err
-		}
-
-		return 0, fmt.Errorf("%v. %v", errorResponse.Error.Message, errorResponse.Error.Hint)
-	}
-
 	return len(deletedDomains), nil
 }
 
+// refreshAuth must be called with p.mu held.
 func (p *Client) refreshAuth() error {
 	log.Info("Refreshing Pi-Hole authentication")
-	if err := p.Logout(); err != nil {
+	if err := p.logout(); err != nil {
 		log.Warn("Failed to logout old Pi-Hole session", "error", err)
 	}
-	return p.Login()
+	return p.login()
 }
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -15,7 +16,7 @@ import (
 // setupTestServer creates a new test server and a client pointing to it.
 func setupTestServer(handler http.Handler, password string) (*Client, *httptest.Server) {
 	server := httptest.NewServer(handler)
-	client := NewClient(server.URL, password)
+	client := NewClient(server.URL, password, "")
 	client.Client = *server.Client() // Replace the default client with the test server's client
 	return client, server
 }
@@ -58,6 +59,130 @@ func TestLogin(t *testing.T) {
 		assert.Equal(t, "Invalid password", err.Error())
 		assert.Empty(t, client.sid)
 	})
+
+	t.Run("session expiry is tracked from validity", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			_, _ = fmt.Fprint(w, `{"session": {"sid": "test-sid", "validity": 1800, "message": "Login successful"}}`)
+		})
+		client, server := setupTestServer(handler, "test-password")
+		defer server.Close()
+
+		err := client.Login()
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1800*time.Second, client.sessionValidity)
+		assert.WithinDuration(t, time.Now().Add(1800*time.Second), client.sidExpireTime, 5*time.Second)
+	})
+
+	t.Run("totp code is sent when a secret is configured", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var payload loginRequest
+			_ = json.NewDecoder(r.Body).Decode(&payload)
+			assert.Equal(t, "test-password", payload.Password)
+			if assert.NotNil(t, payload.Totp) {
+				expected, err := generateTotpCode("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", time.Now().Unix()/totpPeriod)
+				assert.NoError(t, err)
+				assert.Equal(t, expected, *payload.Totp)
+			}
+
+			w.WriteHeader(http.StatusOK)
+			_, _ = fmt.Fprint(w, `{"session": {"sid": "test-sid", "totp": true, "validity": 1800}}`)
+		})
+		client, server := setupTestServer(handler, "test-password")
+		defer server.Close()
+		client.totpSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+		err := client.Login()
+
+		assert.NoError(t, err)
+		assert.Equal(t, "test-sid", client.sid)
+	})
+
+	t.Run("2FA enabled without a totp secret", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprint(w, `{"error": {"key": "bad_request", "message": "No 2FA token found in JSON payload", "hint": null}}`)
+		})
+		client, server := setupTestServer(handler, "test-password")
+		defer server.Close()
+
+		err := client.Login()
+
+		assert.ErrorIs(t, err, errTotpRequired)
+		assert.Empty(t, client.sid)
+	})
+}
+
+func TestSessionRenewal(t *testing.T) {
+	t.Run("expired session is renewed before the request", func(t *testing.T) {
+		var authCalls int
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/api/auth" && r.Method == http.MethodDelete:
+				w.WriteHeader(http.StatusOK)
+			case r.URL.Path == "/api/auth" && r.Method == http.MethodPost:
+				authCalls++
+				w.WriteHeader(http.StatusOK)
+				_, _ = fmt.Fprint(w, `{"session": {"sid": "new-sid", "validity": 1800}}`)
+			case r.URL.Path == "/api/config" && r.Method == http.MethodGet:
+				assert.Equal(t, "new-sid", r.Header.Get("X-FTL-SID"))
+				w.WriteHeader(http.StatusOK)
+				_, _ = fmt.Fprint(w, `{"config": {"dns": {"hosts": []}}}`)
+			default:
+				t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+			}
+		})
+		client, server := setupTestServer(handler, "test-password")
+		defer server.Close()
+		client.sid = "old-sid"
+		client.sidExpireTime = time.Now().Add(-time.Minute)
+
+		_, err := client.GetDnsRecords()
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, authCalls)
+		assert.Equal(t, "new-sid", client.sid)
+	})
+
+	t.Run("re-authentication on 401 is bounded", func(t *testing.T) {
+		var authCalls, configCalls int
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/api/auth" && r.Method == http.MethodDelete:
+				w.WriteHeader(http.StatusOK)
+			case r.URL.Path == "/api/auth" && r.Method == http.MethodPost:
+				authCalls++
+				w.WriteHeader(http.StatusOK)
+				_, _ = fmt.Fprint(w, `{"session": {"sid": "test-sid", "validity": 1800}}`)
+			case r.URL.Path == "/api/config":
+				configCalls++
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = fmt.Fprint(w, `{"error": {"key": "unauthorized", "message": "Unauthorized", "hint": null}}`)
+			default:
+				t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+			}
+		})
+		client, server := setupTestServer(handler, "test-password")
+		defer server.Close()
+		client.sid = "test-sid"
+
+		_, _, err := client.AddDnsRecords([]string{"test.com"}, "1.2.3.4")
+
+		assert.ErrorIs(t, err, errAuthRetriesExhausted)
+		assert.Equal(t, maxAuthRetries, authCalls)
+		assert.Equal(t, maxAuthRetries+1, configCalls)
+	})
+}
+
+func TestGenerateTotpCode(t *testing.T) {
+	// RFC 6238 appendix B test vector (SHA1, T=59), truncated to 6 digits
+	code, err := generateTotpCode("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", 59/totpPeriod)
+	assert.NoError(t, err)
+	assert.Equal(t, 287082, code)
+
+	_, err = generateTotpCode("not base32!", 1)
+	assert.ErrorIs(t, err, errInvalidTotpSecret)
 }
 
 func TestLogout(t *testing.T) {
@@ -152,12 +277,77 @@ func TestAddDnsRecords(t *testing.T) {
 		// Manually set the session ID that the login step would have provided
 		client.sid = "test-sid"
 
-		count, err := client.AddDnsRecords([]string{"test1.com", "test2.com"}, "1.2.3.4")
+		count, updated, err := client.AddDnsRecords([]string{"test1.com", "test2.com"}, "1.2.3.4")
 		assert.Equal(t, 2, count)
+		assert.Equal(t, 0, updated)
 		assert.NoError(t, err)
 	})
 }
 
+func TestAddDnsRecordsBatch(t *testing.T) {
+	t.Run("single read and write for all added and changed records", func(t *testing.T) {
+		getCalls, patchCalls := 0, 0
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/config", r.URL.Path)
+
+			if r.Method == http.MethodGet {
+				getCalls++
+				w.WriteHeader(http.StatusOK)
+				_, _ = fmt.Fprint(w, `{"config": {"dns": {"hosts": ["1.1.1.1 one.com"]}}}`)
+				return
+			}
+
+			if r.Method == http.MethodPatch {
+				patchCalls++
+				var payload updateDnsRecordsPayload
+				err := json.NewDecoder(r.Body).Decode(&payload)
+				assert.NoError(t, err)
+
+				expectedRecords := []string{"9.9.9.9 one.com", "1.2.3.4 test1.com", "5.6.7.8 test2.com"}
+				assert.ElementsMatch(t, expectedRecords, payload.Config.DNS.Hosts)
+
+				w.WriteHeader(http.StatusOK)
+				_, _ = fmt.Fprint(w, `{"success": true}`)
+				return
+			}
+
+			t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+		})
+
+		client, server := setupTestServer(handler, "test-password")
+		defer server.Close()
+		client.sid = "test-sid"
+
+		count, updated, err := client.AddDnsRecordsBatch(DnsRecords{"one.com": "9.9.9.9", "test1.com": "1.2.3.4", "test2.com": "5.6.7.8"})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+		assert.Equal(t, 1, updated)
+		assert.Equal(t, 1, getCalls)
+		assert.Equal(t, 1, patchCalls)
+	})
+
+	t.Run("no write when nothing is missing or changed", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				w.WriteHeader(http.StatusOK)
+				_, _ = fmt.Fprint(w, `{"config": {"dns": {"hosts": ["1.1.1.1 one.com"]}}}`)
+				return
+			}
+
+			t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+		})
+
+		client, server := setupTestServer(handler, "test-password")
+		defer server.Close()
+		client.sid = "test-sid"
+
+		count, updated, err := client.AddDnsRecordsBatch(DnsRecords{"one.com": "1.1.1.1"})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+		assert.Equal(t, 0, updated)
+	})
+}
+
 func TestDeleteDnsRecords(t *testing.T) {
 	t.Run("successful delete multiple", func(t *testing.T) {
 		patchCalled := false
@@ -306,8 +496,9 @@ func TestAddCNameRecords(t *testing.T) {
 		// Manually set the session ID that the login step would have provided
 		client.sid = "test-sid"
 
-		count, err := client.AddCNameRecords([]string{"test1.com", "test2.com"}, "test.two.com")
+		count, updated, err := client.AddCNameRecords([]string{"test1.com", "test2.com"}, "test.two.com", 0)
 		assert.Equal(t, 2, count)
+		assert.Equal(t, 0, updated)
 		assert.NoError(t, err)
 	})
 }
@@ -378,35 +569,115 @@ func TestDeleteCNameRecords(t *testing.T) {
 	})
 }
 
+func TestAddCNameRecordsWithTTL(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusOK)
+			_, _ = fmt.Fprint(w, `{"config": {"dns": {"cnameRecords": ["one.com,one.two.com,300"]}}}`)
+			return
+		}
+
+		var payload updateCNameRecordsPayload
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		assert.NoError(t, err)
+
+		// The TTL of the existing record must be preserved
+		expectedCNames := []string{"one.com,one.two.com,300", "test1.com,test.two.com,60"}
+		assert.ElementsMatch(t, expectedCNames, payload.Config.DNS.CnameRecords)
+
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprint(w, `{"success": true}`)
+	})
+
+	client, server := setupTestServer(handler, "test-password")
+	defer server.Close()
+	client.sid = "test-sid"
+
+	count, updated, err := client.AddCNameRecords([]string{"test1.com"}, "test.two.com", 60)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, 0, updated)
+	assert.NoError(t, err)
+}
+
+func TestAddCNameRecordsReplacesChangedRecords(t *testing.T) {
+	patchCalls := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusOK)
+			_, _ = fmt.Fprint(w, `{"config": {"dns": {"cnameRecords": ["one.com,one.two.com,300", "two.com,old.two.com", "three.com,three.two.com,60"]}}}`)
+			return
+		}
+
+		patchCalls++
+		var payload updateCNameRecordsPayload
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		assert.NoError(t, err)
+
+		expectedCNames := []string{"one.com,one.two.com,60", "two.com,new.two.com", "three.com,three.two.com,60"}
+		assert.ElementsMatch(t, expectedCNames, payload.Config.DNS.CnameRecords)
+
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprint(w, `{"success": true}`)
+	})
+
+	client, server := setupTestServer(handler, "test-password")
+	defer server.Close()
+	client.sid = "test-sid"
+
+	count, updated, err := client.AddCNameRecordsBatch(CNameRecords{
+		"one.com":   {Target: "one.two.com", TTL: 60},
+		"two.com":   {Target: "new.two.com"},
+		"three.com": {Target: "three.two.com", TTL: 60},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+	assert.Equal(t, 2, updated)
+	assert.Equal(t, 1, patchCalls)
+}
+
 func TestRawCNameRecordToRecord(t *testing.T) {
 	testCases := []struct {
 		name               string
 		input              string
 		expectedDomainName DomainName
-		expectedTarget     Target
+		expectedRecord     CNameRecord
 		expectErr          bool
 	}{
 		{
 			name:               "happy path",
 			input:              "test.com,target.com",
 			expectedDomainName: "test.com",
-			expectedTarget:     "target.com",
+			expectedRecord:     CNameRecord{Target: "target.com"},
 			expectErr:          false,
 		},
+		{
+			name:               "with ttl",
+			input:              "test.com,target.com,300",
+			expectedDomainName: "test.com",
+			expectedRecord:     CNameRecord{Target: "target.com", TTL: 300},
+			expectErr:          false,
+		},
+		{
+			name:               "malformed ttl",
+			input:              "test.com,target.com,abc",
+			expectedDomainName: "",
+			expectedRecord:     CNameRecord{},
+			expectErr:          true,
+		},
 		{
 			name:               "malformed record",
 			input:              "baddata",
 			expectedDomainName: "",
-			expectedTarget:     "",
+			expectedRecord:     CNameRecord{},
 			expectErr:          true,
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			domainName, target, err := rawCNameRecordToRecord(tc.input)
+			domainName, record, err := rawCNameRecordToRecord(tc.input)
 			assert.Equal(t, tc.expectedDomainName, domainName)
-			assert.Equal(t, tc.expectedTarget, target)
+			assert.Equal(t, tc.expectedRecord, record)
 			assert.Equal(t, tc.expectErr, err != nil)
 		})
 	}
@@ -414,8 +685,6 @@ func TestRawCNameRecordToRecord(t *testing.T) {
 
 func TestCNameRecordToRaw(t *testing.T) {
 	dom := DomainName("test.com")
-	target := Target("target.com")
-	expected := "test.com,target.com"
-	actual := cNameRecordToRaw(dom, target)
-	assert.Equal(t, expected, actual)
+	assert.Equal(t, "test.com,target.com", cNameRecordToRaw(dom, CNameRecord{Target: "target.com"}))
+	assert.Equal(t, "test.com,target.com,300", cNameRecordToRaw(dom, CNameRecord{Target: "target.com", TTL: 300}))
 }
@@ -0,0 +1,62 @@
+package pihole
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"strings"
+	"time"
+)
+
+const (
+	totpDigits = 6
+	totpPeriod = 30
+)
+
+// generateTotpCode implements RFC 6238 with the parameters Pi-Hole uses
+// (HMAC-SHA1, 6 digits, 30 second period).
+func generateTotpCode(secret string, step int64) (int, error) {
+	normalizedSecret := strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	normalizedSecret = strings.TrimRight(normalizedSecret, "=")
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(normalizedSecret)
+	if err != nil || len(key) == 0 {
+		return 0, errInvalidTotpSecret
+	}
+
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	truncated := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for range totpDigits {
+		modulo *= 10
+	}
+	return int(truncated % modulo), nil
+}
+
+// nextTotpCode returns a code for the current time step. Pi-Hole rejects a
+// code that was already used, so if the current step was used for a previous
+// login this waits for the next one.
+func (p *Client) nextTotpCode() (int, error) {
+	step := time.Now().Unix() / totpPeriod
+	if step <= p.lastTotpStep {
+		wait := time.Until(time.Unix((p.lastTotpStep+1)*totpPeriod, 0))
+		log.Debug("Waiting for the next TOTP period before logging in to Pi-Hole", "wait", wait)
+		time.Sleep(wait)
+		step = p.lastTotpStep + 1
+	}
+
+	code, err := generateTotpCode(p.totpSecret, step)
+	if err != nil {
+		return 0, err
+	}
+	p.lastTotpStep = step
+	return code, nil
+}
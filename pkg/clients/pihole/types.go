@@ -1,5 +1,16 @@
 package pihole
 
+const (
+	API_VERSION_AUTO = "auto"
+	API_VERSION_5    = "5"
+	API_VERSION_6    = "6"
+)
+
+type loginRequest struct {
+	Password string `json:"password"`
+	Totp     *int   `json:"totp,omitempty"`
+}
+
 type loginResponse struct {
 	Session struct {
 		Valid    bool   `json:"valid"`
@@ -18,6 +29,9 @@ type configResponse struct {
 			Hosts        []string `json:"hosts"`
 			CnameRecords []any    `json:"cnameRecords"`
 		} `json:"dns"`
+		DHCP struct {
+			Hosts []string `json:"hosts"`
+		} `json:"dhcp"`
 	} `json:"config"`
 	Took float64 `json:"took"`
 }
@@ -47,12 +61,47 @@ type updateCNameRecordsPayload struct {
 	}
 }
 
+type updateDhcpHostsPayload struct {
+	Config struct {
+		DHCP struct {
+			Hosts []string `json:"hosts"`
+		} `json:"dhcp"`
+	} `json:"config"`
+}
+
+type legacyVersionResponse struct {
+	Version int `json:"version"`
+}
+
+type legacyListResponse struct {
+	Data [][]string `json:"data"`
+}
+
+type legacyActionResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
 type PiHoleOptions struct {
+	Dhcp         bool
+	DhcpHost     *DhcpHost
 	TargetDomain string
+	TTL          int
+}
+
+type DhcpHost struct {
+	Hostname string
+	IP       string
+	MAC      string
+}
+
+type CNameRecord struct {
+	Target Target
+	TTL    int
 }
 
 type (
-	CNameRecords map[DomainName]Target
+	CNameRecords map[DomainName]CNameRecord
 	DnsRecords   map[DomainName]IP
 	DomainName   string
 	IP           string
@@ -0,0 +1,205 @@
+package rfc2136
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/deepspace2/plugnpin/pkg/metrics"
+)
+
+const (
+	// NAME identifies RFC 2136 servers in logs and metrics
+	NAME = "rfc2136"
+
+	DEFAULT_ALGORITHM = "hmac-sha256"
+
+	// OWNER_PREFIX is prepended to a domain to get the name of the TXT record
+	// that marks the records of the domain as created by PlugNPiN, like
+	// external-dns' registry. A CNAME can't share its name with other records.
+	OWNER_PREFIX = "_plugnpin."
+	// OWNER_TXT is the text of the owner TXT record
+	OWNER_TXT = "heritage=plugnpin"
+
+	// tsigFudge is how many seconds of clock skew the server accepts
+	tsigFudge = 300
+	timeout   = 10 * time.Second
+)
+
+var algorithms = map[string]string{
+	"hmac-sha1":   dns.HmacSHA1,
+	"hmac-sha224": dns.HmacSHA224,
+	"hmac-sha256": dns.HmacSHA256,
+	"hmac-sha384": dns.HmacSHA384,
+	"hmac-sha512": dns.HmacSHA512,
+}
+
+// managedTypes are the record types PlugNPiN reads and writes, a domain only
+// ever has one of them.
+var managedTypes = []uint16{dns.TypeA, dns.TypeAAAA, dns.TypeCNAME}
+
+// Client sends RFC 2136 updates for a zone to its primary server, signed with
+// TSIG if a key is set.
+type Client struct {
+	client    *dns.Client
+	server    string
+	zone      string
+	keyName   string
+	algorithm string
+}
+
+// NewClient returns a client of the zone at server, which is 'host' or
+// 'host:port'. keyName, algorithm and secret (base64) are the TSIG key, the
+// key name may be empty to send unsigned messages and the algorithm defaults
+// to DEFAULT_ALGORITHM.
+func NewClient(server, zone, keyName, algorithm, secret string) (*Client, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	if algorithm == "" {
+		algorithm = DEFAULT_ALGORITHM
+	}
+
+	c := &Client{
+		// TCP avoids truncated responses
+		client: &dns.Client{Net: "tcp", Timeout: timeout},
+		server: server,
+		zone:   dns.Fqdn(zone),
+	}
+
+	if keyName != "" {
+		tsigAlgorithm, exists := algorithms[strings.TrimSuffix(strings.ToLower(algorithm), ".")]
+		if !exists {
+			return nil, fmt.Errorf("unsupported TSIG algorithm '%v'", algorithm)
+		}
+		c.keyName = dns.Fqdn(keyName)
+		c.algorithm = tsigAlgorithm
+		c.client.TsigSecret = map[string]string{c.keyName: secret}
+	}
+	return c, nil
+}
+
+func (c *Client) GetIP() string {
+	host, _, _ := net.SplitHostPort(c.server)
+	return host
+}
+
+// InZone returns whether domain belongs to the zone.
+func (c *Client) InZone(domain string) bool {
+	return dns.IsSubDomain(c.zone, dns.Fqdn(domain))
+}
+
+func (c *Client) sign(m *dns.Msg) {
+	if c.keyName != "" {
+		m.SetTsig(c.keyName, c.algorithm, tsigFudge, time.Now().Unix())
+	}
+}
+
+func (c *Client) exchange(m *dns.Msg, method string) (*dns.Msg, error) {
+	c.sign(m)
+	start := time.Now()
+	resp, _, err := c.client.Exchange(m, c.server)
+	statusGroup := "transport_error"
+	if err == nil {
+		statusGroup = strings.ToLower(dns.RcodeToString[resp.Rcode])
+	}
+	metrics.ObserveApiRequestDuration(NAME, method, statusGroup, time.Since(start).Seconds())
+
+	if err != nil {
+		return nil, err
+	}
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		return nil, fmt.Errorf("server returned %v", dns.RcodeToString[resp.Rcode])
+	}
+	return resp, nil
+}
+
+// Query returns the A, AAAA and CNAME records of domain, as the server has
+// them.
+func (c *Client) Query(domain string) ([]dns.RR, error) {
+	domain = dns.Fqdn(domain)
+	records := []dns.RR{}
+	for _, rrType := range managedTypes {
+		m := new(dns.Msg)
+		m.SetQuestion(domain, rrType)
+		m.RecursionDesired = false
+		resp, err := c.exchange(m, "QUERY")
+		if err != nil {
+			return nil, err
+		}
+		for _, rr := range resp.Answer {
+			// Answers to A queries can include the CNAME being followed
+			if rr.Header().Rrtype == rrType && strings.EqualFold(rr.Header().Name, domain) {
+				records = append(records, rr)
+			}
+		}
+	}
+	return records, nil
+}
+
+// Owned returns whether the records of domain were created by PlugNPiN,
+// i.e. whether it has an owner TXT record.
+func (c *Client) Owned(domain string) (bool, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(ownerName(domain), dns.TypeTXT)
+	m.RecursionDesired = false
+	resp, err := c.exchange(m, "QUERY")
+	if err != nil {
+		return false, err
+	}
+	for _, rr := range resp.Answer {
+		if txt, ok := rr.(*dns.TXT); ok && slices.Contains(txt.Txt, OWNER_TXT) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Replace atomically replaces the A, AAAA and CNAME records of domain with
+// record, and marks it as created by PlugNPiN.
+func (c *Client) Replace(domain string, record dns.RR) error {
+	m := new(dns.Msg)
+	m.SetUpdate(c.zone)
+	m.RemoveRRset(removals(domain))
+	m.Insert([]dns.RR{record, ownerRR(domain, record.Header().Ttl)})
+	_, err := c.exchange(m, "UPDATE")
+	return err
+}
+
+// Delete deletes the A, AAAA and CNAME records of domains, along with their
+// owner TXT records.
+func (c *Client) Delete(domains []string) error {
+	m := new(dns.Msg)
+	m.SetUpdate(c.zone)
+	for _, domain := range domains {
+		m.RemoveRRset(removals(domain))
+	}
+	_, err := c.exchange(m, "UPDATE")
+	return err
+}
+
+func ownerName(domain string) string {
+	return OWNER_PREFIX + dns.Fqdn(domain)
+}
+
+func ownerRR(domain string, ttl uint32) dns.RR {
+	return &dns.TXT{
+		Hdr: dns.RR_Header{Name: ownerName(domain), Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: ttl},
+		Txt: []string{OWNER_TXT},
+	}
+}
+
+// removals returns the RRsets replaced or deleted for domain, including its
+// owner TXT record.
+func removals(domain string) []dns.RR {
+	rrs := []dns.RR{}
+	for _, rrType := range managedTypes {
+		rrs = append(rrs, &dns.ANY{Hdr: dns.RR_Header{Name: dns.Fqdn(domain), Rrtype: rrType, Class: dns.ClassINET}})
+	}
+	rrs = append(rrs, &dns.ANY{Hdr: dns.RR_Header{Name: ownerName(domain), Rrtype: dns.TypeTXT, Class: dns.ClassINET}})
+	return rrs
+}
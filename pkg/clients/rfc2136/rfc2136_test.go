@@ -0,0 +1,214 @@
+//go:build unit
+
+package rfc2136
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testZone    = "example.com."
+	testKeyName = "plugnpin."
+	testSecret  = "c2VjcmV0LXNlY3JldC1zZWNyZXQ="
+)
+
+// fakeServer is an in-process authoritative server of testZone, that only
+// answers messages signed with the test key.
+type fakeServer struct {
+	mu      sync.Mutex
+	records []dns.RR
+	updates int
+}
+
+func (f *fakeServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	tsig := r.IsTsig()
+	if tsig == nil || w.TsigStatus() != nil {
+		m.Rcode = dns.RcodeNotAuth
+		_ = w.WriteMsg(m)
+		return
+	}
+	m.SetTsig(tsig.Hdr.Name, tsig.Algorithm, tsig.Fudge, time.Now().Unix())
+
+	switch {
+	case r.Opcode == dns.OpcodeUpdate:
+		f.updates++
+		for _, rr := range r.Ns {
+			if rr.Header().Class == dns.ClassANY {
+				f.records = removeRRset(f.records, rr.Header().Name, rr.Header().Rrtype)
+			} else {
+				f.records = append(f.records, rr)
+			}
+		}
+	default:
+		question := r.Question[0]
+		for _, rr := range f.records {
+			if strings.EqualFold(rr.Header().Name, question.Name) && (rr.Header().Rrtype == question.Qtype || rr.Header().Rrtype == dns.TypeCNAME) {
+				m.Answer = append(m.Answer, rr)
+			}
+		}
+	}
+	_ = w.WriteMsg(m)
+}
+
+func removeRRset(records []dns.RR, name string, rrType uint16) []dns.RR {
+	kept := []dns.RR{}
+	for _, rr := range records {
+		if !strings.EqualFold(rr.Header().Name, name) || rr.Header().Rrtype != rrType {
+			kept = append(kept, rr)
+		}
+	}
+	return kept
+}
+
+func newRR(t *testing.T, s string) dns.RR {
+	t.Helper()
+	rr, err := dns.NewRR(s)
+	require.NoError(t, err)
+	return rr
+}
+
+// rrStrings returns the records in zone file format, separated by spaces,
+// which unlike the records themselves doesn't depend on whether they went
+// over the wire.
+func rrStrings(rrs []dns.RR) []string {
+	strs := []string{}
+	for _, rr := range rrs {
+		strs = append(strs, strings.ReplaceAll(rr.String(), "\t", " "))
+	}
+	return strs
+}
+
+func setupTestServer(t *testing.T, records ...dns.RR) (*Client, *fakeServer) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	fake := &fakeServer{records: records}
+	started := make(chan struct{})
+	server := &dns.Server{
+		Listener:          listener,
+		Handler:           fake,
+		TsigSecret:        map[string]string{testKeyName: testSecret},
+		NotifyStartedFunc: func() { close(started) },
+		// The default rejects UPDATE messages
+		MsgAcceptFunc: func(dns.Header) dns.MsgAcceptAction { return dns.MsgAccept },
+	}
+	go func() { _ = server.ActivateAndServe() }()
+	<-started
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	client, err := NewClient(listener.Addr().String(), "example.com", "plugnpin", "hmac-sha256", testSecret)
+	require.NoError(t, err)
+	return client, fake
+}
+
+func TestNewClient(t *testing.T) {
+	client, err := NewClient("ns1.example.com", "example.com", "plugnpin", "", testSecret)
+	assert.NoError(t, err)
+	assert.Equal(t, "ns1.example.com:53", client.server)
+	assert.Equal(t, "ns1.example.com", client.GetIP())
+	assert.Equal(t, dns.HmacSHA256, client.algorithm)
+
+	client, err = NewClient("fd00::53", "example.com", "plugnpin", "HMAC-SHA512.", testSecret)
+	assert.NoError(t, err)
+	assert.Equal(t, "[fd00::53]:53", client.server)
+	assert.Equal(t, dns.HmacSHA512, client.algorithm)
+
+	_, err = NewClient("ns1.example.com", "example.com", "plugnpin", "hmac-md5", testSecret)
+	assert.ErrorContains(t, err, "unsupported TSIG algorithm")
+}
+
+func TestInZone(t *testing.T) {
+	client, err := NewClient("ns1.example.com", "example.com", "", "", "")
+	require.NoError(t, err)
+
+	assert.True(t, client.InZone("app.example.com"))
+	assert.True(t, client.InZone("example.com"))
+	assert.False(t, client.InZone("app.example.org"))
+	assert.False(t, client.InZone("notexample.com"))
+}
+
+func TestQuery(t *testing.T) {
+	client, _ := setupTestServer(t,
+		newRR(t, "app.example.com. 300 IN A 10.0.0.1"),
+		newRR(t, "app.example.com. 300 IN AAAA fd00::1"),
+		newRR(t, "alias.example.com. 60 IN CNAME app.example.com."),
+	)
+
+	records, err := client.Query("app.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"app.example.com. 300 IN A 10.0.0.1",
+		"app.example.com. 300 IN AAAA fd00::1",
+	}, rrStrings(records))
+
+	// The CNAME is not followed
+	records, err = client.Query("alias.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"alias.example.com. 60 IN CNAME app.example.com."}, rrStrings(records))
+
+	records, err = client.Query("missing.example.com")
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestReplaceAndDelete(t *testing.T) {
+	client, fake := setupTestServer(t,
+		newRR(t, "app.example.com. 300 IN A 10.0.0.1"),
+		newRR(t, "app.example.com. 300 IN TXT \"kept\""),
+		newRR(t, "other.example.com. 300 IN A 10.0.0.2"),
+	)
+
+	err := client.Replace("app.example.com", newRR(t, "app.example.com. 60 IN CNAME target.example.com."))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"app.example.com. 300 IN TXT \"kept\"",
+		"other.example.com. 300 IN A 10.0.0.2",
+		"app.example.com. 60 IN CNAME target.example.com.",
+		"_plugnpin.app.example.com. 60 IN TXT \"heritage=plugnpin\"",
+	}, rrStrings(fake.records))
+
+	assert.NoError(t, client.Delete([]string{"app.example.com", "other.example.com"}))
+	assert.Equal(t, []string{"app.example.com. 300 IN TXT \"kept\""}, rrStrings(fake.records))
+	assert.Equal(t, 2, fake.updates)
+}
+
+func TestOwned(t *testing.T) {
+	client, _ := setupTestServer(t,
+		newRR(t, "app.example.com. 300 IN A 10.0.0.1"),
+		newRR(t, "_plugnpin.app.example.com. 300 IN TXT \"heritage=plugnpin\""),
+		newRR(t, "manual.example.com. 300 IN A 10.0.0.2"),
+		newRR(t, "_plugnpin.other.example.com. 300 IN TXT \"something else\""),
+	)
+
+	for domain, expected := range map[string]bool{
+		"app.example.com":    true,
+		"manual.example.com": false,
+		"other.example.com":  false,
+	} {
+		owned, err := client.Owned(domain)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, owned, domain)
+	}
+}
+
+func TestWrongKey(t *testing.T) {
+	client, _ := setupTestServer(t)
+	client.client.TsigSecret[testKeyName] = "d3Jvbmc="
+
+	_, err := client.Query("app.example.com")
+	assert.Error(t, err)
+}
@@ -0,0 +1,155 @@
+package technitium
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/deepspace2/plugnpin/pkg/clients/common"
+	"github.com/deepspace2/plugnpin/pkg/metrics"
+)
+
+// NAME identifies Technitium in logs and metrics.
+const NAME = "technitium"
+
+// OWNER_COMMENT marks the records created by PlugNPiN. Records without it are
+// never changed or deleted.
+const OWNER_COMMENT = "Managed by PlugNPiN"
+
+type Client struct {
+	http.Client
+	baseURL string
+	token   string
+	zone    string
+}
+
+var headers map[string]string = map[string]string{
+	"accept":       "application/json",
+	"content-type": "application/x-www-form-urlencoded",
+}
+
+func NewClient(baseURL, token, zone string) *Client {
+	return &Client{
+		Client: http.Client{
+			Transport: common.NewInstrumentedRoundTripper(NAME, metrics.ObserveApiRequestDuration),
+		},
+		baseURL: fmt.Sprintf("%v/api", strings.TrimSuffix(baseURL, "/")),
+		token:   token,
+		zone:    strings.TrimSuffix(zone, "."),
+	}
+}
+
+// Owned reports whether the record was created by PlugNPiN.
+func (r Record) Owned() bool {
+	return r.Comments == OWNER_COMMENT
+}
+
+// Target returns the IP address of A and AAAA records, or the domain CNAME
+// records point at.
+func (r Record) Target() string {
+	if r.Type == RECORD_TYPE_CNAME {
+		return r.RData.CName
+	}
+	return r.RData.IPAddress
+}
+
+// InZone reports whether domain is the zone or one of its subdomains.
+func (c *Client) InZone(domain string) bool {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	zone := strings.ToLower(c.zone)
+	return domain == zone || strings.HasSuffix(domain, "."+zone)
+}
+
+// post calls an API endpoint, passing the token and params as form values.
+// Technitium answers errors with status 200 and an error status in the body.
+func (c *Client) post(path string, params url.Values) (string, error) {
+	params.Set("token", c.token)
+	payload := params.Encode()
+	resp, statusCode, err := common.Post(&c.Client, c.baseURL+path, headers, &payload)
+	if err != nil {
+		return "", err
+	}
+
+	if statusCode == 401 {
+		return "", errors.New("Unauthorized")
+	}
+
+	if statusCode >= 400 {
+		return "", fmt.Errorf("Technitium returned status %d for %v: %v", statusCode, path, strings.TrimSpace(resp))
+	}
+
+	var parsedResp response
+	if err := json.Unmarshal([]byte(resp), &parsedResp); err != nil {
+		return "", err
+	}
+	switch parsedResp.Status {
+	case "ok":
+		return resp, nil
+	case "invalid-token":
+		return "", errors.New("Unauthorized")
+	default:
+		return "", fmt.Errorf("Technitium returned status '%v' for %v: %v", parsedResp.Status, path, parsedResp.ErrorMessage)
+	}
+}
+
+// GetRecords returns the A, AAAA and CNAME records of the whole zone.
+func (c *Client) GetRecords() ([]Record, error) {
+	resp, err := c.post("/zones/records/get", url.Values{
+		"domain":   {c.zone},
+		"zone":     {c.zone},
+		"listZone": {"true"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var parsedResp getRecordsResponse
+	if err := json.Unmarshal([]byte(resp), &parsedResp); err != nil {
+		return nil, err
+	}
+
+	records := []Record{}
+	for _, record := range parsedResp.Response.Records {
+		switch record.Type {
+		case RECORD_TYPE_A, RECORD_TYPE_AAAA, RECORD_TYPE_CNAME:
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (c *Client) recordParams(record Record) url.Values {
+	params := url.Values{
+		"domain": {record.Name},
+		"zone":   {c.zone},
+		"type":   {record.Type},
+	}
+	if record.Type == RECORD_TYPE_CNAME {
+		params.Set("cname", record.RData.CName)
+	} else {
+		params.Set("ipAddress", record.RData.IPAddress)
+	}
+	return params
+}
+
+// AddRecord creates record, replacing the existing records of its name and
+// type, and marks it with OWNER_COMMENT. A TTL of 0 uses the server's default.
+func (c *Client) AddRecord(record Record) error {
+	params := c.recordParams(record)
+	params.Set("overwrite", "true")
+	params.Set("comments", OWNER_COMMENT)
+	if record.TTL > 0 {
+		params.Set("ttl", strconv.Itoa(record.TTL))
+	}
+	_, err := c.post("/zones/records/add", params)
+	return err
+}
+
+func (c *Client) DeleteRecord(record Record) error {
+	_, err := c.post("/zones/records/delete", c.recordParams(record))
+	return err
+}
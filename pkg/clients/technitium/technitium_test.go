@@ -0,0 +1,142 @@
+//go:build unit
+
+package technitium
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupTestServer creates a new test server and a client pointing to it.
+func setupTestServer(handler http.Handler) (*Client, *httptest.Server) {
+	server := httptest.NewServer(handler)
+	client := NewClient(server.URL, "test-token", "example.com")
+	client.Client = *server.Client()
+	return client, server
+}
+
+func TestInZone(t *testing.T) {
+	client := NewClient("http://technitium", "test-token", "example.com.")
+
+	assert.True(t, client.InZone("example.com"))
+	assert.True(t, client.InZone("App.Example.com."))
+	assert.False(t, client.InZone("example.org"))
+	assert.False(t, client.InZone("notexample.com"))
+}
+
+func TestGetRecords(t *testing.T) {
+	client, server := setupTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/zones/records/get", r.URL.Path)
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "test-token", r.PostForm.Get("token"))
+		assert.Equal(t, "example.com", r.PostForm.Get("zone"))
+		assert.Equal(t, "true", r.PostForm.Get("listZone"))
+		_, _ = fmt.Fprint(w, `{"status": "ok", "response": {"records": [
+			{"name": "example.com", "type": "SOA", "ttl": 900, "rData": {"primaryNameServer": "ns1.example.com"}},
+			{"name": "app.example.com", "type": "A", "ttl": 300, "rData": {"ipAddress": "10.0.0.1"}, "comments": "Managed by PlugNPiN"},
+			{"name": "www.example.com", "type": "CNAME", "ttl": 60, "rData": {"cname": "app.example.com"}, "disabled": true}
+		]}}`)
+	}))
+	defer server.Close()
+
+	records, err := client.GetRecords()
+	assert.NoError(t, err)
+	assert.Equal(t, []Record{
+		{Name: "app.example.com", Type: RECORD_TYPE_A, TTL: 300, RData: RData{IPAddress: "10.0.0.1"}, Comments: OWNER_COMMENT},
+		{Name: "www.example.com", Type: RECORD_TYPE_CNAME, TTL: 60, RData: RData{CName: "app.example.com"}, Disabled: true},
+	}, records)
+	assert.True(t, records[0].Owned())
+	assert.False(t, records[1].Owned())
+	assert.Equal(t, "10.0.0.1", records[0].Target())
+	assert.Equal(t, "app.example.com", records[1].Target())
+}
+
+func TestAddRecord(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		record         Record
+		expectedParams map[string]string
+	}{
+		{
+			name:   "A record",
+			record: Record{Name: "app.example.com", Type: RECORD_TYPE_A, TTL: 300, RData: RData{IPAddress: "10.0.0.1"}},
+			expectedParams: map[string]string{
+				"domain": "app.example.com", "type": "A", "ipAddress": "10.0.0.1", "ttl": "300", "cname": "",
+			},
+		},
+		{
+			name:   "CNAME record with the server's default TTL",
+			record: Record{Name: "app.example.com", Type: RECORD_TYPE_CNAME, RData: RData{CName: "proxy.example.com"}},
+			expectedParams: map[string]string{
+				"domain": "app.example.com", "type": "CNAME", "cname": "proxy.example.com", "ttl": "", "ipAddress": "",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			called := false
+			client, server := setupTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				assert.Equal(t, "/api/zones/records/add", r.URL.Path)
+				require.NoError(t, r.ParseForm())
+				assert.Equal(t, "test-token", r.PostForm.Get("token"))
+				assert.Equal(t, "example.com", r.PostForm.Get("zone"))
+				assert.Equal(t, "true", r.PostForm.Get("overwrite"))
+				assert.Equal(t, OWNER_COMMENT, r.PostForm.Get("comments"))
+				for param, value := range tc.expectedParams {
+					assert.Equal(t, value, r.PostForm.Get(param), param)
+				}
+				_, _ = fmt.Fprint(w, `{"status": "ok", "response": {}}`)
+			}))
+			defer server.Close()
+
+			assert.NoError(t, client.AddRecord(tc.record))
+			assert.True(t, called)
+		})
+	}
+}
+
+func TestDeleteRecord(t *testing.T) {
+	called := false
+	client, server := setupTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		assert.Equal(t, "/api/zones/records/delete", r.URL.Path)
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "app.example.com", r.PostForm.Get("domain"))
+		assert.Equal(t, "AAAA", r.PostForm.Get("type"))
+		assert.Equal(t, "fd00::1", r.PostForm.Get("ipAddress"))
+		_, _ = fmt.Fprint(w, `{"status": "ok", "response": {}}`)
+	}))
+	defer server.Close()
+
+	assert.NoError(t, client.DeleteRecord(Record{Name: "app.example.com", Type: RECORD_TYPE_AAAA, RData: RData{IPAddress: "fd00::1"}}))
+	assert.True(t, called)
+}
+
+func TestErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		statusCode    int
+		body          string
+		expectedError string
+	}{
+		{"error status", http.StatusOK, `{"status": "error", "errorMessage": "No such zone was found: example.com"}`, "Technitium returned status 'error' for /zones/records/get: No such zone was found: example.com"},
+		{"invalid token", http.StatusOK, `{"status": "invalid-token", "errorMessage": "Invalid token or session expired."}`, "Unauthorized"},
+		{"http error", http.StatusInternalServerError, "oops", "Technitium returned status 500 for /zones/records/get: oops"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client, server := setupTestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.statusCode)
+				_, _ = fmt.Fprint(w, tc.body)
+			}))
+			defer server.Close()
+
+			_, err := client.GetRecords()
+			assert.EqualError(t, err, tc.expectedError)
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package technitium
+
+const (
+	RECORD_TYPE_A     = "A"
+	RECORD_TYPE_AAAA  = "AAAA"
+	RECORD_TYPE_CNAME = "CNAME"
+)
+
+type Record struct {
+	Comments string `json:"comments,omitempty"`
+	Disabled bool   `json:"disabled"`
+	Name     string `json:"name"`
+	RData    RData  `json:"rData"`
+	TTL      int    `json:"ttl"`
+	Type     string `json:"type"`
+}
+
+type RData struct {
+	CName     string `json:"cname,omitempty"`
+	IPAddress string `json:"ipAddress,omitempty"`
+}
+
+type response struct {
+	ErrorMessage string `json:"errorMessage"`
+	Status       string `json:"status"`
+}
+
+type getRecordsResponse struct {
+	Response struct {
+		Records []Record `json:"records"`
+	} `json:"response"`
+}
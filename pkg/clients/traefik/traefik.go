@@ -0,0 +1,85 @@
+package traefik
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NAME identifies Traefik in logs and metrics.
+const NAME = "traefik"
+
+// HEADER starts the file, it is owned by PlugNPiN as a whole.
+const HEADER = "# Generated by PlugNPiN, any change is overwritten\n"
+
+// Client reads and writes a dynamic configuration file watched by Traefik's
+// file provider.
+type Client struct {
+	path string
+	// written is the content last read or written, to skip unchanged writes
+	written []byte
+}
+
+func NewClient(path string) *Client {
+	return &Client{path: path}
+}
+
+// Read returns the configuration in the file, which is empty if the file
+// doesn't exist yet.
+func (c *Client) Read() (*Config, error) {
+	content, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	if err := yaml.Unmarshal(content, config); err != nil {
+		return nil, fmt.Errorf("failed to parse '%v': %w", c.path, err)
+	}
+	c.written = content
+	return config, nil
+}
+
+// Write writes config to the file if its content changed, replacing the file
+// atomically so Traefik never loads a partial configuration.
+func (c *Client) Write(config *Config) (bool, error) {
+	content, err := yaml.Marshal(config)
+	if err != nil {
+		return false, err
+	}
+	content = append([]byte(HEADER), content...)
+	if bytes.Equal(content, c.written) {
+		return false, nil
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(c.path), "."+filepath.Base(c.path)+".*")
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = os.Remove(file.Name()) }()
+
+	if _, err := file.Write(content); err != nil {
+		_ = file.Close()
+		return false, err
+	}
+	if err := file.Chmod(0o644); err != nil {
+		_ = file.Close()
+		return false, err
+	}
+	if err := file.Close(); err != nil {
+		return false, err
+	}
+	if err := os.Rename(file.Name(), c.path); err != nil {
+		return false, err
+	}
+
+	c.written = content
+	return true, nil
+}
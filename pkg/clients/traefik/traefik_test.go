@@ -0,0 +1,90 @@
+//go:build unit
+
+package traefik
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadMissingFile(t *testing.T) {
+	client := NewClient(filepath.Join(t.TempDir(), "plugnpin.yml"))
+
+	config, err := client.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, &Config{}, config)
+}
+
+func TestReadInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plugnpin.yml")
+	assert.NoError(t, os.WriteFile(path, []byte("http: [not, a, map]"), 0o644))
+
+	_, err := NewClient(path).Read()
+	assert.Error(t, err)
+}
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "plugnpin.yml")
+	client := NewClient(path)
+
+	config := &Config{HTTP: HTTPConfig{
+		Routers: map[string]Router{"plugnpin-app-com": {
+			Rule:    "Host(`app.com`)",
+			Service: "plugnpin-app-com",
+			TLS:     &TLS{},
+		}},
+		Services: map[string]Service{"plugnpin-app-com": {
+			LoadBalancer: LoadBalancer{Servers: []Server{{URL: "http://10.0.0.1:8080"}}},
+		}},
+	}}
+
+	written, err := client.Write(config)
+	assert.NoError(t, err)
+	assert.True(t, written)
+
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, HEADER+`http:
+    routers:
+        plugnpin-app-com:
+            rule: Host(`+"`app.com`"+`)
+            service: plugnpin-app-com
+            tls: {}
+    services:
+        plugnpin-app-com:
+            loadBalancer:
+                servers:
+                    - url: http://10.0.0.1:8080
+`, string(content))
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	t.Run("unchanged content is not written", func(t *testing.T) {
+		written, err := client.Write(config)
+		assert.NoError(t, err)
+		assert.False(t, written)
+	})
+
+	t.Run("read back", func(t *testing.T) {
+		otherClient := NewClient(path)
+		readConfig, err := otherClient.Read()
+		assert.NoError(t, err)
+		assert.Equal(t, config, readConfig)
+
+		written, err := otherClient.Write(readConfig)
+		assert.NoError(t, err)
+		assert.False(t, written)
+	})
+}
+
+func TestWriteMissingDirectory(t *testing.T) {
+	_, err := NewClient(filepath.Join(t.TempDir(), "missing", "plugnpin.yml")).Write(&Config{})
+	assert.Error(t, err)
+}
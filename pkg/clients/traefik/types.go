@@ -0,0 +1,54 @@
+package traefik
+
+// Config is a Traefik dynamic configuration, only with the fields PlugNPiN
+// manages.
+type Config struct {
+	HTTP HTTPConfig `yaml:"http"`
+}
+
+type HTTPConfig struct {
+	Middlewares map[string]Middleware `yaml:"middlewares,omitempty"`
+	Routers     map[string]Router     `yaml:"routers,omitempty"`
+	Services    map[string]Service    `yaml:"services,omitempty"`
+}
+
+type Router struct {
+	EntryPoints []string `yaml:"entryPoints,omitempty"`
+	Middlewares []string `yaml:"middlewares,omitempty"`
+	Rule        string   `yaml:"rule"`
+	Service     string   `yaml:"service"`
+	// TLS is set to an empty object to only match HTTPS requests, served with
+	// a certificate of Traefik's default store
+	TLS *TLS `yaml:"tls,omitempty"`
+}
+
+type TLS struct {
+	CertResolver string `yaml:"certResolver,omitempty"`
+}
+
+type Middleware struct {
+	Headers        *Headers        `yaml:"headers,omitempty"`
+	RedirectScheme *RedirectScheme `yaml:"redirectScheme,omitempty"`
+}
+
+type Headers struct {
+	StsIncludeSubdomains bool `yaml:"stsIncludeSubdomains,omitempty"`
+	StsSeconds           int  `yaml:"stsSeconds"`
+}
+
+type RedirectScheme struct {
+	Permanent bool   `yaml:"permanent"`
+	Scheme    string `yaml:"scheme"`
+}
+
+type Service struct {
+	LoadBalancer LoadBalancer `yaml:"loadBalancer"`
+}
+
+type LoadBalancer struct {
+	Servers []Server `yaml:"servers"`
+}
+
+type Server struct {
+	URL string `yaml:"url"`
+}
@@ -3,9 +3,13 @@ package config
 import (
 	"errors"
 	"fmt"
+	"maps"
+	"net"
 	"os"
 	"path/filepath"
 	"reflect"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 
@@ -15,24 +19,45 @@ import (
 	"github.com/deepspace2/plugnpin/pkg/logging"
 )
 
+// DEFAULT_NPM_INSTANCE is the name of the instance configured with
+// NGINX_PROXY_MANAGER_HOST, _USERNAME and _PASSWORD.
+const DEFAULT_NPM_INSTANCE = "default"
+
 var (
 	log                  = logging.GetLogger("config")
 	dockerSecretRootPath = filepath.Join(string(os.PathSeparator), "run", "secrets")
 )
 
 type Config struct {
-	AdguardHomeDisabled bool   `env:"ADGUARD_HOME_DISABLED" envDefault:"true"`
-	AdguardHomeHost     string `env:"ADGUARD_HOME_HOST" secret:"true"`
-	AdguardHomePassword string `env:"ADGUARD_HOME_PASSWORD" secret:"true"`
-	AdguardHomeUsername string `env:"ADGUARD_HOME_USERNAME" secret:"true"`
+	AdguardHomeDisabled      bool   `env:"ADGUARD_HOME_DISABLED" envDefault:"true"`
+	AdguardHomeDisableOnStop bool   `env:"ADGUARD_HOME_DISABLE_ON_STOP" envDefault:"false"`
+	AdguardHomeHost          string `env:"ADGUARD_HOME_HOST" secret:"true"`
+	AdguardHomePassword      string `env:"ADGUARD_HOME_PASSWORD" secret:"true"`
+	AdguardHomeUsername      string `env:"ADGUARD_HOME_USERNAME" secret:"true"`
+
+	NpmCacheTTL                       time.Duration `env:"NGINX_PROXY_MANAGER_CACHE_TTL" envDefault:"30s"`
+	NpmCertificateExpiryWarningDays   int           `env:"NGINX_PROXY_MANAGER_CERTIFICATE_EXPIRY_WARNING_DAYS" envDefault:"14"`
+	NpmDNSChallengeCredentials        string        `env:"NGINX_PROXY_MANAGER_DNS_CHALLENGE_CREDENTIALS" secret:"true"`
+	NpmDNSChallengePropagationSeconds int           `env:"NGINX_PROXY_MANAGER_DNS_CHALLENGE_PROPAGATION_SECONDS"`
+	NpmDNSChallengeProvider           string        `env:"NGINX_PROXY_MANAGER_DNS_CHALLENGE_PROVIDER"`
+	NpmDisableOnStop                  bool          `env:"NGINX_PROXY_MANAGER_DISABLE_ON_STOP" envDefault:"false"`
+	NpmDisabled                       bool          `env:"NGINX_PROXY_MANAGER_DISABLED" envDefault:"false"`
+	NpmHost                           string        `env:"NGINX_PROXY_MANAGER_HOST" secret:"true"`
+	NpmInstanceNames                  []string      `env:"NGINX_PROXY_MANAGER_INSTANCES"`
+	NpmInstances                      map[string]NpmInstance
+	NpmLetsencryptEmail               string `env:"NGINX_PROXY_MANAGER_LETSENCRYPT_EMAIL"`
+	NpmPassword                       string `env:"NGINX_PROXY_MANAGER_PASSWORD" secret:"true"`
+	NpmSnippetsDir                    string `env:"NGINX_PROXY_MANAGER_SNIPPETS_DIR"`
+	NpmUsername                       string `env:"NGINX_PROXY_MANAGER_USERNAME" secret:"true"`
 
-	NpmHost     string `env:"NGINX_PROXY_MANAGER_HOST" secret:"true"`
-	NpmPassword string `env:"NGINX_PROXY_MANAGER_PASSWORD" secret:"true"`
-	NpmUsername string `env:"NGINX_PROXY_MANAGER_USERNAME" secret:"true"`
+	PiholeAPIToken   string `env:"PIHOLE_API_TOKEN" secret:"true"`
+	PiholeAPIVersion string `env:"PIHOLE_API_VERSION" envDefault:"auto"`
+	PiholeDisabled   bool   `env:"PIHOLE_DISABLED" envDefault:"false"`
+	PiholeHost       string `env:"PIHOLE_HOST" secret:"true"`
+	PiholePassword   string `env:"PIHOLE_PASSWORD" secret:"true"`
+	PiholeTotpSecret string `env:"PIHOLE_TOTP_SECRET" secret:"true"`
 
-	PiholeDisabled bool   `env:"PIHOLE_DISABLED" envDefault:"false"`
-	PiholeHost     string `env:"PIHOLE_HOST" secret:"true"`
-	PiholePassword string `env:"PIHOLE_PASSWORD" secret:"true"`
+	DnsTargetIP string `env:"DNS_TARGET_IP"`
 
 	DockerHost  string   `env:"DOCKER_HOST"`
 	DockerHosts []string `env:"DOCKER_HOSTS"`
@@ -43,6 +68,72 @@ type Config struct {
 	RunInterval       time.Duration `env:"RUN_INTERVAL" envDefault:"1h"`
 }
 
+// NpmInstance is an additional Nginx Proxy Manager instance, configured with
+// NGINX_PROXY_MANAGER_<NAME>_HOST, _USERNAME and _PASSWORD.
+type NpmInstance struct {
+	Host     string
+	Password string
+	Username string
+}
+
+// AllNpmInstances returns the additional NPM instances together with the
+// default one.
+func (c *Config) AllNpmInstances() map[string]NpmInstance {
+	instances := map[string]NpmInstance{
+		DEFAULT_NPM_INSTANCE: {Host: c.NpmHost, Password: c.NpmPassword, Username: c.NpmUsername},
+	}
+	maps.Copy(instances, c.NpmInstances)
+	return instances
+}
+
+var validNpmInstanceName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+func getValueFromEnvOrSecret(name string) (string, error) {
+	if value, exists := os.LookupEnv(name); exists {
+		return value, nil
+	}
+	return getValueFromSecret(name)
+}
+
+func loadNpmInstances(names []string) (map[string]NpmInstance, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	instances := map[string]NpmInstance{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if !validNpmInstanceName.MatchString(name) || name == DEFAULT_NPM_INSTANCE {
+			return nil, fmt.Errorf(`env: invalid instance name '%v' in 'NGINX_PROXY_MANAGER_INSTANCES', only lowercase letters, digits and '_' are allowed and '%v' is reserved`, name, DEFAULT_NPM_INSTANCE)
+		}
+		if _, exists := instances[name]; exists {
+			return nil, fmt.Errorf(`env: duplicate instance name '%v' in 'NGINX_PROXY_MANAGER_INSTANCES'`, name)
+		}
+
+		prefix := "NGINX_PROXY_MANAGER_" + strings.ToUpper(name) + "_"
+		var instance NpmInstance
+		for _, field := range []struct {
+			suffix string
+			value  *string
+		}{
+			{"HOST", &instance.Host},
+			{"USERNAME", &instance.Username},
+			{"PASSWORD", &instance.Password},
+		} {
+			value, err := getValueFromEnvOrSecret(prefix + field.suffix)
+			if err != nil {
+				return nil, err
+			}
+			if value == "" {
+				return nil, fmt.Errorf(`env: %v is required but not set via env var or secret`, prefix+field.suffix)
+			}
+			*field.value = value
+		}
+		instances[name] = instance
+	}
+	return instances, nil
+}
+
 func getValueFromSecret(secretFile string) (string, error) {
 	path := filepath.Join(dockerSecretRootPath, secretFile)
 	content, err := os.ReadFile(path)
@@ -56,11 +147,24 @@ func getValueFromSecret(secretFile string) (string, error) {
 	return strings.TrimRight(string(content), "\r\n"), nil
 }
 
-func Get() (*Config, error) {
-	var config Config
+// ReadSecret reads the Docker secret with the given name, failing if it does
+// not exist.
+func ReadSecret(name string) (string, error) {
+	path := filepath.Join(dockerSecretRootPath, name)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file %v: %w", path, err)
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
 
+// Parse reads the fields of the struct v points to from environment
+// variables, a .env file and Docker secrets, following their 'env',
+// 'envDefault' and 'secret' tags. Providers use it to read their own
+// configuration, see Get for the core one.
+func Parse(v any) error {
 	// Reflection-based secret loading
-	val := reflect.ValueOf(&config).Elem()
+	val := reflect.ValueOf(v).Elem()
 	typ := val.Type()
 
 	for i := 0; i < typ.NumField(); i++ {
@@ -69,21 +173,25 @@ func Get() (*Config, error) {
 			envName := field.Tag.Get("env")
 			secretVal, err := getValueFromSecret(envName)
 			if err != nil {
-				return nil, err
+				return err
 			}
 			val.Field(i).SetString(secretVal)
 		}
 	}
 
 	_ = godotenv.Load()
-	err := env.ParseWithOptions(&config, env.Options{
+	return env.ParseWithOptions(v, env.Options{
 		OnSet: func(tag string, value any, isDefault bool) {
 			if isDefault {
 				log.Info(fmt.Sprintf(`env: environment variable '%v' is not set, using default value '%v'`, tag, value))
 			}
 		},
 	})
-	if err != nil {
+}
+
+func Get() (*Config, error) {
+	var config Config
+	if err := Parse(&config); err != nil {
 		return nil, err
 	}
 
@@ -91,6 +199,14 @@ func Get() (*Config, error) {
 		return nil, err
 	}
 
+	if !config.NpmDisabled {
+		var err error
+		config.NpmInstances, err = loadNpmInstances(config.NpmInstanceNames)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &config, nil
 }
 
@@ -103,21 +219,40 @@ func (c *Config) Validate() error {
 		return fmt.Errorf(`env: 'METRICS_SERVER_PORT' must be between 1 and 65535, got %d`, c.MetricsServerPort)
 	}
 
-	if c.NpmHost == "" {
-		return errors.New(`env: NGINX_PROXY_MANAGER_HOST is required but not set via env var or secret`)
+	if c.NpmCacheTTL < 0 {
+		return errors.New(`env: 'NGINX_PROXY_MANAGER_CACHE_TTL' must be >= 0`)
+	}
+
+	if c.NpmCertificateExpiryWarningDays < 0 {
+		return errors.New(`env: 'NGINX_PROXY_MANAGER_CERTIFICATE_EXPIRY_WARNING_DAYS' must be >= 0`)
 	}
-	if c.NpmUsername == "" {
-		return errors.New(`env: NGINX_PROXY_MANAGER_USERNAME is required but not set via env var or secret`)
+
+	if c.DnsTargetIP != "" && net.ParseIP(c.DnsTargetIP) == nil {
+		return fmt.Errorf(`env: 'DNS_TARGET_IP' must be an IP address, got '%v'`, c.DnsTargetIP)
 	}
-	if c.NpmPassword == "" {
-		return errors.New(`env: NGINX_PROXY_MANAGER_PASSWORD is required but not set via env var or secret`)
+
+	if !c.NpmDisabled {
+		if c.NpmHost == "" {
+			return errors.New(`env: NGINX_PROXY_MANAGER_HOST is required but not set via env var or secret`)
+		}
+		if c.NpmUsername == "" {
+			return errors.New(`env: NGINX_PROXY_MANAGER_USERNAME is required but not set via env var or secret`)
+		}
+		if c.NpmPassword == "" {
+			return errors.New(`env: NGINX_PROXY_MANAGER_PASSWORD is required but not set via env var or secret`)
+		}
 	}
 
 	if !c.PiholeDisabled {
 		if c.PiholeHost == "" {
 			return errors.New(`env: PIHOLE_HOST is required but not set via env var or secret`)
 		}
-		if c.PiholePassword == "" {
+		if !slices.Contains([]string{"auto", "5", "6"}, c.PiholeAPIVersion) {
+			return fmt.Errorf(`env: 'PIHOLE_API_VERSION' must be one of 'auto', '5', '6', got '%v'`, c.PiholeAPIVersion)
+		}
+		// Pi-Hole v5 can authenticate with an API token instead of the password.
+		// With "auto", v6 may be detected, which needs the password.
+		if c.PiholePassword == "" && (c.PiholeAPIToken == "" || c.PiholeAPIVersion != "5") {
 			return errors.New(`env: PIHOLE_PASSWORD is required but not set via env var or secret`)
 		}
 	}
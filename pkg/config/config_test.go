@@ -35,16 +35,19 @@ func TestGetConfig_EnvVars(t *testing.T) {
 				"RUN_INTERVAL":                 "5m",
 			},
 			expectedConfig: &Config{
-				AdguardHomeDisabled: true,
-				NpmHost:             "npm.example.com",
-				NpmPassword:         "password",
-				NpmUsername:         "user",
-				PiholeDisabled:      false,
-				PiholeHost:          "pihole.example.com",
-				PiholePassword:      "pihole_pass",
-				DockerHost:          "unix:///var/run/docker.sock",
-				MetricsServerPort:   9100,
-				RunInterval:         5 * time.Minute,
+				AdguardHomeDisabled:             true,
+				NpmCacheTTL:                     30 * time.Second,
+				NpmCertificateExpiryWarningDays: 14,
+				NpmHost:                         "npm.example.com",
+				NpmPassword:                     "password",
+				NpmUsername:                     "user",
+				PiholeDisabled:                  false,
+				PiholeAPIVersion:                "auto",
+				PiholeHost:                      "pihole.example.com",
+				PiholePassword:                  "pihole_pass",
+				DockerHost:                      "unix:///var/run/docker.sock",
+				MetricsServerPort:               9100,
+				RunInterval:                     5 * time.Minute,
 			},
 			expectErr: false,
 		},
@@ -70,15 +73,18 @@ func TestGetConfig_EnvVars(t *testing.T) {
 				"PIHOLE_PASSWORD":              "pihole_pass",
 			},
 			expectedConfig: &Config{
-				AdguardHomeDisabled: true,
-				NpmHost:             "npm.example.com",
-				NpmPassword:         "password",
-				NpmUsername:         "user",
-				PiholeDisabled:      false,
-				PiholeHost:          "pihole.example.com",
-				PiholePassword:      "pihole_pass",
-				MetricsServerPort:   9100,
-				RunInterval:         1 * time.Hour, // Default value
+				AdguardHomeDisabled:             true,
+				NpmCacheTTL:                     30 * time.Second,
+				NpmCertificateExpiryWarningDays: 14,
+				NpmHost:                         "npm.example.com",
+				NpmPassword:                     "password",
+				NpmUsername:                     "user",
+				PiholeDisabled:                  false,
+				PiholeAPIVersion:                "auto",
+				PiholeHost:                      "pihole.example.com",
+				PiholePassword:                  "pihole_pass",
+				MetricsServerPort:               9100,
+				RunInterval:                     1 * time.Hour, // Default value
 			},
 			expectErr: false,
 		},
@@ -159,15 +165,18 @@ func TestGetConfig_EnvVars(t *testing.T) {
 				"RUN_INTERVAL":                 "5m",
 			},
 			expectedConfig: &Config{
-				AdguardHomeDisabled: true,
-				NpmHost:             "npm.example.com",
-				NpmPassword:         "password",
-				NpmUsername:         "user",
-				PiholeDisabled:      false,
-				PiholeHost:          "pihole.example.com",
-				PiholePassword:      "pihole_pass",
-				MetricsServerPort:   1,
-				RunInterval:         5 * time.Minute,
+				AdguardHomeDisabled:             true,
+				NpmCacheTTL:                     30 * time.Second,
+				NpmCertificateExpiryWarningDays: 14,
+				NpmHost:                         "npm.example.com",
+				NpmPassword:                     "password",
+				NpmUsername:                     "user",
+				PiholeDisabled:                  false,
+				PiholeAPIVersion:                "auto",
+				PiholeHost:                      "pihole.example.com",
+				PiholePassword:                  "pihole_pass",
+				MetricsServerPort:               1,
+				RunInterval:                     5 * time.Minute,
 			},
 			expectErr: false,
 		},
@@ -183,15 +192,18 @@ func TestGetConfig_EnvVars(t *testing.T) {
 				"RUN_INTERVAL":                 "5m",
 			},
 			expectedConfig: &Config{
-				AdguardHomeDisabled: true,
-				NpmHost:             "npm.example.com",
-				NpmPassword:         "password",
-				NpmUsername:         "user",
-				PiholeDisabled:      false,
-				PiholeHost:          "pihole.example.com",
-				PiholePassword:      "pihole_pass",
-				MetricsServerPort:   65535,
-				RunInterval:         5 * time.Minute,
+				AdguardHomeDisabled:             true,
+				NpmCacheTTL:                     30 * time.Second,
+				NpmCertificateExpiryWarningDays: 14,
+				NpmHost:                         "npm.example.com",
+				NpmPassword:                     "password",
+				NpmUsername:                     "user",
+				PiholeDisabled:                  false,
+				PiholeAPIVersion:                "auto",
+				PiholeHost:                      "pihole.example.com",
+				PiholePassword:                  "pihole_pass",
+				MetricsServerPort:               65535,
+				RunInterval:                     5 * time.Minute,
 			},
 			expectErr: false,
 		},
@@ -207,15 +219,18 @@ func TestGetConfig_EnvVars(t *testing.T) {
 				"RUN_INTERVAL":                 "5m",
 			},
 			expectedConfig: &Config{
-				AdguardHomeDisabled: true,
-				NpmHost:             "npm.example.com",
-				NpmPassword:         "password",
-				NpmUsername:         "user",
-				PiholeDisabled:      false,
-				PiholeHost:          "pihole.example.com",
-				PiholePassword:      "pihole_pass",
-				MetricsServerPort:   8080,
-				RunInterval:         5 * time.Minute,
+				AdguardHomeDisabled:             true,
+				NpmCacheTTL:                     30 * time.Second,
+				NpmCertificateExpiryWarningDays: 14,
+				NpmHost:                         "npm.example.com",
+				NpmPassword:                     "password",
+				NpmUsername:                     "user",
+				PiholeDisabled:                  false,
+				PiholeAPIVersion:                "auto",
+				PiholeHost:                      "pihole.example.com",
+				PiholePassword:                  "pihole_pass",
+				MetricsServerPort:               8080,
+				RunInterval:                     5 * time.Minute,
 			},
 			expectErr: false,
 		},
@@ -230,14 +245,17 @@ func TestGetConfig_EnvVars(t *testing.T) {
 				"RUN_INTERVAL":                 "5m",
 			},
 			expectedConfig: &Config{
-				AdguardHomeDisabled: true,
-				NpmHost:             "npm.example.com",
-				NpmPassword:         "password",
-				NpmUsername:         "user",
-				PiholeDisabled:      true,
-				DockerHost:          "unix:///var/run/docker.sock",
-				MetricsServerPort:   9100,
-				RunInterval:         5 * time.Minute,
+				AdguardHomeDisabled:             true,
+				NpmCacheTTL:                     30 * time.Second,
+				NpmCertificateExpiryWarningDays: 14,
+				NpmHost:                         "npm.example.com",
+				NpmPassword:                     "password",
+				NpmUsername:                     "user",
+				PiholeDisabled:                  true,
+				PiholeAPIVersion:                "auto",
+				DockerHost:                      "unix:///var/run/docker.sock",
+				MetricsServerPort:               9100,
+				RunInterval:                     5 * time.Minute,
 			},
 			expectErr: false,
 		},
@@ -253,6 +271,70 @@ func TestGetConfig_EnvVars(t *testing.T) {
 			expectedConfig: nil,
 			expectErr:      true,
 		},
+		{
+			name: "Invalid PIHOLE_API_VERSION",
+			envVars: map[string]string{
+				"NGINX_PROXY_MANAGER_HOST":     "npm.example.com",
+				"NGINX_PROXY_MANAGER_PASSWORD": "password",
+				"NGINX_PROXY_MANAGER_USERNAME": "user",
+				"PIHOLE_HOST":                  "pihole.example.com",
+				"PIHOLE_PASSWORD":              "pihole_pass",
+				"PIHOLE_API_VERSION":           "4",
+			},
+			expectedConfig: nil,
+			expectErr:      true,
+		},
+		{
+			name: "Pi-Hole v5 API token instead of password",
+			envVars: map[string]string{
+				"NGINX_PROXY_MANAGER_HOST":     "npm.example.com",
+				"NGINX_PROXY_MANAGER_PASSWORD": "password",
+				"NGINX_PROXY_MANAGER_USERNAME": "user",
+				"PIHOLE_HOST":                  "pihole.example.com",
+				"PIHOLE_API_TOKEN":             "token",
+				"PIHOLE_API_VERSION":           "5",
+			},
+			expectedConfig: &Config{
+				AdguardHomeDisabled:             true,
+				NpmCacheTTL:                     30 * time.Second,
+				NpmCertificateExpiryWarningDays: 14,
+				NpmHost:                         "npm.example.com",
+				NpmPassword:                     "password",
+				NpmUsername:                     "user",
+				PiholeAPIToken:                  "token",
+				PiholeAPIVersion:                "5",
+				PiholeDisabled:                  false,
+				PiholeHost:                      "pihole.example.com",
+				MetricsServerPort:               9100,
+				RunInterval:                     1 * time.Hour,
+			},
+			expectErr: false,
+		},
+		{
+			name: "Pi-Hole API version auto requires a password",
+			envVars: map[string]string{
+				"NGINX_PROXY_MANAGER_HOST":     "npm.example.com",
+				"NGINX_PROXY_MANAGER_PASSWORD": "password",
+				"NGINX_PROXY_MANAGER_USERNAME": "user",
+				"PIHOLE_HOST":                  "pihole.example.com",
+				"PIHOLE_API_TOKEN":             "token",
+			},
+			expectedConfig: nil,
+			expectErr:      true,
+		},
+		{
+			name: "Pi-Hole v6 API requires a password",
+			envVars: map[string]string{
+				"NGINX_PROXY_MANAGER_HOST":     "npm.example.com",
+				"NGINX_PROXY_MANAGER_PASSWORD": "password",
+				"NGINX_PROXY_MANAGER_USERNAME": "user",
+				"PIHOLE_HOST":                  "pihole.example.com",
+				"PIHOLE_API_TOKEN":             "token",
+				"PIHOLE_API_VERSION":           "6",
+			},
+			expectedConfig: nil,
+			expectErr:      true,
+		},
 		{
 			name: "No need to set AdguardHome env vars if AdguardHome is disabled",
 			envVars: map[string]string{
@@ -265,14 +347,17 @@ func TestGetConfig_EnvVars(t *testing.T) {
 				"RUN_INTERVAL":                 "5m",
 			},
 			expectedConfig: &Config{
-				AdguardHomeDisabled: true,
-				NpmHost:             "npm.example.com",
-				NpmPassword:         "password",
-				NpmUsername:         "user",
-				PiholeDisabled:      true,
-				DockerHost:          "unix:///var/run/docker.sock",
-				MetricsServerPort:   9100,
-				RunInterval:         5 * time.Minute,
+				AdguardHomeDisabled:             true,
+				NpmCacheTTL:                     30 * time.Second,
+				NpmCertificateExpiryWarningDays: 14,
+				NpmHost:                         "npm.example.com",
+				NpmPassword:                     "password",
+				NpmUsername:                     "user",
+				PiholeDisabled:                  true,
+				PiholeAPIVersion:                "auto",
+				DockerHost:                      "unix:///var/run/docker.sock",
+				MetricsServerPort:               9100,
+				RunInterval:                     5 * time.Minute,
 			},
 			expectErr: false,
 		},
@@ -290,6 +375,39 @@ func TestGetConfig_EnvVars(t *testing.T) {
 			expectedConfig: nil,
 			expectErr:      true,
 		},
+		{
+			name: "No need to set NPM env vars if NPM is disabled",
+			envVars: map[string]string{
+				"NGINX_PROXY_MANAGER_DISABLED": "true",
+				"PIHOLE_DISABLED":              "true",
+				"DNS_TARGET_IP":                "192.168.1.5",
+				"DOCKER_HOST":                  "unix:///var/run/docker.sock",
+				"RUN_INTERVAL":                 "5m",
+			},
+			expectedConfig: &Config{
+				AdguardHomeDisabled:             true,
+				DnsTargetIP:                     "192.168.1.5",
+				NpmCacheTTL:                     30 * time.Second,
+				NpmCertificateExpiryWarningDays: 14,
+				NpmDisabled:                     true,
+				PiholeDisabled:                  true,
+				PiholeAPIVersion:                "auto",
+				DockerHost:                      "unix:///var/run/docker.sock",
+				MetricsServerPort:               9100,
+				RunInterval:                     5 * time.Minute,
+			},
+			expectErr: false,
+		},
+		{
+			name: "Invalid DNS_TARGET_IP",
+			envVars: map[string]string{
+				"NGINX_PROXY_MANAGER_DISABLED": "true",
+				"PIHOLE_DISABLED":              "true",
+				"DNS_TARGET_IP":                "not-an-ip",
+			},
+			expectedConfig: nil,
+			expectErr:      true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -350,6 +468,97 @@ func TestGetConfig_DockerSecrets(t *testing.T) {
 	}
 }
 
+func TestReadSecret(t *testing.T) {
+	oldPath := dockerSecretRootPath
+	defer func() { dockerSecretRootPath = oldPath }()
+
+	tmpDir := t.TempDir()
+	dockerSecretRootPath = tmpDir
+
+	err := writeFile(tmpDir, "alice_password", "hunter2\n")
+	assert.NoError(t, err)
+
+	secret, err := ReadSecret("alice_password")
+	assert.NoError(t, err)
+	assert.Equal(t, "hunter2", secret)
+
+	_, err = ReadSecret("missing")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestParse(t *testing.T) {
+	oldPath := dockerSecretRootPath
+	defer func() { dockerSecretRootPath = oldPath }()
+
+	tmpDir := t.TempDir()
+	dockerSecretRootPath = tmpDir
+
+	err := writeFile(tmpDir, "EXAMPLE_TOKEN", "token\n")
+	assert.NoError(t, err)
+	t.Setenv("EXAMPLE_HOST", "http://example:8080")
+
+	var exampleConfig struct {
+		Host  string `env:"EXAMPLE_HOST"`
+		TTL   int    `env:"EXAMPLE_TTL" envDefault:"300"`
+		Token string `env:"EXAMPLE_TOKEN" secret:"true"`
+	}
+	assert.NoError(t, Parse(&exampleConfig))
+	assert.Equal(t, "http://example:8080", exampleConfig.Host)
+	assert.Equal(t, 300, exampleConfig.TTL)
+	assert.Equal(t, "token", exampleConfig.Token)
+
+	t.Setenv("EXAMPLE_TTL", "1h")
+	assert.Error(t, Parse(&exampleConfig))
+}
+
+func TestLoadNpmInstances(t *testing.T) {
+	oldPath := dockerSecretRootPath
+	defer func() { dockerSecretRootPath = oldPath }()
+
+	tmpDir := t.TempDir()
+	dockerSecretRootPath = tmpDir
+
+	t.Run("from env vars and secrets", func(t *testing.T) {
+		t.Setenv("NGINX_PROXY_MANAGER_DMZ_HOST", "http://dmz:81")
+		t.Setenv("NGINX_PROXY_MANAGER_DMZ_USERNAME", "dmz-user")
+		err := writeFile(tmpDir, "NGINX_PROXY_MANAGER_DMZ_PASSWORD", "dmz-pass\n")
+		assert.NoError(t, err)
+
+		instances, err := loadNpmInstances([]string{"dmz"})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]NpmInstance{
+			"dmz": {Host: "http://dmz:81", Password: "dmz-pass", Username: "dmz-user"},
+		}, instances)
+	})
+
+	t.Run("missing settings", func(t *testing.T) {
+		t.Setenv("NGINX_PROXY_MANAGER_LAB_HOST", "http://lab:81")
+
+		_, err := loadNpmInstances([]string{"lab"})
+		assert.EqualError(t, err, "env: NGINX_PROXY_MANAGER_LAB_USERNAME is required but not set via env var or secret")
+	})
+
+	t.Run("invalid names", func(t *testing.T) {
+		for _, name := range []string{"default", "DMZ", "d-m-z", ""} {
+			_, err := loadNpmInstances([]string{name})
+			assert.Error(t, err, name)
+		}
+	})
+
+	t.Run("all instances include the default one", func(t *testing.T) {
+		config := Config{
+			NpmHost:      "http://npm:81",
+			NpmInstances: map[string]NpmInstance{"dmz": {Host: "http://dmz:81"}},
+			NpmPassword:  "pass",
+			NpmUsername:  "user",
+		}
+		assert.Equal(t, map[string]NpmInstance{
+			DEFAULT_NPM_INSTANCE: {Host: "http://npm:81", Password: "pass", Username: "user"},
+			"dmz":                {Host: "http://dmz:81"},
+		}, config.AllNpmInstances())
+	})
+}
+
 func TestGetConfig_SecretPrecedence(t *testing.T) {
 	unsetAllConfigEnvVars()
 	oldPath := dockerSecretRootPath
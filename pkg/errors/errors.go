@@ -1,6 +1,9 @@
 package errors
 
 type (
+	InvalidLabelValueError struct {
+		Msg string
+	}
 	InvalidSchemeError struct {
 		Msg string
 	}
@@ -12,6 +15,10 @@ type (
 	}
 )
 
+func (e *InvalidLabelValueError) Error() string {
+	return e.Msg
+}
+
 func (e *InvalidSchemeError) Error() string {
 	return e.Msg
 }
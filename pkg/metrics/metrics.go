@@ -14,19 +14,37 @@ const (
 const (
 	ADDED   = "added"
 	DELETED = "deleted"
+	UPDATED = "updated"
 )
 
 const (
-	ADD_CNAME_RECORD    = "add_cname_record"
-	ADD_DNS_RECORD      = "add_dns_record"
-	ADD_DNS_REWRITE     = "add_dns_rewrite"
-	ADD_PROXY_HOST      = "add_proxy_host"
-	DELETE_CNAME_RECORD = "delete_cname_record"
-	DELETE_DNS_RECORD   = "delete_dns_record"
-	DELETE_DNS_REWRITE  = "delete_dns_rewrite"
-	DELETE_PROXY_HOST   = "delete_proxy_host"
-	GET_ACCESS_LIST_ID  = "get_access_list_id"
-	GET_CERTIFICATE_ID  = "get_certificate_id"
+	ADD_CNAME_RECORD         = "add_cname_record"
+	ADD_DHCP_HOST            = "add_dhcp_host"
+	ADD_DNS_RECORD           = "add_dns_record"
+	ADD_DNS_REWRITE          = "add_dns_rewrite"
+	ADD_PROXY_HOST           = "add_proxy_host"
+	ADD_REDIRECTION_HOST     = "add_redirection_host"
+	ADD_STREAM               = "add_stream"
+	DELETE_ACCESS_LIST       = "delete_access_list"
+	DELETE_CNAME_RECORD      = "delete_cname_record"
+	DELETE_DHCP_HOST         = "delete_dhcp_host"
+	DELETE_DNS_RECORD        = "delete_dns_record"
+	DELETE_DNS_REWRITE       = "delete_dns_rewrite"
+	DELETE_PROXY_HOST        = "delete_proxy_host"
+	DELETE_REDIRECTION_HOST  = "delete_redirection_host"
+	DELETE_ROUTE             = "delete_route"
+	DELETE_STREAM            = "delete_stream"
+	DISABLE_DNS_REWRITE      = "disable_dns_rewrite"
+	DISABLE_PROXY_HOST       = "disable_proxy_host"
+	DISABLE_REDIRECTION_HOST = "disable_redirection_host"
+	DISABLE_STREAM           = "disable_stream"
+	ENSURE_ACCESS_LIST       = "ensure_access_list"
+	ENSURE_ROUTE             = "ensure_route"
+	GET_ACCESS_LIST_ID       = "get_access_list_id"
+	GET_CERTIFICATE_ID       = "get_certificate_id"
+	GET_PROXY_HOST_HEALTH    = "get_proxy_host_health"
+	REQUEST_CERTIFICATE      = "request_certificate"
+	WRITE_CONFIG             = "write_config"
 )
 
 var (
@@ -41,7 +59,7 @@ var (
 	managedEntries = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "plugnpin_managed_entries_total",
-			Help: "Total number of DNS entries and proxy hosts created or deleted per service",
+			Help: "Total number of DNS entries and proxy hosts created, updated or deleted per service",
 		},
 		[]string{"service", "action"},
 	)
@@ -70,6 +88,30 @@ var (
 		[]string{"docker_host"},
 	)
 
+	cacheRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "plugnpin_cache_requests_total",
+			Help: "Total number of lookups in the API response cache, by result (hit or miss)",
+		},
+		[]string{"service", "resource", "result"},
+	)
+
+	npmCertificateDaysToExpiry = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "plugnpin_npm_certificate_days_to_expiry",
+			Help: "Days until the certificate of a managed NPM proxy host expires, as of the last reconciliation scan",
+		},
+		[]string{"instance", "proxy_host"},
+	)
+
+	npmNginxOnline = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "plugnpin_npm_nginx_online",
+			Help: "Whether NPM reports nginx as online (1) or not (0) for a managed proxy host, as of the last reconciliation scan",
+		},
+		[]string{"instance", "proxy_host"},
+	)
+
 	apiRequestDuration = promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name: "plugnpin_api_request_duration_seconds",
@@ -79,6 +121,13 @@ var (
 	)
 )
 
+// IncrementManagedEntries counts the entries a service added, updated or
+// deleted. Providers without a helper of their own use it with their name as
+// service.
+func IncrementManagedEntries(service, action string, n int) {
+	managedEntries.WithLabelValues(service, action).Add(float64(n))
+}
+
 func IncrementAdguardHomeEntriesCreated(n int) {
 	managedEntries.WithLabelValues(ADGUARD_HOME, ADDED).Add(float64(n))
 }
@@ -87,6 +136,10 @@ func IncrementAdguardHomeEntriesDeleted(n int) {
 	managedEntries.WithLabelValues(ADGUARD_HOME, DELETED).Add(float64(n))
 }
 
+func IncrementAdguardHomeEntriesUpdated(n int) {
+	managedEntries.WithLabelValues(ADGUARD_HOME, UPDATED).Add(float64(n))
+}
+
 func IncrementPiHoleEntriesCreated(n int) {
 	managedEntries.WithLabelValues(PI_HOLE, ADDED).Add(float64(n))
 }
@@ -95,6 +148,10 @@ func IncrementPiHoleEntriesDeleted(n int) {
 	managedEntries.WithLabelValues(PI_HOLE, DELETED).Add(float64(n))
 }
 
+func IncrementPiHoleEntriesUpdated(n int) {
+	managedEntries.WithLabelValues(PI_HOLE, UPDATED).Add(float64(n))
+}
+
 func IncrementNpmEntriesCreated() {
 	managedEntries.WithLabelValues(NPM, ADDED).Add(float64(1))
 }
@@ -103,6 +160,10 @@ func IncrementNpmEntriesDeleted() {
 	managedEntries.WithLabelValues(NPM, DELETED).Add(float64(1))
 }
 
+func IncrementNpmEntriesUpdated() {
+	managedEntries.WithLabelValues(NPM, UPDATED).Add(float64(1))
+}
+
 func SetDiscoveredContainers(dockerHost string, n int) {
 	discoveredContainers.WithLabelValues(dockerHost).Set(float64(n))
 }
@@ -111,23 +172,50 @@ func IncrementHandledDockerEvents(dockerHost, event string) {
 	handledDockerEvents.WithLabelValues(dockerHost, event).Inc()
 }
 
+// ResetNpmProxyHostHealth removes the health of all proxy hosts, so hosts
+// that are no longer managed don't linger.
+func ResetNpmProxyHostHealth() {
+	npmCertificateDaysToExpiry.Reset()
+	npmNginxOnline.Reset()
+}
+
+func SetNpmCertificateDaysToExpiry(instance, proxyHost string, days float64) {
+	npmCertificateDaysToExpiry.WithLabelValues(instance, proxyHost).Set(days)
+}
+
+func SetNpmNginxOnline(instance, proxyHost string, online bool) {
+	value := 0.0
+	if online {
+		value = 1
+	}
+	npmNginxOnline.WithLabelValues(instance, proxyHost).Set(value)
+}
+
 func ObserveScanDuration(dockerHost string, durationSeconds float64) {
 	scanDuration.WithLabelValues(dockerHost).Observe(durationSeconds)
 }
 
+func IncrementNpmCacheHits(resource string) {
+	cacheRequests.WithLabelValues(NPM, resource, "hit").Inc()
+}
+
+func IncrementNpmCacheMisses(resource string) {
+	cacheRequests.WithLabelValues(NPM, resource, "miss").Inc()
+}
+
 func IncrementAdguardHomeApiRequestErrors(action string) {
-	incrementApiRequestErrors(ADGUARD_HOME, action)
+	IncrementApiRequestErrors(ADGUARD_HOME, action)
 }
 
 func IncrementPiHoleApiRequestErrors(action string) {
-	incrementApiRequestErrors(PI_HOLE, action)
+	IncrementApiRequestErrors(PI_HOLE, action)
 }
 
 func IncrementNpmApiRequestErrors(action string) {
-	incrementApiRequestErrors(NPM, action)
+	IncrementApiRequestErrors(NPM, action)
 }
 
-func incrementApiRequestErrors(service, action string) {
+func IncrementApiRequestErrors(service, action string) {
 	servicesApiErrors.WithLabelValues(service, action).Inc()
 }
 
@@ -0,0 +1,69 @@
+package processor
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"github.com/deepspace2/plugnpin/pkg/providers"
+)
+
+// dnsBatch collects the DNS records of all containers during RunOnce, so they
+// can be applied to each DNS provider at once instead of once per container.
+type dnsBatch struct {
+	// records are keyed by provider name and domain
+	records map[string]map[string]providers.Record
+}
+
+type dnsBatchKey struct{}
+
+func newDnsBatch() *dnsBatch {
+	return &dnsBatch{
+		records: map[string]map[string]providers.Record{},
+	}
+}
+
+func withDnsBatch(ctx context.Context, batch *dnsBatch) context.Context {
+	return context.WithValue(ctx, dnsBatchKey{}, batch)
+}
+
+// dnsBatchFromContext returns nil outside of RunOnce, in which case records
+// are applied right away.
+func dnsBatchFromContext(ctx context.Context) *dnsBatch {
+	batch, _ := ctx.Value(dnsBatchKey{}).(*dnsBatch)
+	return batch
+}
+
+func (b *dnsBatch) addRecords(providerName string, records []providers.Record) {
+	if b.records[providerName] == nil {
+		b.records[providerName] = map[string]providers.Record{}
+	}
+	for _, record := range records {
+		b.records[providerName][record.Domain] = record
+	}
+}
+
+// providerRecords returns the records of a provider, ordered by domain.
+func (b *dnsBatch) providerRecords(providerName string) []providers.Record {
+	providerRecords := b.records[providerName]
+	records := []providers.Record{}
+	for _, domain := range slices.Sorted(maps.Keys(providerRecords)) {
+		records = append(records, providerRecords[domain])
+	}
+	return records
+}
+
+func (p *Processor) applyDnsBatch(ctx context.Context, batch *dnsBatch) {
+	for _, dnsProvider := range p.dnsProviders {
+		records := batch.providerRecords(dnsProvider.Name())
+		if len(records) == 0 {
+			continue
+		}
+
+		log := log.With("provider", dnsProvider.Name())
+		log.Info("Adding DNS records", "count", len(records))
+		if err := dnsProvider.EnsureRecords(ctx, records); err != nil {
+			log.Error("Failed to add DNS records", "error", err)
+		}
+	}
+}
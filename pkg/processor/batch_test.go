@@ -0,0 +1,48 @@
+//go:build unit
+
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/deepspace2/plugnpin/pkg/providers"
+	"github.com/docker/docker/api/types/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDnsBatchFromContext(t *testing.T) {
+	assert.Nil(t, dnsBatchFromContext(context.Background()))
+
+	batch := newDnsBatch()
+	ctx := withDnsBatch(context.Background(), batch)
+	assert.Same(t, batch, dnsBatchFromContext(ctx))
+}
+
+func TestDnsBatch(t *testing.T) {
+	batch := newDnsBatch()
+
+	batch.addRecords("one", providers.ARecords([]string{"two.com", "one.com"}, "1.2.3.4"))
+	batch.addRecords("one", providers.ARecords([]string{"two.com"}, "5.6.7.8"))
+	batch.addRecords("two", providers.CNameRecords([]string{"three.com"}, "target.com", 60))
+
+	assert.Equal(t, []providers.Record{
+		{Domain: "one.com", Type: providers.RECORD_TYPE_A, Target: "1.2.3.4"},
+		{Domain: "two.com", Type: providers.RECORD_TYPE_A, Target: "5.6.7.8"},
+	}, batch.providerRecords("one"))
+	assert.Equal(t, providers.CNameRecords([]string{"three.com"}, "target.com", 60), batch.providerRecords("two"))
+	assert.Empty(t, batch.providerRecords("three"))
+}
+
+func TestApplyDnsBatch(t *testing.T) {
+	dnsProvider := &fakeDNSProvider{}
+	p := New(nil, []providers.DNSProvider{dnsProvider}, nil, Options{})
+
+	batch := newDnsBatch()
+	ctx := withDnsBatch(context.Background(), batch)
+	p.handleContainer(ctx, events.ActionStart, newContainer(t, map[string]string{}))
+	assert.Empty(t, dnsProvider.ensured, "records are queued until the batch is applied")
+
+	p.applyDnsBatch(ctx, batch)
+	assert.Equal(t, [][]providers.Record{providers.ARecords([]string{"app.com"}, "172.17.0.2")}, dnsProvider.ensured)
+}
@@ -3,37 +3,43 @@ package processor
 import (
 	"context"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/events"
 
-	"github.com/deepspace2/plugnpin/pkg/clients/adguardhome"
 	"github.com/deepspace2/plugnpin/pkg/clients/docker"
-	"github.com/deepspace2/plugnpin/pkg/clients/npm"
-	"github.com/deepspace2/plugnpin/pkg/clients/pihole"
 	"github.com/deepspace2/plugnpin/pkg/errors"
 	"github.com/deepspace2/plugnpin/pkg/logging"
 	"github.com/deepspace2/plugnpin/pkg/metrics"
+	"github.com/deepspace2/plugnpin/pkg/providers"
+	"github.com/deepspace2/plugnpin/pkg/snippets"
 )
 
 var log = logging.GetLogger("processor")
 
 type Processor struct {
-	dockerClients     map[string]*docker.Client
-	adguardHomeClient *adguardhome.Client
-	piholeClient      *pihole.Client
-	npmClient         *npm.Client
-	dryRun            bool
+	dockerClients map[string]*docker.Client
+	dnsProviders  []providers.DNSProvider
+	// proxyProvider is nil if containers are not put behind a reverse proxy
+	proxyProvider providers.ProxyProvider
+	options       Options
 }
 
-func New(dockerClients map[string]*docker.Client, adguardHomeClient *adguardhome.Client, piholeClient *pihole.Client, npmClient *npm.Client, dryRun bool) *Processor {
+type Options struct {
+	// DnsTargetIP is the DNS answer of containers that are not behind a
+	// proxy, instead of their own IP
+	DnsTargetIP string
+	DryRun      bool
+}
+
+func New(dockerClients map[string]*docker.Client, dnsProviders []providers.DNSProvider, proxyProvider providers.ProxyProvider, options Options) *Processor {
 	return &Processor{
-		dockerClients:     dockerClients,
-		adguardHomeClient: adguardHomeClient,
-		piholeClient:      piholeClient,
-		npmClient:         npmClient,
-		dryRun:            dryRun,
+		dockerClients: dockerClients,
+		dnsProviders:  dnsProviders,
+		proxyProvider: proxyProvider,
+		options:       options,
 	}
 }
 
@@ -75,6 +81,16 @@ func (p *Processor) ListenForEvents(ctx context.Context) {
 }
 
 func (p *Processor) RunOnce(ctx context.Context) {
+	batch := newDnsBatch()
+	batchCtx := withDnsBatch(ctx, batch)
+
+	// All containers of this sync share the same lookups
+	for _, syncer := range p.syncers() {
+		syncer.BeginSync()
+		defer syncer.EndSync()
+	}
+
+	managedUrls := []string{}
 	for _, dockerClient := range p.dockerClients {
 		scanStartTime := time.Now()
 		containers, err := dockerClient.GetRelevantContainers()
@@ -87,16 +103,43 @@ func (p *Processor) RunOnce(ctx context.Context) {
 		metrics.SetDiscoveredContainers(dockerClient.DisplayHost, len(containers))
 
 		for _, container := range containers {
-			p.preprocessContainer(ctx, container, dockerClient)
+			urls := p.preprocessContainer(batchCtx, container, dockerClient)
+			managedUrls = append(managedUrls, urls...)
+		}
+
+		if p.canDisableOnStop() {
+			p.reconcileStoppedContainers(ctx, dockerClient)
 		}
 
 		scanDurationSeconds := time.Since(scanStartTime).Seconds()
 		metrics.ObserveScanDuration(dockerClient.DisplayHost, scanDurationSeconds)
 	}
+
+	p.applyDnsBatch(ctx, batch)
+
+	if healthReporter, ok := p.proxyProvider.(providers.HealthReporter); ok {
+		healthReporter.ReportHealth(managedUrls)
+	}
 	log.Info("Done")
 }
 
-func (p *Processor) preprocessContainer(ctx context.Context, container container.Summary, dockerClient *docker.Client) {
+// syncers returns the providers that want to know about full syncs.
+func (p *Processor) syncers() []providers.Syncer {
+	syncers := []providers.Syncer{}
+	for _, dnsProvider := range p.dnsProviders {
+		if syncer, ok := dnsProvider.(providers.Syncer); ok {
+			syncers = append(syncers, syncer)
+		}
+	}
+	if syncer, ok := p.proxyProvider.(providers.Syncer); ok {
+		syncers = append(syncers, syncer)
+	}
+	return syncers
+}
+
+// preprocessContainer processes a running container and returns its urls, or
+// nil if its labels are invalid.
+func (p *Processor) preprocessContainer(ctx context.Context, container container.Summary, dockerClient *docker.Client) []string {
 	parsedContainerName := docker.GetParsedContainerName(container)
 
 	ip, urls, port, opts, err := docker.GetValuesFromLabels(container.Labels)
@@ -104,12 +147,83 @@ func (p *Processor) preprocessContainer(ctx context.Context, container container
 		switch err.(type) {
 		case *errors.NonExistingLabelsError:
 			log.Info(fmt.Sprintf("Skipping container '%v': %v", parsedContainerName, err))
-		case *errors.MalformedIPLabelError, *errors.InvalidSchemeError:
+		case *errors.MalformedIPLabelError, *errors.InvalidSchemeError, *errors.InvalidLabelValueError:
 			log.Error("Failed to handle container", "container", parsedContainerName, "error", err)
 		}
-		return
+		return nil
 	}
 	p.processContainer(ctx, events.ActionStart, container.ID, dockerClient, parsedContainerName, ip, urls, port, opts)
+	return urls
+}
+
+// canDisableOnStop reports whether any provider can disable entries instead
+// of deleting them, in which case stopped containers have to be reconciled.
+func (p *Processor) canDisableOnStop() bool {
+	for _, dnsProvider := range p.dnsProviders {
+		if _, ok := dnsProvider.(providers.RecordDisabler); ok {
+			return true
+		}
+	}
+	_, ok := p.proxyProvider.(providers.RouteDisabler)
+	return ok
+}
+
+// reconcileStoppedContainers disables the entries of stopped containers that
+// use disable-on-stop, in case their 'die' event was missed.
+func (p *Processor) reconcileStoppedContainers(ctx context.Context, dockerClient *docker.Client) {
+	containers, err := dockerClient.GetStoppedRelevantContainers()
+	if err != nil {
+		log.Error("Failed to get stopped containers", "host", dockerClient.DisplayHost, "error", err)
+		return
+	}
+
+	for _, stoppedContainer := range containers {
+		ip, urls, port, opts, err := docker.GetValuesFromLabels(stoppedContainer.Labels)
+		if err != nil {
+			continue
+		}
+
+		container := providers.Container{
+			ID:      stoppedContainer.ID,
+			Name:    docker.GetParsedContainerName(stoppedContainer),
+			IP:      ip,
+			Port:    port,
+			URLs:    urls,
+			Options: opts,
+		}
+
+		dnsProviders := []providers.DNSProvider{}
+		for _, dnsProvider := range p.dnsProviders {
+			if disabler, ok := dnsProvider.(providers.RecordDisabler); ok && disabler.DisableOnStop(container) {
+				dnsProviders = append(dnsProviders, dnsProvider)
+			}
+		}
+		route := providers.NewRoute(container)
+		disabler, ok := p.proxyProvider.(providers.RouteDisabler)
+		disableRoute := ok && !opts.GeneralOptions.SkipProxy && disabler.DisableOnStop(route)
+		if len(dnsProviders) == 0 && !disableRoute {
+			continue
+		}
+
+		log := log.With(
+			"container", container.Name,
+			"containerId", dockerClient.GetShortContainerId(container.ID),
+			"host", dockerClient.DisplayHost,
+		)
+
+		if p.options.DryRun {
+			log.Info("Container is stopped. In dry run mode, not disabling its entries.", "urls", urls)
+			continue
+		}
+
+		ctx := logging.WithLogger(ctx, log)
+		for _, dnsProvider := range dnsProviders {
+			p.handleDns(ctx, dnsProvider, events.ActionDie, container, "")
+		}
+		if disableRoute {
+			p.handleProxy(ctx, events.ActionDie, route)
+		}
+	}
 }
 
 func (p *Processor) handleDockerEvent(ctx context.Context, event events.Message, dockerClient *docker.Client) {
@@ -125,7 +239,7 @@ func (p *Processor) handleDockerEvent(ctx context.Context, event events.Message,
 		case *errors.NonExistingLabelsError:
 			// This is not an error, it just means the container is not relevant for us
 			return
-		case *errors.MalformedIPLabelError, *errors.InvalidSchemeError:
+		case *errors.MalformedIPLabelError, *errors.InvalidSchemeError, *errors.InvalidLabelValueError:
 			log.Error("Failed to handle event for container", "host", dockerClient.DisplayHost, "container", containerName, "error", err)
 		}
 		return
@@ -137,165 +251,128 @@ func (p *Processor) shouldSkip(generalOptions *docker.GeneralOptions, event even
 	return (event == events.ActionStart && generalOptions.CreateOnHealthy) || (event == events.ActionHealthStatusHealthy && !generalOptions.CreateOnHealthy)
 }
 
-func (p *Processor) handleAdguardHome(ctx context.Context, containerEvent events.Action, urls []string, ip string, adguardHomeOptions adguardhome.AdguardHomeOptions, generalOptions *docker.GeneralOptions) {
+// dnsRecords returns the records of container, leaving out what dnsProvider
+// can't hold.
+func (p *Processor) dnsRecords(ctx context.Context, dnsProvider providers.DNSProvider, container providers.Container, answer string) []providers.Record {
 	log := logging.FromContext(ctx)
+	capabilities := dnsProvider.Capabilities()
 
-	if p.adguardHomeClient != nil {
-		if adguardHomeOptions.TargetDomain != "" {
-			// quick "workaround" for the fact that adguard unifies "local DNS records" and "CNAME records"
-			ip = adguardHomeOptions.TargetDomain
+	records := []providers.Record{}
+	for _, record := range dnsProvider.Records(container, answer) {
+		if record.Type == providers.RECORD_TYPE_CNAME && !capabilities.CNAME {
+			log.Warn("DNS provider doesn't support CNAME records, skipping record", "record", record)
+			continue
 		}
-
-		switch containerEvent {
-		case events.ActionStart, events.ActionHealthStatusHealthy:
-			log.Info("Adding a DNS rewrite to AdGuard Home", "domains", urls, "answer", ip)
-			numOfAddedRewrites, err := p.adguardHomeClient.AddDnsRewrites(urls, ip)
-			if err != nil {
-				log.Error("Failed to add a DNS rewrite to AdGuard Home", "domains", urls, "answer", ip, "error", err)
-				metrics.IncrementAdguardHomeApiRequestErrors(metrics.ADD_DNS_REWRITE)
-			} else {
-				metrics.IncrementAdguardHomeEntriesCreated(numOfAddedRewrites)
-			}
-		case events.ActionDie:
-			log.Info("Deleting DNS rewrite from AdGuard Home", "domains", urls)
-			numOfDeletedRewrites, err := p.adguardHomeClient.DeleteDnsRewrites(urls, ip)
-			if err != nil {
-				log.Error("Failed to delete DNS rewrite from AdGuard Home", "domains", urls, "error", err)
-				metrics.IncrementAdguardHomeApiRequestErrors(metrics.DELETE_DNS_REWRITE)
-			} else {
-				metrics.IncrementAdguardHomeEntriesDeleted(numOfDeletedRewrites)
-			}
+		if record.TTL > 0 && !capabilities.TTL {
+			log.Warn("DNS provider doesn't support TTLs, ignoring it", "record", record)
+			record.TTL = 0
 		}
+		records = append(records, record)
 	}
+	return records
 }
 
-func (p *Processor) handlePiHole(ctx context.Context, containerEvent events.Action, urls []string, ip string, piholeOptions pihole.PiHoleOptions, generalOptions *docker.GeneralOptions) {
-	log := logging.FromContext(ctx)
+func (p *Processor) handleDns(ctx context.Context, dnsProvider providers.DNSProvider, containerEvent events.Action, container providers.Container, answer string) {
+	log := logging.FromContext(ctx).With("provider", dnsProvider.Name())
+	ctx = logging.WithLogger(ctx, log)
+	hook, hasHook := dnsProvider.(providers.ContainerHook)
 
-	if p.piholeClient != nil {
-		switch containerEvent {
-		case events.ActionStart, events.ActionHealthStatusHealthy:
-			var numOfAddedEntries int
-			var err error
-
-			if piholeOptions.TargetDomain == "" {
-				log.Info("Adding local DNS records to Pi-Hole", "urls", urls, "ip", ip)
-				numOfAddedEntries, err = p.piholeClient.AddDnsRecords(urls, ip)
-				if err != nil {
-					log.Error("Failed to add local DNS records to Pi-Hole", "urls", urls, "ip", ip, "error", err)
-					metrics.IncrementPiHoleApiRequestErrors(metrics.ADD_DNS_RECORD)
-					return
-				}
-			} else {
-				log.Info("Adding local CNAME records to Pi-Hole", "urls", urls, "targetDomain", piholeOptions.TargetDomain)
-				numOfAddedEntries, err = p.piholeClient.AddCNameRecords(urls, piholeOptions.TargetDomain)
-				if err != nil {
-					log.Error("Failed to add local CNAME records to Pi-Hole", "urls", urls, "targetDomain", piholeOptions.TargetDomain, "error", err)
-					metrics.IncrementPiHoleApiRequestErrors(metrics.ADD_CNAME_RECORD)
-					return
-				}
+	switch containerEvent {
+	case events.ActionStart, events.ActionHealthStatusHealthy:
+		if hasHook {
+			if err := hook.ContainerStarted(ctx, container); err != nil {
+				log.Error("Not adding DNS records", "error", err)
+				return
 			}
-			metrics.IncrementPiHoleEntriesCreated(numOfAddedEntries)
-		case events.ActionDie:
-			var numOfDeletedEntries int
-			var err error
-
-			if piholeOptions.TargetDomain == "" {
-				log.Info("Deleting local DNS records from Pi-Hole", "urls", urls)
-				numOfDeletedEntries, err = p.piholeClient.DeleteDnsRecords(urls)
-				if err != nil {
-					log.Error("Failed to delete local DNS records from Pi-Hole", "urls", urls, "error", err)
-					metrics.IncrementPiHoleApiRequestErrors(metrics.DELETE_DNS_RECORD)
-					return
-				}
-			} else {
-				log.Info("Deleting local CNAME records from Pi-Hole", "urls", urls, "targetDomain", piholeOptions.TargetDomain)
-				numOfDeletedEntries, err = p.piholeClient.DeleteCNameRecords(urls)
-				if err != nil {
-					log.Error("Failed to delete local CNAME records from Pi-Hole", "urls", urls, "targetDomain", piholeOptions.TargetDomain, "error", err)
-					metrics.IncrementPiHoleApiRequestErrors(metrics.DELETE_CNAME_RECORD)
-					return
-				}
+		}
+
+		records := p.dnsRecords(ctx, dnsProvider, container, answer)
+		if batch := dnsBatchFromContext(ctx); batch != nil {
+			log.Debug("Queueing DNS records", "records", records)
+			batch.addRecords(dnsProvider.Name(), records)
+			return
+		}
+
+		log.Info("Adding DNS records", "records", records)
+		if err := dnsProvider.EnsureRecords(ctx, records); err != nil {
+			log.Error("Failed to add DNS records", "records", records, "error", err)
+		}
+	case events.ActionDie:
+		if hasHook {
+			if err := hook.ContainerStopped(ctx, container); err != nil {
+				log.Error("Failed to clean up after container", "error", err)
 			}
-			metrics.IncrementPiHoleEntriesDeleted(numOfDeletedEntries)
+		}
+
+		records := p.dnsRecords(ctx, dnsProvider, container, answer)
+		domains := providers.Domains(records)
+		if disabler, ok := dnsProvider.(providers.RecordDisabler); ok && disabler.DisableOnStop(container) {
+			log.Info("Disabling DNS records", "domains", domains)
+			if err := disabler.DisableRecords(ctx, records); err != nil {
+				log.Error("Failed to disable DNS records", "domains", domains, "error", err)
+			}
+			return
+		}
+
+		log.Info("Deleting DNS records", "domains", domains)
+		if err := dnsProvider.DeleteRecords(ctx, records); err != nil {
+			log.Error("Failed to delete DNS records", "domains", domains, "error", err)
 		}
 	}
 }
 
-func (p *Processor) handleNpm(ctx context.Context, containerEvent events.Action, urls []string, ip string, port int, npmProxyHostOptions npm.NpmProxyHostOptions, generalOptions *docker.GeneralOptions) {
-	log := logging.FromContext(ctx)
+func (p *Processor) handleProxy(ctx context.Context, containerEvent events.Action, route providers.Route) {
+	log := logging.FromContext(ctx).With("provider", p.proxyProvider.Name())
+	ctx = logging.WithLogger(ctx, log)
 
 	switch containerEvent {
 	case events.ActionStart, events.ActionHealthStatusHealthy:
-		npmProxyHost := npm.ProxyHost{
-			AdvancedConfig:        npmProxyHostOptions.AdvancedConfig,
-			AllowWebsocketUpgrade: npmProxyHostOptions.AllowWebsocketUpgrade,
-			BlockExploits:         npmProxyHostOptions.BlockExploits,
-			CachingEnabled:        npmProxyHostOptions.CachingEnabled,
-			ForwardScheme:         npmProxyHostOptions.ForwardScheme,
-			HTTP2Support:          npmProxyHostOptions.HTTP2Support,
-			HstsEnabled:           npmProxyHostOptions.HstsEnabled,
-			HstsSubdomains:        npmProxyHostOptions.HstsSubdomains,
-			SslForced:             npmProxyHostOptions.SslForced,
-
-			DomainNames: urls,
-			ForwardHost: ip,
-			ForwardPort: port,
-			Locations:   []npm.Location{},
-			Meta:        npm.Meta{},
-		}
-
-		if npmProxyHostOptions.AccessListName != "" {
-			npmAccessListID, err := p.npmClient.GetAccessListIDByName(npmProxyHostOptions.AccessListName)
-			if err != nil {
-				log.Error("Not creating Nginx Proxy Manager entry", "error", err)
-				metrics.IncrementNpmApiRequestErrors(metrics.GET_ACCESS_LIST_ID)
-				return
+		log.Info("Adding proxy route")
+		if err := p.proxyProvider.EnsureRoute(ctx, route); err != nil {
+			log.Error("Failed to add proxy route", "error", err)
+		}
+	case events.ActionDie:
+		if disabler, ok := p.proxyProvider.(providers.RouteDisabler); ok && disabler.DisableOnStop(route) {
+			log.Info("Disabling proxy route")
+			if err := disabler.DisableRoute(ctx, route); err != nil {
+				log.Error("Failed to disable proxy route", "error", err)
 			}
-			npmProxyHost.AccessListID = npmAccessListID
+			return
 		}
 
-		if npmProxyHostOptions.CertificateName != "" {
-			npmCertificateID, err := p.npmClient.GetCertificateIDByName(npmProxyHostOptions.CertificateName)
-			if err != nil {
-				log.Error("Not creating Nginx Proxy Manager entry", "error", err)
-				metrics.IncrementNpmApiRequestErrors(metrics.GET_CERTIFICATE_ID)
-				return
-			}
-			npmProxyHost.CertificateID = npmCertificateID
+		log.Info("Deleting proxy route")
+		if err := p.proxyProvider.DeleteRoute(ctx, route); err != nil {
+			log.Error("Failed to delete proxy route", "error", err)
 		}
+	}
+}
 
-		log.Info("Adding entry to Nginx Proxy Manager")
+// HandleSnippetsChange re-processes the running containers that reference
+// any of the changed snippets, so their proxy hosts are updated.
+func (p *Processor) HandleSnippetsChange(ctx context.Context, changed []string) {
+	referencesChangedSnippet := func(reference snippets.Reference) bool {
+		return slices.Contains(changed, reference.Name)
+	}
 
-		addedNpmEntry, err := p.npmClient.AddProxyHost(npmProxyHost)
-		if err != nil {
-			log.Error("Failed to add entry to Nginx Proxy Manager", "error", err)
-			metrics.IncrementNpmApiRequestErrors(metrics.ADD_PROXY_HOST)
-			return
-		}
-		if addedNpmEntry {
-			metrics.IncrementNpmEntriesCreated()
-		}
-	case events.ActionDie:
-		log.Info("Deleting entry from Nginx Proxy Manager")
-		deletedNpmEntry, err := p.npmClient.DeleteProxyHosts(urls)
+	for _, dockerClient := range p.dockerClients {
+		containers, err := dockerClient.GetRelevantContainers()
 		if err != nil {
-			log.Error("Failed to delete entry from Nginx Proxy Manager", "error", err)
-			metrics.IncrementNpmApiRequestErrors(metrics.DELETE_PROXY_HOST)
-			return
+			log.Error("Failed to get containers", "host", dockerClient.DisplayHost, "error", err)
+			continue
 		}
-		if deletedNpmEntry {
-			metrics.IncrementNpmEntriesDeleted()
+
+		for _, container := range containers {
+			_, _, _, opts, err := docker.GetValuesFromLabels(container.Labels)
+			if err != nil || !slices.ContainsFunc(providers.Container{Options: opts}.Snippets(), referencesChangedSnippet) {
+				continue
+			}
+			p.preprocessContainer(ctx, container, dockerClient)
 		}
 	}
 }
 
 func (p *Processor) Shutdown() {
-	if p.piholeClient != nil {
-		if err := p.piholeClient.Logout(); err != nil {
-			log.Warn("Failed to logout from Pi-Hole", "error", err)
-		}
-	}
+	providers.Close(p.dnsProviders, p.proxyProvider)
 }
 
 func (p *Processor) processContainer(ctx context.Context, containerEvent events.Action, containerId string, dockerClient *docker.Client, containerName, ip string, urls []string, port int, opts *docker.ClientOptions) {
@@ -337,7 +414,7 @@ func (p *Processor) processContainer(ctx context.Context, containerEvent events.
 
 	msg := "Handling container"
 
-	if p.dryRun {
+	if p.options.DryRun {
 		msg += ". In dry run mode, not doing anything."
 		log.Info(msg, "ip", ip, "port", port, "urls", urls)
 		return
@@ -347,16 +424,65 @@ func (p *Processor) processContainer(ctx context.Context, containerEvent events.
 
 	metrics.IncrementHandledDockerEvents(dockerClient.DisplayHost, string(containerEvent))
 
-	if p.npmClient != nil {
-		npmHost := p.npmClient.GetIP()
-		if opts.AdguardHome != nil {
-			p.handleAdguardHome(ctx, containerEvent, urls, npmHost, *opts.AdguardHome, &opts.GeneralOptions)
+	p.handleContainer(ctx, containerEvent, providers.Container{
+		ID:      containerId,
+		Name:    containerName,
+		IP:      ip,
+		Port:    port,
+		URLs:    urls,
+		Options: opts,
+		NetworkAddresses: func(ctx context.Context) (mac, ip string, err error) {
+			containerInspectResponse, err := dockerClient.InspectContainer(ctx, containerId)
+			if err != nil {
+				return "", "", err
+			}
+			return dockerClient.GetMacAndIPAddress(containerInspectResponse)
+		},
+	})
+}
+
+// handleContainer hands the container to all DNS providers and, unless it
+// skips it, the proxy provider.
+func (p *Processor) handleContainer(ctx context.Context, containerEvent events.Action, container providers.Container) {
+	log := logging.FromContext(ctx)
+	opts := container.Options
+
+	// Without a proxy, DNS entries point straight at the container
+	useProxy := p.proxyProvider != nil && !opts.GeneralOptions.SkipProxy
+	dnsAnswer := container.IP
+	if p.options.DnsTargetIP != "" {
+		dnsAnswer = p.options.DnsTargetIP
+	}
+	dnsContainer := container
+
+	if useProxy {
+		address, err := p.proxyProvider.Address(container)
+		if err != nil {
+			log.Error("Not handling container", "error", err)
+			return
 		}
-		if opts.Pihole != nil {
-			p.handlePiHole(ctx, containerEvent, urls, npmHost, *opts.Pihole, &opts.GeneralOptions)
+		// DNS entries point at the proxy that serves the container
+		dnsAnswer = address
+
+		capabilities := p.proxyProvider.Capabilities()
+		if redirects := container.Redirects(); redirects != nil {
+			if capabilities.Redirects {
+				// Redirected domains have to resolve to the proxy as well
+				dnsContainer.URLs = append(slices.Clone(container.URLs), redirects...)
+			} else {
+				log.Warn("Proxy provider doesn't support redirects, ignoring them", "provider", p.proxyProvider.Name(), "redirects", redirects)
+			}
 		}
-		if opts.NPM != nil {
-			p.handleNpm(ctx, containerEvent, urls, ip, port, *opts.NPM, &opts.GeneralOptions)
+		if incomingPort := container.StreamPort(); incomingPort != 0 && !capabilities.Streams {
+			log.Warn("Proxy provider doesn't support streams, ignoring it", "provider", p.proxyProvider.Name(), "incomingPort", incomingPort)
 		}
 	}
+
+	for _, dnsProvider := range p.dnsProviders {
+		p.handleDns(ctx, dnsProvider, containerEvent, dnsContainer, dnsAnswer)
+	}
+
+	if useProxy {
+		p.handleProxy(ctx, containerEvent, providers.NewRoute(container))
+	}
 }
@@ -3,13 +3,87 @@
 package processor
 
 import (
+	"context"
 	"testing"
 
 	"github.com/deepspace2/plugnpin/pkg/clients/docker"
+	"github.com/deepspace2/plugnpin/pkg/providers"
 	"github.com/docker/docker/api/types/events"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+type fakeDNSProvider struct {
+	capabilities  providers.DNSCapabilities
+	cNameTarget   string
+	disableOnStop bool
+
+	ensured  [][]providers.Record
+	deleted  [][]providers.Record
+	disabled [][]providers.Record
+}
+
+func (f *fakeDNSProvider) Name() string                             { return "fake-dns" }
+func (f *fakeDNSProvider) Capabilities() providers.DNSCapabilities  { return f.capabilities }
+func (f *fakeDNSProvider) ListRecords() ([]providers.Record, error) { return nil, nil }
+
+func (f *fakeDNSProvider) Records(container providers.Container, answer string) []providers.Record {
+	if f.cNameTarget != "" {
+		return providers.CNameRecords(container.URLs, f.cNameTarget, 60)
+	}
+	return providers.ARecords(container.URLs, answer)
+}
+
+func (f *fakeDNSProvider) EnsureRecords(ctx context.Context, records []providers.Record) error {
+	f.ensured = append(f.ensured, records)
+	return nil
+}
+
+func (f *fakeDNSProvider) DeleteRecords(ctx context.Context, records []providers.Record) error {
+	f.deleted = append(f.deleted, records)
+	return nil
+}
+
+func (f *fakeDNSProvider) DisableOnStop(container providers.Container) bool {
+	return f.disableOnStop
+}
+
+func (f *fakeDNSProvider) DisableRecords(ctx context.Context, records []providers.Record) error {
+	f.disabled = append(f.disabled, records)
+	return nil
+}
+
+type fakeProxyProvider struct {
+	capabilities providers.ProxyCapabilities
+
+	ensured []providers.Route
+	deleted []providers.Route
+}
+
+func (f *fakeProxyProvider) Name() string                                { return "fake-proxy" }
+func (f *fakeProxyProvider) Capabilities() providers.ProxyCapabilities   { return f.capabilities }
+func (f *fakeProxyProvider) Address(providers.Container) (string, error) { return "10.0.0.1", nil }
+func (f *fakeProxyProvider) ListRoutes() ([]providers.Route, error)      { return nil, nil }
+
+func (f *fakeProxyProvider) EnsureRoute(ctx context.Context, route providers.Route) error {
+	f.ensured = append(f.ensured, route)
+	return nil
+}
+
+func (f *fakeProxyProvider) DeleteRoute(ctx context.Context, route providers.Route) error {
+	f.deleted = append(f.deleted, route)
+	return nil
+}
+
+func newContainer(t *testing.T, labels map[string]string) providers.Container {
+	t.Helper()
+	labels["plugNPiN.ip"] = "172.17.0.2:8080"
+	labels["plugNPiN.url"] = "app.com"
+	ip, urls, port, opts, err := docker.GetValuesFromLabels(labels)
+	require.NoError(t, err)
+	return providers.Container{ID: "id", Name: "app", IP: ip, Port: port, URLs: urls, Options: opts}
+}
+
 func TestShouldSkip(t *testing.T) {
 	p := &Processor{}
 
@@ -65,3 +139,87 @@ func TestShouldSkip(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleContainer(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("DNS records point at the proxy", func(t *testing.T) {
+		dnsProvider := &fakeDNSProvider{}
+		proxyProvider := &fakeProxyProvider{capabilities: providers.ProxyCapabilities{Redirects: true}}
+		p := New(nil, []providers.DNSProvider{dnsProvider}, proxyProvider, Options{})
+
+		container := newContainer(t, map[string]string{"plugNPiN.redirects": "old-app.com"})
+		p.handleContainer(ctx, events.ActionStart, container)
+
+		assert.Equal(t, [][]providers.Record{providers.ARecords([]string{"app.com", "old-app.com"}, "10.0.0.1")}, dnsProvider.ensured)
+		require.Len(t, proxyProvider.ensured, 1)
+		assert.Equal(t, []string{"app.com"}, proxyProvider.ensured[0].Domains)
+		assert.Equal(t, "172.17.0.2", proxyProvider.ensured[0].ForwardHost)
+		assert.Equal(t, 8080, proxyProvider.ensured[0].ForwardPort)
+	})
+
+	t.Run("redirects the proxy doesn't support don't resolve to it", func(t *testing.T) {
+		dnsProvider := &fakeDNSProvider{}
+		p := New(nil, []providers.DNSProvider{dnsProvider}, &fakeProxyProvider{}, Options{})
+
+		p.handleContainer(ctx, events.ActionStart, newContainer(t, map[string]string{"plugNPiN.redirects": "old-app.com"}))
+
+		assert.Equal(t, [][]providers.Record{providers.ARecords([]string{"app.com"}, "10.0.0.1")}, dnsProvider.ensured)
+	})
+
+	t.Run("without a proxy, DNS records point at the container", func(t *testing.T) {
+		dnsProvider := &fakeDNSProvider{}
+		p := New(nil, []providers.DNSProvider{dnsProvider}, nil, Options{})
+
+		p.handleContainer(ctx, events.ActionStart, newContainer(t, map[string]string{}))
+
+		assert.Equal(t, [][]providers.Record{providers.ARecords([]string{"app.com"}, "172.17.0.2")}, dnsProvider.ensured)
+	})
+
+	t.Run("skipped proxy with DNS target IP", func(t *testing.T) {
+		dnsProvider := &fakeDNSProvider{}
+		proxyProvider := &fakeProxyProvider{}
+		p := New(nil, []providers.DNSProvider{dnsProvider}, proxyProvider, Options{DnsTargetIP: "192.168.1.10"})
+
+		p.handleContainer(ctx, events.ActionStart, newContainer(t, map[string]string{"plugNPiN.options.skipProxy": "true"}))
+
+		assert.Equal(t, [][]providers.Record{providers.ARecords([]string{"app.com"}, "192.168.1.10")}, dnsProvider.ensured)
+		assert.Empty(t, proxyProvider.ensured)
+	})
+
+	t.Run("stopped container", func(t *testing.T) {
+		dnsProvider := &fakeDNSProvider{}
+		disablingDnsProvider := &fakeDNSProvider{disableOnStop: true}
+		proxyProvider := &fakeProxyProvider{}
+		p := New(nil, []providers.DNSProvider{dnsProvider, disablingDnsProvider}, proxyProvider, Options{})
+
+		p.handleContainer(ctx, events.ActionDie, newContainer(t, map[string]string{}))
+
+		assert.Len(t, dnsProvider.deleted, 1)
+		assert.Empty(t, dnsProvider.disabled)
+		assert.Len(t, disablingDnsProvider.disabled, 1)
+		assert.Empty(t, disablingDnsProvider.deleted)
+		assert.Len(t, proxyProvider.deleted, 1)
+	})
+}
+
+func TestDnsRecordsCapabilities(t *testing.T) {
+	ctx := context.Background()
+	p := &Processor{}
+	container := newContainer(t, map[string]string{})
+
+	t.Run("CNAME records are skipped", func(t *testing.T) {
+		dnsProvider := &fakeDNSProvider{cNameTarget: "target.com"}
+		assert.Empty(t, p.dnsRecords(ctx, dnsProvider, container, "10.0.0.1"))
+	})
+
+	t.Run("TTLs are dropped", func(t *testing.T) {
+		dnsProvider := &fakeDNSProvider{cNameTarget: "target.com", capabilities: providers.DNSCapabilities{CNAME: true}}
+		assert.Equal(t, providers.CNameRecords([]string{"app.com"}, "target.com", 0), p.dnsRecords(ctx, dnsProvider, container, "10.0.0.1"))
+	})
+
+	t.Run("supported records are kept", func(t *testing.T) {
+		dnsProvider := &fakeDNSProvider{cNameTarget: "target.com", capabilities: providers.DNSCapabilities{CNAME: true, TTL: true}}
+		assert.Equal(t, providers.CNameRecords([]string{"app.com"}, "target.com", 60), p.dnsRecords(ctx, dnsProvider, container, "10.0.0.1"))
+	})
+}
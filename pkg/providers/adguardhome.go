@@ -0,0 +1,129 @@
+package providers
+
+import (
+	"context"
+	"maps"
+	"net"
+	"slices"
+
+	"github.com/deepspace2/plugnpin/pkg/clients/adguardhome"
+	"github.com/deepspace2/plugnpin/pkg/clients/docker"
+	"github.com/deepspace2/plugnpin/pkg/config"
+	"github.com/deepspace2/plugnpin/pkg/metrics"
+)
+
+const (
+	adguardHomeOptionsNamespace          = "adguardHomeOptions"
+	adguardHomeOptionsDisableOnStopLabel = "plugNPiN.adguardHomeOptions.disableOnStop"
+	adguardHomeOptionsTargetDomainLabel  = "plugNPiN.adguardHomeOptions.targetDomain"
+)
+
+func init() {
+	docker.RegisterOptionsParser(adguardHomeOptionsNamespace, func(labels map[string]string) (any, error) {
+		disableOnStop, err := docker.ParseOptionalBoolLabel(labels, adguardHomeOptionsDisableOnStopLabel)
+		if err != nil {
+			return nil, err
+		}
+		return &adguardhome.AdguardHomeOptions{DisableOnStop: disableOnStop, TargetDomain: labels[adguardHomeOptionsTargetDomainLabel]}, nil
+	})
+
+	RegisterDNSProvider(metrics.ADGUARD_HOME, func(config *config.Config, options Options) (DNSProvider, error) {
+		if config.AdguardHomeDisabled {
+			return nil, nil
+		}
+		client := adguardhome.NewClient(config.AdguardHomeHost, config.AdguardHomeUsername, config.AdguardHomePassword)
+		return NewAdguardHome(client, config.AdguardHomeDisableOnStop), nil
+	})
+}
+
+// AdguardHome manages DNS rewrites. AdGuard Home doesn't tell local DNS
+// records and CNAME records apart, both are rewrites to an answer.
+type AdguardHome struct {
+	client *adguardhome.Client
+	// disableOnStop is the global ADGUARD_HOME_DISABLE_ON_STOP setting
+	disableOnStop bool
+}
+
+func NewAdguardHome(client *adguardhome.Client, disableOnStop bool) *AdguardHome {
+	return &AdguardHome{
+		client:        client,
+		disableOnStop: disableOnStop,
+	}
+}
+
+func (a *AdguardHome) Name() string {
+	return metrics.ADGUARD_HOME
+}
+
+func (a *AdguardHome) Capabilities() DNSCapabilities {
+	return DNSCapabilities{CNAME: true}
+}
+
+func (a *AdguardHome) Records(container Container, answer string) []Record {
+	if targetDomain := containerOptions[adguardhome.AdguardHomeOptions](container, adguardHomeOptionsNamespace).TargetDomain; targetDomain != "" {
+		return CNameRecords(container.URLs, targetDomain, 0)
+	}
+	return ARecords(container.URLs, answer)
+}
+
+func (a *AdguardHome) ListRecords() ([]Record, error) {
+	dnsRewrites, err := a.client.GetDnsRewrites()
+	if err != nil {
+		return nil, err
+	}
+
+	records := []Record{}
+	for _, domain := range slices.Sorted(maps.Keys(dnsRewrites)) {
+		for _, answer := range dnsRewrites[domain] {
+			recordType := RECORD_TYPE_CNAME
+			if net.ParseIP(string(answer)) != nil {
+				recordType = RECORD_TYPE_A
+			}
+			records = append(records, Record{Domain: string(domain), Type: recordType, Target: string(answer)})
+		}
+	}
+	return records, nil
+}
+
+func (a *AdguardHome) EnsureRecords(ctx context.Context, records []Record) error {
+	answers := map[adguardhome.DomainName]string{}
+	for _, record := range records {
+		answers[adguardhome.DomainName(record.Domain)] = record.Target
+	}
+
+	numOfAddedRewrites, numOfUpdatedRewrites, err := a.client.AddDnsRewritesBatch(answers)
+	metrics.IncrementAdguardHomeEntriesCreated(numOfAddedRewrites)
+	metrics.IncrementAdguardHomeEntriesUpdated(numOfUpdatedRewrites)
+	if err != nil {
+		metrics.IncrementAdguardHomeApiRequestErrors(metrics.ADD_DNS_REWRITE)
+		return err
+	}
+	return nil
+}
+
+func (a *AdguardHome) DeleteRecords(ctx context.Context, records []Record) error {
+	numOfDeletedRewrites, err := a.client.DeleteDnsRewrites(Domains(records))
+	metrics.IncrementAdguardHomeEntriesDeleted(numOfDeletedRewrites)
+	if err != nil {
+		metrics.IncrementAdguardHomeApiRequestErrors(metrics.DELETE_DNS_REWRITE)
+		return err
+	}
+	return nil
+}
+
+func (a *AdguardHome) DisableOnStop(container Container) bool {
+	if disableOnStop := containerOptions[adguardhome.AdguardHomeOptions](container, adguardHomeOptionsNamespace).DisableOnStop; disableOnStop != nil {
+		return *disableOnStop
+	}
+	return a.disableOnStop
+}
+
+func (a *AdguardHome) DisableRecords(ctx context.Context, records []Record) error {
+	numOfDisabledRewrites, err := a.client.DisableDnsRewrites(Domains(records))
+	if err != nil {
+		metrics.IncrementAdguardHomeApiRequestErrors(metrics.DISABLE_DNS_REWRITE)
+		return err
+	}
+	metrics.IncrementAdguardHomeEntriesUpdated(numOfDisabledRewrites)
+	return nil
+}
@@ -0,0 +1,133 @@
+//go:build unit
+
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deepspace2/plugnpin/pkg/clients/adguardhome"
+	"github.com/deepspace2/plugnpin/pkg/clients/docker"
+	"github.com/deepspace2/plugnpin/pkg/errors"
+)
+
+func TestAdguardHomeRecords(t *testing.T) {
+	a := NewAdguardHome(nil, false)
+
+	adguardHomeOptions := &adguardhome.AdguardHomeOptions{}
+	container := Container{
+		URLs:    []string{"one.com", "two.com"},
+		Options: &docker.ClientOptions{Providers: map[string]any{adguardHomeOptionsNamespace: adguardHomeOptions}},
+	}
+	assert.Equal(t, ARecords([]string{"one.com", "two.com"}, "1.2.3.4"), a.Records(container, "1.2.3.4"))
+
+	adguardHomeOptions.TargetDomain = "target.com"
+	assert.Equal(t, CNameRecords([]string{"one.com", "two.com"}, "target.com", 0), a.Records(container, "1.2.3.4"))
+}
+
+func TestAdguardHomeDisableOnStop(t *testing.T) {
+	disabled := false
+	adguardHomeOptions := &adguardhome.AdguardHomeOptions{}
+	container := Container{Options: &docker.ClientOptions{Providers: map[string]any{adguardHomeOptionsNamespace: adguardHomeOptions}}}
+
+	assert.False(t, NewAdguardHome(nil, false).DisableOnStop(container))
+	assert.True(t, NewAdguardHome(nil, true).DisableOnStop(container))
+
+	adguardHomeOptions.DisableOnStop = &disabled
+	assert.False(t, NewAdguardHome(nil, true).DisableOnStop(container))
+}
+
+func TestAdguardHomeOptionsLabels(t *testing.T) {
+	disableOnStop := true
+
+	testCases := []struct {
+		name                  string
+		labels                map[string]string
+		expectedErr           error
+		expectedTargetDomain  string
+		expectedDisableOnStop *bool
+	}{
+		{
+			name: "AdguardHome options - target domain",
+			labels: map[string]string{
+				docker.IpLabel:                      "192.168.1.10:8080",
+				docker.UrlLabel:                     "my-service.example.com",
+				adguardHomeOptionsTargetDomainLabel: "custom.domain.adguard",
+			},
+			expectedTargetDomain: "custom.domain.adguard",
+		},
+		{
+			name: "AdguardHome options - disable on stop",
+			labels: map[string]string{
+				docker.IpLabel:                       "192.168.1.10:8080",
+				docker.UrlLabel:                      "my-service.example.com",
+				adguardHomeOptionsDisableOnStopLabel: "true",
+			},
+			expectedDisableOnStop: &disableOnStop,
+		},
+		{
+			name: "AdguardHome options - invalid disable on stop",
+			labels: map[string]string{
+				docker.IpLabel:                       "192.168.1.10:8080",
+				docker.UrlLabel:                      "my-service.example.com",
+				adguardHomeOptionsDisableOnStopLabel: "sometimes",
+			},
+			expectedErr: &errors.InvalidLabelValueError{Msg: fmt.Sprintf("value of '%v' label must be a boolean, got 'sometimes'", adguardHomeOptionsDisableOnStopLabel)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, _, opts, err := docker.GetValuesFromLabels(tc.labels)
+
+			assert.Equal(t, tc.expectedErr, err)
+			if err != nil {
+				return
+			}
+			adguardHomeOptions := containerOptions[adguardhome.AdguardHomeOptions](Container{Options: opts}, adguardHomeOptionsNamespace)
+			assert.Equal(t, tc.expectedTargetDomain, adguardHomeOptions.TargetDomain)
+			assert.Equal(t, tc.expectedDisableOnStop, adguardHomeOptions.DisableOnStop)
+		})
+	}
+}
+
+func TestAdguardHomeListAndEnsureRecords(t *testing.T) {
+	added := []adguardhome.DnsRewrite{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/control/rewrite/list":
+			_, _ = fmt.Fprint(w, `[{"domain": "one.com", "answer": "1.2.3.4", "enabled": true}, {"domain": "one.com", "answer": "::1", "enabled": true}, {"domain": "alias.com", "answer": "target.com", "enabled": true}]`)
+		case r.Method == http.MethodPost && r.URL.Path == "/control/rewrite/add":
+			var rewrite adguardhome.DnsRewrite
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&rewrite))
+			added = append(added, rewrite)
+			_, _ = fmt.Fprint(w, `{}`)
+		default:
+			t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	a := NewAdguardHome(adguardhome.NewClient(server.URL, "user", "pass"), false)
+
+	records, err := a.ListRecords()
+	assert.NoError(t, err)
+	assert.Equal(t, []Record{
+		{Domain: "alias.com", Type: RECORD_TYPE_CNAME, Target: "target.com"},
+		{Domain: "one.com", Type: RECORD_TYPE_A, Target: "1.2.3.4"},
+		{Domain: "one.com", Type: RECORD_TYPE_A, Target: "::1"},
+	}, records)
+
+	err = a.EnsureRecords(context.Background(), []Record{
+		{Domain: "one.com", Type: RECORD_TYPE_A, Target: "1.2.3.4"},
+		{Domain: "two.com", Type: RECORD_TYPE_A, Target: "1.2.3.4"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []adguardhome.DnsRewrite{{Domain: "two.com", Answer: "1.2.3.4", Enabled: true}}, added)
+}
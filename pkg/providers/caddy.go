@@ -0,0 +1,274 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/deepspace2/plugnpin/pkg/clients/caddy"
+	"github.com/deepspace2/plugnpin/pkg/config"
+	"github.com/deepspace2/plugnpin/pkg/logging"
+	"github.com/deepspace2/plugnpin/pkg/metrics"
+)
+
+const (
+	hstsHeader            = "Strict-Transport-Security"
+	hstsMaxAge            = "max-age=63072000"
+	hstsSubdomains        = "; includeSubDomains"
+	protocolHTTP          = "http"
+	handlerHeaders        = "headers"
+	handlerProxy          = "reverse_proxy"
+	handlerStaticResponse = "static_response"
+	handlerSubroute       = "subroute"
+	forwardSchemeHTTPS    = "https"
+	httpsRedirectLocation = "https://{http.request.host}{http.request.uri}"
+	httpsRedirectStatus   = http.StatusPermanentRedirect
+)
+
+// CaddyConfig is the configuration of the Caddy provider.
+type CaddyConfig struct {
+	Disabled bool   `env:"CADDY_DISABLED" envDefault:"true"`
+	Host     string `env:"CADDY_HOST" secret:"true"`
+	IP       string `env:"CADDY_IP"`
+	Server   string `env:"CADDY_SERVER" envDefault:"srv0"`
+}
+
+func (c CaddyConfig) Enabled() bool {
+	return !c.Disabled
+}
+
+func (c CaddyConfig) Validate() error {
+	if c.Host == "" {
+		return errors.New(`env: CADDY_HOST is required but not set via env var or secret`)
+	}
+	if net.ParseIP(c.IP) == nil {
+		return fmt.Errorf(`env: 'CADDY_IP' must be an IP address, got '%v'`, c.IP)
+	}
+	if c.Server == "" {
+		return errors.New(`env: 'CADDY_SERVER' must not be empty`)
+	}
+	return nil
+}
+
+func init() {
+	RegisterProxyProvider(caddy.NAME, func(_ *config.Config, options Options) (ProxyProvider, error) {
+		var caddyConfig CaddyConfig
+		if enabled, err := parseConfig(&caddyConfig); !enabled || err != nil {
+			return nil, err
+		}
+		client := caddy.NewClient(caddyConfig.Host, caddyConfig.Server)
+		if err := client.CheckServer(); err != nil {
+			return nil, fmt.Errorf("failed to reach Caddy: %w", err)
+		}
+		return NewCaddy(client, caddyConfig.IP), nil
+	})
+}
+
+// Caddy manages one route per container in an HTTP server of Caddy's admin
+// API. Routes are owned by PlugNPiN through their '@id', see
+// caddy.ROUTE_ID_PREFIX, so routes from the Caddyfile are never touched.
+// Certificates are left to Caddy's automatic HTTPS.
+type Caddy struct {
+	client *caddy.Client
+	ip     string
+
+	mu sync.Mutex
+	// routeIDs are the '@id's of the routes of containers by container name,
+	// so a container's previous route is deleted when its first domain changes
+	routeIDs map[string]string
+}
+
+func NewCaddy(client *caddy.Client, ip string) *Caddy {
+	return &Caddy{client: client, ip: ip, routeIDs: map[string]string{}}
+}
+
+func (c *Caddy) Name() string {
+	return caddy.NAME
+}
+
+func (c *Caddy) Capabilities() ProxyCapabilities {
+	return ProxyCapabilities{}
+}
+
+func (c *Caddy) Address(container Container) (string, error) {
+	return c.ip, nil
+}
+
+func (c *Caddy) ListRoutes() ([]Route, error) {
+	caddyRoutes, err := c.client.GetRoutes()
+	if err != nil {
+		return nil, err
+	}
+
+	routes := []Route{}
+	for _, caddyRoute := range caddyRoutes {
+		route, err := routeFromCaddy(caddyRoute)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse route '%v': %w", caddyRoute.ID, err)
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// EnsureRoute adds or updates the route, and deletes the previous routes of
+// its domains or container, whose '@id' differs if the first domain changed.
+func (c *Caddy) EnsureRoute(ctx context.Context, route Route) error {
+	newRoute := caddyRoute(route)
+	added, updated, err := c.client.AddRoute(newRoute)
+	if err != nil {
+		metrics.IncrementApiRequestErrors(caddy.NAME, metrics.ENSURE_ROUTE)
+		return fmt.Errorf("failed to add route: %w", err)
+	}
+	if added {
+		metrics.IncrementManagedEntries(caddy.NAME, metrics.ADDED, 1)
+	}
+	if updated {
+		metrics.IncrementManagedEntries(caddy.NAME, metrics.UPDATED, 1)
+	}
+
+	staleIDs, err := c.client.GetRouteIDsOfHosts(route.Domains)
+	if err != nil {
+		metrics.IncrementApiRequestErrors(caddy.NAME, metrics.ENSURE_ROUTE)
+		return fmt.Errorf("failed to get previous routes: %w", err)
+	}
+	if previousID := c.rememberRouteID(route, newRoute.ID); previousID != "" {
+		staleIDs = append(staleIDs, previousID)
+	}
+
+	var errs []error
+	for _, id := range slices.Compact(slices.Sorted(slices.Values(staleIDs))) {
+		if id != newRoute.ID {
+			errs = append(errs, c.deleteRoute(ctx, id))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Caddy) DeleteRoute(ctx context.Context, route Route) error {
+	c.rememberRouteID(route, "")
+	return c.deleteRoute(ctx, caddy.RouteID(route.Domains[0]))
+}
+
+func (c *Caddy) deleteRoute(ctx context.Context, id string) error {
+	deleted, err := c.client.DeleteRoute(id)
+	if err != nil {
+		metrics.IncrementApiRequestErrors(caddy.NAME, metrics.DELETE_ROUTE)
+		return fmt.Errorf("failed to delete route '%v': %w", id, err)
+	}
+	if deleted {
+		logging.FromContext(ctx).Info("Deleted route", "id", id)
+		metrics.IncrementManagedEntries(caddy.NAME, metrics.DELETED, 1)
+	}
+	return nil
+}
+
+// rememberRouteID remembers id as the '@id' of the route of the route's
+// container, or forgets it if id is empty, and returns the previous one.
+func (c *Caddy) rememberRouteID(route Route, id string) string {
+	if route.Container == nil {
+		return ""
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	previousID := c.routeIDs[route.Container.Name]
+	if id == "" {
+		delete(c.routeIDs, route.Container.Name)
+	} else {
+		c.routeIDs[route.Container.Name] = id
+	}
+	return previousID
+}
+
+// caddyRoute maps route onto a host matcher and a reverse_proxy handler,
+// preceded by a headers handler if HSTS is enabled and by a subroute that
+// redirects HTTP to HTTPS if SSL is forced.
+func caddyRoute(route Route) caddy.Route {
+	handlers := []caddy.Handler{}
+	if route.SslForced {
+		handlers = append(handlers, caddy.Handler{
+			Handler: handlerSubroute,
+			Routes: []caddy.Route{{
+				Match: []caddy.Match{{Protocol: protocolHTTP}},
+				Handle: []caddy.Handler{{
+					Handler:    handlerStaticResponse,
+					Headers:    &caddy.ProxyHeaders{Location: []string{httpsRedirectLocation}},
+					StatusCode: httpsRedirectStatus,
+				}},
+			}},
+		})
+	}
+	if route.HstsEnabled {
+		hsts := hstsMaxAge
+		if route.Container != nil && route.Container.npmOptions().HstsSubdomains {
+			hsts += hstsSubdomains
+		}
+		handlers = append(handlers, caddy.Handler{
+			Handler:  handlerHeaders,
+			Response: &caddy.HeaderOps{Set: map[string][]string{hstsHeader: {hsts}}},
+		})
+	}
+
+	proxyHandler := caddy.Handler{
+		Handler:   handlerProxy,
+		Upstreams: []caddy.Upstream{{Dial: net.JoinHostPort(route.ForwardHost, strconv.Itoa(route.ForwardPort))}},
+	}
+	if route.ForwardScheme == forwardSchemeHTTPS {
+		proxyHandler.Transport = &caddy.Transport{Protocol: protocolHTTP, TLS: &struct{}{}}
+	}
+	if !route.Websockets {
+		// Caddy proxies websockets by default
+		proxyHandler.Headers = &caddy.ProxyHeaders{Request: &caddy.HeaderOps{Delete: []string{"Connection", "Upgrade"}}}
+	}
+	handlers = append(handlers, proxyHandler)
+
+	return caddy.Route{
+		ID:       caddy.RouteID(route.Domains[0]),
+		Match:    []caddy.Match{{Host: route.Domains}},
+		Handle:   handlers,
+		Terminal: true,
+	}
+}
+
+func routeFromCaddy(caddyRoute caddy.Route) (Route, error) {
+	route := Route{ForwardScheme: "http", Websockets: true}
+	for _, match := range caddyRoute.Match {
+		route.Domains = append(route.Domains, match.Host...)
+	}
+
+	for _, handler := range caddyRoute.Handle {
+		switch handler.Handler {
+		case handlerHeaders:
+			if handler.Response != nil && len(handler.Response.Set[hstsHeader]) > 0 {
+				route.HstsEnabled = true
+			}
+		case handlerProxy:
+			if len(handler.Upstreams) == 0 {
+				return Route{}, fmt.Errorf("reverse_proxy handler has no upstreams")
+			}
+			host, port, err := net.SplitHostPort(handler.Upstreams[0].Dial)
+			if err != nil {
+				return Route{}, err
+			}
+			route.ForwardHost = host
+			route.ForwardPort, err = strconv.Atoi(port)
+			if err != nil {
+				return Route{}, fmt.Errorf("invalid upstream port '%v'", port)
+			}
+			if handler.Transport != nil && handler.Transport.TLS != nil {
+				route.ForwardScheme = forwardSchemeHTTPS
+			}
+			if handler.Headers != nil && handler.Headers.Request != nil && len(handler.Headers.Request.Delete) > 0 {
+				route.Websockets = false
+			}
+		}
+	}
+	return route, nil
+}
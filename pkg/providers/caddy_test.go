@@ -0,0 +1,191 @@
+//go:build unit
+
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deepspace2/plugnpin/pkg/clients/caddy"
+	"github.com/deepspace2/plugnpin/pkg/clients/docker"
+	"github.com/deepspace2/plugnpin/pkg/clients/npm"
+)
+
+func TestCaddyConfig(t *testing.T) {
+	t.Setenv("CADDY_DISABLED", "false")
+	t.Setenv("CADDY_HOST", "http://caddy:2019")
+	t.Setenv("CADDY_IP", "192.168.1.5")
+
+	var caddyConfig CaddyConfig
+	enabled, err := parseConfig(&caddyConfig)
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+	assert.Equal(t, CaddyConfig{Host: "http://caddy:2019", IP: "192.168.1.5", Server: "srv0"}, caddyConfig)
+
+	t.Setenv("CADDY_IP", "caddy")
+	_, err = parseConfig(&CaddyConfig{})
+	assert.ErrorContains(t, err, "CADDY_IP")
+
+	t.Setenv("CADDY_HOST", "")
+	_, err = parseConfig(&CaddyConfig{})
+	assert.ErrorContains(t, err, "CADDY_HOST")
+
+	// No need to set the Caddy env vars if Caddy is disabled
+	t.Setenv("CADDY_DISABLED", "true")
+	enabled, err = parseConfig(&CaddyConfig{})
+	assert.NoError(t, err)
+	assert.False(t, enabled)
+}
+
+func TestCaddyRoute(t *testing.T) {
+	container := Container{
+		IP:   "10.0.0.1",
+		Port: 8443,
+		URLs: []string{"app.com", "www.app.com"},
+		Options: &docker.ClientOptions{Providers: map[string]any{npmOptionsNamespace: &npm.NpmProxyHostOptions{
+			ForwardScheme:  "https",
+			HstsEnabled:    true,
+			HstsSubdomains: true,
+		}}},
+	}
+
+	assert.Equal(t, caddy.Route{
+		ID:    "plugnpin_app.com",
+		Match: []caddy.Match{{Host: []string{"app.com", "www.app.com"}}},
+		Handle: []caddy.Handler{
+			{Handler: "headers", Response: &caddy.HeaderOps{Set: map[string][]string{"Strict-Transport-Security": {"max-age=63072000; includeSubDomains"}}}},
+			{
+				Handler:   "reverse_proxy",
+				Headers:   &caddy.ProxyHeaders{Request: &caddy.HeaderOps{Delete: []string{"Connection", "Upgrade"}}},
+				Transport: &caddy.Transport{Protocol: "http", TLS: &struct{}{}},
+				Upstreams: []caddy.Upstream{{Dial: "10.0.0.1:8443"}},
+			},
+		},
+		Terminal: true,
+	}, caddyRoute(NewRoute(container)))
+
+	npmOptions := &npm.NpmProxyHostOptions{ForwardScheme: "http", AllowWebsocketUpgrade: true}
+	container.Options.Providers[npmOptionsNamespace] = npmOptions
+	assert.Equal(t, caddy.Route{
+		ID:       "plugnpin_app.com",
+		Match:    []caddy.Match{{Host: []string{"app.com", "www.app.com"}}},
+		Handle:   []caddy.Handler{{Handler: "reverse_proxy", Upstreams: []caddy.Upstream{{Dial: "10.0.0.1:8443"}}}},
+		Terminal: true,
+	}, caddyRoute(NewRoute(container)))
+
+	npmOptions.SslForced = true
+	assert.Equal(t, []caddy.Handler{
+		{
+			Handler: "subroute",
+			Routes: []caddy.Route{{
+				Match: []caddy.Match{{Protocol: "http"}},
+				Handle: []caddy.Handler{{
+					Handler:    "static_response",
+					Headers:    &caddy.ProxyHeaders{Location: []string{"https://{http.request.host}{http.request.uri}"}},
+					StatusCode: http.StatusPermanentRedirect,
+				}},
+			}},
+		},
+		{Handler: "reverse_proxy", Upstreams: []caddy.Upstream{{Dial: "10.0.0.1:8443"}}},
+	}, caddyRoute(NewRoute(container)).Handle)
+}
+
+func TestRouteFromCaddy(t *testing.T) {
+	for _, route := range []Route{
+		{Domains: []string{"app.com"}, ForwardScheme: "http", ForwardHost: "10.0.0.1", ForwardPort: 80},
+		{Domains: []string{"app.com", "www.app.com"}, ForwardScheme: "https", ForwardHost: "fd00::1", ForwardPort: 443, HstsEnabled: true, Websockets: true},
+	} {
+		parsedRoute, err := routeFromCaddy(caddyRoute(route))
+		assert.NoError(t, err)
+		assert.Equal(t, route, parsedRoute)
+	}
+
+	_, err := routeFromCaddy(caddy.Route{Handle: []caddy.Handler{{Handler: "reverse_proxy"}}})
+	assert.Error(t, err)
+}
+
+func TestCaddyEnsureListAndDeleteRoute(t *testing.T) {
+	routes := []caddy.Route{{Handle: []caddy.Handler{{Handler: "file_server"}}}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/config/apps/http/servers/srv0/routes":
+			assert.NoError(t, json.NewEncoder(w).Encode(routes))
+		case r.Method == http.MethodPut && r.URL.Path == "/config/apps/http/servers/srv0/routes/0":
+			var route caddy.Route
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&route))
+			routes = append([]caddy.Route{route}, routes...)
+		case r.Method == http.MethodDelete && r.URL.Path == "/id/plugnpin_app.com":
+			routes = routes[1:]
+		default:
+			t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	c := NewCaddy(caddy.NewClient(server.URL, "srv0"), "192.168.1.5")
+	address, err := c.Address(Container{})
+	assert.NoError(t, err)
+	assert.Equal(t, "192.168.1.5", address)
+
+	route := Route{Domains: []string{"app.com"}, ForwardScheme: "http", ForwardHost: "10.0.0.1", ForwardPort: 80, Websockets: true}
+	assert.NoError(t, c.EnsureRoute(context.Background(), route))
+
+	listedRoutes, err := c.ListRoutes()
+	assert.NoError(t, err)
+	assert.Equal(t, []Route{route}, listedRoutes)
+
+	assert.NoError(t, c.DeleteRoute(context.Background(), route))
+	listedRoutes, err = c.ListRoutes()
+	assert.NoError(t, err)
+	assert.Empty(t, listedRoutes)
+	assert.Len(t, routes, 1, fmt.Sprintf("foreign route was touched: %v", routes))
+}
+
+func TestCaddyEnsureRouteDeletesPreviousRoutes(t *testing.T) {
+	routes := []caddy.Route{}
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/config/apps/http/servers/srv0/routes":
+			assert.NoError(t, json.NewEncoder(w).Encode(routes))
+		case r.Method == http.MethodPut && r.URL.Path == "/config/apps/http/servers/srv0/routes/0":
+			var route caddy.Route
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&route))
+			routes = append([]caddy.Route{route}, routes...)
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/id/"):
+			id := strings.TrimPrefix(r.URL.Path, "/id/")
+			deleted = append(deleted, id)
+			routes = slices.DeleteFunc(routes, func(route caddy.Route) bool { return route.ID == id })
+		default:
+			t.Fatalf("Received unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	c := NewCaddy(caddy.NewClient(server.URL, "srv0"), "192.168.1.5")
+	container := Container{Name: "app", IP: "10.0.0.1", Port: 80, URLs: []string{"app.com", "www.app.com"}, Options: &docker.ClientOptions{}}
+	assert.NoError(t, c.EnsureRoute(context.Background(), NewRoute(container)))
+
+	t.Run("route of a reordered domain", func(t *testing.T) {
+		container.URLs = []string{"www.app.com", "app.com"}
+		assert.NoError(t, c.EnsureRoute(context.Background(), NewRoute(container)))
+		assert.Equal(t, []string{"plugnpin_app.com"}, deleted)
+	})
+
+	t.Run("route of the container's previous domains", func(t *testing.T) {
+		deleted = nil
+		container.URLs = []string{"new.com"}
+		assert.NoError(t, c.EnsureRoute(context.Background(), NewRoute(container)))
+		assert.Equal(t, []string{"plugnpin_www.app.com"}, deleted)
+	})
+
+	assert.Equal(t, []caddy.Route{caddyRoute(NewRoute(container))}, routes)
+}
@@ -0,0 +1,171 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path"
+
+	"github.com/deepspace2/plugnpin/pkg/clients/nginx"
+	"github.com/deepspace2/plugnpin/pkg/config"
+	"github.com/deepspace2/plugnpin/pkg/metrics"
+	"github.com/deepspace2/plugnpin/pkg/snippets"
+)
+
+// NginxConfig is the configuration of the nginx provider.
+type NginxConfig struct {
+	CertificatesDir string `env:"NGINX_CERTIFICATES_DIR" envDefault:"/etc/nginx/certs"`
+	ConfDir         string `env:"NGINX_CONF_DIR"`
+	Disabled        bool   `env:"NGINX_DISABLED" envDefault:"true"`
+	IP              string `env:"NGINX_IP"`
+	ReloadCommand   string `env:"NGINX_RELOAD_COMMAND"`
+	TemplateFile    string `env:"NGINX_TEMPLATE_FILE"`
+	ValidateCommand string `env:"NGINX_VALIDATE_COMMAND"`
+}
+
+func (c NginxConfig) Enabled() bool {
+	return !c.Disabled
+}
+
+func (c NginxConfig) Validate() error {
+	if c.ConfDir == "" {
+		return errors.New(`env: NGINX_CONF_DIR is required but not set via env var or secret`)
+	}
+	if net.ParseIP(c.IP) == nil {
+		return fmt.Errorf(`env: 'NGINX_IP' must be an IP address, got '%v'`, c.IP)
+	}
+	return nil
+}
+
+func init() {
+	RegisterProxyProvider(nginx.NAME, func(_ *config.Config, options Options) (ProxyProvider, error) {
+		var nginxConfig NginxConfig
+		if enabled, err := parseConfig(&nginxConfig); !enabled || err != nil {
+			return nil, err
+		}
+
+		templateText := nginx.DefaultTemplate
+		if nginxConfig.TemplateFile != "" {
+			content, err := os.ReadFile(nginxConfig.TemplateFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read nginx template: %w", err)
+			}
+			templateText = string(content)
+		}
+
+		client, err := nginx.NewClient(nginxConfig.ConfDir, templateText, nginxConfig.ValidateCommand, nginxConfig.ReloadCommand)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create nginx client: %w", err)
+		}
+		return NewNginx(client, nginxConfig.IP, nginxConfig.CertificatesDir, options.Snippets), nil
+	})
+}
+
+// Nginx writes a server block file per container to the conf.d directory of
+// a plain nginx. Only files starting with nginx.HEADER are owned by PlugNPiN,
+// hand-written files are never touched.
+type Nginx struct {
+	client *nginx.Client
+	// ip is the address of nginx the DNS records point at
+	ip string
+	// certificatesDir holds a directory per certificate name, as seen by nginx
+	certificatesDir string
+	snippets        *snippets.Library
+}
+
+func NewNginx(client *nginx.Client, ip, certificatesDir string, snippets *snippets.Library) *Nginx {
+	return &Nginx{
+		client:          client,
+		ip:              ip,
+		certificatesDir: certificatesDir,
+		snippets:        snippets,
+	}
+}
+
+func (n *Nginx) Name() string {
+	return nginx.NAME
+}
+
+func (n *Nginx) Capabilities() ProxyCapabilities {
+	return ProxyCapabilities{}
+}
+
+func (n *Nginx) Address(container Container) (string, error) {
+	return n.ip, nil
+}
+
+func (n *Nginx) ListRoutes() ([]Route, error) {
+	servers, err := n.client.GetServers()
+	if err != nil {
+		return nil, err
+	}
+
+	routes := []Route{}
+	for _, server := range servers {
+		routes = append(routes, Route{
+			Domains:       server.Domains,
+			ForwardScheme: server.ForwardScheme,
+			ForwardHost:   server.ForwardHost,
+			ForwardPort:   server.ForwardPort,
+			HstsEnabled:   server.HstsEnabled,
+			SslForced:     server.SslForced,
+			Websockets:    server.Websockets,
+		})
+	}
+	return routes, nil
+}
+
+func (n *Nginx) EnsureRoute(ctx context.Context, route Route) error {
+	server := nginx.Server{
+		Domains:       route.Domains,
+		ForwardHost:   route.ForwardHost,
+		ForwardPort:   route.ForwardPort,
+		ForwardScheme: route.ForwardScheme,
+		HstsEnabled:   route.HstsEnabled,
+		SslForced:     route.SslForced,
+		Websockets:    route.Websockets,
+	}
+	if server.ForwardScheme == "" {
+		server.ForwardScheme = "http"
+	}
+	if route.Container != nil {
+		npmOptions := route.Container.npmOptions()
+		advancedConfig, err := renderAdvancedConfig(n.snippets, npmOptions)
+		if err != nil {
+			return fmt.Errorf("not writing server block, failed to render snippets: %w", err)
+		}
+		server.AdvancedConfig = advancedConfig
+		server.CertificateName = npmOptions.CertificateName
+		if server.CertificateName != "" {
+			server.CertificateDir = path.Join(n.certificatesDir, server.CertificateName)
+		}
+		server.HstsSubdomains = npmOptions.HstsSubdomains
+	}
+
+	added, updated, err := n.client.AddServer(server)
+	if err != nil {
+		metrics.IncrementApiRequestErrors(nginx.NAME, metrics.ENSURE_ROUTE)
+		return fmt.Errorf("failed to write server block: %w", err)
+	}
+	if added {
+		metrics.IncrementManagedEntries(nginx.NAME, metrics.ADDED, 1)
+	}
+	if updated {
+		metrics.IncrementManagedEntries(nginx.NAME, metrics.UPDATED, 1)
+	}
+	return nil
+}
+
+func (n *Nginx) DeleteRoute(ctx context.Context, route Route) error {
+	deleted, err := n.client.DeleteServer(route.Domains[0])
+	if err != nil {
+		metrics.IncrementApiRequestErrors(nginx.NAME, metrics.DELETE_ROUTE)
+		return fmt.Errorf("failed to delete server block: %w", err)
+	}
+	if deleted {
+		metrics.IncrementManagedEntries(nginx.NAME, metrics.DELETED, 1)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+//go:build unit
+
+package providers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deepspace2/plugnpin/pkg/clients/docker"
+	"github.com/deepspace2/plugnpin/pkg/clients/nginx"
+	"github.com/deepspace2/plugnpin/pkg/clients/npm"
+	"github.com/deepspace2/plugnpin/pkg/snippets"
+)
+
+func TestNginxConfig(t *testing.T) {
+	t.Setenv("NGINX_DISABLED", "false")
+	t.Setenv("NGINX_CONF_DIR", "/etc/nginx/conf.d")
+	t.Setenv("NGINX_IP", "192.168.1.5")
+
+	var nginxConfig NginxConfig
+	enabled, err := parseConfig(&nginxConfig)
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+	assert.Equal(t, NginxConfig{CertificatesDir: "/etc/nginx/certs", ConfDir: "/etc/nginx/conf.d", IP: "192.168.1.5"}, nginxConfig)
+
+	t.Setenv("NGINX_CONF_DIR", "")
+	_, err = parseConfig(&NginxConfig{})
+	assert.ErrorContains(t, err, "NGINX_CONF_DIR")
+
+	t.Setenv("NGINX_DISABLED", "true")
+	enabled, err = parseConfig(&NginxConfig{})
+	assert.NoError(t, err)
+	assert.False(t, enabled)
+}
+
+func TestNginxEnsureListAndDeleteRoute(t *testing.T) {
+	snippetsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(snippetsDir, "uploads.conf"), []byte(`    client_max_body_size {{default "1g" .size}};`), 0o644))
+	library, err := snippets.Load(snippetsDir)
+	require.NoError(t, err)
+
+	confDir := t.TempDir()
+	client, err := nginx.NewClient(confDir, "{{ .CertificateName }} {{ .CertificateDir }} {{ .HstsSubdomains }}\n{{ .AdvancedConfig }}\n", "", "")
+	require.NoError(t, err)
+	n := NewNginx(client, "192.168.1.5", "/etc/nginx/certs", library)
+
+	address, err := n.Address(Container{})
+	assert.NoError(t, err)
+	assert.Equal(t, "192.168.1.5", address)
+
+	route := NewRoute(Container{
+		IP:   "10.0.0.1",
+		Port: 8080,
+		URLs: []string{"app.com"},
+		Options: &docker.ClientOptions{Providers: map[string]any{npmOptionsNamespace: &npm.NpmProxyHostOptions{
+			CertificateName: "wildcard",
+			ForwardScheme:   "http",
+			HstsEnabled:     true,
+			HstsSubdomains:  true,
+			Snippets:        []snippets.Reference{{Name: "uploads"}},
+		}}},
+	})
+	assert.NoError(t, n.EnsureRoute(context.Background(), route))
+
+	content, err := os.ReadFile(filepath.Join(confDir, "plugnpin-app.com.conf"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "wildcard /etc/nginx/certs/wildcard true\n    client_max_body_size 1g;\n")
+
+	routes, err := n.ListRoutes()
+	assert.NoError(t, err)
+	assert.Equal(t, []Route{{
+		Domains:       []string{"app.com"},
+		ForwardScheme: "http",
+		ForwardHost:   "10.0.0.1",
+		ForwardPort:   8080,
+		HstsEnabled:   true,
+	}}, routes)
+
+	assert.NoError(t, n.DeleteRoute(context.Background(), route))
+	routes, err = n.ListRoutes()
+	assert.NoError(t, err)
+	assert.Empty(t, routes)
+}
+
+func TestNginxEnsureRouteUnknownSnippet(t *testing.T) {
+	client, err := nginx.NewClient(t.TempDir(), nginx.DefaultTemplate, "", "")
+	require.NoError(t, err)
+	n := NewNginx(client, "192.168.1.5", "/etc/nginx/certs", nil)
+
+	route := NewRoute(Container{
+		URLs:    []string{"app.com"},
+		Options: &docker.ClientOptions{Providers: map[string]any{npmOptionsNamespace: &npm.NpmProxyHostOptions{Snippets: []snippets.Reference{{Name: "uploads"}}}}},
+	})
+	assert.ErrorContains(t, n.EnsureRoute(context.Background(), route), "NGINX_PROXY_MANAGER_SNIPPETS_DIR")
+}
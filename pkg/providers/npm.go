@@ -0,0 +1,546 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/deepspace2/plugnpin/pkg/clients/npm"
+	"github.com/deepspace2/plugnpin/pkg/config"
+	"github.com/deepspace2/plugnpin/pkg/logging"
+	"github.com/deepspace2/plugnpin/pkg/metrics"
+	"github.com/deepspace2/plugnpin/pkg/snippets"
+)
+
+func init() {
+	RegisterProxyProvider(metrics.NPM, func(config *config.Config, options Options) (ProxyProvider, error) {
+		if config.NpmDisabled {
+			return nil, nil
+		}
+
+		clients := map[string]*npm.Client{}
+		for name, npmInstance := range config.AllNpmInstances() {
+			client := npm.NewClient(npmInstance.Host, npmInstance.Username, npmInstance.Password)
+			client.SetCacheTTL(config.NpmCacheTTL)
+			client.SetCertificateRequestOptions(npm.CertificateRequestOptions{
+				DNSChallengeCredentials: config.NpmDNSChallengeCredentials,
+				DNSChallengeProvider:    config.NpmDNSChallengeProvider,
+				LetsencryptEmail:        config.NpmLetsencryptEmail,
+				PropagationSeconds:      config.NpmDNSChallengePropagationSeconds,
+			})
+			if err := client.Login(); err != nil {
+				return nil, fmt.Errorf("failed to login to Nginx Proxy Manager instance '%v': %w", name, err)
+			}
+			clients[name] = client
+		}
+
+		return NewNpm(clients, NpmOptions{
+			CertificateExpiryWarningDays: config.NpmCertificateExpiryWarningDays,
+			DisableOnStop:                config.NpmDisableOnStop,
+			Snippets:                     options.Snippets,
+		}), nil
+	})
+}
+
+type NpmOptions struct {
+	// CertificateExpiryWarningDays is how many days before expiry a warning is
+	// logged for the certificate of a managed proxy host
+	CertificateExpiryWarningDays int
+	// DisableOnStop disables proxy hosts instead of deleting them when a
+	// container stops, unless overridden per container
+	DisableOnStop bool
+	// Snippets are the nginx snippets containers can reference, nil if no
+	// snippet directory is configured
+	Snippets *snippets.Library
+}
+
+// Npm manages proxy hosts, redirection hosts, streams and access lists in
+// one or more Nginx Proxy Manager instances.
+type Npm struct {
+	// clients are keyed by instance name, see config.DEFAULT_NPM_INSTANCE
+	clients map[string]*npm.Client
+	options NpmOptions
+}
+
+func NewNpm(clients map[string]*npm.Client, options NpmOptions) *Npm {
+	return &Npm{
+		clients: clients,
+		options: options,
+	}
+}
+
+func (n *Npm) Name() string {
+	return metrics.NPM
+}
+
+func (n *Npm) Capabilities() ProxyCapabilities {
+	return ProxyCapabilities{Redirects: true, Streams: true}
+}
+
+// Address returns the address of the instance that serves the container.
+func (n *Npm) Address(container Container) (string, error) {
+	client, err := n.clientFor(container.npmOptions())
+	if err != nil {
+		return "", err
+	}
+	return client.GetIP(), nil
+}
+
+func (n *Npm) ListRoutes() ([]Route, error) {
+	routes := []Route{}
+	for _, instance := range slices.Sorted(maps.Keys(n.clients)) {
+		proxyHosts, err := n.clients[instance].GetProxyHostReplies()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list proxy hosts of instance '%v': %w", instance, err)
+		}
+		for _, proxyHost := range proxyHosts {
+			routes = append(routes, Route{
+				Domains:       proxyHost.DomainNames,
+				ForwardScheme: proxyHost.ForwardScheme,
+				ForwardHost:   proxyHost.ForwardHost,
+				ForwardPort:   proxyHost.ForwardPort,
+				HstsEnabled:   proxyHost.HstsEnabled,
+				SslForced:     proxyHost.SslForced,
+				Websockets:    proxyHost.AllowWebsocketUpgrade,
+			})
+		}
+	}
+	return routes, nil
+}
+
+// EnsureRoute adds or updates the proxy host of the route, as well as the
+// redirection host and stream of its container.
+func (n *Npm) EnsureRoute(ctx context.Context, route Route) error {
+	client, err := n.routeClient(route)
+	if err != nil {
+		return err
+	}
+
+	redirectionOptions, streamOptions := npmRedirectionAndStreamOptions(*route.Container)
+	var errs []error
+	if err := n.ensureProxyHost(ctx, client, route, route.Container.npmOptions()); err != nil {
+		errs = append(errs, err)
+	}
+	if redirectionOptions != nil {
+		if err := ensureRedirectionHost(ctx, client, route.Domains[0], *redirectionOptions); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if streamOptions != nil {
+		if err := ensureStream(ctx, client, route, *streamOptions); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DeleteRoute deletes the proxy host of the route, as well as the access list,
+// redirection host and stream of its container.
+func (n *Npm) DeleteRoute(ctx context.Context, route Route) error {
+	client, err := n.routeClient(route)
+	if err != nil {
+		return err
+	}
+
+	redirectionOptions, streamOptions := npmRedirectionAndStreamOptions(*route.Container)
+	var errs []error
+	if err := deleteProxyHost(ctx, client, route.Domains, route.Container.npmOptions()); err != nil {
+		errs = append(errs, err)
+	}
+	errs = append(errs, deleteRedirectionHostAndStream(ctx, client, redirectionOptions, streamOptions))
+	return errors.Join(errs...)
+}
+
+func (n *Npm) DisableOnStop(route Route) bool {
+	if route.Container != nil {
+		if disableOnStop := route.Container.npmOptions().DisableOnStop; disableOnStop != nil {
+			return *disableOnStop
+		}
+	}
+	return n.options.DisableOnStop
+}
+
+// DisableRoute disables the proxy host, redirection host and stream of the
+// route, leaving alone the ones PlugNPiN didn't create.
+func (n *Npm) DisableRoute(ctx context.Context, route Route) error {
+	client, err := n.routeClient(route)
+	if err != nil {
+		return err
+	}
+
+	redirectionOptions, streamOptions := npmRedirectionAndStreamOptions(*route.Container)
+	var errs []error
+	numOfDisabledProxyHosts, err := client.DisableProxyHosts(route.Domains)
+	for range numOfDisabledProxyHosts {
+		metrics.IncrementNpmEntriesUpdated()
+	}
+	if err != nil {
+		metrics.IncrementNpmApiRequestErrors(metrics.DISABLE_PROXY_HOST)
+		errs = append(errs, fmt.Errorf("failed to disable proxy host: %w", err))
+	}
+	errs = append(errs, disableRedirectionHostAndStream(ctx, client, redirectionOptions, streamOptions))
+	return errors.Join(errs...)
+}
+
+// BeginSync makes all containers of a sync share the same lookups.
+func (n *Npm) BeginSync() {
+	for _, client := range n.clients {
+		client.BeginSnapshot()
+	}
+}
+
+func (n *Npm) EndSync() {
+	for _, client := range n.clients {
+		client.EndSnapshot()
+	}
+}
+
+// ReportHealth exports the certificate expiry and nginx state of the proxy
+// hosts of domains, and warns about expiring certificates and nginx errors.
+func (n *Npm) ReportHealth(domains []string) {
+	metrics.ResetNpmProxyHostHealth()
+	for _, instance := range slices.Sorted(maps.Keys(n.clients)) {
+		n.reportInstanceHealth(instance, domains)
+	}
+}
+
+func (n *Npm) reportInstanceHealth(instance string, domains []string) {
+	healths, err := n.clients[instance].GetProxyHostsHealth(domains)
+	if err != nil {
+		log.Error("Failed to get the health of Nginx Proxy Manager proxy hosts", "instance", instance, "error", err)
+		metrics.IncrementNpmApiRequestErrors(metrics.GET_PROXY_HOST_HEALTH)
+		return
+	}
+
+	for _, health := range healths {
+		proxyHost := health.DomainNames[0]
+		log := log.With("instance", instance, "proxyHost", proxyHost, "id", health.ID)
+
+		metrics.SetNpmNginxOnline(instance, proxyHost, health.NginxOnline)
+		if !health.NginxOnline || health.NginxErr != "" {
+			log.Warn("Nginx Proxy Manager reports an nginx error for proxy host", "nginxOnline", health.NginxOnline, "nginxErr", health.NginxErr)
+		}
+
+		if health.CertificateExpiresOn.IsZero() {
+			continue
+		}
+		daysToExpiry := time.Until(health.CertificateExpiresOn).Hours() / 24
+		metrics.SetNpmCertificateDaysToExpiry(instance, proxyHost, daysToExpiry)
+		if daysToExpiry < float64(n.options.CertificateExpiryWarningDays) {
+			log.Warn("Certificate of proxy host is about to expire", "expiresOn", health.CertificateExpiresOn.Format(time.RFC3339), "daysToExpiry", int(daysToExpiry))
+		}
+	}
+}
+
+func (n *Npm) routeClient(route Route) (*npm.Client, error) {
+	if route.Container == nil {
+		return nil, fmt.Errorf("route of %v has no container", route.Domains)
+	}
+	return n.clientFor(route.Container.npmOptions())
+}
+
+// clientFor returns the client of the instance chosen by the container, or of
+// the default instance.
+func (n *Npm) clientFor(npmProxyHostOptions npm.NpmProxyHostOptions) (*npm.Client, error) {
+	instance := npmProxyHostOptions.Instance
+	if instance == "" {
+		instance = config.DEFAULT_NPM_INSTANCE
+	}
+
+	client, exists := n.clients[instance]
+	if !exists {
+		return nil, fmt.Errorf("unknown Nginx Proxy Manager instance '%v', it has to be listed in NGINX_PROXY_MANAGER_INSTANCES", instance)
+	}
+	return client, nil
+}
+
+func (n *Npm) ensureProxyHost(ctx context.Context, client *npm.Client, route Route, npmProxyHostOptions npm.NpmProxyHostOptions) error {
+	log := logging.FromContext(ctx)
+
+	advancedConfig, err := renderAdvancedConfig(n.options.Snippets, npmProxyHostOptions)
+	if err != nil {
+		return fmt.Errorf("not creating proxy host, failed to render snippets: %w", err)
+	}
+
+	npmProxyHost := npm.ProxyHost{
+		AdvancedConfig:        advancedConfig,
+		AllowWebsocketUpgrade: route.Websockets,
+		BlockExploits:         npmProxyHostOptions.BlockExploits,
+		CachingEnabled:        npmProxyHostOptions.CachingEnabled,
+		ForwardScheme:         route.ForwardScheme,
+		HTTP2Support:          npmProxyHostOptions.HTTP2Support,
+		HstsEnabled:           route.HstsEnabled,
+		HstsSubdomains:        npmProxyHostOptions.HstsSubdomains,
+		SslForced:             route.SslForced,
+
+		DomainNames: route.Domains,
+		ForwardHost: route.ForwardHost,
+		ForwardPort: route.ForwardPort,
+		Locations:   routeLocations(route, npmProxyHostOptions.Locations),
+		Meta:        npm.Meta{},
+	}
+
+	if npmProxyHostOptions.AccessList != nil {
+		npmAccessListID, err := ensureAccessList(client, route.Domains, *npmProxyHostOptions.AccessList)
+		if err != nil {
+			metrics.IncrementNpmApiRequestErrors(metrics.ENSURE_ACCESS_LIST)
+			return fmt.Errorf("not creating proxy host, failed to create access list: %w", err)
+		}
+		npmProxyHost.AccessListID = npmAccessListID
+	} else if npmProxyHostOptions.AccessListName != "" {
+		npmAccessListID, err := client.GetAccessListIDByName(npmProxyHostOptions.AccessListName)
+		if err != nil {
+			metrics.IncrementNpmApiRequestErrors(metrics.GET_ACCESS_LIST_ID)
+			return fmt.Errorf("not creating proxy host: %w", err)
+		}
+		npmProxyHost.AccessListID = npmAccessListID
+	}
+
+	if npmProxyHostOptions.CertificateName == npm.CERTIFICATE_NAME_AUTO {
+		npmCertificateID, err := client.GetOrRequestCertificate(route.Domains)
+		if err != nil {
+			// The certificate is attached on a later sync once it is issued
+			log.Error("Failed to get a certificate, creating Nginx Proxy Manager entry without one", "error", err)
+			metrics.IncrementNpmApiRequestErrors(metrics.REQUEST_CERTIFICATE)
+		}
+		npmProxyHost.CertificateID = npmCertificateID
+	} else if npmProxyHostOptions.CertificateName != "" {
+		npmCertificateID, err := client.GetCertificateIDByName(npmProxyHostOptions.CertificateName)
+		if err != nil {
+			metrics.IncrementNpmApiRequestErrors(metrics.GET_CERTIFICATE_ID)
+			return fmt.Errorf("not creating proxy host: %w", err)
+		}
+		npmProxyHost.CertificateID = npmCertificateID
+	}
+
+	addedNpmEntry, updatedNpmEntry, err := client.AddProxyHost(npmProxyHost)
+	if err != nil {
+		metrics.IncrementNpmApiRequestErrors(metrics.ADD_PROXY_HOST)
+		return fmt.Errorf("failed to add proxy host: %w", err)
+	}
+	if addedNpmEntry {
+		metrics.IncrementNpmEntriesCreated()
+	}
+	if updatedNpmEntry {
+		metrics.IncrementNpmEntriesUpdated()
+	}
+	return nil
+}
+
+// routeLocations returns the custom locations of the route's proxy host, with
+// the forward host, port and scheme of the route where the location's labels
+// don't set them.
+func routeLocations(route Route, locations []npm.Location) []npm.Location {
+	if locations == nil {
+		return nil
+	}
+	routeLocations := make([]npm.Location, 0, len(locations))
+	for _, location := range locations {
+		if location.ForwardHost == "" {
+			location.ForwardHost = route.ForwardHost
+		}
+		if location.ForwardPort == 0 {
+			location.ForwardPort = route.ForwardPort
+		}
+		if location.ForwardScheme == "" {
+			location.ForwardScheme = route.ForwardScheme
+		}
+		routeLocations = append(routeLocations, location)
+	}
+	return routeLocations
+}
+
+func deleteProxyHost(ctx context.Context, client *npm.Client, domains []string, npmProxyHostOptions npm.NpmProxyHostOptions) error {
+	numOfDeletedProxyHosts, numOfUpdatedProxyHosts, err := client.DeleteProxyHosts(domains)
+	for range numOfDeletedProxyHosts {
+		metrics.IncrementNpmEntriesDeleted()
+	}
+	for range numOfUpdatedProxyHosts {
+		metrics.IncrementNpmEntriesUpdated()
+	}
+	if err != nil {
+		metrics.IncrementNpmApiRequestErrors(metrics.DELETE_PROXY_HOST)
+		return fmt.Errorf("failed to delete proxy host: %w", err)
+	}
+
+	if npmProxyHostOptions.AccessList == nil {
+		return nil
+	}
+	accessListName := accessListName(domains, *npmProxyHostOptions.AccessList)
+	deletedNpmAccessList, err := client.DeleteAccessListIfUnused(accessListName)
+	if err != nil {
+		metrics.IncrementNpmApiRequestErrors(metrics.DELETE_ACCESS_LIST)
+		return fmt.Errorf("failed to delete access list '%v': %w", accessListName, err)
+	}
+	if deletedNpmAccessList {
+		logging.FromContext(ctx).Info("Deleted unused access list from Nginx Proxy Manager", "name", accessListName)
+		metrics.IncrementNpmEntriesDeleted()
+	}
+	return nil
+}
+
+// accessListName returns the name of the shared access list, or otherwise the
+// name of the access list owned by the container.
+func accessListName(domains []string, accessListOptions npm.AccessListOptions) string {
+	if accessListOptions.Name != "" {
+		return accessListOptions.Name
+	}
+	return npm.ACCESS_LIST_NAME_PREFIX + domains[0]
+}
+
+func ensureAccessList(client *npm.Client, domains []string, accessListOptions npm.AccessListOptions) (int, error) {
+	accessList := npm.AccessList{
+		Clients:    []npm.AccessListClient{},
+		Items:      []npm.AccessListItem{},
+		Name:       accessListName(domains, accessListOptions),
+		SatisfyAny: accessListOptions.SatisfyAny,
+	}
+	for _, address := range accessListOptions.Allow {
+		accessList.Clients = append(accessList.Clients, npm.AccessListClient{Address: address, Directive: "allow"})
+	}
+	for _, address := range accessListOptions.Deny {
+		accessList.Clients = append(accessList.Clients, npm.AccessListClient{Address: address, Directive: "deny"})
+	}
+	for _, user := range accessListOptions.Users {
+		password, err := config.ReadSecret(user.PasswordSecret)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read password of access list user '%v': %w", user.Username, err)
+		}
+		accessList.Items = append(accessList.Items, npm.AccessListItem{Password: password, Username: user.Username})
+	}
+
+	id, created, updated, err := client.EnsureAccessList(accessList)
+	if err != nil {
+		return 0, err
+	}
+	if created {
+		metrics.IncrementNpmEntriesCreated()
+	}
+	if updated {
+		metrics.IncrementNpmEntriesUpdated()
+	}
+	return id, nil
+}
+
+func ensureRedirectionHost(ctx context.Context, client *npm.Client, forwardDomainName string, npmRedirectionOptions npm.NpmRedirectionOptions) error {
+	npmRedirectionHost := npm.RedirectionHost{
+		BlockExploits:     true,
+		DomainNames:       npmRedirectionOptions.Domains,
+		ForwardDomainName: forwardDomainName,
+		ForwardHTTPCode:   npmRedirectionOptions.ForwardHTTPCode,
+		ForwardScheme:     npmRedirectionOptions.ForwardScheme,
+		Meta:              npm.Meta{},
+		PreservePath:      npmRedirectionOptions.PreservePath,
+	}
+
+	logging.FromContext(ctx).Info("Adding redirection host to Nginx Proxy Manager", "redirects", npmRedirectionOptions.Domains, "forwardDomain", forwardDomainName, "code", npmRedirectionHost.ForwardHTTPCode)
+
+	addedNpmRedirectionHost, updatedNpmRedirectionHost, err := client.AddRedirectionHost(npmRedirectionHost)
+	if err != nil {
+		metrics.IncrementNpmApiRequestErrors(metrics.ADD_REDIRECTION_HOST)
+		return fmt.Errorf("failed to add redirection host for %v: %w", npmRedirectionOptions.Domains, err)
+	}
+	if addedNpmRedirectionHost {
+		metrics.IncrementNpmEntriesCreated()
+	}
+	if updatedNpmRedirectionHost {
+		metrics.IncrementNpmEntriesUpdated()
+	}
+	return nil
+}
+
+func ensureStream(ctx context.Context, client *npm.Client, route Route, npmStreamOptions npm.NpmStreamOptions) error {
+	npmStream := npm.Stream{
+		ForwardingHost: route.ForwardHost,
+		ForwardingPort: route.ForwardPort,
+		IncomingPort:   npmStreamOptions.IncomingPort,
+		Meta:           npm.Meta{},
+		TCPForwarding:  npmStreamOptions.TCPForwarding,
+		UDPForwarding:  npmStreamOptions.UDPForwarding,
+	}
+
+	logging.FromContext(ctx).Info("Adding stream to Nginx Proxy Manager", "incomingPort", npmStreamOptions.IncomingPort, "tcp", npmStream.TCPForwarding, "udp", npmStream.UDPForwarding)
+
+	addedNpmStream, updatedNpmStream, err := client.AddStream(npmStream)
+	if err != nil {
+		metrics.IncrementNpmApiRequestErrors(metrics.ADD_STREAM)
+		return fmt.Errorf("failed to add stream on incoming port %v: %w", npmStreamOptions.IncomingPort, err)
+	}
+	if addedNpmStream {
+		metrics.IncrementNpmEntriesCreated()
+	}
+	if updatedNpmStream {
+		metrics.IncrementNpmEntriesUpdated()
+	}
+	return nil
+}
+
+// deleteRedirectionHostAndStream deletes the redirection host and stream of a
+// container, whichever it has.
+func deleteRedirectionHostAndStream(ctx context.Context, client *npm.Client, npmRedirectionOptions *npm.NpmRedirectionOptions, npmStreamOptions *npm.NpmStreamOptions) error {
+	log := logging.FromContext(ctx)
+	var errs []error
+
+	if npmRedirectionOptions != nil {
+		log.Info("Deleting redirection host from Nginx Proxy Manager", "redirects", npmRedirectionOptions.Domains)
+		numOfDeletedRedirectionHosts, numOfUpdatedRedirectionHosts, err := client.DeleteRedirectionHosts(npmRedirectionOptions.Domains)
+		for range numOfDeletedRedirectionHosts {
+			metrics.IncrementNpmEntriesDeleted()
+		}
+		for range numOfUpdatedRedirectionHosts {
+			metrics.IncrementNpmEntriesUpdated()
+		}
+		if err != nil {
+			metrics.IncrementNpmApiRequestErrors(metrics.DELETE_REDIRECTION_HOST)
+			errs = append(errs, fmt.Errorf("failed to delete redirection host for %v: %w", npmRedirectionOptions.Domains, err))
+		}
+	}
+
+	if npmStreamOptions != nil {
+		log.Info("Deleting stream from Nginx Proxy Manager", "incomingPort", npmStreamOptions.IncomingPort)
+		deletedNpmStream, err := client.DeleteStream(npmStreamOptions.IncomingPort)
+		if err != nil {
+			metrics.IncrementNpmApiRequestErrors(metrics.DELETE_STREAM)
+			errs = append(errs, fmt.Errorf("failed to delete stream on incoming port %v: %w", npmStreamOptions.IncomingPort, err))
+		} else if deletedNpmStream {
+			metrics.IncrementNpmEntriesDeleted()
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// disableRedirectionHostAndStream disables the redirection host and stream of a
+// container, whichever it has.
+func disableRedirectionHostAndStream(ctx context.Context, client *npm.Client, npmRedirectionOptions *npm.NpmRedirectionOptions, npmStreamOptions *npm.NpmStreamOptions) error {
+	log := logging.FromContext(ctx)
+	var errs []error
+
+	if npmRedirectionOptions != nil {
+		log.Info("Disabling redirection host in Nginx Proxy Manager", "redirects", npmRedirectionOptions.Domains)
+		numOfDisabledRedirectionHosts, err := client.DisableRedirectionHosts(npmRedirectionOptions.Domains)
+		for range numOfDisabledRedirectionHosts {
+			metrics.IncrementNpmEntriesUpdated()
+		}
+		if err != nil {
+			metrics.IncrementNpmApiRequestErrors(metrics.DISABLE_REDIRECTION_HOST)
+			errs = append(errs, fmt.Errorf("failed to disable redirection host for %v: %w", npmRedirectionOptions.Domains, err))
+		}
+	}
+
+	if npmStreamOptions != nil {
+		log.Info("Disabling stream in Nginx Proxy Manager", "incomingPort", npmStreamOptions.IncomingPort)
+		disabledNpmStream, err := client.DisableStream(npmStreamOptions.IncomingPort)
+		if err != nil {
+			metrics.IncrementNpmApiRequestErrors(metrics.DISABLE_STREAM)
+			errs = append(errs, fmt.Errorf("failed to disable stream on incoming port %v: %w", npmStreamOptions.IncomingPort, err))
+		} else if disabledNpmStream {
+			metrics.IncrementNpmEntriesUpdated()
+		}
+	}
+
+	return errors.Join(errs...)
+}
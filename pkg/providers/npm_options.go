@@ -0,0 +1,404 @@
+package providers
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/deepspace2/plugnpin/pkg/clients/docker"
+	"github.com/deepspace2/plugnpin/pkg/clients/npm"
+	"github.com/deepspace2/plugnpin/pkg/errors"
+	"github.com/deepspace2/plugnpin/pkg/snippets"
+)
+
+const (
+	npmOptionsNamespace = "npmOptions"
+	redirectsNamespace  = "redirects"
+	streamNamespace     = "stream"
+
+	npmOptionsAccessListAllowLabel      = "plugNPiN.npmOptions.accessList.allow"
+	npmOptionsAccessListDenyLabel       = "plugNPiN.npmOptions.accessList.deny"
+	npmOptionsAccessListNameLabel       = "plugNPiN.npmOptions.accessListName"
+	npmOptionsAccessListSatisfyAnyLabel = "plugNPiN.npmOptions.accessList.satisfyAny"
+	npmOptionsAccessListSharedNameLabel = "plugNPiN.npmOptions.accessList.name"
+	npmOptionsAccessListUsersLabel      = "plugNPiN.npmOptions.accessList.users"
+	npmOptionsAdvancedConfigLabel       = "plugNPiN.npmOptions.advancedConfig"
+	npmOptionsBlockExploitsLabel        = "plugNPiN.npmOptions.blockExploits"
+	npmOptionsCachingEnabledLabel       = "plugNPiN.npmOptions.cachingEnabled"
+	npmOptionsCertificateNameLabel      = "plugNPiN.npmOptions.certificateName"
+	npmOptionsDisableOnStopLabel        = "plugNPiN.npmOptions.disableOnStop"
+	npmOptionsHTTP2SupportLabel         = "plugNPiN.npmOptions.http2Support"
+	npmOptionsHstsEnabledLabel          = "plugNPiN.npmOptions.hstsEnabled"
+	npmOptionsHstsSubdomainsLabel       = "plugNPiN.npmOptions.hstsSubdomains"
+	npmOptionsInstanceLabel             = "plugNPiN.npmOptions.instance"
+	npmOptionsLocationsLabelPrefix      = "plugNPiN.npmOptions.locations."
+	npmOptionsSchemeLabel               = "plugNPiN.npmOptions.scheme"
+	npmOptionsSnippetsLabel             = "plugNPiN.npmOptions.snippets"
+	npmOptionsSslForcedLabel            = "plugNPiN.npmOptions.forceSsl"
+	npmOptionsWebsocketsSupportLabel    = "plugNPiN.npmOptions.websocketsSupport"
+	redirectOptionsCodeLabel            = "plugNPiN.redirectOptions.code"
+	redirectOptionsPreservePathLabel    = "plugNPiN.redirectOptions.preservePath"
+	redirectOptionsSchemeLabel          = "plugNPiN.redirectOptions.scheme"
+	redirectsLabel                      = "plugNPiN.redirects"
+	streamIncomingPortLabel             = "plugNPiN.stream.incomingPort"
+	streamTCPLabel                      = "plugNPiN.stream.tcp"
+	streamUDPLabel                      = "plugNPiN.stream.udp"
+)
+
+func init() {
+	docker.RegisterOptionsParser(npmOptionsNamespace, func(labels map[string]string) (any, error) {
+		return parseNpmOptionsLabels(labels)
+	})
+	docker.RegisterOptionsParser(redirectsNamespace, func(labels map[string]string) (any, error) {
+		return parseRedirectLabels(labels)
+	})
+	docker.RegisterOptionsParser(streamNamespace, func(labels map[string]string) (any, error) {
+		return parseStreamLabels(labels)
+	})
+}
+
+// npmOptions returns the options of the container's 'plugNPiN.npmOptions.*'
+// labels, which all proxy providers read.
+func (c Container) npmOptions() npm.NpmProxyHostOptions {
+	return containerOptions[npm.NpmProxyHostOptions](c, npmOptionsNamespace)
+}
+
+// Redirects returns the domains the container redirects to its main domain,
+// see 'plugNPiN.redirects'.
+func (c Container) Redirects() []string {
+	if redirectionOptions, _ := npmRedirectionAndStreamOptions(c); redirectionOptions != nil {
+		return redirectionOptions.Domains
+	}
+	return nil
+}
+
+// StreamPort returns the incoming port of the container's TCP/UDP stream, see
+// 'plugNPiN.stream.*', or 0 if it has none.
+func (c Container) StreamPort() int {
+	if _, streamOptions := npmRedirectionAndStreamOptions(c); streamOptions != nil {
+		return streamOptions.IncomingPort
+	}
+	return 0
+}
+
+// npmRedirectionAndStreamOptions returns the options of the container's
+// 'plugNPiN.redirects' and 'plugNPiN.stream.*' labels, each nil if the
+// container has none.
+func npmRedirectionAndStreamOptions(container Container) (*npm.NpmRedirectionOptions, *npm.NpmStreamOptions) {
+	return optionalContainerOptions[npm.NpmRedirectionOptions](container, redirectsNamespace),
+		optionalContainerOptions[npm.NpmStreamOptions](container, streamNamespace)
+}
+
+// Snippets returns the snippets the container references.
+func (c Container) Snippets() []snippets.Reference {
+	return c.npmOptions().Snippets
+}
+
+func parseNpmOptionsLabels(labels map[string]string) (*npm.NpmProxyHostOptions, error) {
+	npmOptionsBlockExploitsLabelValue, exists := labels[npmOptionsBlockExploitsLabel]
+	if !exists {
+		npmOptionsBlockExploitsLabelValue = "true"
+	}
+
+	npmOptionsBlockExploits, _ := strconv.ParseBool(npmOptionsBlockExploitsLabelValue)
+	npmOptionsWebsocketsSupport, _ := strconv.ParseBool(labels[npmOptionsWebsocketsSupportLabel])
+	npmOptionsCachingEnabled, _ := strconv.ParseBool(labels[npmOptionsCachingEnabledLabel])
+
+	npmOptionsScheme, exists := labels[npmOptionsSchemeLabel]
+	if !exists {
+		npmOptionsScheme = "http"
+	}
+	npmOptionsScheme = strings.ToLower(npmOptionsScheme)
+	if !slices.Contains([]string{"http", "https"}, npmOptionsScheme) {
+		return nil, &errors.InvalidSchemeError{
+			Msg: fmt.Sprintf("value of '%v' label must be one of 'http', 'https', got '%v'", npmOptionsSchemeLabel, npmOptionsScheme),
+		}
+	}
+
+	npmOptionsAdvancedConfig := labels[npmOptionsAdvancedConfigLabel]
+	npmOptionsCertificateName := labels[npmOptionsCertificateNameLabel]
+	npmOptionsAccessListName := labels[npmOptionsAccessListNameLabel]
+	npmOptionsHTTP2Support, _ := strconv.ParseBool(labels[npmOptionsHTTP2SupportLabel])
+	npmOptionsHstsEnabled, _ := strconv.ParseBool(labels[npmOptionsHstsEnabledLabel])
+	npmOptionsHstsSubdomains, _ := strconv.ParseBool(labels[npmOptionsHstsSubdomainsLabel])
+	npmOptionsSslForced, _ := strconv.ParseBool(labels[npmOptionsSslForcedLabel])
+	npmOptionsInstance := strings.ToLower(strings.TrimSpace(labels[npmOptionsInstanceLabel]))
+
+	npmOptionsDisableOnStop, err := docker.ParseOptionalBoolLabel(labels, npmOptionsDisableOnStopLabel)
+	if err != nil {
+		return nil, err
+	}
+
+	npmOptionsLocations, err := parseNpmLocationsLabels(labels)
+	if err != nil {
+		return nil, err
+	}
+
+	npmOptionsSnippets, err := snippets.ParseReferences(labels[npmOptionsSnippetsLabel])
+	if err != nil {
+		return nil, &errors.InvalidLabelValueError{
+			Msg: fmt.Sprintf("value of '%v' label is invalid: %v", npmOptionsSnippetsLabel, err),
+		}
+	}
+
+	npmOptionsAccessList, err := parseNpmAccessListLabels(labels)
+	if err != nil {
+		return nil, err
+	}
+	if npmOptionsAccessList != nil && npmOptionsAccessListName != "" {
+		return nil, &errors.InvalidLabelValueError{
+			Msg: fmt.Sprintf("'%v' label can't be combined with inline access list labels, use '%v' instead", npmOptionsAccessListNameLabel, npmOptionsAccessListSharedNameLabel),
+		}
+	}
+
+	return &npm.NpmProxyHostOptions{
+		AccessList:            npmOptionsAccessList,
+		AccessListName:        npmOptionsAccessListName,
+		AdvancedConfig:        npmOptionsAdvancedConfig,
+		AllowWebsocketUpgrade: npmOptionsWebsocketsSupport,
+		BlockExploits:         npmOptionsBlockExploits,
+		CachingEnabled:        npmOptionsCachingEnabled,
+		CertificateName:       npmOptionsCertificateName,
+		DisableOnStop:         npmOptionsDisableOnStop,
+		ForwardScheme:         npmOptionsScheme,
+		HTTP2Support:          npmOptionsHTTP2Support,
+		HstsEnabled:           npmOptionsHstsEnabled,
+		HstsSubdomains:        npmOptionsHstsSubdomains,
+		Instance:              npmOptionsInstance,
+		Locations:             npmOptionsLocations,
+		Snippets:              npmOptionsSnippets,
+		SslForced:             npmOptionsSslForced,
+	}, nil
+}
+
+// parseNpmLocationsLabels parses labels of the form
+// "plugNPiN.npmOptions.locations.<name>.<field>" into custom locations, sorted
+// by path, or returns nil if there are none. Only the path is required, the
+// forward host, port and scheme are left empty to default to those of the
+// proxy host itself.
+func parseNpmLocationsLabels(labels map[string]string) ([]npm.Location, error) {
+	locationsByName := map[string]*npm.Location{}
+
+	for label, value := range labels {
+		nameAndField, found := strings.CutPrefix(label, npmOptionsLocationsLabelPrefix)
+		if !found {
+			continue
+		}
+
+		name, field, found := strings.Cut(nameAndField, ".")
+		if !found || name == "" {
+			return nil, &errors.InvalidLabelValueError{
+				Msg: fmt.Sprintf("label '%v' must be of the form '%v<name>.<field>'", label, npmOptionsLocationsLabelPrefix),
+			}
+		}
+
+		location, exists := locationsByName[name]
+		if !exists {
+			location = &npm.Location{}
+			locationsByName[name] = location
+		}
+
+		switch field {
+		case "path":
+			location.Path = value
+		case "forwardHost":
+			location.ForwardHost = value
+		case "forwardPort":
+			forwardPort, err := strconv.Atoi(value)
+			if err != nil || forwardPort < 1 || forwardPort > 65535 {
+				return nil, &errors.InvalidLabelValueError{
+					Msg: fmt.Sprintf("value of '%v' label must be a valid port, got '%v'", label, value),
+				}
+			}
+			location.ForwardPort = forwardPort
+		case "scheme":
+			forwardScheme := strings.ToLower(value)
+			if !slices.Contains([]string{"http", "https"}, forwardScheme) {
+				return nil, &errors.InvalidSchemeError{
+					Msg: fmt.Sprintf("value of '%v' label must be one of 'http', 'https', got '%v'", label, value),
+				}
+			}
+			location.ForwardScheme = forwardScheme
+		case "advancedConfig":
+			location.AdvancedConfig = value
+		default:
+			return nil, &errors.InvalidLabelValueError{
+				Msg: fmt.Sprintf("label '%v' has unknown field '%v', must be one of 'path', 'forwardHost', 'forwardPort', 'scheme', 'advancedConfig'", label, field),
+			}
+		}
+	}
+
+	var locations []npm.Location
+	for name, location := range locationsByName {
+		if !strings.HasPrefix(location.Path, "/") {
+			return nil, &errors.InvalidLabelValueError{
+				Msg: fmt.Sprintf("value of '%v%v.path' label must start with '/', got '%v'", npmOptionsLocationsLabelPrefix, name, location.Path),
+			}
+		}
+		locations = append(locations, *location)
+	}
+
+	slices.SortFunc(locations, npm.CompareLocations)
+
+	return locations, nil
+}
+
+// parseStreamLabels returns nil if the container doesn't ask for an NPM stream.
+// TCP forwarding is enabled by default, UDP forwarding is not.
+func parseStreamLabels(labels map[string]string) (*npm.NpmStreamOptions, error) {
+	incomingPortLabelValue, exists := labels[streamIncomingPortLabel]
+	if !exists {
+		return nil, nil
+	}
+
+	incomingPort, err := strconv.Atoi(incomingPortLabelValue)
+	if err != nil || incomingPort < 1 || incomingPort > 65535 {
+		return nil, &errors.InvalidLabelValueError{
+			Msg: fmt.Sprintf("value of '%v' label must be a valid port, got '%v'", streamIncomingPortLabel, incomingPortLabelValue),
+		}
+	}
+
+	tcpForwarding, err := docker.ParseBoolLabel(labels, streamTCPLabel, true)
+	if err != nil {
+		return nil, err
+	}
+	udpForwarding, err := docker.ParseBoolLabel(labels, streamUDPLabel, false)
+	if err != nil {
+		return nil, err
+	}
+
+	streamOptions := &npm.NpmStreamOptions{
+		IncomingPort:  incomingPort,
+		TCPForwarding: tcpForwarding,
+		UDPForwarding: udpForwarding,
+	}
+
+	if !streamOptions.TCPForwarding && !streamOptions.UDPForwarding {
+		return nil, &errors.InvalidLabelValueError{
+			Msg: fmt.Sprintf("at least one of '%v' and '%v' labels must be 'true'", streamTCPLabel, streamUDPLabel),
+		}
+	}
+
+	return streamOptions, nil
+}
+
+// parseRedirectLabels returns nil if the container doesn't have any domains to
+// redirect. By default, redirects are permanent (301), keep the scheme of the
+// request ("auto") and preserve the path.
+func parseRedirectLabels(labels map[string]string) (*npm.NpmRedirectionOptions, error) {
+	redirectsLabelValue, exists := labels[redirectsLabel]
+	if !exists {
+		return nil, nil
+	}
+
+	domains := []string{}
+	for domain := range strings.SplitSeq(redirectsLabelValue, ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	if len(domains) == 0 {
+		return nil, &errors.InvalidLabelValueError{
+			Msg: fmt.Sprintf("value of '%v' label must be a comma-separated list of domains, got '%v'", redirectsLabel, redirectsLabelValue),
+		}
+	}
+
+	forwardHTTPCode := 301
+	if codeLabelValue, exists := labels[redirectOptionsCodeLabel]; exists {
+		code, err := strconv.Atoi(codeLabelValue)
+		if err != nil || !slices.Contains([]int{300, 301, 302, 303, 307, 308}, code) {
+			return nil, &errors.InvalidLabelValueError{
+				Msg: fmt.Sprintf("value of '%v' label must be one of 300, 301, 302, 303, 307, 308, got '%v'", redirectOptionsCodeLabel, codeLabelValue),
+			}
+		}
+		forwardHTTPCode = code
+	}
+
+	forwardScheme, exists := labels[redirectOptionsSchemeLabel]
+	if !exists {
+		forwardScheme = "auto"
+	}
+	forwardScheme = strings.ToLower(forwardScheme)
+	if !slices.Contains([]string{"auto", "http", "https"}, forwardScheme) {
+		return nil, &errors.InvalidSchemeError{
+			Msg: fmt.Sprintf("value of '%v' label must be one of 'auto', 'http', 'https', got '%v'", redirectOptionsSchemeLabel, forwardScheme),
+		}
+	}
+
+	preservePath, err := docker.ParseBoolLabel(labels, redirectOptionsPreservePathLabel, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &npm.NpmRedirectionOptions{
+		Domains:         domains,
+		ForwardHTTPCode: forwardHTTPCode,
+		ForwardScheme:   forwardScheme,
+		PreservePath:    preservePath,
+	}, nil
+}
+
+// parseNpmAccessListLabels returns nil if the container doesn't declare an
+// inline access list. Users are given as "<username>:<secret name>", where the
+// Docker secret holds the user's password.
+func parseNpmAccessListLabels(labels map[string]string) (*npm.AccessListOptions, error) {
+	accessListLabels := []string{
+		npmOptionsAccessListAllowLabel,
+		npmOptionsAccessListDenyLabel,
+		npmOptionsAccessListSatisfyAnyLabel,
+		npmOptionsAccessListSharedNameLabel,
+		npmOptionsAccessListUsersLabel,
+	}
+	if !slices.ContainsFunc(accessListLabels, func(label string) bool { _, exists := labels[label]; return exists }) {
+		return nil, nil
+	}
+
+	accessList := &npm.AccessListOptions{Name: labels[npmOptionsAccessListSharedNameLabel]}
+
+	addressLabels := []struct {
+		label     string
+		addresses *[]string
+	}{
+		{npmOptionsAccessListAllowLabel, &accessList.Allow},
+		{npmOptionsAccessListDenyLabel, &accessList.Deny},
+	}
+	for _, addressLabel := range addressLabels {
+		label, addresses := addressLabel.label, addressLabel.addresses
+		for address := range strings.SplitSeq(labels[label], ",") {
+			address = strings.TrimSpace(address)
+			if address == "" {
+				continue
+			}
+			if _, _, err := net.ParseCIDR(address); err != nil && net.ParseIP(address) == nil && address != "all" {
+				return nil, &errors.InvalidLabelValueError{
+					Msg: fmt.Sprintf("value of '%v' label must be a comma-separated list of IP addresses or CIDRs, got '%v'", label, address),
+				}
+			}
+			*addresses = append(*addresses, address)
+		}
+	}
+
+	satisfyAny, err := docker.ParseBoolLabel(labels, npmOptionsAccessListSatisfyAnyLabel, false)
+	if err != nil {
+		return nil, err
+	}
+	accessList.SatisfyAny = satisfyAny
+
+	for user := range strings.SplitSeq(labels[npmOptionsAccessListUsersLabel], ",") {
+		user = strings.TrimSpace(user)
+		if user == "" {
+			continue
+		}
+		username, passwordSecret, found := strings.Cut(user, ":")
+		if !found || username == "" || passwordSecret == "" {
+			return nil, &errors.InvalidLabelValueError{
+				Msg: fmt.Sprintf("value of '%v' label must be a comma-separated list of '<username>:<secret name>', got '%v'", npmOptionsAccessListUsersLabel, user),
+			}
+		}
+		accessList.Users = append(accessList.Users, npm.AccessListUser{PasswordSecret: passwordSecret, Username: username})
+	}
+
+	return accessList, nil
+}
@@ -0,0 +1,445 @@
+//go:build unit
+
+package providers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deepspace2/plugnpin/pkg/clients/docker"
+	"github.com/deepspace2/plugnpin/pkg/clients/npm"
+	"github.com/deepspace2/plugnpin/pkg/errors"
+	"github.com/deepspace2/plugnpin/pkg/snippets"
+)
+
+func TestNpmOptionsLabels(t *testing.T) {
+	disableOnStop := true
+
+	testCases := []struct {
+		name                      string
+		labels                    map[string]string
+		expectedErr               error
+		expectedDisableOnStop     *bool
+		expectedSnippets          []snippets.Reference
+		expectedInstance          string
+		expectedBlockExploits     bool
+		expectedCachingEnabled    bool
+		expectedScheme            string
+		expectedWebsocketsSupport bool
+	}{
+		{
+			name: "Defaults",
+			labels: map[string]string{
+				docker.IpLabel:  "192.168.1.10:8080",
+				docker.UrlLabel: "my-service.example.com",
+			},
+			expectedBlockExploits: true,
+			expectedScheme:        "http",
+		},
+		{
+			name: "NPM options",
+			labels: map[string]string{
+				docker.IpLabel:                   "192.168.1.10:8080",
+				docker.UrlLabel:                  "my-service.example.com",
+				npmOptionsBlockExploitsLabel:     "",
+				npmOptionsCachingEnabledLabel:    "true",
+				npmOptionsSchemeLabel:            "https",
+				npmOptionsWebsocketsSupportLabel: "",
+			},
+			expectedBlockExploits:     false,
+			expectedCachingEnabled:    true,
+			expectedScheme:            "https",
+			expectedWebsocketsSupport: false,
+		},
+		{
+			name: "NPM options - true values",
+			labels: map[string]string{
+				docker.IpLabel:                   "192.168.1.10:8080",
+				docker.UrlLabel:                  "my-service.example.com",
+				npmOptionsBlockExploitsLabel:     "true",
+				npmOptionsCachingEnabledLabel:    "1",
+				npmOptionsWebsocketsSupportLabel: "T",
+			},
+			expectedBlockExploits:     true,
+			expectedCachingEnabled:    true,
+			expectedScheme:            "http",
+			expectedWebsocketsSupport: true,
+		},
+		{
+			name: "NPM options - false values",
+			labels: map[string]string{
+				docker.IpLabel:                   "192.168.1.10:8080",
+				docker.UrlLabel:                  "my-service.example.com",
+				npmOptionsBlockExploitsLabel:     "false",
+				npmOptionsCachingEnabledLabel:    "0",
+				npmOptionsWebsocketsSupportLabel: "F",
+			},
+			expectedBlockExploits:     false,
+			expectedCachingEnabled:    false,
+			expectedScheme:            "http",
+			expectedWebsocketsSupport: false,
+		},
+		{
+			name: "NPM options - invalid boolean values",
+			labels: map[string]string{
+				docker.IpLabel:                   "192.168.1.10:8080",
+				docker.UrlLabel:                  "my-service.example.com",
+				npmOptionsBlockExploitsLabel:     "yes",
+				npmOptionsCachingEnabledLabel:    "no",
+				npmOptionsWebsocketsSupportLabel: "2",
+			},
+			expectedBlockExploits:     false,
+			expectedCachingEnabled:    false,
+			expectedScheme:            "http",
+			expectedWebsocketsSupport: false,
+		},
+		{
+			name: "NPM options - invalid scheme",
+			labels: map[string]string{
+				docker.IpLabel:        "192.168.1.10:8080",
+				docker.UrlLabel:       "my-service.example.com",
+				npmOptionsSchemeLabel: "invalid",
+			},
+			expectedErr: &errors.InvalidSchemeError{Msg: fmt.Sprintf("value of '%v' label must be one of 'http', 'https', got 'invalid'", npmOptionsSchemeLabel)},
+		},
+		{
+			name: "NPM options - case-insensitive scheme",
+			labels: map[string]string{
+				docker.IpLabel:        "192.168.1.10:8080",
+				docker.UrlLabel:       "my-service.example.com",
+				npmOptionsSchemeLabel: "HTTPS",
+			},
+			expectedBlockExploits:     true,
+			expectedCachingEnabled:    false,
+			expectedScheme:            "https",
+			expectedWebsocketsSupport: false,
+		},
+		{
+			name: "NPM options - disable on stop",
+			labels: map[string]string{
+				docker.IpLabel:               "192.168.1.10:8080",
+				docker.UrlLabel:              "my-service.example.com",
+				npmOptionsDisableOnStopLabel: "true",
+			},
+			expectedScheme:        "http",
+			expectedBlockExploits: true,
+			expectedDisableOnStop: &disableOnStop,
+		},
+		{
+			name: "NPM options - invalid disable on stop",
+			labels: map[string]string{
+				docker.IpLabel:               "192.168.1.10:8080",
+				docker.UrlLabel:              "my-service.example.com",
+				npmOptionsDisableOnStopLabel: "sometimes",
+			},
+			expectedErr: &errors.InvalidLabelValueError{Msg: fmt.Sprintf("value of '%v' label must be a boolean, got 'sometimes'", npmOptionsDisableOnStopLabel)},
+		},
+		{
+			name: "NPM options - snippets",
+			labels: map[string]string{
+				docker.IpLabel:          "192.168.1.10:8080",
+				docker.UrlLabel:         "my-service.example.com",
+				npmOptionsSnippetsLabel: "authelia,uploads(size=2g)",
+			},
+			expectedScheme:        "http",
+			expectedBlockExploits: true,
+			expectedSnippets: []snippets.Reference{
+				{Name: "authelia"},
+				{Name: "uploads", Params: map[string]string{"size": "2g"}},
+			},
+		},
+		{
+			name: "NPM options - invalid snippets",
+			labels: map[string]string{
+				docker.IpLabel:          "192.168.1.10:8080",
+				docker.UrlLabel:         "my-service.example.com",
+				npmOptionsSnippetsLabel: "uploads(size=2g",
+			},
+			expectedErr: &errors.InvalidLabelValueError{Msg: fmt.Sprintf("value of '%v' label is invalid: malformed parameters of snippet 'uploads'", npmOptionsSnippetsLabel)},
+		},
+		{
+			name: "NPM options - instance",
+			labels: map[string]string{
+				docker.IpLabel:          "192.168.1.10:8080",
+				docker.UrlLabel:         "my-service.example.com",
+				npmOptionsInstanceLabel: " DMZ ",
+			},
+			expectedScheme:        "http",
+			expectedBlockExploits: true,
+			expectedInstance:      "dmz",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, _, opts, err := docker.GetValuesFromLabels(tc.labels)
+
+			assert.Equal(t, tc.expectedErr, err)
+			if err != nil {
+				return
+			}
+			npmOptions := Container{Options: opts}.npmOptions()
+			assert.Equal(t, tc.expectedBlockExploits, npmOptions.BlockExploits)
+			assert.Equal(t, tc.expectedCachingEnabled, npmOptions.CachingEnabled)
+			assert.Equal(t, tc.expectedScheme, npmOptions.ForwardScheme)
+			assert.Equal(t, tc.expectedWebsocketsSupport, npmOptions.AllowWebsocketUpgrade)
+			assert.Equal(t, tc.expectedDisableOnStop, npmOptions.DisableOnStop)
+			assert.Equal(t, tc.expectedInstance, npmOptions.Instance)
+			if tc.expectedSnippets == nil {
+				assert.Empty(t, npmOptions.Snippets)
+			} else {
+				assert.Equal(t, tc.expectedSnippets, npmOptions.Snippets)
+			}
+		})
+	}
+}
+
+func TestRedirectsAndStreamPort(t *testing.T) {
+	_, _, _, opts, err := docker.GetValuesFromLabels(map[string]string{
+		docker.IpLabel:  "192.168.1.10:8080",
+		docker.UrlLabel: "my-service.example.com",
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, Container{Options: opts}.Redirects())
+	assert.Zero(t, Container{Options: opts}.StreamPort())
+
+	_, _, _, opts, err = docker.GetValuesFromLabels(map[string]string{
+		docker.IpLabel:          "192.168.1.10:8080",
+		docker.UrlLabel:         "my-service.example.com",
+		redirectsLabel:          "old.example.com",
+		streamIncomingPortLabel: "2222",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"old.example.com"}, Container{Options: opts}.Redirects())
+	assert.Equal(t, 2222, Container{Options: opts}.StreamPort())
+}
+
+func TestParseNpmLocationsLabels(t *testing.T) {
+	testCases := []struct {
+		name              string
+		labels            map[string]string
+		expectedLocations []npm.Location
+		expectedErr       error
+	}{
+		{
+			name:              "No locations",
+			labels:            map[string]string{},
+			expectedLocations: nil,
+		},
+		{
+			name: "Leaves the proxy host's target to the route",
+			labels: map[string]string{
+				"plugNPiN.npmOptions.locations.api.path": "/api",
+			},
+			expectedLocations: []npm.Location{
+				{Path: "/api"},
+			},
+		},
+		{
+			name: "Multiple locations sorted by path",
+			labels: map[string]string{
+				"plugNPiN.npmOptions.locations.ws.path":           "/ws",
+				"plugNPiN.npmOptions.locations.ws.advancedConfig": "proxy_read_timeout 1h;",
+				"plugNPiN.npmOptions.locations.api.path":          "/api",
+				"plugNPiN.npmOptions.locations.api.forwardHost":   "api-sidecar",
+				"plugNPiN.npmOptions.locations.api.forwardPort":   "9000",
+				"plugNPiN.npmOptions.locations.api.scheme":        "HTTPS",
+			},
+			expectedLocations: []npm.Location{
+				{Path: "/api", ForwardHost: "api-sidecar", ForwardPort: 9000, ForwardScheme: "https"},
+				{Path: "/ws", AdvancedConfig: "proxy_read_timeout 1h;"},
+			},
+		},
+		{
+			name: "Missing path",
+			labels: map[string]string{
+				"plugNPiN.npmOptions.locations.api.forwardPort": "9000",
+			},
+			expectedErr: &errors.InvalidLabelValueError{Msg: "value of 'plugNPiN.npmOptions.locations.api.path' label must start with '/', got ''"},
+		},
+		{
+			name: "Invalid port",
+			labels: map[string]string{
+				"plugNPiN.npmOptions.locations.api.path":        "/api",
+				"plugNPiN.npmOptions.locations.api.forwardPort": "abc",
+			},
+			expectedErr: &errors.InvalidLabelValueError{Msg: "value of 'plugNPiN.npmOptions.locations.api.forwardPort' label must be a valid port, got 'abc'"},
+		},
+		{
+			name: "Invalid scheme",
+			labels: map[string]string{
+				"plugNPiN.npmOptions.locations.api.path":   "/api",
+				"plugNPiN.npmOptions.locations.api.scheme": "ftp",
+			},
+			expectedErr: &errors.InvalidSchemeError{Msg: "value of 'plugNPiN.npmOptions.locations.api.scheme' label must be one of 'http', 'https', got 'ftp'"},
+		},
+		{
+			name: "Unknown field",
+			labels: map[string]string{
+				"plugNPiN.npmOptions.locations.api.target": "x",
+			},
+			expectedErr: &errors.InvalidLabelValueError{Msg: "label 'plugNPiN.npmOptions.locations.api.target' has unknown field 'target', must be one of 'path', 'forwardHost', 'forwardPort', 'scheme', 'advancedConfig'"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			locations, err := parseNpmLocationsLabels(tc.labels)
+			assert.Equal(t, tc.expectedErr, err)
+			assert.Equal(t, tc.expectedLocations, locations)
+		})
+	}
+}
+
+func TestParseStreamLabels(t *testing.T) {
+	testCases := []struct {
+		name            string
+		labels          map[string]string
+		expectedOptions *npm.NpmStreamOptions
+		expectedErr     error
+	}{
+		{
+			name:   "No stream",
+			labels: map[string]string{},
+		},
+		{
+			name:            "TCP by default",
+			labels:          map[string]string{streamIncomingPortLabel: "1883"},
+			expectedOptions: &npm.NpmStreamOptions{IncomingPort: 1883, TCPForwarding: true},
+		},
+		{
+			name:            "UDP only",
+			labels:          map[string]string{streamIncomingPortLabel: "27015", streamTCPLabel: "false", streamUDPLabel: "true"},
+			expectedOptions: &npm.NpmStreamOptions{IncomingPort: 27015, UDPForwarding: true},
+		},
+		{
+			name:        "Invalid incoming port",
+			labels:      map[string]string{streamIncomingPortLabel: "70000"},
+			expectedErr: &errors.InvalidLabelValueError{Msg: fmt.Sprintf("value of '%v' label must be a valid port, got '70000'", streamIncomingPortLabel)},
+		},
+		{
+			name:        "Invalid boolean",
+			labels:      map[string]string{streamIncomingPortLabel: "1883", streamUDPLabel: "maybe"},
+			expectedErr: &errors.InvalidLabelValueError{Msg: fmt.Sprintf("value of '%v' label must be a boolean, got 'maybe'", streamUDPLabel)},
+		},
+		{
+			name:        "Neither TCP nor UDP",
+			labels:      map[string]string{streamIncomingPortLabel: "1883", streamTCPLabel: "false"},
+			expectedErr: &errors.InvalidLabelValueError{Msg: fmt.Sprintf("at least one of '%v' and '%v' labels must be 'true'", streamTCPLabel, streamUDPLabel)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			options, err := parseStreamLabels(tc.labels)
+			assert.Equal(t, tc.expectedErr, err)
+			assert.Equal(t, tc.expectedOptions, options)
+		})
+	}
+}
+
+func TestParseRedirectLabels(t *testing.T) {
+	testCases := []struct {
+		name            string
+		labels          map[string]string
+		expectedOptions *npm.NpmRedirectionOptions
+		expectedErr     error
+	}{
+		{
+			name:   "No redirects",
+			labels: map[string]string{},
+		},
+		{
+			name:            "Defaults",
+			labels:          map[string]string{redirectsLabel: "old.home.lan, legacy.home.lan"},
+			expectedOptions: &npm.NpmRedirectionOptions{Domains: []string{"old.home.lan", "legacy.home.lan"}, ForwardHTTPCode: 301, ForwardScheme: "auto", PreservePath: true},
+		},
+		{
+			name: "All options",
+			labels: map[string]string{
+				redirectsLabel:                   "old.home.lan",
+				redirectOptionsCodeLabel:         "308",
+				redirectOptionsSchemeLabel:       "HTTPS",
+				redirectOptionsPreservePathLabel: "false",
+			},
+			expectedOptions: &npm.NpmRedirectionOptions{Domains: []string{"old.home.lan"}, ForwardHTTPCode: 308, ForwardScheme: "https", PreservePath: false},
+		},
+		{
+			name:        "Empty redirects",
+			labels:      map[string]string{redirectsLabel: " , "},
+			expectedErr: &errors.InvalidLabelValueError{Msg: fmt.Sprintf("value of '%v' label must be a comma-separated list of domains, got ' , '", redirectsLabel)},
+		},
+		{
+			name:        "Invalid code",
+			labels:      map[string]string{redirectsLabel: "old.home.lan", redirectOptionsCodeLabel: "200"},
+			expectedErr: &errors.InvalidLabelValueError{Msg: fmt.Sprintf("value of '%v' label must be one of 300, 301, 302, 303, 307, 308, got '200'", redirectOptionsCodeLabel)},
+		},
+		{
+			name:        "Invalid scheme",
+			labels:      map[string]string{redirectsLabel: "old.home.lan", redirectOptionsSchemeLabel: "ftp"},
+			expectedErr: &errors.InvalidSchemeError{Msg: fmt.Sprintf("value of '%v' label must be one of 'auto', 'http', 'https', got 'ftp'", redirectOptionsSchemeLabel)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			options, err := parseRedirectLabels(tc.labels)
+			assert.Equal(t, tc.expectedErr, err)
+			assert.Equal(t, tc.expectedOptions, options)
+		})
+	}
+}
+
+func TestParseNpmAccessListLabels(t *testing.T) {
+	testCases := []struct {
+		name            string
+		labels          map[string]string
+		expectedOptions *npm.AccessListOptions
+		expectedErr     error
+	}{
+		{
+			name:   "No inline access list",
+			labels: map[string]string{npmOptionsAccessListNameLabel: "existing"},
+		},
+		{
+			name: "All options",
+			labels: map[string]string{
+				npmOptionsAccessListAllowLabel:      "192.168.0.0/16, 10.0.0.1",
+				npmOptionsAccessListDenyLabel:       "all",
+				npmOptionsAccessListSatisfyAnyLabel: "true",
+				npmOptionsAccessListSharedNameLabel: "home",
+				npmOptionsAccessListUsersLabel:      "alice:alice_password,bob:bob_password",
+			},
+			expectedOptions: &npm.AccessListOptions{
+				Allow:      []string{"192.168.0.0/16", "10.0.0.1"},
+				Deny:       []string{"all"},
+				Name:       "home",
+				SatisfyAny: true,
+				Users: []npm.AccessListUser{
+					{PasswordSecret: "alice_password", Username: "alice"},
+					{PasswordSecret: "bob_password", Username: "bob"},
+				},
+			},
+		},
+		{
+			name:        "Invalid address",
+			labels:      map[string]string{npmOptionsAccessListAllowLabel: "192.168.0.0/33"},
+			expectedErr: &errors.InvalidLabelValueError{Msg: fmt.Sprintf("value of '%v' label must be a comma-separated list of IP addresses or CIDRs, got '192.168.0.0/33'", npmOptionsAccessListAllowLabel)},
+		},
+		{
+			name:        "User without secret",
+			labels:      map[string]string{npmOptionsAccessListUsersLabel: "alice"},
+			expectedErr: &errors.InvalidLabelValueError{Msg: fmt.Sprintf("value of '%v' label must be a comma-separated list of '<username>:<secret name>', got 'alice'", npmOptionsAccessListUsersLabel)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			options, err := parseNpmAccessListLabels(tc.labels)
+			assert.Equal(t, tc.expectedErr, err)
+			assert.Equal(t, tc.expectedOptions, options)
+		})
+	}
+}
@@ -0,0 +1,135 @@
+//go:build unit
+
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deepspace2/plugnpin/pkg/clients/docker"
+	"github.com/deepspace2/plugnpin/pkg/clients/npm"
+	"github.com/deepspace2/plugnpin/pkg/config"
+)
+
+func TestNpmAddress(t *testing.T) {
+	n := NewNpm(map[string]*npm.Client{
+		config.DEFAULT_NPM_INSTANCE: npm.NewClient("http://npm:81", "user", "pass"),
+		"dmz":                       npm.NewClient("http://dmz:81", "user", "pass"),
+	}, NpmOptions{})
+
+	npmOptions := &npm.NpmProxyHostOptions{}
+	container := Container{Options: &docker.ClientOptions{Providers: map[string]any{npmOptionsNamespace: npmOptions}}}
+	address, err := n.Address(container)
+	assert.NoError(t, err)
+	assert.Equal(t, "npm", address)
+
+	npmOptions.Instance = "dmz"
+	address, err = n.Address(container)
+	assert.NoError(t, err)
+	assert.Equal(t, "dmz", address)
+
+	npmOptions.Instance = "lab"
+	_, err = n.Address(container)
+	assert.EqualError(t, err, "unknown Nginx Proxy Manager instance 'lab', it has to be listed in NGINX_PROXY_MANAGER_INSTANCES")
+}
+
+func TestNpmDisableOnStop(t *testing.T) {
+	enabled := true
+	npmOptions := &npm.NpmProxyHostOptions{}
+	route := NewRoute(Container{Options: &docker.ClientOptions{Providers: map[string]any{npmOptionsNamespace: npmOptions}}})
+
+	assert.False(t, NewNpm(nil, NpmOptions{}).DisableOnStop(route))
+	assert.True(t, NewNpm(nil, NpmOptions{DisableOnStop: true}).DisableOnStop(route))
+
+	npmOptions.DisableOnStop = &enabled
+	assert.True(t, NewNpm(nil, NpmOptions{}).DisableOnStop(route))
+}
+
+func TestRouteLocations(t *testing.T) {
+	route := Route{ForwardScheme: "http", ForwardHost: "10.0.0.1", ForwardPort: 8080}
+
+	assert.Nil(t, routeLocations(route, nil))
+	assert.Equal(t, []npm.Location{
+		{Path: "/api", ForwardHost: "api-sidecar", ForwardPort: 9000, ForwardScheme: "https"},
+		{Path: "/ws", ForwardHost: "10.0.0.1", ForwardPort: 8080, ForwardScheme: "http"},
+	}, routeLocations(route, []npm.Location{
+		{Path: "/api", ForwardHost: "api-sidecar", ForwardPort: 9000, ForwardScheme: "https"},
+		{Path: "/ws"},
+	}))
+}
+
+// newFakeNpm returns an Npm provider whose default instance serves a managed
+// proxy host, redirection host and stream, and a function returning the
+// changing requests it received.
+func newFakeNpm(t *testing.T) (*Npm, func() []string) {
+	managed := npm.Meta{ManagedBy: npm.MANAGED_BY}
+	var mu sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/tokens":
+			_ = json.NewEncoder(w).Encode(npm.LoginResponse{Expires: time.Now().Add(time.Hour).Format(time.RFC3339Nano), Token: "token"})
+		case r.Method != http.MethodGet:
+			mu.Lock()
+			requests = append(requests, r.Method+" "+r.URL.Path)
+			mu.Unlock()
+		case r.URL.Path == "/api/nginx/proxy-hosts":
+			_ = json.NewEncoder(w).Encode([]npm.ProxyHostReply{{ID: 1, DomainNames: []string{"app.example.com"}, Enabled: true, Meta: managed}})
+		case r.URL.Path == "/api/nginx/redirection-hosts":
+			_ = json.NewEncoder(w).Encode([]npm.RedirectionHostReply{{ID: 2, DomainNames: []string{"www.example.com"}, Enabled: true, Meta: managed}})
+		case r.URL.Path == "/api/nginx/streams":
+			_ = json.NewEncoder(w).Encode([]npm.StreamReply{{ID: 3, IncomingPort: 1883, Enabled: true, Meta: managed}})
+		default:
+			t.Errorf("unexpected request: %v %v", r.Method, r.URL.Path)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client := npm.NewClient(server.URL, "user", "pass")
+	require.NoError(t, client.Login())
+	n := NewNpm(map[string]*npm.Client{config.DEFAULT_NPM_INSTANCE: client}, NpmOptions{})
+	return n, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+func TestNpmDeleteAndDisableRoute(t *testing.T) {
+	route := NewRoute(Container{
+		URLs: []string{"app.example.com"},
+		Options: &docker.ClientOptions{Providers: map[string]any{
+			npmOptionsNamespace: &npm.NpmProxyHostOptions{},
+			redirectsNamespace:  &npm.NpmRedirectionOptions{Domains: []string{"www.example.com"}},
+			streamNamespace:     &npm.NpmStreamOptions{IncomingPort: 1883},
+		}},
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		n, requests := newFakeNpm(t)
+		require.NoError(t, n.DeleteRoute(context.Background(), route))
+		assert.Equal(t, []string{
+			"DELETE /api/nginx/proxy-hosts/1",
+			"DELETE /api/nginx/redirection-hosts/2",
+			"DELETE /api/nginx/streams/3",
+		}, requests())
+	})
+
+	t.Run("disable", func(t *testing.T) {
+		n, requests := newFakeNpm(t)
+		require.NoError(t, n.DisableRoute(context.Background(), route))
+		assert.Equal(t, []string{
+			"POST /api/nginx/proxy-hosts/1/disable",
+			"POST /api/nginx/redirection-hosts/2/disable",
+			"POST /api/nginx/streams/3/disable",
+		}, requests())
+	})
+}
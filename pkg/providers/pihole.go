@@ -0,0 +1,241 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/deepspace2/plugnpin/pkg/clients/pihole"
+	"github.com/deepspace2/plugnpin/pkg/config"
+	"github.com/deepspace2/plugnpin/pkg/logging"
+	"github.com/deepspace2/plugnpin/pkg/metrics"
+)
+
+func init() {
+	RegisterDNSProvider(metrics.PI_HOLE, func(config *config.Config, options Options) (DNSProvider, error) {
+		if config.PiholeDisabled {
+			return nil, nil
+		}
+
+		piholeAPIVersion := config.PiholeAPIVersion
+		if piholeAPIVersion == pihole.API_VERSION_AUTO {
+			var err error
+			piholeAPIVersion, err = pihole.DetectAPIVersion(config.PiholeHost)
+			if err != nil {
+				return nil, fmt.Errorf("failed to detect Pi-Hole API version: %w", err)
+			}
+			log.Info(fmt.Sprintf("Detected Pi-Hole API version %v", piholeAPIVersion))
+		}
+
+		var client *pihole.Client
+		if piholeAPIVersion == pihole.API_VERSION_5 {
+			piholeAPIToken := config.PiholeAPIToken
+			if piholeAPIToken == "" {
+				piholeAPIToken = pihole.LegacyAPITokenFromPassword(config.PiholePassword)
+			}
+			client = pihole.NewLegacyClient(config.PiholeHost, piholeAPIToken)
+		} else {
+			client = pihole.NewClient(config.PiholeHost, config.PiholePassword, config.PiholeTotpSecret)
+		}
+		if err := client.Login(); err != nil {
+			return nil, fmt.Errorf("failed to login to Pi-Hole: %w", err)
+		}
+		return NewPiHole(client), nil
+	})
+}
+
+// PiHole manages local DNS records, local CNAME records and, for containers
+// that ask for it, static DHCP leases.
+type PiHole struct {
+	client *pihole.Client
+}
+
+func NewPiHole(client *pihole.Client) *PiHole {
+	return &PiHole{client: client}
+}
+
+func (p *PiHole) Name() string {
+	return metrics.PI_HOLE
+}
+
+func (p *PiHole) Capabilities() DNSCapabilities {
+	// Pi-Hole only supports TTLs on CNAME records
+	return DNSCapabilities{CNAME: true, TTL: true}
+}
+
+func (p *PiHole) Records(container Container, answer string) []Record {
+	piholeOptions := containerOptions[pihole.PiHoleOptions](container, piholeOptionsNamespace)
+	if piholeOptions.Dhcp {
+		// The DNS record points straight at the container rather than at the proxy
+		if piholeOptions.DhcpHost != nil && piholeOptions.DhcpHost.IP != "" {
+			answer = piholeOptions.DhcpHost.IP
+		}
+		return ARecords(container.URLs, answer)
+	}
+	if piholeOptions.TargetDomain != "" {
+		return CNameRecords(container.URLs, piholeOptions.TargetDomain, piholeOptions.TTL)
+	}
+	return ARecords(container.URLs, answer)
+}
+
+func (p *PiHole) ListRecords() ([]Record, error) {
+	dnsRecords, err := p.client.GetDnsRecords()
+	if err != nil {
+		return nil, err
+	}
+	cNameRecords, err := p.client.GetCNameRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	records := []Record{}
+	for _, domain := range slices.Sorted(maps.Keys(dnsRecords)) {
+		records = append(records, Record{Domain: string(domain), Type: RECORD_TYPE_A, Target: string(dnsRecords[domain])})
+	}
+	for _, domain := range slices.Sorted(maps.Keys(cNameRecords)) {
+		cNameRecord := cNameRecords[domain]
+		records = append(records, Record{Domain: string(domain), Type: RECORD_TYPE_CNAME, Target: string(cNameRecord.Target), TTL: cNameRecord.TTL})
+	}
+	return records, nil
+}
+
+func (p *PiHole) EnsureRecords(ctx context.Context, records []Record) error {
+	aRecords, cNameRecords := SplitRecords(records)
+	var errs []error
+
+	if len(aRecords) > 0 {
+		dnsRecords := pihole.DnsRecords{}
+		for _, record := range aRecords {
+			dnsRecords[pihole.DomainName(record.Domain)] = pihole.IP(record.Target)
+		}
+		numOfAddedEntries, numOfUpdatedEntries, err := p.client.AddDnsRecordsBatch(dnsRecords)
+		metrics.IncrementPiHoleEntriesCreated(numOfAddedEntries)
+		metrics.IncrementPiHoleEntriesUpdated(numOfUpdatedEntries)
+		if err != nil {
+			metrics.IncrementPiHoleApiRequestErrors(metrics.ADD_DNS_RECORD)
+			errs = append(errs, fmt.Errorf("failed to add local DNS records: %w", err))
+		}
+	}
+
+	if len(cNameRecords) > 0 {
+		piholeCNameRecords := pihole.CNameRecords{}
+		for _, record := range cNameRecords {
+			piholeCNameRecords[pihole.DomainName(record.Domain)] = pihole.CNameRecord{Target: pihole.Target(record.Target), TTL: record.TTL}
+		}
+		numOfAddedEntries, numOfUpdatedEntries, err := p.client.AddCNameRecordsBatch(piholeCNameRecords)
+		metrics.IncrementPiHoleEntriesCreated(numOfAddedEntries)
+		metrics.IncrementPiHoleEntriesUpdated(numOfUpdatedEntries)
+		if err != nil {
+			metrics.IncrementPiHoleApiRequestErrors(metrics.ADD_CNAME_RECORD)
+			errs = append(errs, fmt.Errorf("failed to add local CNAME records: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (p *PiHole) DeleteRecords(ctx context.Context, records []Record) error {
+	aRecords, cNameRecords := SplitRecords(records)
+	var errs []error
+
+	if len(aRecords) > 0 {
+		numOfDeletedEntries, err := p.client.DeleteDnsRecords(Domains(aRecords))
+		if err != nil {
+			metrics.IncrementPiHoleApiRequestErrors(metrics.DELETE_DNS_RECORD)
+			errs = append(errs, fmt.Errorf("failed to delete local DNS records: %w", err))
+		} else {
+			metrics.IncrementPiHoleEntriesDeleted(numOfDeletedEntries)
+		}
+	}
+
+	if len(cNameRecords) > 0 {
+		numOfDeletedEntries, err := p.client.DeleteCNameRecords(Domains(cNameRecords))
+		if err != nil {
+			metrics.IncrementPiHoleApiRequestErrors(metrics.DELETE_CNAME_RECORD)
+			errs = append(errs, fmt.Errorf("failed to delete local CNAME records: %w", err))
+		} else {
+			metrics.IncrementPiHoleEntriesDeleted(numOfDeletedEntries)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ContainerStarted adds the static DHCP lease of the container, if it has
+// Pi-Hole DHCP enabled.
+func (p *PiHole) ContainerStarted(ctx context.Context, container Container) error {
+	log := logging.FromContext(ctx)
+
+	piholeOptions := optionalContainerOptions[pihole.PiHoleOptions](container, piholeOptionsNamespace)
+	if piholeOptions == nil {
+		return nil
+	}
+	if piholeOptions.Dhcp && piholeOptions.TargetDomain != "" {
+		log.Warn("Static DHCP leases use local DNS records, ignoring Pi-Hole target domain", "targetDomain", piholeOptions.TargetDomain)
+	} else if piholeOptions.TargetDomain == "" && piholeOptions.TTL > 0 {
+		log.Warn("Pi-Hole only supports TTLs on CNAME records, ignoring it for local DNS records", "ttl", piholeOptions.TTL)
+	}
+
+	if !piholeOptions.Dhcp {
+		return nil
+	}
+
+	dhcpHost := pihole.DhcpHost{Hostname: pihole.DhcpHostname(container.Name)}
+	if piholeOptions.DhcpHost != nil {
+		dhcpHost.MAC = piholeOptions.DhcpHost.MAC
+		dhcpHost.IP = piholeOptions.DhcpHost.IP
+	} else {
+		if container.NetworkAddresses == nil {
+			return fmt.Errorf("the container's static DHCP lease is unknown")
+		}
+		var err error
+		dhcpHost.MAC, dhcpHost.IP, err = container.NetworkAddresses(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to resolve the container's static DHCP lease: %w", err)
+		}
+	}
+	// The container's records point at the IP address of its lease
+	piholeOptions.DhcpHost = &dhcpHost
+
+	log.Info("Adding static DHCP lease to Pi-Hole", "mac", dhcpHost.MAC, "ip", dhcpHost.IP, "hostname", dhcpHost.Hostname)
+	addedDhcpHost, err := p.client.AddDhcpHost(dhcpHost)
+	if err != nil {
+		metrics.IncrementPiHoleApiRequestErrors(metrics.ADD_DHCP_HOST)
+		return fmt.Errorf("failed to add static DHCP lease: %w", err)
+	}
+	if addedDhcpHost {
+		metrics.IncrementPiHoleEntriesCreated(1)
+	}
+	return nil
+}
+
+// ContainerStopped deletes the static DHCP lease of the container, if it has
+// Pi-Hole DHCP enabled. Leases are deleted by MAC address or hostname, so the
+// stopped container's network settings aren't needed.
+func (p *PiHole) ContainerStopped(ctx context.Context, container Container) error {
+	piholeOptions := containerOptions[pihole.PiHoleOptions](container, piholeOptionsNamespace)
+	if !piholeOptions.Dhcp {
+		return nil
+	}
+
+	dhcpHost := pihole.DhcpHost{Hostname: pihole.DhcpHostname(container.Name)}
+	if piholeOptions.DhcpHost != nil {
+		dhcpHost.MAC = piholeOptions.DhcpHost.MAC
+		dhcpHost.IP = piholeOptions.DhcpHost.IP
+	}
+	logging.FromContext(ctx).Info("Deleting static DHCP lease from Pi-Hole", "mac", dhcpHost.MAC, "hostname", dhcpHost.Hostname)
+	numOfDeletedDhcpHosts, err := p.client.DeleteDhcpHosts(dhcpHost)
+	if err != nil {
+		metrics.IncrementPiHoleApiRequestErrors(metrics.DELETE_DHCP_HOST)
+		return fmt.Errorf("failed to delete static DHCP lease: %w", err)
+	}
+	metrics.IncrementPiHoleEntriesDeleted(numOfDeletedDhcpHosts)
+	return nil
+}
+
+// Close logs out of Pi-Hole.
+func (p *PiHole) Close() error {
+	return p.client.Logout()
+}
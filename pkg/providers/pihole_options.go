@@ -0,0 +1,71 @@
+package providers
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/deepspace2/plugnpin/pkg/clients/docker"
+	"github.com/deepspace2/plugnpin/pkg/clients/pihole"
+	"github.com/deepspace2/plugnpin/pkg/errors"
+)
+
+const (
+	piholeOptionsNamespace         = "piholeOptions"
+	piholeOptionsDhcpLabel         = "plugNPiN.piholeOptions.dhcp"
+	piholeOptionsTargetDomainLabel = "plugNPiN.piholeOptions.targetDomain"
+	piholeOptionsTTLLabel          = "plugNPiN.piholeOptions.ttl"
+)
+
+func init() {
+	docker.RegisterOptionsParser(piholeOptionsNamespace, func(labels map[string]string) (any, error) {
+		ttl, err := docker.ParseTTLLabel(labels, piholeOptionsTTLLabel)
+		if err != nil {
+			return nil, err
+		}
+		dhcp, dhcpHost, err := parsePiholeDhcpLabel(labels)
+		if err != nil {
+			return nil, err
+		}
+		return &pihole.PiHoleOptions{
+			Dhcp:         dhcp,
+			DhcpHost:     dhcpHost,
+			TargetDomain: labels[piholeOptionsTargetDomainLabel],
+			TTL:          ttl,
+		}, nil
+	})
+}
+
+// parsePiholeDhcpLabel accepts either a boolean, in which case the MAC and IP
+// address are taken from the container's network settings, or an explicit
+// "<mac>,<ip>" pair.
+func parsePiholeDhcpLabel(labels map[string]string) (bool, *pihole.DhcpHost, error) {
+	value, exists := labels[piholeOptionsDhcpLabel]
+	if !exists {
+		return false, nil, nil
+	}
+
+	if enabled, err := strconv.ParseBool(value); err == nil {
+		return enabled, nil, nil
+	}
+
+	invalidValueError := &errors.InvalidLabelValueError{
+		Msg: fmt.Sprintf("value of '%v' label must be 'true', 'false' or '<mac>,<ip>', got '%v'", piholeOptionsDhcpLabel, value),
+	}
+
+	splitValue := strings.Split(value, ",")
+	if len(splitValue) != 2 {
+		return false, nil, invalidValueError
+	}
+	mac, err := net.ParseMAC(strings.TrimSpace(splitValue[0]))
+	if err != nil {
+		return false, nil, invalidValueError
+	}
+	ip := net.ParseIP(strings.TrimSpace(splitValue[1]))
+	if ip == nil || ip.To4() == nil {
+		return false, nil, invalidValueError
+	}
+
+	return true, &pihole.DhcpHost{MAC: mac.String(), IP: ip.String()}, nil
+}
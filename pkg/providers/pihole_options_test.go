@@ -0,0 +1,121 @@
+//go:build unit
+
+package providers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deepspace2/plugnpin/pkg/clients/docker"
+	"github.com/deepspace2/plugnpin/pkg/clients/pihole"
+	"github.com/deepspace2/plugnpin/pkg/errors"
+)
+
+func TestPiholeOptionsLabels(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		labels               map[string]string
+		expectedErr          error
+		expectedTargetDomain string
+		expectedTTL          int
+	}{
+		{
+			name: "Pi-Hole options - no target domain",
+			labels: map[string]string{
+				docker.IpLabel:  "192.168.1.10:8080",
+				docker.UrlLabel: "my-service.example.com",
+			},
+			expectedTargetDomain: "",
+		},
+		{
+			name: "Pi-Hole options - target domain",
+			labels: map[string]string{
+				docker.IpLabel:                 "192.168.1.10:8080",
+				docker.UrlLabel:                "my-service.example.com",
+				piholeOptionsTargetDomainLabel: "custom.domain",
+			},
+			expectedTargetDomain: "custom.domain",
+		},
+		{
+			name: "Pi-Hole options - ttl",
+			labels: map[string]string{
+				docker.IpLabel:                 "192.168.1.10:8080",
+				docker.UrlLabel:                "my-service.example.com",
+				piholeOptionsTargetDomainLabel: "custom.domain",
+				piholeOptionsTTLLabel:          "60",
+			},
+			expectedTargetDomain: "custom.domain",
+			expectedTTL:          60,
+		},
+		{
+			name: "Pi-Hole options - invalid ttl",
+			labels: map[string]string{
+				docker.IpLabel:        "192.168.1.10:8080",
+				docker.UrlLabel:       "my-service.example.com",
+				piholeOptionsTTLLabel: "-1",
+			},
+			expectedErr: &errors.InvalidLabelValueError{Msg: fmt.Sprintf("value of '%v' label must be a non-negative integer, got '-1'", piholeOptionsTTLLabel)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, _, opts, err := docker.GetValuesFromLabels(tc.labels)
+
+			assert.Equal(t, tc.expectedErr, err)
+			if err != nil {
+				return
+			}
+			piholeOptions := containerOptions[pihole.PiHoleOptions](Container{Options: opts}, piholeOptionsNamespace)
+			assert.Equal(t, tc.expectedTargetDomain, piholeOptions.TargetDomain)
+			assert.Equal(t, tc.expectedTTL, piholeOptions.TTL)
+		})
+	}
+}
+
+func TestParsePiholeDhcpLabel(t *testing.T) {
+	testCases := []struct {
+		name             string
+		labels           map[string]string
+		expectedEnabled  bool
+		expectedDhcpHost *pihole.DhcpHost
+		expectErr        bool
+	}{
+		{
+			name:   "No label",
+			labels: map[string]string{},
+		},
+		{
+			name:            "From network settings",
+			labels:          map[string]string{piholeOptionsDhcpLabel: "true"},
+			expectedEnabled: true,
+		},
+		{
+			name:             "Explicit MAC and IP",
+			labels:           map[string]string{piholeOptionsDhcpLabel: "AA:BB:CC:DD:EE:FF, 192.168.0.30"},
+			expectedEnabled:  true,
+			expectedDhcpHost: &pihole.DhcpHost{MAC: "aa:bb:cc:dd:ee:ff", IP: "192.168.0.30"},
+		},
+		{
+			name:      "Invalid MAC",
+			labels:    map[string]string{piholeOptionsDhcpLabel: "not-a-mac,192.168.0.30"},
+			expectErr: true,
+		},
+		{
+			name:      "Invalid value",
+			labels:    map[string]string{piholeOptionsDhcpLabel: "sometimes"},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			enabled, dhcpHost, err := parsePiholeDhcpLabel(tc.labels)
+			assert.Equal(t, tc.expectErr, err != nil)
+			assert.Equal(t, tc.expectedEnabled, enabled)
+			assert.Equal(t, tc.expectedDhcpHost, dhcpHost)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+//go:build unit
+
+package providers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deepspace2/plugnpin/pkg/clients/docker"
+	"github.com/deepspace2/plugnpin/pkg/clients/pihole"
+)
+
+func TestPiHoleRecords(t *testing.T) {
+	p := NewPiHole(nil)
+	urls := []string{"one.com", "two.com"}
+
+	testCases := []struct {
+		name          string
+		piholeOptions pihole.PiHoleOptions
+		expected      []Record
+	}{
+		{
+			name:     "local DNS records",
+			expected: ARecords(urls, "1.2.3.4"),
+		},
+		{
+			name:          "local CNAME records",
+			piholeOptions: pihole.PiHoleOptions{TargetDomain: "target.com", TTL: 60},
+			expected:      CNameRecords(urls, "target.com", 60),
+		},
+		{
+			name: "static DHCP lease points at the container",
+			piholeOptions: pihole.PiHoleOptions{
+				Dhcp:         true,
+				DhcpHost:     &pihole.DhcpHost{MAC: "02:42:ac:11:00:02", IP: "172.17.0.2"},
+				TargetDomain: "target.com",
+			},
+			expected: ARecords(urls, "172.17.0.2"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			container := Container{URLs: urls, Options: &docker.ClientOptions{Providers: map[string]any{piholeOptionsNamespace: &tc.piholeOptions}}}
+			assert.Equal(t, tc.expected, p.Records(container, "1.2.3.4"))
+		})
+	}
+}
+
+func TestPiHoleContainerStarted(t *testing.T) {
+	// Pi-Hole v5 has no static DHCP leases, so the lease is resolved but not added
+	p := NewPiHole(pihole.NewLegacyClient("http://pihole.local", "token"))
+	urls := []string{"one.com"}
+
+	container := Container{Name: "my_service", URLs: urls, Options: &docker.ClientOptions{}}
+	assert.NoError(t, p.ContainerStarted(context.Background(), container))
+
+	container.Options.Providers = map[string]any{piholeOptionsNamespace: &pihole.PiHoleOptions{Dhcp: true}}
+	assert.EqualError(t, p.ContainerStarted(context.Background(), container), "the container's static DHCP lease is unknown")
+
+	container.NetworkAddresses = func(ctx context.Context) (string, string, error) {
+		return "", "", fmt.Errorf("no network")
+	}
+	assert.EqualError(t, p.ContainerStarted(context.Background(), container), "failed to resolve the container's static DHCP lease: no network")
+
+	container.NetworkAddresses = func(ctx context.Context) (string, string, error) {
+		return "02:42:ac:11:00:02", "172.17.0.2", nil
+	}
+	assert.ErrorContains(t, p.ContainerStarted(context.Background(), container), "failed to add static DHCP lease")
+	assert.Equal(t, &pihole.DhcpHost{MAC: "02:42:ac:11:00:02", IP: "172.17.0.2", Hostname: "my-service"}, containerOptions[pihole.PiHoleOptions](container, piholeOptionsNamespace).DhcpHost)
+	assert.Equal(t, ARecords(urls, "172.17.0.2"), p.Records(container, "1.2.3.4"))
+}
@@ -0,0 +1,238 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/deepspace2/plugnpin/pkg/clients/docker"
+	"github.com/deepspace2/plugnpin/pkg/clients/npm"
+	"github.com/deepspace2/plugnpin/pkg/logging"
+	"github.com/deepspace2/plugnpin/pkg/snippets"
+)
+
+var log = logging.GetLogger("providers")
+
+type RecordType string
+
+const (
+	RECORD_TYPE_A     RecordType = "A"
+	RECORD_TYPE_CNAME RecordType = "CNAME"
+)
+
+// Record is a DNS record managed for a container.
+type Record struct {
+	Domain string
+	Type   RecordType
+	// Target is the IP address of A records and the domain of CNAME records
+	Target string
+	// TTL is in seconds, 0 leaves it up to the provider
+	TTL int
+}
+
+func (r Record) String() string {
+	if r.TTL > 0 {
+		return fmt.Sprintf("%v %v %v (TTL %v)", r.Domain, r.Type, r.Target, r.TTL)
+	}
+	return fmt.Sprintf("%v %v %v", r.Domain, r.Type, r.Target)
+}
+
+// Container is a labelled container, as seen by providers.
+type Container struct {
+	ID      string
+	Name    string
+	IP      string
+	Port    int
+	URLs    []string
+	Options *docker.ClientOptions
+	// NetworkAddresses returns the MAC and IP address of the container on its
+	// network, it is nil for containers that aren't running
+	NetworkAddresses func(ctx context.Context) (mac, ip string, err error)
+}
+
+// containerOptions returns the options a provider parsed from the labels of
+// container, registered with docker.RegisterOptionsParser under namespace, or
+// the zero value if there are none.
+func containerOptions[T any](container Container, namespace string) T {
+	if options := optionalContainerOptions[T](container, namespace); options != nil {
+		return *options
+	}
+	var zero T
+	return zero
+}
+
+// optionalContainerOptions is like containerOptions, but returns nil if there
+// are no options, and the options themselves otherwise, so that providers can
+// tell the two apart, or keep state about the container in them.
+func optionalContainerOptions[T any](container Container, namespace string) *T {
+	if container.Options == nil {
+		return nil
+	}
+	if options, ok := container.Options.Providers[namespace].(*T); ok {
+		return options
+	}
+	return nil
+}
+
+type DNSCapabilities struct {
+	// CNAME is set if the provider can hold CNAME records
+	CNAME bool
+	// TTL is set if the provider honours the TTL of records
+	TTL bool
+}
+
+// DNSProvider manages the DNS records of containers.
+type DNSProvider interface {
+	// Name identifies the provider in logs, and is its metrics service label
+	Name() string
+	Capabilities() DNSCapabilities
+	// Records returns the records container needs, pointing at answer unless
+	// its label options ask for something else
+	Records(container Container, answer string) []Record
+	ListRecords() ([]Record, error)
+	EnsureRecords(ctx context.Context, records []Record) error
+	DeleteRecords(ctx context.Context, records []Record) error
+}
+
+// RecordDisabler is implemented by DNS providers that can disable records
+// instead of deleting them when a container stops.
+type RecordDisabler interface {
+	DisableOnStop(container Container) bool
+	DisableRecords(ctx context.Context, records []Record) error
+}
+
+// ContainerHook is implemented by providers that manage more than records or
+// routes for a container, e.g. Pi-Hole's static DHCP leases. A failing
+// ContainerStarted prevents the container's records from being added.
+type ContainerHook interface {
+	ContainerStarted(ctx context.Context, container Container) error
+	ContainerStopped(ctx context.Context, container Container) error
+}
+
+// Route is a reverse proxy entry for a container.
+type Route struct {
+	// Domains are the container's urls, the first one being its main domain
+	Domains       []string
+	ForwardScheme string
+	ForwardHost   string
+	ForwardPort   int
+	HstsEnabled   bool
+	SslForced     bool
+	Websockets    bool
+
+	// Container is the container the route is for, so providers can read
+	// their own label options. It is nil for listed routes.
+	Container *Container
+}
+
+// NewRoute returns the route of container.
+func NewRoute(container Container) Route {
+	route := Route{
+		Domains:     container.URLs,
+		ForwardHost: container.IP,
+		ForwardPort: container.Port,
+		Container:   &container,
+	}
+	if npmOptions := optionalContainerOptions[npm.NpmProxyHostOptions](container, npmOptionsNamespace); npmOptions != nil {
+		route.ForwardScheme = npmOptions.ForwardScheme
+		route.HstsEnabled = npmOptions.HstsEnabled
+		route.SslForced = npmOptions.SslForced
+		route.Websockets = npmOptions.AllowWebsocketUpgrade
+	}
+	return route
+}
+
+type ProxyCapabilities struct {
+	// Redirects is set if the provider serves the redirects of
+	// 'plugNPiN.redirects'
+	Redirects bool
+	// Streams is set if the provider serves the TCP/UDP streams of
+	// 'plugNPiN.stream.*'
+	Streams bool
+}
+
+// ProxyProvider manages the reverse proxy routes of containers.
+type ProxyProvider interface {
+	// Name identifies the provider in logs, and is its metrics service label
+	Name() string
+	Capabilities() ProxyCapabilities
+	// Address returns the address the DNS records of container point at
+	Address(container Container) (string, error)
+	ListRoutes() ([]Route, error)
+	EnsureRoute(ctx context.Context, route Route) error
+	DeleteRoute(ctx context.Context, route Route) error
+}
+
+// RouteDisabler is implemented by proxy providers that can disable routes
+// instead of deleting them when a container stops.
+type RouteDisabler interface {
+	DisableOnStop(route Route) bool
+	DisableRoute(ctx context.Context, route Route) error
+}
+
+// Syncer is implemented by providers that want to know when a full sync of
+// all containers starts and ends, e.g. to share lookups between containers.
+type Syncer interface {
+	BeginSync()
+	EndSync()
+}
+
+// HealthReporter is implemented by proxy providers that can report the health
+// of the routes of domains after a full sync.
+type HealthReporter interface {
+	ReportHealth(domains []string)
+}
+
+// ARecords returns an A record pointing at ip for each of domains.
+func ARecords(domains []string, ip string) []Record {
+	records := []Record{}
+	for _, domain := range domains {
+		records = append(records, Record{Domain: domain, Type: RECORD_TYPE_A, Target: ip})
+	}
+	return records
+}
+
+// CNameRecords returns a CNAME record pointing at target for each of domains.
+func CNameRecords(domains []string, target string, ttl int) []Record {
+	records := []Record{}
+	for _, domain := range domains {
+		records = append(records, Record{Domain: domain, Type: RECORD_TYPE_CNAME, Target: target, TTL: ttl})
+	}
+	return records
+}
+
+// Domains returns the domains of records.
+func Domains(records []Record) []string {
+	domains := []string{}
+	for _, record := range records {
+		domains = append(domains, record.Domain)
+	}
+	return domains
+}
+
+// SplitRecords splits records into A and CNAME records.
+func SplitRecords(records []Record) (aRecords, cNameRecords []Record) {
+	aRecords = slices.DeleteFunc(slices.Clone(records), func(record Record) bool { return record.Type != RECORD_TYPE_A })
+	cNameRecords = slices.DeleteFunc(slices.Clone(records), func(record Record) bool { return record.Type != RECORD_TYPE_CNAME })
+	return aRecords, cNameRecords
+}
+
+// renderAdvancedConfig renders the snippets referenced by the container,
+// followed by its inline advanced config.
+func renderAdvancedConfig(library *snippets.Library, npmProxyHostOptions npm.NpmProxyHostOptions) (string, error) {
+	if len(npmProxyHostOptions.Snippets) == 0 {
+		return npmProxyHostOptions.AdvancedConfig, nil
+	}
+	if library == nil {
+		return "", fmt.Errorf("snippets are referenced, but NGINX_PROXY_MANAGER_SNIPPETS_DIR is not set")
+	}
+
+	renderedSnippets, err := library.Render(npmProxyHostOptions.Snippets)
+	if err != nil {
+		return "", err
+	}
+	if npmProxyHostOptions.AdvancedConfig == "" {
+		return renderedSnippets, nil
+	}
+	return renderedSnippets + "\n" + npmProxyHostOptions.AdvancedConfig, nil
+}
@@ -0,0 +1,78 @@
+//go:build unit
+
+package providers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deepspace2/plugnpin/pkg/clients/docker"
+	"github.com/deepspace2/plugnpin/pkg/clients/npm"
+	"github.com/deepspace2/plugnpin/pkg/snippets"
+)
+
+func TestNewRoute(t *testing.T) {
+	container := Container{
+		IP:   "10.0.0.2",
+		Port: 8080,
+		URLs: []string{"app.com", "www.app.com"},
+		Options: &docker.ClientOptions{Providers: map[string]any{npmOptionsNamespace: &npm.NpmProxyHostOptions{
+			AllowWebsocketUpgrade: true,
+			ForwardScheme:         "https",
+			HstsEnabled:           true,
+		}}},
+	}
+
+	route := NewRoute(container)
+	assert.Equal(t, []string{"app.com", "www.app.com"}, route.Domains)
+	assert.Equal(t, "https", route.ForwardScheme)
+	assert.Equal(t, "10.0.0.2", route.ForwardHost)
+	assert.Equal(t, 8080, route.ForwardPort)
+	assert.True(t, route.HstsEnabled)
+	assert.False(t, route.SslForced)
+	assert.True(t, route.Websockets)
+	assert.Equal(t, &container, route.Container)
+}
+
+func TestSplitRecords(t *testing.T) {
+	records := []Record{
+		{Domain: "one.com", Type: RECORD_TYPE_A, Target: "1.2.3.4"},
+		{Domain: "two.com", Type: RECORD_TYPE_CNAME, Target: "one.com", TTL: 60},
+	}
+
+	aRecords, cNameRecords := SplitRecords(records)
+	assert.Equal(t, records[:1], aRecords)
+	assert.Equal(t, records[1:], cNameRecords)
+	assert.Equal(t, []string{"one.com", "two.com"}, Domains(records))
+	assert.Equal(t, "two.com CNAME one.com (TTL 60)", records[1].String())
+}
+
+func TestRenderAdvancedConfig(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "uploads.conf"), []byte(`client_max_body_size {{default "1g" .size}};`), 0o644))
+	library, err := snippets.Load(dir)
+	require.NoError(t, err)
+
+	uploads := []snippets.Reference{{Name: "uploads", Params: map[string]string{"size": "2g"}}}
+
+	t.Run("inline advanced config only", func(t *testing.T) {
+		advancedConfig, err := renderAdvancedConfig(nil, npm.NpmProxyHostOptions{AdvancedConfig: "gzip on;"})
+		assert.NoError(t, err)
+		assert.Equal(t, "gzip on;", advancedConfig)
+	})
+
+	t.Run("snippets followed by inline advanced config", func(t *testing.T) {
+		advancedConfig, err := renderAdvancedConfig(library, npm.NpmProxyHostOptions{AdvancedConfig: "gzip on;", Snippets: uploads})
+		assert.NoError(t, err)
+		assert.Equal(t, "client_max_body_size 2g;\ngzip on;", advancedConfig)
+	})
+
+	t.Run("snippets without a snippet directory", func(t *testing.T) {
+		_, err := renderAdvancedConfig(nil, npm.NpmProxyHostOptions{Snippets: uploads})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,129 @@
+package providers
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/deepspace2/plugnpin/pkg/config"
+	"github.com/deepspace2/plugnpin/pkg/snippets"
+)
+
+// Options are shared with all providers when they are built.
+type Options struct {
+	// Snippets are the nginx snippets containers can reference, nil if no
+	// snippet directory is configured
+	Snippets *snippets.Library
+}
+
+// DNSProviderFactory builds a DNS provider from the configuration. It returns
+// nil if the provider is not enabled.
+type DNSProviderFactory func(config *config.Config, options Options) (DNSProvider, error)
+
+// ProxyProviderFactory builds a proxy provider from the configuration. It
+// returns nil if the provider is not enabled.
+type ProxyProviderFactory func(config *config.Config, options Options) (ProxyProvider, error)
+
+// providerConfig is the configuration a provider reads for itself with
+// parseConfig, so that adding a provider doesn't touch the core configuration.
+type providerConfig interface {
+	Enabled() bool
+	Validate() error
+}
+
+// parseConfig reads the configuration of a provider into the struct c points
+// to, see config.Parse, and validates it if the provider is enabled.
+func parseConfig(c providerConfig) (enabled bool, err error) {
+	if err := config.Parse(c); err != nil {
+		return false, err
+	}
+	if !c.Enabled() {
+		return false, nil
+	}
+	return true, c.Validate()
+}
+
+var (
+	dnsProviderFactories   = map[string]DNSProviderFactory{}
+	proxyProviderFactories = map[string]ProxyProviderFactory{}
+)
+
+// RegisterDNSProvider makes a DNS provider available to New. It is meant to be
+// called from the init function of the provider's file.
+func RegisterDNSProvider(name string, factory DNSProviderFactory) {
+	if _, exists := dnsProviderFactories[name]; exists {
+		panic(fmt.Sprintf("DNS provider '%v' is already registered", name))
+	}
+	dnsProviderFactories[name] = factory
+}
+
+// RegisterProxyProvider makes a proxy provider available to New. It is meant
+// to be called from the init function of the provider's file.
+func RegisterProxyProvider(name string, factory ProxyProviderFactory) {
+	if _, exists := proxyProviderFactories[name]; exists {
+		panic(fmt.Sprintf("proxy provider '%v' is already registered", name))
+	}
+	proxyProviderFactories[name] = factory
+}
+
+// New builds all enabled providers. Any number of DNS providers can be
+// enabled, but at most one proxy provider, since the DNS records of a
+// container can only point at one of them.
+func New(config *config.Config, options Options) (dnsProviders []DNSProvider, proxyProvider ProxyProvider, err error) {
+	defer func() {
+		if err != nil {
+			Close(dnsProviders, proxyProvider)
+		}
+	}()
+
+	dnsProviders = []DNSProvider{}
+	for _, name := range slices.Sorted(maps.Keys(dnsProviderFactories)) {
+		dnsProvider, err := dnsProviderFactories[name](config, options)
+		if err != nil {
+			return dnsProviders, proxyProvider, fmt.Errorf("failed to create DNS provider '%v': %w", name, err)
+		}
+		if dnsProvider != nil {
+			log.Debug("Enabled DNS provider", "name", name)
+			dnsProviders = append(dnsProviders, dnsProvider)
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(proxyProviderFactories)) {
+		enabledProxyProvider, err := proxyProviderFactories[name](config, options)
+		if err != nil {
+			return dnsProviders, proxyProvider, fmt.Errorf("failed to create proxy provider '%v': %w", name, err)
+		}
+		if enabledProxyProvider == nil {
+			continue
+		}
+		if proxyProvider != nil {
+			closeProvider(enabledProxyProvider)
+			return dnsProviders, proxyProvider, fmt.Errorf("only one proxy provider can be enabled, but both '%v' and '%v' are", proxyProvider.Name(), name)
+		}
+		log.Debug("Enabled proxy provider", "name", name)
+		proxyProvider = enabledProxyProvider
+	}
+
+	return dnsProviders, proxyProvider, nil
+}
+
+// Close closes the providers that hold on to resources, e.g. login sessions.
+func Close(dnsProviders []DNSProvider, proxyProvider ProxyProvider) {
+	for _, dnsProvider := range dnsProviders {
+		closeProvider(dnsProvider)
+	}
+	if proxyProvider != nil {
+		closeProvider(proxyProvider)
+	}
+}
+
+func closeProvider(provider interface{ Name() string }) {
+	closer, ok := provider.(io.Closer)
+	if !ok {
+		return
+	}
+	if err := closer.Close(); err != nil {
+		log.Warn("Failed to close provider", "name", provider.Name(), "error", err)
+	}
+}
@@ -0,0 +1,104 @@
+//go:build unit
+
+package providers
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deepspace2/plugnpin/pkg/config"
+)
+
+type stubProxyProvider struct {
+	name string
+}
+
+func (s *stubProxyProvider) Name() string                             { return s.name }
+func (s *stubProxyProvider) Capabilities() ProxyCapabilities          { return ProxyCapabilities{} }
+func (s *stubProxyProvider) Address(Container) (string, error)        { return "", nil }
+func (s *stubProxyProvider) ListRoutes() ([]Route, error)             { return nil, nil }
+func (s *stubProxyProvider) EnsureRoute(context.Context, Route) error { return nil }
+func (s *stubProxyProvider) DeleteRoute(context.Context, Route) error { return nil }
+
+func registerStubProxyProvider(t *testing.T, name string) {
+	t.Helper()
+	RegisterProxyProvider(name, func(config *config.Config, options Options) (ProxyProvider, error) {
+		return &stubProxyProvider{name: name}, nil
+	})
+	t.Cleanup(func() {
+		delete(proxyProviderFactories, name)
+	})
+}
+
+func disabledConfig() *config.Config {
+	return &config.Config{
+		AdguardHomeDisabled: true,
+		NpmDisabled:         true,
+		PiholeDisabled:      true,
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Run("nothing enabled", func(t *testing.T) {
+		dnsProviders, proxyProvider, err := New(disabledConfig(), Options{})
+		assert.NoError(t, err)
+		assert.Empty(t, dnsProviders)
+		assert.Nil(t, proxyProvider)
+	})
+
+	t.Run("AdGuard Home enabled", func(t *testing.T) {
+		config := disabledConfig()
+		config.AdguardHomeDisabled = false
+		config.AdguardHomeHost = "http://adguard"
+
+		dnsProviders, proxyProvider, err := New(config, Options{})
+		assert.NoError(t, err)
+		assert.Len(t, dnsProviders, 1)
+		assert.IsType(t, &AdguardHome{}, dnsProviders[0])
+		assert.Nil(t, proxyProvider)
+	})
+
+	t.Run("Traefik enabled by its own configuration", func(t *testing.T) {
+		t.Setenv("TRAEFIK_DISABLED", "false")
+		t.Setenv("TRAEFIK_CONFIG_FILE", filepath.Join(t.TempDir(), "plugnpin.yml"))
+		t.Setenv("TRAEFIK_IP", "192.168.1.5")
+
+		dnsProviders, proxyProvider, err := New(disabledConfig(), Options{})
+		assert.NoError(t, err)
+		assert.Empty(t, dnsProviders)
+		assert.IsType(t, &Traefik{}, proxyProvider)
+	})
+
+	t.Run("invalid provider configuration", func(t *testing.T) {
+		t.Setenv("TRAEFIK_DISABLED", "false")
+
+		_, _, err := New(disabledConfig(), Options{})
+		assert.ErrorContains(t, err, "TRAEFIK_CONFIG_FILE")
+	})
+
+	t.Run("registered proxy provider", func(t *testing.T) {
+		registerStubProxyProvider(t, "stub")
+
+		_, proxyProvider, err := New(disabledConfig(), Options{})
+		assert.NoError(t, err)
+		assert.Equal(t, "stub", proxyProvider.Name())
+	})
+
+	t.Run("more than one proxy provider", func(t *testing.T) {
+		registerStubProxyProvider(t, "stub-a")
+		registerStubProxyProvider(t, "stub-b")
+
+		_, _, err := New(disabledConfig(), Options{})
+		assert.EqualError(t, err, "only one proxy provider can be enabled, but both 'stub-a' and 'stub-b' are")
+	})
+}
+
+func TestRegisterTwice(t *testing.T) {
+	registerStubProxyProvider(t, "stub")
+	assert.Panics(t, func() {
+		registerStubProxyProvider(t, "stub")
+	})
+}
@@ -0,0 +1,300 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+
+	"github.com/deepspace2/plugnpin/pkg/clients/docker"
+	"github.com/deepspace2/plugnpin/pkg/clients/rfc2136"
+	"github.com/deepspace2/plugnpin/pkg/config"
+	"github.com/deepspace2/plugnpin/pkg/metrics"
+)
+
+const (
+	rfc2136OptionsNamespace         = "rfc2136Options"
+	rfc2136OptionsTargetDomainLabel = "plugNPiN.rfc2136Options.targetDomain"
+	rfc2136OptionsTTLLabel          = "plugNPiN.rfc2136Options.ttl"
+)
+
+// Rfc2136Options are the options of a container's 'plugNPiN.rfc2136Options.*'
+// labels.
+type Rfc2136Options struct {
+	TargetDomain string
+	TTL          int
+}
+
+// Rfc2136Config is the configuration of the RFC 2136 provider.
+type Rfc2136Config struct {
+	Disabled      bool   `env:"RFC2136_DISABLED" envDefault:"true"`
+	Server        string `env:"RFC2136_SERVER" secret:"true"`
+	TTL           int    `env:"RFC2136_TTL" envDefault:"300"`
+	TsigAlgorithm string `env:"RFC2136_TSIG_ALGORITHM" secret:"true"`
+	TsigKeyName   string `env:"RFC2136_TSIG_KEY_NAME" secret:"true"`
+	TsigSecret    string `env:"RFC2136_TSIG_SECRET" secret:"true"`
+	Zone          string `env:"RFC2136_ZONE" secret:"true"`
+}
+
+func (c Rfc2136Config) Enabled() bool {
+	return !c.Disabled
+}
+
+func (c Rfc2136Config) Validate() error {
+	for _, field := range []struct {
+		name  string
+		value string
+	}{
+		{"RFC2136_SERVER", c.Server},
+		{"RFC2136_ZONE", c.Zone},
+		{"RFC2136_TSIG_KEY_NAME", c.TsigKeyName},
+		{"RFC2136_TSIG_SECRET", c.TsigSecret},
+	} {
+		if field.value == "" {
+			return fmt.Errorf(`env: %v is required but not set via env var or secret`, field.name)
+		}
+	}
+	if c.TTL < 0 {
+		return errors.New(`env: 'RFC2136_TTL' must be >= 0`)
+	}
+	return nil
+}
+
+func init() {
+	docker.RegisterOptionsParser(rfc2136OptionsNamespace, func(labels map[string]string) (any, error) {
+		ttl, err := docker.ParseTTLLabel(labels, rfc2136OptionsTTLLabel)
+		if err != nil {
+			return nil, err
+		}
+		return &Rfc2136Options{TargetDomain: labels[rfc2136OptionsTargetDomainLabel], TTL: ttl}, nil
+	})
+
+	RegisterDNSProvider(rfc2136.NAME, func(_ *config.Config, options Options) (DNSProvider, error) {
+		var rfc2136Config Rfc2136Config
+		if enabled, err := parseConfig(&rfc2136Config); !enabled || err != nil {
+			return nil, err
+		}
+		client, err := rfc2136.NewClient(rfc2136Config.Server, rfc2136Config.Zone, rfc2136Config.TsigKeyName, rfc2136Config.TsigAlgorithm, rfc2136Config.TsigSecret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create RFC 2136 client: %w", err)
+		}
+		return NewRfc2136(client, rfc2136Config.TTL), nil
+	})
+}
+
+// Rfc2136 manages A, AAAA and CNAME records of an authoritative zone with
+// RFC 2136 dynamic updates, e.g. on BIND or Knot. A records pointing at IPv6
+// addresses are written as AAAA records.
+type Rfc2136 struct {
+	client *rfc2136.Client
+	// ttl is used for records without a TTL of their own
+	ttl int
+
+	// ensuredDomains are the domains whose records were ensured since
+	// PlugNPiN started, see ListRecords
+	ensuredDomains map[string]bool
+	mu             sync.Mutex
+}
+
+func NewRfc2136(client *rfc2136.Client, ttl int) *Rfc2136 {
+	return &Rfc2136{
+		client:         client,
+		ttl:            ttl,
+		ensuredDomains: map[string]bool{},
+	}
+}
+
+func (r *Rfc2136) Name() string {
+	return rfc2136.NAME
+}
+
+func (r *Rfc2136) Capabilities() DNSCapabilities {
+	return DNSCapabilities{CNAME: true, TTL: true}
+}
+
+func (r *Rfc2136) Records(container Container, answer string) []Record {
+	rfc2136Options := containerOptions[Rfc2136Options](container, rfc2136OptionsNamespace)
+	if rfc2136Options.TargetDomain != "" {
+		return CNameRecords(container.URLs, rfc2136Options.TargetDomain, rfc2136Options.TTL)
+	}
+
+	records := ARecords(container.URLs, answer)
+	for i := range records {
+		records[i].TTL = rfc2136Options.TTL
+	}
+	return records
+}
+
+// ListRecords queries the records of the domains ensured since PlugNPiN
+// started, as the zone can't be listed without a zone transfer.
+func (r *Rfc2136) ListRecords() ([]Record, error) {
+	r.mu.Lock()
+	domains := slices.Sorted(maps.Keys(r.ensuredDomains))
+	r.mu.Unlock()
+
+	records := []Record{}
+	for _, domain := range domains {
+		rrs, err := r.client.Query(domain)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up '%v': %w", domain, err)
+		}
+		for _, rr := range rrs {
+			records = append(records, recordFromRR(rr))
+		}
+	}
+	return records, nil
+}
+
+// EnsureRecords replaces the records of each domain that differ from the
+// server's, as read with queries. A domain with records not created by
+// PlugNPiN, i.e. without an owner TXT record, is never changed.
+func (r *Rfc2136) EnsureRecords(ctx context.Context, records []Record) error {
+	var errs []error
+	for _, record := range records {
+		added, updated, err := r.ensureRecord(record)
+		if err != nil {
+			metrics.IncrementApiRequestErrors(rfc2136.NAME, metrics.ADD_DNS_RECORD)
+			errs = append(errs, fmt.Errorf("failed to add record %v: %w", record, err))
+			continue
+		}
+		r.mu.Lock()
+		r.ensuredDomains[record.Domain] = true
+		r.mu.Unlock()
+		if added {
+			metrics.IncrementManagedEntries(rfc2136.NAME, metrics.ADDED, 1)
+		}
+		if updated {
+			metrics.IncrementManagedEntries(rfc2136.NAME, metrics.UPDATED, 1)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Rfc2136) ensureRecord(record Record) (added, updated bool, err error) {
+	if !r.client.InZone(record.Domain) {
+		return false, false, fmt.Errorf("'%v' is not in the zone", record.Domain)
+	}
+	rr, err := r.rrFromRecord(record)
+	if err != nil {
+		return false, false, err
+	}
+
+	existing, err := r.client.Query(record.Domain)
+	if err != nil {
+		return false, false, err
+	}
+	if len(existing) > 0 {
+		owned, err := r.client.Owned(record.Domain)
+		if err != nil {
+			return false, false, err
+		}
+		if !owned {
+			return false, false, fmt.Errorf("'%v' has records not managed by PlugNPiN", record.Domain)
+		}
+	}
+	if len(existing) == 1 && dns.IsDuplicate(existing[0], rr) && existing[0].Header().Ttl == rr.Header().Ttl {
+		return false, false, nil
+	}
+
+	if err := r.client.Replace(record.Domain, rr); err != nil {
+		return false, false, err
+	}
+	return len(existing) == 0, len(existing) > 0, nil
+}
+
+// DeleteRecords deletes the records of the given domains created by PlugNPiN.
+func (r *Rfc2136) DeleteRecords(ctx context.Context, records []Record) error {
+	var errs []error
+	domains := []string{}
+	for _, domain := range Domains(records) {
+		if !r.client.InZone(domain) {
+			errs = append(errs, fmt.Errorf("not deleting '%v', it is not in the zone", domain))
+			continue
+		}
+		existing, err := r.client.Query(domain)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to look up '%v': %w", domain, err))
+			continue
+		}
+		if len(existing) == 0 {
+			continue
+		}
+		owned, err := r.client.Owned(domain)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to look up the owner of '%v': %w", domain, err))
+			continue
+		}
+		if !owned {
+			log.Warn("Not deleting DNS records not managed by PlugNPiN", "provider", rfc2136.NAME, "domain", domain)
+			continue
+		}
+		domains = append(domains, domain)
+	}
+
+	if len(domains) > 0 {
+		if err := r.client.Delete(domains); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete records: %w", err))
+		} else {
+			metrics.IncrementManagedEntries(rfc2136.NAME, metrics.DELETED, len(domains))
+			r.mu.Lock()
+			for _, domain := range domains {
+				delete(r.ensuredDomains, domain)
+			}
+			r.mu.Unlock()
+		}
+	}
+
+	if len(errs) > 0 {
+		metrics.IncrementApiRequestErrors(rfc2136.NAME, metrics.DELETE_DNS_RECORD)
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Rfc2136) rrFromRecord(record Record) (dns.RR, error) {
+	ttl := record.TTL
+	if ttl == 0 {
+		ttl = r.ttl
+	}
+	header := dns.RR_Header{Name: dns.Fqdn(record.Domain), Class: dns.ClassINET, Ttl: uint32(ttl)}
+
+	if record.Type == RECORD_TYPE_CNAME {
+		header.Rrtype = dns.TypeCNAME
+		return &dns.CNAME{Hdr: header, Target: dns.Fqdn(record.Target)}, nil
+	}
+
+	ip := net.ParseIP(record.Target)
+	if ip == nil {
+		return nil, fmt.Errorf("'%v' is not an IP address", record.Target)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		header.Rrtype = dns.TypeA
+		return &dns.A{Hdr: header, A: ip4}, nil
+	}
+	header.Rrtype = dns.TypeAAAA
+	return &dns.AAAA{Hdr: header, AAAA: ip}, nil
+}
+
+func recordFromRR(rr dns.RR) Record {
+	record := Record{
+		Domain: strings.TrimSuffix(rr.Header().Name, "."),
+		TTL:    int(rr.Header().Ttl),
+	}
+	switch rr := rr.(type) {
+	case *dns.A:
+		record.Type = RECORD_TYPE_A
+		record.Target = rr.A.String()
+	case *dns.AAAA:
+		record.Type = RECORD_TYPE_A
+		record.Target = rr.AAAA.String()
+	case *dns.CNAME:
+		record.Type = RECORD_TYPE_CNAME
+		record.Target = strings.TrimSuffix(rr.Target, ".")
+	}
+	return record
+}
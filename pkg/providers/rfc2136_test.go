@@ -0,0 +1,232 @@
+//go:build unit
+
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deepspace2/plugnpin/pkg/clients/docker"
+	"github.com/deepspace2/plugnpin/pkg/clients/rfc2136"
+	"github.com/deepspace2/plugnpin/pkg/errors"
+)
+
+// fakeZone is an in-process authoritative server that answers queries and
+// applies updates, without TSIG.
+type fakeZone struct {
+	mu      sync.Mutex
+	records map[string]dns.RR
+	updates int
+}
+
+func (f *fakeZone) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	if r.Opcode == dns.OpcodeUpdate {
+		f.updates++
+		for _, rr := range r.Ns {
+			name := strings.ToLower(rr.Header().Name)
+			if rr.Header().Class == dns.ClassANY {
+				if existing, exists := f.records[name]; exists && existing.Header().Rrtype == rr.Header().Rrtype {
+					delete(f.records, name)
+				}
+			} else {
+				f.records[name] = rr
+			}
+		}
+	} else if rr, exists := f.records[strings.ToLower(r.Question[0].Name)]; exists && rr.Header().Rrtype == r.Question[0].Qtype {
+		m.Answer = append(m.Answer, rr)
+	}
+	_ = w.WriteMsg(m)
+}
+
+func newRfc2136(t *testing.T) (*Rfc2136, *fakeZone) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	zone := &fakeZone{records: map[string]dns.RR{}}
+	started := make(chan struct{})
+	server := &dns.Server{
+		Listener:          listener,
+		Handler:           zone,
+		NotifyStartedFunc: func() { close(started) },
+		MsgAcceptFunc:     func(dns.Header) dns.MsgAcceptAction { return dns.MsgAccept },
+	}
+	go func() { _ = server.ActivateAndServe() }()
+	<-started
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	client, err := rfc2136.NewClient(listener.Addr().String(), "example.com", "", "", "")
+	require.NoError(t, err)
+	return NewRfc2136(client, 300), zone
+}
+
+func TestRfc2136Config(t *testing.T) {
+	t.Setenv("RFC2136_DISABLED", "false")
+	t.Setenv("RFC2136_SERVER", "ns1.example.com")
+	t.Setenv("RFC2136_ZONE", "example.com")
+	t.Setenv("RFC2136_TSIG_KEY_NAME", "plugnpin")
+	t.Setenv("RFC2136_TSIG_SECRET", "c2VjcmV0")
+
+	var rfc2136Config Rfc2136Config
+	enabled, err := parseConfig(&rfc2136Config)
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+	assert.Equal(t, Rfc2136Config{Server: "ns1.example.com", TTL: 300, TsigKeyName: "plugnpin", TsigSecret: "c2VjcmV0", Zone: "example.com"}, rfc2136Config)
+
+	t.Setenv("RFC2136_TSIG_SECRET", "")
+	_, err = parseConfig(&Rfc2136Config{})
+	assert.ErrorContains(t, err, "RFC2136_TSIG_SECRET")
+
+	t.Setenv("RFC2136_DISABLED", "true")
+	enabled, err = parseConfig(&Rfc2136Config{})
+	assert.NoError(t, err)
+	assert.False(t, enabled)
+}
+
+func TestRfc2136Records(t *testing.T) {
+	r := NewRfc2136(nil, 300)
+	container := Container{
+		URLs:    []string{"app.example.com"},
+		Options: &docker.ClientOptions{Providers: map[string]any{rfc2136OptionsNamespace: &Rfc2136Options{TTL: 60}}},
+	}
+	assert.Equal(t, []Record{{Domain: "app.example.com", Type: RECORD_TYPE_A, Target: "10.0.0.1", TTL: 60}}, r.Records(container, "10.0.0.1"))
+
+	_, _, _, options, err := docker.GetValuesFromLabels(map[string]string{
+		docker.IpLabel:                  "192.168.1.10:8080",
+		docker.UrlLabel:                 "app.example.com",
+		rfc2136OptionsTargetDomainLabel: "proxy.example.com",
+		rfc2136OptionsTTLLabel:          "60",
+	})
+	require.NoError(t, err)
+	container.Options = options
+	assert.Equal(t, CNameRecords([]string{"app.example.com"}, "proxy.example.com", 60), r.Records(container, "10.0.0.1"))
+
+	container.Options = &docker.ClientOptions{}
+	assert.Equal(t, []Record{{Domain: "app.example.com", Type: RECORD_TYPE_A, Target: "10.0.0.1"}}, r.Records(container, "10.0.0.1"))
+
+	for _, ttl := range []string{"1h", "-5"} {
+		_, _, _, _, err = docker.GetValuesFromLabels(map[string]string{
+			docker.IpLabel:         "192.168.1.10:8080",
+			docker.UrlLabel:        "app.example.com",
+			rfc2136OptionsTTLLabel: ttl,
+		})
+		assert.Equal(t, &errors.InvalidLabelValueError{Msg: fmt.Sprintf("value of '%v' label must be a non-negative integer, got '%v'", rfc2136OptionsTTLLabel, ttl)}, err)
+	}
+}
+
+func TestRfc2136RecordConversion(t *testing.T) {
+	r := NewRfc2136(nil, 300)
+
+	for _, tc := range []struct {
+		record   Record
+		expected string
+	}{
+		{Record{Domain: "app.example.com", Type: RECORD_TYPE_A, Target: "10.0.0.1"}, "app.example.com.\t300\tIN\tA\t10.0.0.1"},
+		{Record{Domain: "app.example.com", Type: RECORD_TYPE_A, Target: "fd00::1", TTL: 60}, "app.example.com.\t60\tIN\tAAAA\tfd00::1"},
+		{Record{Domain: "app.example.com", Type: RECORD_TYPE_CNAME, Target: "proxy.example.com", TTL: 60}, "app.example.com.\t60\tIN\tCNAME\tproxy.example.com."},
+	} {
+		rr, err := r.rrFromRecord(tc.record)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, rr.String())
+
+		expectedRecord := tc.record
+		expectedRecord.TTL = int(rr.Header().Ttl)
+		assert.Equal(t, expectedRecord, recordFromRR(rr))
+	}
+
+	_, err := r.rrFromRecord(Record{Domain: "app.example.com", Type: RECORD_TYPE_A, Target: "not-an-ip"})
+	assert.Error(t, err)
+}
+
+func TestRfc2136EnsureAndDeleteRecords(t *testing.T) {
+	r, zone := newRfc2136(t)
+	ctx := context.Background()
+
+	record := Record{Domain: "app.example.com", Type: RECORD_TYPE_A, Target: "10.0.0.1"}
+	assert.NoError(t, r.EnsureRecords(ctx, []Record{record}))
+	assert.Equal(t, "app.example.com.\t300\tIN\tA\t10.0.0.1", zone.records["app.example.com."].String())
+	assert.Equal(t, "_plugnpin.app.example.com.\t300\tIN\tTXT\t\"heritage=plugnpin\"", zone.records["_plugnpin.app.example.com."].String())
+	assert.Equal(t, 1, zone.updates)
+
+	t.Run("unchanged record is not updated", func(t *testing.T) {
+		assert.NoError(t, r.EnsureRecords(ctx, []Record{record}))
+		assert.Equal(t, 1, zone.updates)
+	})
+
+	t.Run("A record replaced by CNAME record", func(t *testing.T) {
+		record := Record{Domain: "app.example.com", Type: RECORD_TYPE_CNAME, Target: "proxy.example.com"}
+		assert.NoError(t, r.EnsureRecords(ctx, []Record{record}))
+		assert.Equal(t, "app.example.com.\t300\tIN\tCNAME\tproxy.example.com.", zone.records["app.example.com."].String())
+	})
+
+	t.Run("domain outside the zone", func(t *testing.T) {
+		err := r.EnsureRecords(ctx, []Record{{Domain: "app.example.org", Type: RECORD_TYPE_A, Target: "10.0.0.1"}})
+		assert.ErrorContains(t, err, "not in the zone")
+	})
+
+	t.Run("records not managed by PlugNPiN are left alone", func(t *testing.T) {
+		manualRR, err := dns.NewRR("manual.example.com. 3600 IN A 10.0.0.9")
+		require.NoError(t, err)
+		zone.mu.Lock()
+		zone.records["manual.example.com."] = manualRR
+		zone.mu.Unlock()
+		updates := zone.updates
+
+		err = r.EnsureRecords(ctx, []Record{{Domain: "manual.example.com", Type: RECORD_TYPE_A, Target: "10.0.0.1"}})
+		assert.ErrorContains(t, err, "not managed by PlugNPiN")
+		assert.NoError(t, r.DeleteRecords(ctx, []Record{{Domain: "manual.example.com", Type: RECORD_TYPE_A}}))
+		assert.Equal(t, updates, zone.updates)
+		assert.Equal(t, manualRR, zone.records["manual.example.com."])
+
+		zone.mu.Lock()
+		delete(zone.records, "manual.example.com.")
+		zone.mu.Unlock()
+	})
+
+	updates := zone.updates
+	assert.NoError(t, r.DeleteRecords(ctx, []Record{record, {Domain: "missing.example.com", Type: RECORD_TYPE_A}}))
+	assert.Empty(t, zone.records)
+	assert.Equal(t, updates+1, zone.updates)
+
+	// Nothing left to delete
+	assert.NoError(t, r.DeleteRecords(ctx, []Record{record}))
+	assert.Equal(t, updates+1, zone.updates)
+}
+
+func TestRfc2136ListRecords(t *testing.T) {
+	r, zone := newRfc2136(t)
+	ctx := context.Background()
+
+	manualRR, err := dns.NewRR("manual.example.com. 3600 IN A 10.0.0.9")
+	require.NoError(t, err)
+	zone.records["manual.example.com."] = manualRR
+
+	records := []Record{
+		{Domain: "app.example.com", Type: RECORD_TYPE_A, Target: "10.0.0.1", TTL: 300},
+		{Domain: "web.example.com", Type: RECORD_TYPE_CNAME, Target: "proxy.example.com", TTL: 60},
+	}
+	require.NoError(t, r.EnsureRecords(ctx, records))
+
+	// Only the records ensured since PlugNPiN started are listed
+	listed, err := r.ListRecords()
+	assert.NoError(t, err)
+	assert.Equal(t, records, listed)
+
+	require.NoError(t, r.DeleteRecords(ctx, records[:1]))
+	listed, err = r.ListRecords()
+	assert.NoError(t, err)
+	assert.Equal(t, records[1:], listed)
+}
@@ -0,0 +1,294 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/deepspace2/plugnpin/pkg/clients/docker"
+	"github.com/deepspace2/plugnpin/pkg/clients/technitium"
+	"github.com/deepspace2/plugnpin/pkg/config"
+	"github.com/deepspace2/plugnpin/pkg/metrics"
+)
+
+const (
+	technitiumOptionsNamespace         = "technitiumOptions"
+	technitiumOptionsTargetDomainLabel = "plugNPiN.technitiumOptions.targetDomain"
+	technitiumOptionsTTLLabel          = "plugNPiN.technitiumOptions.ttl"
+)
+
+// TechnitiumOptions are the options of a container's
+// 'plugNPiN.technitiumOptions.*' labels.
+type TechnitiumOptions struct {
+	TargetDomain string
+	TTL          int
+}
+
+// TechnitiumConfig is the configuration of the Technitium provider.
+type TechnitiumConfig struct {
+	Disabled bool   `env:"TECHNITIUM_DISABLED" envDefault:"true"`
+	Host     string `env:"TECHNITIUM_HOST" secret:"true"`
+	TTL      int    `env:"TECHNITIUM_TTL"`
+	Token    string `env:"TECHNITIUM_TOKEN" secret:"true"`
+	Zone     string `env:"TECHNITIUM_ZONE" secret:"true"`
+}
+
+func (c TechnitiumConfig) Enabled() bool {
+	return !c.Disabled
+}
+
+func (c TechnitiumConfig) Validate() error {
+	for _, field := range []struct {
+		name  string
+		value string
+	}{
+		{"TECHNITIUM_HOST", c.Host},
+		{"TECHNITIUM_TOKEN", c.Token},
+		{"TECHNITIUM_ZONE", c.Zone},
+	} {
+		if field.value == "" {
+			return fmt.Errorf(`env: %v is required but not set via env var or secret`, field.name)
+		}
+	}
+	if c.TTL < 0 {
+		return errors.New(`env: 'TECHNITIUM_TTL' must be >= 0`)
+	}
+	return nil
+}
+
+func init() {
+	docker.RegisterOptionsParser(technitiumOptionsNamespace, func(labels map[string]string) (any, error) {
+		ttl, err := docker.ParseTTLLabel(labels, technitiumOptionsTTLLabel)
+		if err != nil {
+			return nil, err
+		}
+		return &TechnitiumOptions{TargetDomain: labels[technitiumOptionsTargetDomainLabel], TTL: ttl}, nil
+	})
+
+	RegisterDNSProvider(technitium.NAME, func(_ *config.Config, options Options) (DNSProvider, error) {
+		var technitiumConfig TechnitiumConfig
+		if enabled, err := parseConfig(&technitiumConfig); !enabled || err != nil {
+			return nil, err
+		}
+		return NewTechnitium(technitium.NewClient(technitiumConfig.Host, technitiumConfig.Token, technitiumConfig.Zone), technitiumConfig.TTL), nil
+	})
+}
+
+// Technitium manages A, AAAA and CNAME records of a zone on a Technitium DNS
+// Server. Its records are marked with technitium.OWNER_COMMENT, and records
+// without it are left alone.
+type Technitium struct {
+	client *technitium.Client
+	// ttl is used for records without a TTL of their own, 0 uses the server's
+	// default
+	ttl int
+}
+
+func NewTechnitium(client *technitium.Client, ttl int) *Technitium {
+	return &Technitium{
+		client: client,
+		ttl:    ttl,
+	}
+}
+
+func (t *Technitium) Name() string {
+	return technitium.NAME
+}
+
+func (t *Technitium) Capabilities() DNSCapabilities {
+	return DNSCapabilities{CNAME: true, TTL: true}
+}
+
+func (t *Technitium) Records(container Container, answer string) []Record {
+	technitiumOptions := containerOptions[TechnitiumOptions](container, technitiumOptionsNamespace)
+	if technitiumOptions.TargetDomain != "" {
+		return CNameRecords(container.URLs, technitiumOptions.TargetDomain, technitiumOptions.TTL)
+	}
+
+	records := ARecords(container.URLs, answer)
+	for i := range records {
+		records[i].TTL = technitiumOptions.TTL
+	}
+	return records
+}
+
+// ListRecords returns the records of the zone created by PlugNPiN.
+func (t *Technitium) ListRecords() ([]Record, error) {
+	technitiumRecords, err := t.client.GetRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	records := []Record{}
+	for _, technitiumRecord := range technitiumRecords {
+		if technitiumRecord.Owned() {
+			records = append(records, recordFromTechnitium(technitiumRecord))
+		}
+	}
+	return records, nil
+}
+
+// zoneRecords lists the records of the zone once, keyed by lowercase name.
+func (t *Technitium) zoneRecords() (map[string][]technitium.Record, error) {
+	technitiumRecords, err := t.client.GetRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	recordsByName := map[string][]technitium.Record{}
+	for _, technitiumRecord := range technitiumRecords {
+		name := strings.ToLower(technitiumRecord.Name)
+		recordsByName[name] = append(recordsByName[name], technitiumRecord)
+	}
+	return recordsByName, nil
+}
+
+func (t *Technitium) EnsureRecords(ctx context.Context, records []Record) error {
+	recordsByName, err := t.zoneRecords()
+	if err != nil {
+		metrics.IncrementApiRequestErrors(technitium.NAME, metrics.ADD_DNS_RECORD)
+		return fmt.Errorf("failed to list records: %w", err)
+	}
+
+	var errs []error
+	for _, record := range records {
+		added, updated, err := t.ensureRecord(record, recordsByName[strings.ToLower(record.Domain)])
+		if err != nil {
+			metrics.IncrementApiRequestErrors(technitium.NAME, metrics.ADD_DNS_RECORD)
+			errs = append(errs, fmt.Errorf("failed to add record %v: %w", record, err))
+			continue
+		}
+		if added {
+			metrics.IncrementManagedEntries(technitium.NAME, metrics.ADDED, 1)
+		}
+		if updated {
+			metrics.IncrementManagedEntries(technitium.NAME, metrics.UPDATED, 1)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ensureRecord replaces the existing records of a domain unless they already
+// match record. A domain with records not created by PlugNPiN is never
+// changed.
+func (t *Technitium) ensureRecord(record Record, existing []technitium.Record) (added, updated bool, err error) {
+	if !t.client.InZone(record.Domain) {
+		return false, false, fmt.Errorf("'%v' is not in the zone", record.Domain)
+	}
+	technitiumRecord, err := t.technitiumFromRecord(record)
+	if err != nil {
+		return false, false, err
+	}
+
+	for _, existingRecord := range existing {
+		if !existingRecord.Owned() {
+			return false, false, fmt.Errorf("'%v' has a %v record not managed by PlugNPiN", record.Domain, existingRecord.Type)
+		}
+	}
+	if len(existing) == 1 && sameTechnitiumRecord(existing[0], technitiumRecord) {
+		return false, false, nil
+	}
+
+	// Records of the same type are overwritten when adding
+	for _, existingRecord := range existing {
+		if existingRecord.Type == technitiumRecord.Type {
+			continue
+		}
+		if err := t.client.DeleteRecord(existingRecord); err != nil {
+			return false, false, err
+		}
+	}
+	if err := t.client.AddRecord(technitiumRecord); err != nil {
+		return false, false, err
+	}
+	return len(existing) == 0, len(existing) > 0, nil
+}
+
+// DeleteRecords deletes the records of the given domains created by PlugNPiN.
+func (t *Technitium) DeleteRecords(ctx context.Context, records []Record) error {
+	recordsByName, err := t.zoneRecords()
+	if err != nil {
+		metrics.IncrementApiRequestErrors(technitium.NAME, metrics.DELETE_DNS_RECORD)
+		return fmt.Errorf("failed to list records: %w", err)
+	}
+
+	var errs []error
+	numOfDeletedEntries := 0
+	for _, domain := range Domains(records) {
+		for _, existingRecord := range recordsByName[strings.ToLower(domain)] {
+			if !existingRecord.Owned() {
+				log.Warn("Not deleting DNS record not managed by PlugNPiN", "provider", technitium.NAME, "domain", domain, "type", existingRecord.Type)
+				continue
+			}
+			if err := t.client.DeleteRecord(existingRecord); err != nil {
+				errs = append(errs, fmt.Errorf("failed to delete %v record of '%v': %w", existingRecord.Type, domain, err))
+				continue
+			}
+			numOfDeletedEntries += 1
+		}
+	}
+	metrics.IncrementManagedEntries(technitium.NAME, metrics.DELETED, numOfDeletedEntries)
+
+	if len(errs) > 0 {
+		metrics.IncrementApiRequestErrors(technitium.NAME, metrics.DELETE_DNS_RECORD)
+	}
+	return errors.Join(errs...)
+}
+
+func (t *Technitium) technitiumFromRecord(record Record) (technitium.Record, error) {
+	technitiumRecord := technitium.Record{
+		Name: record.Domain,
+		TTL:  record.TTL,
+	}
+	if technitiumRecord.TTL == 0 {
+		technitiumRecord.TTL = t.ttl
+	}
+
+	if record.Type == RECORD_TYPE_CNAME {
+		technitiumRecord.Type = technitium.RECORD_TYPE_CNAME
+		technitiumRecord.RData.CName = record.Target
+		return technitiumRecord, nil
+	}
+
+	ip := net.ParseIP(record.Target)
+	if ip == nil {
+		return technitium.Record{}, fmt.Errorf("'%v' is not an IP address", record.Target)
+	}
+	technitiumRecord.Type = technitium.RECORD_TYPE_AAAA
+	if ip.To4() != nil {
+		technitiumRecord.Type = technitium.RECORD_TYPE_A
+	}
+	technitiumRecord.RData.IPAddress = ip.String()
+	return technitiumRecord, nil
+}
+
+// sameTechnitiumRecord compares an existing record with a wanted one, whose
+// TTL of 0 matches any TTL.
+func sameTechnitiumRecord(existing, wanted technitium.Record) bool {
+	if existing.Type != wanted.Type || existing.Disabled {
+		return false
+	}
+	if wanted.TTL != 0 && existing.TTL != wanted.TTL {
+		return false
+	}
+	if existing.Type == technitium.RECORD_TYPE_CNAME {
+		return strings.EqualFold(existing.RData.CName, wanted.RData.CName)
+	}
+	return net.ParseIP(existing.RData.IPAddress).Equal(net.ParseIP(wanted.RData.IPAddress))
+}
+
+func recordFromTechnitium(technitiumRecord technitium.Record) Record {
+	record := Record{
+		Domain: technitiumRecord.Name,
+		Target: technitiumRecord.Target(),
+		TTL:    technitiumRecord.TTL,
+	}
+	if technitiumRecord.Type == technitium.RECORD_TYPE_CNAME {
+		record.Type = RECORD_TYPE_CNAME
+	} else {
+		record.Type = RECORD_TYPE_A
+	}
+	return record
+}
@@ -0,0 +1,182 @@
+//go:build unit
+
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deepspace2/plugnpin/pkg/clients/docker"
+	"github.com/deepspace2/plugnpin/pkg/clients/technitium"
+	"github.com/deepspace2/plugnpin/pkg/errors"
+)
+
+// fakeTechnitium keeps the records of a zone in memory and counts the
+// changes made to them.
+type fakeTechnitium struct {
+	mu      sync.Mutex
+	records []technitium.Record
+	changes int
+}
+
+func (f *fakeTechnitium) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_ = r.ParseForm()
+	ttl, _ := strconv.Atoi(r.PostForm.Get("ttl"))
+	record := technitium.Record{
+		Comments: r.PostForm.Get("comments"),
+		Name:     r.PostForm.Get("domain"),
+		RData:    technitium.RData{CName: r.PostForm.Get("cname"), IPAddress: r.PostForm.Get("ipAddress")},
+		TTL:      ttl,
+		Type:     r.PostForm.Get("type"),
+	}
+
+	resp := map[string]any{"status": "ok", "response": map[string]any{}}
+	switch r.URL.Path {
+	case "/api/zones/records/get":
+		resp["response"] = map[string]any{"records": f.records}
+	case "/api/zones/records/add":
+		f.records = slices.DeleteFunc(f.records, func(existing technitium.Record) bool {
+			return existing.Name == record.Name && existing.Type == record.Type
+		})
+		f.records = append(f.records, record)
+		f.changes++
+	case "/api/zones/records/delete":
+		f.records = slices.DeleteFunc(f.records, func(existing technitium.Record) bool {
+			return existing.Name == record.Name && existing.Type == record.Type && existing.Target() == record.Target()
+		})
+		f.changes++
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func newTechnitium(t *testing.T, records ...technitium.Record) (*Technitium, *fakeTechnitium) {
+	t.Helper()
+	fake := &fakeTechnitium{records: records}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client := technitium.NewClient(server.URL, "test-token", "example.com")
+	client.Client = *server.Client()
+	return NewTechnitium(client, 300), fake
+}
+
+func TestTechnitiumConfig(t *testing.T) {
+	t.Setenv("TECHNITIUM_DISABLED", "false")
+	t.Setenv("TECHNITIUM_HOST", "http://technitium.example.com:5380")
+	t.Setenv("TECHNITIUM_TOKEN", "token")
+	t.Setenv("TECHNITIUM_TTL", "60")
+	t.Setenv("TECHNITIUM_ZONE", "example.com")
+
+	var technitiumConfig TechnitiumConfig
+	enabled, err := parseConfig(&technitiumConfig)
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+	assert.Equal(t, TechnitiumConfig{Host: "http://technitium.example.com:5380", TTL: 60, Token: "token", Zone: "example.com"}, technitiumConfig)
+
+	t.Setenv("TECHNITIUM_TTL", "-1")
+	_, err = parseConfig(&TechnitiumConfig{})
+	assert.ErrorContains(t, err, "TECHNITIUM_TTL")
+
+	t.Setenv("TECHNITIUM_TOKEN", "")
+	_, err = parseConfig(&TechnitiumConfig{})
+	assert.ErrorContains(t, err, "TECHNITIUM_TOKEN")
+
+	t.Setenv("TECHNITIUM_DISABLED", "true")
+	enabled, err = parseConfig(&TechnitiumConfig{})
+	assert.NoError(t, err)
+	assert.False(t, enabled)
+}
+
+func TestTechnitiumRecords(t *testing.T) {
+	tp := NewTechnitium(nil, 300)
+	container := Container{
+		URLs:    []string{"app.example.com"},
+		Options: &docker.ClientOptions{Providers: map[string]any{technitiumOptionsNamespace: &TechnitiumOptions{TTL: 60}}},
+	}
+	assert.Equal(t, []Record{{Domain: "app.example.com", Type: RECORD_TYPE_A, Target: "10.0.0.1", TTL: 60}}, tp.Records(container, "10.0.0.1"))
+
+	_, _, _, options, err := docker.GetValuesFromLabels(map[string]string{
+		docker.IpLabel:                     "192.168.1.10:8080",
+		docker.UrlLabel:                    "app.example.com",
+		technitiumOptionsTargetDomainLabel: "proxy.example.com",
+		technitiumOptionsTTLLabel:          "60",
+	})
+	require.NoError(t, err)
+	container.Options = options
+	assert.Equal(t, CNameRecords([]string{"app.example.com"}, "proxy.example.com", 60), tp.Records(container, "10.0.0.1"))
+
+	container.Options = &docker.ClientOptions{}
+	assert.Equal(t, []Record{{Domain: "app.example.com", Type: RECORD_TYPE_A, Target: "10.0.0.1"}}, tp.Records(container, "10.0.0.1"))
+
+	for _, ttl := range []string{"1h", "-5"} {
+		_, _, _, _, err = docker.GetValuesFromLabels(map[string]string{
+			docker.IpLabel:            "192.168.1.10:8080",
+			docker.UrlLabel:           "app.example.com",
+			technitiumOptionsTTLLabel: ttl,
+		})
+		assert.Equal(t, &errors.InvalidLabelValueError{Msg: fmt.Sprintf("value of '%v' label must be a non-negative integer, got '%v'", technitiumOptionsTTLLabel, ttl)}, err)
+	}
+}
+
+func TestTechnitiumEnsureAndDeleteRecords(t *testing.T) {
+	foreignRecord := technitium.Record{Name: "manual.example.com", Type: technitium.RECORD_TYPE_A, TTL: 3600, RData: technitium.RData{IPAddress: "10.0.0.9"}}
+	tp, fake := newTechnitium(t, foreignRecord)
+	ctx := context.Background()
+
+	record := Record{Domain: "app.example.com", Type: RECORD_TYPE_A, Target: "10.0.0.1"}
+	require.NoError(t, tp.EnsureRecords(ctx, []Record{record}))
+	assert.Equal(t, 1, fake.changes)
+
+	records, err := tp.ListRecords()
+	require.NoError(t, err)
+	assert.Equal(t, []Record{{Domain: "app.example.com", Type: RECORD_TYPE_A, Target: "10.0.0.1", TTL: 300}}, records)
+
+	t.Run("unchanged record is not updated", func(t *testing.T) {
+		assert.NoError(t, tp.EnsureRecords(ctx, []Record{record}))
+		assert.Equal(t, 1, fake.changes)
+	})
+
+	t.Run("A record replaced by CNAME record", func(t *testing.T) {
+		record := Record{Domain: "app.example.com", Type: RECORD_TYPE_CNAME, Target: "proxy.example.com", TTL: 60}
+		assert.NoError(t, tp.EnsureRecords(ctx, []Record{record}))
+
+		records, err := tp.ListRecords()
+		require.NoError(t, err)
+		assert.Equal(t, []Record{record}, records)
+	})
+
+	t.Run("IPv6 target is written as AAAA record", func(t *testing.T) {
+		assert.NoError(t, tp.EnsureRecords(ctx, []Record{{Domain: "v6.example.com", Type: RECORD_TYPE_A, Target: "fd00::1"}}))
+		assert.Equal(t, technitium.RECORD_TYPE_AAAA, fake.records[len(fake.records)-1].Type)
+	})
+
+	t.Run("records not managed by PlugNPiN are left alone", func(t *testing.T) {
+		changes := fake.changes
+		err := tp.EnsureRecords(ctx, []Record{{Domain: "manual.example.com", Type: RECORD_TYPE_A, Target: "10.0.0.1"}})
+		assert.ErrorContains(t, err, "not managed by PlugNPiN")
+		assert.NoError(t, tp.DeleteRecords(ctx, []Record{{Domain: "manual.example.com", Type: RECORD_TYPE_A}}))
+		assert.Equal(t, changes, fake.changes)
+		assert.Contains(t, fake.records, foreignRecord)
+	})
+
+	t.Run("domain outside the zone", func(t *testing.T) {
+		err := tp.EnsureRecords(ctx, []Record{{Domain: "app.example.org", Type: RECORD_TYPE_A, Target: "10.0.0.1"}})
+		assert.ErrorContains(t, err, "not in the zone")
+	})
+
+	assert.NoError(t, tp.DeleteRecords(ctx, []Record{record, {Domain: "v6.example.com", Type: RECORD_TYPE_A}}))
+	assert.Equal(t, []technitium.Record{foreignRecord}, fake.records)
+}
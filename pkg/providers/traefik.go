@@ -0,0 +1,358 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/deepspace2/plugnpin/pkg/clients/npm"
+	"github.com/deepspace2/plugnpin/pkg/clients/traefik"
+	"github.com/deepspace2/plugnpin/pkg/config"
+	"github.com/deepspace2/plugnpin/pkg/metrics"
+)
+
+const (
+	traefikNamePrefix     = "plugnpin-"
+	traefikHTTPSuffix     = "-http"
+	traefikHstsSuffix     = "-hsts"
+	traefikRedirectSuffix = "-redirect"
+	traefikHstsSeconds    = 63072000
+)
+
+var (
+	traefikHostRule     = regexp.MustCompile("Host\\(`([^`]+)`\\)")
+	traefikNameReplacer = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+)
+
+// TraefikConfig is the configuration of the Traefik provider.
+type TraefikConfig struct {
+	CertResolver string   `env:"TRAEFIK_CERT_RESOLVER"`
+	ConfigFile   string   `env:"TRAEFIK_CONFIG_FILE"`
+	Disabled     bool     `env:"TRAEFIK_DISABLED" envDefault:"true"`
+	EntryPoints  []string `env:"TRAEFIK_ENTRYPOINTS"`
+	IP           string   `env:"TRAEFIK_IP"`
+}
+
+func (c TraefikConfig) Enabled() bool {
+	return !c.Disabled
+}
+
+func (c TraefikConfig) Validate() error {
+	if c.ConfigFile == "" {
+		return errors.New(`env: TRAEFIK_CONFIG_FILE is required but not set via env var or secret`)
+	}
+	if net.ParseIP(c.IP) == nil {
+		return fmt.Errorf(`env: 'TRAEFIK_IP' must be an IP address, got '%v'`, c.IP)
+	}
+	return nil
+}
+
+func init() {
+	RegisterProxyProvider(traefik.NAME, func(_ *config.Config, options Options) (ProxyProvider, error) {
+		var traefikConfig TraefikConfig
+		if enabled, err := parseConfig(&traefikConfig); !enabled || err != nil {
+			return nil, err
+		}
+		return NewTraefik(traefik.NewClient(traefikConfig.ConfigFile), TraefikOptions{
+			CertResolver: traefikConfig.CertResolver,
+			EntryPoints:  traefikConfig.EntryPoints,
+			IP:           traefikConfig.IP,
+		})
+	})
+}
+
+type TraefikOptions struct {
+	// CertResolver is the certificate resolver of routers whose container
+	// sets 'plugNPiN.npmOptions.certificateName=auto'
+	CertResolver string
+	// EntryPoints of the routers, all entry points if empty
+	EntryPoints []string
+	// IP is the address of Traefik the DNS records point at
+	IP string
+}
+
+// Traefik writes the routers, middlewares and services of all containers to a
+// dynamic configuration file watched by Traefik's file provider. Containers
+// get a router and a service named after their first url, the file is owned by
+// PlugNPiN as a whole.
+type Traefik struct {
+	client  *traefik.Client
+	options TraefikOptions
+
+	mu     sync.Mutex
+	config *traefik.Config
+	// syncing defers writing the file to the end of a full sync
+	syncing bool
+}
+
+// NewTraefik returns a provider that starts off the routes already in the
+// file, so routes of containers removed while PlugNPiN was down can still be
+// deleted.
+func NewTraefik(client *traefik.Client, options TraefikOptions) (*Traefik, error) {
+	config, err := client.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Traefik configuration: %w", err)
+	}
+	return &Traefik{
+		client:  client,
+		options: options,
+		config:  config,
+	}, nil
+}
+
+func (t *Traefik) Name() string {
+	return traefik.NAME
+}
+
+func (t *Traefik) Capabilities() ProxyCapabilities {
+	return ProxyCapabilities{}
+}
+
+func (t *Traefik) Address(container Container) (string, error) {
+	return t.options.IP, nil
+}
+
+func (t *Traefik) ListRoutes() ([]Route, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	routes := []Route{}
+	for _, name := range slices.Sorted(maps.Keys(t.config.HTTP.Services)) {
+		route, err := t.route(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse service '%v': %w", name, err)
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+func (t *Traefik) EnsureRoute(ctx context.Context, route Route) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	name := traefikName(route.Domains[0])
+	_, exists := t.config.HTTP.Services[name]
+	before := t.entries(name)
+	t.deleteEntries(name)
+	t.addEntries(name, route)
+	if reflect.DeepEqual(before, t.entries(name)) {
+		return nil
+	}
+
+	if err := t.write(); err != nil {
+		return err
+	}
+	if exists {
+		metrics.IncrementManagedEntries(traefik.NAME, metrics.UPDATED, 1)
+	} else {
+		metrics.IncrementManagedEntries(traefik.NAME, metrics.ADDED, 1)
+	}
+	return nil
+}
+
+func (t *Traefik) DeleteRoute(ctx context.Context, route Route) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	name := traefikName(route.Domains[0])
+	if _, exists := t.config.HTTP.Services[name]; !exists {
+		return nil
+	}
+	t.deleteEntries(name)
+
+	if err := t.write(); err != nil {
+		return err
+	}
+	metrics.IncrementManagedEntries(traefik.NAME, metrics.DELETED, 1)
+	return nil
+}
+
+// BeginSync makes a full sync write the file once, at its end.
+func (t *Traefik) BeginSync() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.syncing = true
+}
+
+func (t *Traefik) EndSync() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.syncing = false
+	if err := t.write(); err != nil {
+		log.Error("Failed to write Traefik configuration", "error", err)
+	}
+}
+
+func (t *Traefik) write() error {
+	if t.syncing {
+		return nil
+	}
+	if _, err := t.client.Write(t.config); err != nil {
+		metrics.IncrementApiRequestErrors(traefik.NAME, metrics.WRITE_CONFIG)
+		return fmt.Errorf("failed to write Traefik configuration: %w", err)
+	}
+	return nil
+}
+
+// traefikName returns the name of the router and service of domain.
+func traefikName(domain string) string {
+	return traefikNamePrefix + strings.Trim(traefikNameReplacer.ReplaceAllString(domain, "-"), "-")
+}
+
+// traefikEntries are the routers, middlewares and service of one route.
+type traefikEntries struct {
+	middlewares map[string]traefik.Middleware
+	routers     map[string]traefik.Router
+	service     *traefik.Service
+}
+
+func (t *Traefik) entries(name string) traefikEntries {
+	entries := traefikEntries{
+		middlewares: map[string]traefik.Middleware{},
+		routers:     map[string]traefik.Router{},
+	}
+	for _, middlewareName := range []string{name + traefikHstsSuffix, name + traefikRedirectSuffix} {
+		if middleware, exists := t.config.HTTP.Middlewares[middlewareName]; exists {
+			entries.middlewares[middlewareName] = middleware
+		}
+	}
+	for _, routerName := range []string{name, name + traefikHTTPSuffix} {
+		if router, exists := t.config.HTTP.Routers[routerName]; exists {
+			entries.routers[routerName] = router
+		}
+	}
+	if service, exists := t.config.HTTP.Services[name]; exists {
+		entries.service = &service
+	}
+	return entries
+}
+
+func (t *Traefik) deleteEntries(name string) {
+	delete(t.config.HTTP.Middlewares, name+traefikHstsSuffix)
+	delete(t.config.HTTP.Middlewares, name+traefikRedirectSuffix)
+	delete(t.config.HTTP.Routers, name)
+	delete(t.config.HTTP.Routers, name+traefikHTTPSuffix)
+	delete(t.config.HTTP.Services, name)
+}
+
+// addEntries adds a router and service for route. Containers with a
+// certificate get a TLS router, with HSTS if enabled, and a plain HTTP router
+// that redirects to HTTPS if SSL is forced or otherwise serves the container
+// too.
+func (t *Traefik) addEntries(name string, route Route) {
+	http := &t.config.HTTP
+	if http.Middlewares == nil {
+		http.Middlewares = map[string]traefik.Middleware{}
+	}
+	if http.Routers == nil {
+		http.Routers = map[string]traefik.Router{}
+	}
+	if http.Services == nil {
+		http.Services = map[string]traefik.Service{}
+	}
+
+	scheme := route.ForwardScheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	http.Services[name] = traefik.Service{LoadBalancer: traefik.LoadBalancer{Servers: []traefik.Server{{
+		URL: fmt.Sprintf("%v://%v", scheme, net.JoinHostPort(route.ForwardHost, strconv.Itoa(route.ForwardPort))),
+	}}}}
+
+	router := traefik.Router{
+		EntryPoints: t.options.EntryPoints,
+		Rule:        traefikRule(route.Domains),
+		Service:     name,
+	}
+
+	var npmOptions npm.NpmProxyHostOptions
+	if route.Container != nil {
+		npmOptions = route.Container.npmOptions()
+	}
+	switch npmOptions.CertificateName {
+	case "":
+		http.Routers[name] = router
+		return
+	case npm.CERTIFICATE_NAME_AUTO:
+		router.TLS = &traefik.TLS{CertResolver: t.options.CertResolver}
+	default:
+		// Traefik picks the certificate of its store that matches the domain
+		router.TLS = &traefik.TLS{}
+	}
+
+	if route.HstsEnabled {
+		http.Middlewares[name+traefikHstsSuffix] = traefik.Middleware{Headers: &traefik.Headers{
+			StsIncludeSubdomains: npmOptions.HstsSubdomains,
+			StsSeconds:           traefikHstsSeconds,
+		}}
+		router.Middlewares = []string{name + traefikHstsSuffix}
+	}
+	http.Routers[name] = router
+
+	httpRouter := traefik.Router{
+		EntryPoints: t.options.EntryPoints,
+		Rule:        router.Rule,
+		Service:     name,
+	}
+	if route.SslForced {
+		http.Middlewares[name+traefikRedirectSuffix] = traefik.Middleware{RedirectScheme: &traefik.RedirectScheme{
+			Permanent: true,
+			Scheme:    "https",
+		}}
+		httpRouter.Middlewares = []string{name + traefikRedirectSuffix}
+	}
+	http.Routers[name+traefikHTTPSuffix] = httpRouter
+}
+
+// route maps the entries named name back onto a route.
+func (t *Traefik) route(name string) (Route, error) {
+	entries := t.entries(name)
+	router, exists := entries.routers[name]
+	if !exists {
+		return Route{}, fmt.Errorf("router is missing")
+	}
+	if len(entries.service.LoadBalancer.Servers) == 0 {
+		return Route{}, fmt.Errorf("service has no servers")
+	}
+
+	serverURL, err := url.Parse(entries.service.LoadBalancer.Servers[0].URL)
+	if err != nil {
+		return Route{}, err
+	}
+	port, err := strconv.Atoi(serverURL.Port())
+	if err != nil {
+		return Route{}, fmt.Errorf("invalid server port '%v'", serverURL.Port())
+	}
+
+	route := Route{
+		ForwardScheme: serverURL.Scheme,
+		ForwardHost:   serverURL.Hostname(),
+		ForwardPort:   port,
+		// Traefik always proxies websockets
+		Websockets: true,
+	}
+	for _, match := range traefikHostRule.FindAllStringSubmatch(router.Rule, -1) {
+		route.Domains = append(route.Domains, match[1])
+	}
+	_, route.HstsEnabled = entries.middlewares[name+traefikHstsSuffix]
+	_, route.SslForced = entries.middlewares[name+traefikRedirectSuffix]
+	return route, nil
+}
+
+func traefikRule(domains []string) string {
+	hosts := []string{}
+	for _, domain := range domains {
+		hosts = append(hosts, fmt.Sprintf("Host(`%v`)", domain))
+	}
+	return strings.Join(hosts, " || ")
+}
@@ -0,0 +1,178 @@
+//go:build unit
+
+package providers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deepspace2/plugnpin/pkg/clients/docker"
+	"github.com/deepspace2/plugnpin/pkg/clients/npm"
+	"github.com/deepspace2/plugnpin/pkg/clients/traefik"
+)
+
+func newTraefik(t *testing.T, options TraefikOptions) (*Traefik, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "plugnpin.yml")
+	tr, err := NewTraefik(traefik.NewClient(path), options)
+	assert.NoError(t, err)
+	return tr, path
+}
+
+func traefikContainer(npmOptions npm.NpmProxyHostOptions) Container {
+	return Container{
+		IP:      "10.0.0.1",
+		Port:    8080,
+		URLs:    []string{"app.com", "www.app.com"},
+		Options: &docker.ClientOptions{Providers: map[string]any{npmOptionsNamespace: &npmOptions}},
+	}
+}
+
+func TestTraefikConfig(t *testing.T) {
+	t.Setenv("TRAEFIK_DISABLED", "false")
+	t.Setenv("TRAEFIK_CONFIG_FILE", "/etc/traefik/dynamic/plugnpin.yml")
+	t.Setenv("TRAEFIK_ENTRYPOINTS", "web,websecure")
+	t.Setenv("TRAEFIK_IP", "192.168.1.5")
+
+	var traefikConfig TraefikConfig
+	enabled, err := parseConfig(&traefikConfig)
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+	assert.Equal(t, TraefikConfig{ConfigFile: "/etc/traefik/dynamic/plugnpin.yml", EntryPoints: []string{"web", "websecure"}, IP: "192.168.1.5"}, traefikConfig)
+
+	t.Setenv("TRAEFIK_IP", "")
+	_, err = parseConfig(&TraefikConfig{})
+	assert.ErrorContains(t, err, "TRAEFIK_IP")
+
+	t.Setenv("TRAEFIK_DISABLED", "true")
+	enabled, err = parseConfig(&TraefikConfig{})
+	assert.NoError(t, err)
+	assert.False(t, enabled)
+}
+
+func TestTraefikName(t *testing.T) {
+	assert.Equal(t, "plugnpin-app-example-com", traefikName("app.example.com"))
+	assert.Equal(t, "plugnpin-wildcard-com", traefikName("*.wildcard.com"))
+}
+
+func TestTraefikRouteWithoutCertificate(t *testing.T) {
+	tr, _ := newTraefik(t, TraefikOptions{EntryPoints: []string{"web"}})
+
+	// HSTS and forced SSL need a certificate
+	route := NewRoute(traefikContainer(npm.NpmProxyHostOptions{ForwardScheme: "http", HstsEnabled: true, SslForced: true}))
+	assert.NoError(t, tr.EnsureRoute(context.Background(), route))
+
+	assert.Equal(t, traefik.HTTPConfig{
+		Middlewares: map[string]traefik.Middleware{},
+		Routers: map[string]traefik.Router{"plugnpin-app-com": {
+			EntryPoints: []string{"web"},
+			Rule:        "Host(`app.com`) || Host(`www.app.com`)",
+			Service:     "plugnpin-app-com",
+		}},
+		Services: map[string]traefik.Service{"plugnpin-app-com": {
+			LoadBalancer: traefik.LoadBalancer{Servers: []traefik.Server{{URL: "http://10.0.0.1:8080"}}},
+		}},
+	}, tr.config.HTTP)
+}
+
+func TestTraefikRouteWithCertificate(t *testing.T) {
+	tr, _ := newTraefik(t, TraefikOptions{CertResolver: "letsencrypt"})
+
+	route := NewRoute(traefikContainer(npm.NpmProxyHostOptions{
+		CertificateName: npm.CERTIFICATE_NAME_AUTO,
+		ForwardScheme:   "https",
+		HstsEnabled:     true,
+		HstsSubdomains:  true,
+		SslForced:       true,
+	}))
+	assert.NoError(t, tr.EnsureRoute(context.Background(), route))
+
+	assert.Equal(t, traefik.HTTPConfig{
+		Middlewares: map[string]traefik.Middleware{
+			"plugnpin-app-com-hsts":     {Headers: &traefik.Headers{StsIncludeSubdomains: true, StsSeconds: 63072000}},
+			"plugnpin-app-com-redirect": {RedirectScheme: &traefik.RedirectScheme{Permanent: true, Scheme: "https"}},
+		},
+		Routers: map[string]traefik.Router{
+			"plugnpin-app-com": {
+				Middlewares: []string{"plugnpin-app-com-hsts"},
+				Rule:        "Host(`app.com`) || Host(`www.app.com`)",
+				Service:     "plugnpin-app-com",
+				TLS:         &traefik.TLS{CertResolver: "letsencrypt"},
+			},
+			"plugnpin-app-com-http": {
+				Middlewares: []string{"plugnpin-app-com-redirect"},
+				Rule:        "Host(`app.com`) || Host(`www.app.com`)",
+				Service:     "plugnpin-app-com",
+			},
+		},
+		Services: map[string]traefik.Service{"plugnpin-app-com": {
+			LoadBalancer: traefik.LoadBalancer{Servers: []traefik.Server{{URL: "https://10.0.0.1:8080"}}},
+		}},
+	}, tr.config.HTTP)
+
+	t.Run("named certificate without forced SSL", func(t *testing.T) {
+		route := NewRoute(traefikContainer(npm.NpmProxyHostOptions{CertificateName: "wildcard", ForwardScheme: "http"}))
+		assert.NoError(t, tr.EnsureRoute(context.Background(), route))
+
+		assert.Empty(t, tr.config.HTTP.Middlewares)
+		assert.Equal(t, &traefik.TLS{}, tr.config.HTTP.Routers["plugnpin-app-com"].TLS)
+		assert.Nil(t, tr.config.HTTP.Routers["plugnpin-app-com-http"].TLS)
+		assert.Empty(t, tr.config.HTTP.Routers["plugnpin-app-com-http"].Middlewares)
+	})
+}
+
+func TestTraefikEnsureListAndDeleteRoute(t *testing.T) {
+	tr, path := newTraefik(t, TraefikOptions{IP: "192.168.1.5"})
+
+	address, err := tr.Address(Container{})
+	assert.NoError(t, err)
+	assert.Equal(t, "192.168.1.5", address)
+
+	route := NewRoute(traefikContainer(npm.NpmProxyHostOptions{CertificateName: "wildcard", ForwardScheme: "http", HstsEnabled: true, SslForced: true}))
+	assert.NoError(t, tr.EnsureRoute(context.Background(), route))
+	assert.FileExists(t, path)
+
+	listedRoutes, err := tr.ListRoutes()
+	assert.NoError(t, err)
+	assert.Equal(t, []Route{{
+		Domains:       []string{"app.com", "www.app.com"},
+		ForwardScheme: "http",
+		ForwardHost:   "10.0.0.1",
+		ForwardPort:   8080,
+		HstsEnabled:   true,
+		SslForced:     true,
+		Websockets:    true,
+	}}, listedRoutes)
+
+	t.Run("routes survive a restart", func(t *testing.T) {
+		restarted, err := NewTraefik(traefik.NewClient(path), TraefikOptions{})
+		assert.NoError(t, err)
+		restartedRoutes, err := restarted.ListRoutes()
+		assert.NoError(t, err)
+		assert.Equal(t, listedRoutes, restartedRoutes)
+	})
+
+	assert.NoError(t, tr.DeleteRoute(context.Background(), route))
+	listedRoutes, err = tr.ListRoutes()
+	assert.NoError(t, err)
+	assert.Empty(t, listedRoutes)
+
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, traefik.HEADER+"http: {}\n", string(content))
+}
+
+func TestTraefikSync(t *testing.T) {
+	tr, path := newTraefik(t, TraefikOptions{})
+
+	tr.BeginSync()
+	assert.NoError(t, tr.EnsureRoute(context.Background(), NewRoute(traefikContainer(npm.NpmProxyHostOptions{}))))
+	assert.NoFileExists(t, path)
+
+	tr.EndSync()
+	assert.FileExists(t, path)
+}
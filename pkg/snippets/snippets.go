@@ -0,0 +1,255 @@
+package snippets
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/deepspace2/plugnpin/pkg/logging"
+)
+
+const (
+	DEFAULT_RELOAD_INTERVAL = 10 * time.Second
+
+	fileExtension = ".conf"
+)
+
+var (
+	log = logging.GetLogger("snippets")
+
+	validName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// Reference is a snippet referenced from a container's labels, together with
+// the parameters to render it with.
+type Reference struct {
+	Name   string
+	Params map[string]string
+}
+
+// Library holds the snippets of a directory. Every "<name>.conf" file in it is
+// a Go template, rendered with the parameters of a reference, e.g.
+// "client_max_body_size {{default "1g" .size}};".
+type Library struct {
+	dir string
+
+	mu        sync.RWMutex
+	sources   map[string]string
+	templates map[string]*template.Template
+}
+
+// Load reads and validates all snippets in dir.
+func Load(dir string) (*Library, error) {
+	library := &Library{dir: dir}
+	if _, err := library.Reload(); err != nil {
+		return nil, err
+	}
+	return library, nil
+}
+
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"default": func(defaultValue, value string) string {
+			if value == "" {
+				return defaultValue
+			}
+			return value
+		},
+		"required": func(name, value string) (string, error) {
+			if value == "" {
+				return "", fmt.Errorf("parameter '%v' is required", name)
+			}
+			return value, nil
+		},
+	}
+}
+
+func readSources(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := map[string]string{}
+	for _, entry := range entries {
+		name, isSnippet := strings.CutSuffix(entry.Name(), fileExtension)
+		if entry.IsDir() || !isSnippet {
+			continue
+		}
+		if !validName.MatchString(name) {
+			return nil, fmt.Errorf("invalid snippet name '%v', only letters, digits, '-' and '_' are allowed", name)
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		sources[name] = string(content)
+	}
+	return sources, nil
+}
+
+// Reload re-reads the snippet directory and returns the names of the snippets
+// that were added, changed or removed. If any snippet is invalid, the
+// previously loaded snippets are kept.
+func (l *Library) Reload() ([]string, error) {
+	sources, err := readSources(l.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snippets from %v: %w", l.dir, err)
+	}
+
+	templates := map[string]*template.Template{}
+	for name, source := range sources {
+		tmpl, err := template.New(name).Funcs(templateFuncs()).Option("missingkey=zero").Parse(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid snippet '%v': %w", name, err)
+		}
+		templates[name] = tmpl
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	changed := []string{}
+	for name, source := range sources {
+		if previousSource, exists := l.sources[name]; !exists || previousSource != source {
+			changed = append(changed, name)
+		}
+	}
+	for name := range l.sources {
+		if _, exists := sources[name]; !exists {
+			changed = append(changed, name)
+		}
+	}
+	slices.Sort(changed)
+
+	l.sources = sources
+	l.templates = templates
+	return changed, nil
+}
+
+// Names returns the names of all loaded snippets.
+func (l *Library) Names() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return slices.Sorted(maps.Keys(l.templates))
+}
+
+// Render renders the referenced snippets in order, separated by newlines.
+func (l *Library) Render(references []Reference) (string, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	rendered := []string{}
+	for _, reference := range references {
+		tmpl, exists := l.templates[reference.Name]
+		if !exists {
+			return "", fmt.Errorf("unknown snippet '%v'", reference.Name)
+		}
+
+		params := reference.Params
+		if params == nil {
+			params = map[string]string{}
+		}
+
+		var sb strings.Builder
+		if err := tmpl.Execute(&sb, params); err != nil {
+			return "", fmt.Errorf("failed to render snippet '%v': %w", reference.Name, err)
+		}
+		rendered = append(rendered, strings.TrimRight(sb.String(), "\n"))
+	}
+	return strings.Join(rendered, "\n"), nil
+}
+
+// Watch polls the snippet directory every interval and calls onChange with
+// the names of changed snippets, until ctx is done.
+func (l *Library) Watch(ctx context.Context, interval time.Duration, onChange func(changed []string)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			changed, err := l.Reload()
+			if err != nil {
+				log.Error("Failed to reload snippets, keeping the previous ones", "error", err)
+				continue
+			}
+			if len(changed) > 0 {
+				log.Info("Reloaded snippets", "changed", changed)
+				onChange(changed)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// ParseReferences parses a comma-separated list of snippet references, each
+// either "<name>" or "<name>(<key>=<value>,...)".
+func ParseReferences(value string) ([]Reference, error) {
+	references := []Reference{}
+	for _, item := range splitTopLevel(value) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, rest, hasParams := strings.Cut(item, "(")
+		name = strings.TrimSpace(name)
+		if !validName.MatchString(name) {
+			return nil, fmt.Errorf("invalid snippet name '%v'", name)
+		}
+
+		reference := Reference{Name: name}
+		if hasParams {
+			rawParams, closed := strings.CutSuffix(rest, ")")
+			if !closed || strings.ContainsAny(rawParams, "()") {
+				return nil, fmt.Errorf("malformed parameters of snippet '%v'", name)
+			}
+
+			reference.Params = map[string]string{}
+			for rawParam := range strings.SplitSeq(rawParams, ",") {
+				if strings.TrimSpace(rawParam) == "" {
+					continue
+				}
+				key, paramValue, found := strings.Cut(rawParam, "=")
+				key = strings.TrimSpace(key)
+				if !found || key == "" {
+					return nil, fmt.Errorf("parameter '%v' of snippet '%v' must be of the form <key>=<value>", strings.TrimSpace(rawParam), name)
+				}
+				reference.Params[key] = strings.TrimSpace(paramValue)
+			}
+		}
+		references = append(references, reference)
+	}
+	return references, nil
+}
+
+// splitTopLevel splits value on commas that are not inside parentheses.
+func splitTopLevel(value string) []string {
+	parts := []string{}
+	depth, start := 0, 0
+	for i, r := range value {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, value[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, value[start:])
+}
@@ -0,0 +1,132 @@
+//go:build unit
+
+package snippets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSnippet(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name+fileExtension), []byte(content), 0o644))
+}
+
+func TestParseReferences(t *testing.T) {
+	testCases := []struct {
+		name        string
+		value       string
+		expected    []Reference
+		expectedErr string
+	}{
+		{
+			name:     "empty",
+			value:    "",
+			expected: []Reference{},
+		},
+		{
+			name:  "names and parameters",
+			value: "authelia, uploads(size=2g), headers(frame=DENY, hsts = 1)",
+			expected: []Reference{
+				{Name: "authelia"},
+				{Name: "uploads", Params: map[string]string{"size": "2g"}},
+				{Name: "headers", Params: map[string]string{"frame": "DENY", "hsts": "1"}},
+			},
+		},
+		{
+			name:        "invalid name",
+			value:       "auth elia",
+			expectedErr: "invalid snippet name 'auth elia'",
+		},
+		{
+			name:        "unclosed parameters",
+			value:       "uploads(size=2g",
+			expectedErr: "malformed parameters of snippet 'uploads'",
+		},
+		{
+			name:        "parameter without value",
+			value:       "uploads(size)",
+			expectedErr: "parameter 'size' of snippet 'uploads' must be of the form <key>=<value>",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			references, err := ParseReferences(tc.value)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, references)
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	dir := t.TempDir()
+	writeSnippet(t, dir, "gzip", "gzip on;\n")
+	writeSnippet(t, dir, "uploads", `client_max_body_size {{default "1g" .size}};`)
+	writeSnippet(t, dir, "authelia", `set $upstream_authelia {{required "url" .url}};`)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a snippet"), 0o644))
+
+	library, err := Load(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"authelia", "gzip", "uploads"}, library.Names())
+
+	rendered, err := library.Render([]Reference{
+		{Name: "gzip"},
+		{Name: "uploads", Params: map[string]string{"size": "2g"}},
+		{Name: "uploads"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "gzip on;\nclient_max_body_size 2g;\nclient_max_body_size 1g;", rendered)
+
+	_, err = library.Render([]Reference{{Name: "authelia"}})
+	assert.ErrorContains(t, err, "parameter 'url' is required")
+
+	_, err = library.Render([]Reference{{Name: "unknown"}})
+	assert.EqualError(t, err, "unknown snippet 'unknown'")
+}
+
+func TestLoadInvalidSnippet(t *testing.T) {
+	dir := t.TempDir()
+	writeSnippet(t, dir, "broken", "{{.size")
+
+	_, err := Load(dir)
+	assert.ErrorContains(t, err, "invalid snippet 'broken'")
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	writeSnippet(t, dir, "gzip", "gzip on;")
+	writeSnippet(t, dir, "uploads", "client_max_body_size 1g;")
+	writeSnippet(t, dir, "old", "")
+
+	library, err := Load(dir)
+	require.NoError(t, err)
+
+	changed, err := library.Reload()
+	assert.NoError(t, err)
+	assert.Empty(t, changed)
+
+	writeSnippet(t, dir, "uploads", "client_max_body_size 2g;")
+	writeSnippet(t, dir, "new", "")
+	require.NoError(t, os.Remove(filepath.Join(dir, "old"+fileExtension)))
+
+	changed, err = library.Reload()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"new", "old", "uploads"}, changed)
+
+	// Invalid snippets keep the previous ones in place
+	writeSnippet(t, dir, "uploads", "{{")
+	_, err = library.Reload()
+	assert.Error(t, err)
+	rendered, err := library.Render([]Reference{{Name: "uploads"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "client_max_body_size 2g;", rendered)
+}